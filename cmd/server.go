@@ -54,7 +54,11 @@ func Start(cfg *model.StartConfig) {
 	}
 	watchExit()
 
-	fmt.Printf("Server start success on http://%s\n", listener.Addr().String())
+	scheme := "http"
+	if cfg.TLS.Enabled() {
+		scheme = "https"
+	}
+	fmt.Printf("Server start success on %s://%s\n", scheme, listener.Addr().String())
 	if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
 		panic(err)
 	}
@@ -20,6 +20,15 @@ type args struct {
 	ApiToken          *string  `json:"apiToken"`
 	StorageDir        *string  `json:"storageDir"`
 	WhiteDownloadDirs []string `json:"whiteDownloadDirs"`
+	TLSCert           *string  `json:"tlsCert"`
+	TLSKey            *string  `json:"tlsKey"`
+	// TLSAutoCert generates a self-signed certificate in the storage directory when no cert and key are provided
+	TLSAutoCert *bool   `json:"tlsAutoCert"`
+	TLSClientCA *string `json:"tlsClientCa"`
+	// SessionLifetime and LoginLockout are durations like 72h or 30m
+	SessionLifetime  *string `json:"sessionLifetime"`
+	LoginMaxAttempts *int    `json:"loginMaxAttempts"`
+	LoginLockout     *string `json:"loginLockout"`
 	// DownloadConfig when the first time to start the server, it will be configured as initial value
 	DownloadConfig *base.DownloaderStoreConfig `json:"downloadConfig"`
 
@@ -45,10 +54,17 @@ func loadCliArgs() *args {
 	cfg.Address = flag.String("A", "0.0.0.0", "Bind Address")
 	cfg.Port = flag.Int("P", 9999, "Bind Port")
 	cfg.Username = flag.String("u", "gopeed", "Web Authentication Username")
-	cfg.Password = flag.String("p", "", "Web Authentication Password, if no password is set, web authentication will not be enabled")
+	cfg.Password = flag.String("p", "", "Web Authentication Password or its bcrypt hash, if no password is set, web authentication will not be enabled")
 	cfg.ApiToken = flag.String("T", "", "API token, it must be configured when using HTTP API in the case of enabling web authentication")
 	cfg.StorageDir = flag.String("d", "", "Storage directory")
 	whiteDownloadDirs := flag.String("w", "", "White download directories, comma-separated")
+	cfg.TLSCert = flag.String("tls-cert", "", "TLS certificate file path, HTTPS is enabled when both certificate and key are set")
+	cfg.TLSKey = flag.String("tls-key", "", "TLS private key file path")
+	cfg.TLSAutoCert = flag.Bool("tls-auto-cert", false, "Enable HTTPS with a self-signed certificate generated in the storage directory on first boot")
+	cfg.TLSClientCA = flag.String("tls-client-ca", "", "TLS client CA file path, clients must present a certificate signed by it when set")
+	cfg.SessionLifetime = flag.String("session-lifetime", "", "Web login session lifetime, e.g. 72h, default is 168h")
+	cfg.LoginMaxAttempts = flag.Int("login-max-attempts", 0, "Max failed web logins per IP and per user before the login is locked, default is 5, negative disables the limit")
+	cfg.LoginLockout = flag.String("login-lockout", "", "How long failed web logins are counted and the login is locked, e.g. 30m, default is 15m")
 	cfg.configPath = flag.String("c", "./config.json", "Config file path")
 	flag.Parse()
 
@@ -82,6 +98,20 @@ func overrideWithCliArgs(cfg *args, cliConfig *args) {
 			cfg.StorageDir = cliConfig.StorageDir
 		case "w":
 			cfg.WhiteDownloadDirs = cliConfig.WhiteDownloadDirs
+		case "tls-cert":
+			cfg.TLSCert = cliConfig.TLSCert
+		case "tls-key":
+			cfg.TLSKey = cliConfig.TLSKey
+		case "tls-auto-cert":
+			cfg.TLSAutoCert = cliConfig.TLSAutoCert
+		case "tls-client-ca":
+			cfg.TLSClientCA = cliConfig.TLSClientCA
+		case "session-lifetime":
+			cfg.SessionLifetime = cliConfig.SessionLifetime
+		case "login-max-attempts":
+			cfg.LoginMaxAttempts = cliConfig.LoginMaxAttempts
+		case "login-lockout":
+			cfg.LoginLockout = cliConfig.LoginLockout
 		case "c":
 			cfg.configPath = cliConfig.configPath
 		}
@@ -108,6 +138,27 @@ func setDefaults(cfg *args, cliConfig *args) {
 	if cfg.StorageDir == nil {
 		cfg.StorageDir = cliConfig.StorageDir
 	}
+	if cfg.TLSCert == nil {
+		cfg.TLSCert = cliConfig.TLSCert
+	}
+	if cfg.TLSKey == nil {
+		cfg.TLSKey = cliConfig.TLSKey
+	}
+	if cfg.TLSAutoCert == nil {
+		cfg.TLSAutoCert = cliConfig.TLSAutoCert
+	}
+	if cfg.TLSClientCA == nil {
+		cfg.TLSClientCA = cliConfig.TLSClientCA
+	}
+	if cfg.SessionLifetime == nil {
+		cfg.SessionLifetime = cliConfig.SessionLifetime
+	}
+	if cfg.LoginMaxAttempts == nil {
+		cfg.LoginMaxAttempts = cliConfig.LoginMaxAttempts
+	}
+	if cfg.LoginLockout == nil {
+		cfg.LoginLockout = cliConfig.LoginLockout
+	}
 }
 
 // loadConfigFile loads configuration from file
@@ -176,6 +227,10 @@ func loadEnvVars(cfg *args) {
 				if intVal, err := strconv.Atoi(envValue); err == nil {
 					field.Elem().SetInt(int64(intVal))
 				}
+			case reflect.Bool:
+				if boolVal, err := strconv.ParseBool(envValue); err == nil {
+					field.Elem().SetBool(boolVal)
+				}
 			default:
 				// For complex types like DownloadConfig, try JSON unmarshaling
 				if field.Type().Elem() == reflect.TypeOf(base.DownloaderStoreConfig{}) {
@@ -779,6 +779,10 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -964,6 +968,119 @@ func TestWhiteDownloadDirs(t *testing.T) {
 	})
 }
 
+func TestTLSArgs(t *testing.T) {
+	t.Run("loadConfigFile should handle TLS options", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.json")
+		configData := `{
+			"tlsCert": "/etc/gopeed/cert.pem",
+			"tlsKey": "/etc/gopeed/key.pem",
+			"tlsAutoCert": true,
+			"tlsClientCa": "/etc/gopeed/ca.pem"
+		}`
+		if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &args{}
+		loadConfigFile(cfg, configPath)
+		expected := &args{
+			TLSCert:     stringPtr("/etc/gopeed/cert.pem"),
+			TLSKey:      stringPtr("/etc/gopeed/key.pem"),
+			TLSAutoCert: boolPtr(true),
+			TLSClientCA: stringPtr("/etc/gopeed/ca.pem"),
+		}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("loadConfigFile() got = %+v, want %+v", cfg, expected)
+		}
+	})
+
+	t.Run("loadEnvVars should handle TLS options", func(t *testing.T) {
+		t.Setenv("GOPEED_TLSCERT", "/env/cert.pem")
+		t.Setenv("GOPEED_TLSKEY", "/env/key.pem")
+		t.Setenv("GOPEED_TLSAUTOCERT", "true")
+		t.Setenv("GOPEED_TLSCLIENTCA", "/env/ca.pem")
+
+		cfg := &args{}
+		loadEnvVars(cfg)
+		expected := &args{
+			TLSCert:     stringPtr("/env/cert.pem"),
+			TLSKey:      stringPtr("/env/key.pem"),
+			TLSAutoCert: boolPtr(true),
+			TLSClientCA: stringPtr("/env/ca.pem"),
+		}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("loadEnvVars() got = %+v, want %+v", cfg, expected)
+		}
+	})
+
+	t.Run("loadEnvVars should ignore invalid bool", func(t *testing.T) {
+		t.Setenv("GOPEED_TLSAUTOCERT", "maybe")
+
+		cfg := &args{}
+		loadEnvVars(cfg)
+		if cfg.TLSAutoCert == nil || *cfg.TLSAutoCert {
+			t.Errorf("loadEnvVars() got = %v, want false", cfg.TLSAutoCert)
+		}
+	})
+
+	t.Run("setDefaults should fill TLS options", func(t *testing.T) {
+		cfg := &args{TLSCert: stringPtr("/config/cert.pem")}
+		setDefaults(cfg, &args{
+			TLSCert:     stringPtr(""),
+			TLSKey:      stringPtr(""),
+			TLSAutoCert: boolPtr(false),
+			TLSClientCA: stringPtr(""),
+		})
+		if *cfg.TLSCert != "/config/cert.pem" || *cfg.TLSKey != "" || *cfg.TLSAutoCert || *cfg.TLSClientCA != "" {
+			t.Errorf("setDefaults() got = %+v", cfg)
+		}
+	})
+}
+
+func TestWebSessionArgs(t *testing.T) {
+	t.Run("loadConfigFile should handle session options", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "config.json")
+		configData := `{
+			"password": "$2y$10$HgpQ8Ab0pTkbDR2uiUJIQeW3smKJT.qkksTPePcLThDm/PK38aa9O",
+			"sessionLifetime": "72h",
+			"loginMaxAttempts": 10,
+			"loginLockout": "30m"
+		}`
+		if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := &args{}
+		loadConfigFile(cfg, configPath)
+		expected := &args{
+			Password:         stringPtr("$2y$10$HgpQ8Ab0pTkbDR2uiUJIQeW3smKJT.qkksTPePcLThDm/PK38aa9O"),
+			SessionLifetime:  stringPtr("72h"),
+			LoginMaxAttempts: intPtr(10),
+			LoginLockout:     stringPtr("30m"),
+		}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("loadConfigFile() got = %+v, want %+v", cfg, expected)
+		}
+	})
+
+	t.Run("loadEnvVars should handle session options", func(t *testing.T) {
+		t.Setenv("GOPEED_SESSIONLIFETIME", "24h")
+		t.Setenv("GOPEED_LOGINMAXATTEMPTS", "-1")
+		t.Setenv("GOPEED_LOGINLOCKOUT", "1h")
+
+		cfg := &args{}
+		loadEnvVars(cfg)
+		expected := &args{
+			SessionLifetime:  stringPtr("24h"),
+			LoginMaxAttempts: intPtr(-1),
+			LoginLockout:     stringPtr("1h"),
+		}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("loadEnvVars() got = %+v, want %+v", cfg, expected)
+		}
+	})
+}
+
 func TestParse(t *testing.T) {
 	// Note: Testing parse() function is challenging because it depends on global flag state
 	// and calls flag.Parse(). These tests document the expected behavior but may not
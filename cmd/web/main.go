@@ -5,10 +5,12 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/GopeedLab/gopeed/cmd"
 	"github.com/GopeedLab/gopeed/pkg/rest/model"
@@ -27,8 +29,11 @@ func main() {
 	var webAuth *model.WebAuth
 	if isNotBlank(args.Username) && isNotBlank(args.Password) {
 		webAuth = &model.WebAuth{
-			Username: *args.Username,
-			Password: *args.Password,
+			Username:         *args.Username,
+			Password:         *args.Password,
+			SessionLifetime:  parseDuration("session lifetime", args.SessionLifetime),
+			MaxLoginAttempts: intValueOf(args.LoginMaxAttempts),
+			LoginLockout:     parseDuration("login lockout", args.LoginLockout),
 		}
 	}
 
@@ -43,6 +48,23 @@ func main() {
 		storageDir = filepath.Join(filepath.Dir(exe), "storage")
 	}
 
+	if isNotBlank(args.TLSCert) != isNotBlank(args.TLSKey) {
+		panic(errors.New("tls-cert and tls-key must be provided together"))
+	}
+	autoCert := args.TLSAutoCert != nil && *args.TLSAutoCert
+	if isNotBlank(args.TLSClientCA) && !isNotBlank(args.TLSCert) && !autoCert {
+		panic(errors.New("tls-client-ca requires tls-cert and tls-key or tls-auto-cert"))
+	}
+	var tlsCfg *model.TLSConfig
+	if isNotBlank(args.TLSCert) || autoCert {
+		tlsCfg = &model.TLSConfig{
+			CertFile:     valueOf(args.TLSCert),
+			KeyFile:      valueOf(args.TLSKey),
+			AutoCert:     autoCert,
+			ClientCAFile: valueOf(args.TLSClientCA),
+		}
+	}
+
 	cfg := &model.StartConfig{
 		Network:           "tcp",
 		Address:           fmt.Sprintf("%s:%d", *args.Address, *args.Port),
@@ -55,6 +77,7 @@ func main() {
 		WebEnable:         true,
 		WebFS:             sub,
 		WebAuth:           webAuth,
+		TLS:               tlsCfg,
 	}
 	cmd.Start(cfg)
 }
@@ -62,3 +85,28 @@ func main() {
 func isNotBlank(str *string) bool {
 	return str != nil && *str != ""
 }
+
+func valueOf(str *string) string {
+	if str == nil {
+		return ""
+	}
+	return *str
+}
+
+func intValueOf(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func parseDuration(name string, str *string) time.Duration {
+	if !isNotBlank(str) {
+		return 0
+	}
+	d, err := time.ParseDuration(*str)
+	if err != nil {
+		panic(fmt.Errorf("invalid %s: %w", name, err))
+	}
+	return d
+}
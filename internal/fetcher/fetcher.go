@@ -1,6 +1,9 @@
 package fetcher
 
 import (
+	"context"
+	"errors"
+	"io"
 	"path"
 	"strings"
 
@@ -37,6 +40,25 @@ type Uploader interface {
 	WaitUpload() error
 }
 
+// ErrRecheckNotSupported is returned by Rechecker when the downloaded data can't be verified, e.g. there is no checksum.
+var ErrRecheckNotSupported = errors.New("recheck is not supported")
+
+// Rechecker is implemented by fetchers that can verify the downloaded data of a task that is not running.
+type Rechecker interface {
+	// Recheck verifies the downloaded data by the hashes, the damaged data is marked to be downloaded again on the next start.
+	Recheck() (*base.Recheck, error)
+}
+
+// ErrStreamNotSupported is returned by Streamer when the file can't be streamed, e.g. the size is unknown.
+var ErrStreamNotSupported = errors.New("stream is not supported")
+
+// Streamer is implemented by fetchers that can serve a file while it is downloading.
+type Streamer interface {
+	// Stream opens a reader of the file, reads block until the data is downloaded or the ctx is done,
+	// and the data at the read position is downloaded first.
+	Stream(ctx context.Context, fileIndex int) (io.ReadSeekCloser, error)
+}
+
 // FetcherMeta defines the meta information of a fetcher.
 type FetcherMeta struct {
 	Req  *base.Request  `json:"req"`
@@ -0,0 +1,249 @@
+package bt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent/iplist"
+)
+
+// blocklistMaxLevel is the max access level blocked by the DAT format, ranges with a higher level are allowed.
+const blocklistMaxLevel = 127
+
+var ErrInvalidBlocklist = errors.New("invalid blocklist, no ip range found")
+
+// Blocklist is an IP block list loaded from a local file or a http(s) URL, and reloaded periodically.
+// Each line is a range in the P2P (desc:first-last), DAT (first - last , level , desc) or CIDR format,
+// or a single IP, blank lines, comments and invalid lines are skipped.
+type Blocklist struct {
+	Source          string
+	RefreshInterval time.Duration
+	HttpClient      *http.Client
+
+	ranges atomic.Pointer[[]ipRange]
+}
+
+type ipRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+func (b *Blocklist) Lookup(ip net.IP) (r iplist.Range, ok bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return r, false
+	}
+	addr = addr.Unmap()
+	ranges := b.loaded()
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].last.Compare(addr) >= 0
+	})
+	if i == len(ranges) || ranges[i].first.Compare(addr) > 0 {
+		return r, false
+	}
+	return iplist.Range{
+		First:       ranges[i].first.AsSlice(),
+		Last:        ranges[i].last.AsSlice(),
+		Description: "blocklist",
+	}, true
+}
+
+func (b *Blocklist) NumRanges() int {
+	return len(b.loaded())
+}
+
+func (b *Blocklist) loaded() []ipRange {
+	if ranges := b.ranges.Load(); ranges != nil {
+		return *ranges
+	}
+	return nil
+}
+
+// Load reads the block list from the source, the previous list is kept if it fails.
+func (b *Blocklist) Load(ctx context.Context) error {
+	reader, err := b.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	ranges, err := parseBlocklist(reader)
+	if err != nil {
+		return err
+	}
+	b.ranges.Store(&ranges)
+	return nil
+}
+
+// Run loads the block list and reloads it by the refresh interval until the ctx is done.
+func (b *Blocklist) Run(ctx context.Context) {
+	b.Load(ctx)
+	if b.RefreshInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(b.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Load(ctx)
+		}
+	}
+}
+
+func (b *Blocklist) open(ctx context.Context) (io.ReadCloser, error) {
+	if !strings.HasPrefix(b.Source, "http://") && !strings.HasPrefix(b.Source, "https://") {
+		return os.Open(b.Source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := b.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("blocklist response status: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// parseBlocklist parses the ranges of a block list, sorted and with the overlapping ranges merged.
+func parseBlocklist(r io.Reader) ([]ipRange, error) {
+	ranges := make([]ipRange, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if rng, ok := parseBlocklistLine(scanner.Text()); ok {
+			ranges = append(ranges, rng)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, ErrInvalidBlocklist
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first.Less(ranges[j].first)
+	})
+	merged := ranges[:1]
+	for _, rng := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		// ranges of different families are never merged, since an IPv4 address is less than any IPv6 address
+		if rng.first.BitLen() == last.last.BitLen() && (rng.first.Compare(last.last) <= 0 || rng.first == last.last.Next()) {
+			if rng.last.Compare(last.last) > 0 {
+				last.last = rng.last
+			}
+			continue
+		}
+		merged = append(merged, rng)
+	}
+	return merged, nil
+}
+
+func parseBlocklistLine(line string) (rng ipRange, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+		return
+	}
+
+	// DAT: 001.002.003.004 - 001.002.003.255 , 000 , description
+	if fields := strings.Split(line, ","); len(fields) > 1 {
+		if rng, ok = parseIpRange(fields[0]); ok {
+			level, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+			if err != nil || level > blocklistMaxLevel {
+				return rng, false
+			}
+			return rng, true
+		}
+	}
+
+	switch {
+	case strings.Contains(line, "/"):
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			return
+		}
+		prefix = prefix.Masked()
+		return ipRange{first: prefix.Addr().Unmap(), last: prefixLast(prefix).Unmap()}, true
+	case strings.Contains(line, "-"):
+		// P2P: description:1.2.3.4-1.2.3.255, the description may contain colons
+		if colon := strings.LastIndex(line, ":"); colon >= 0 && strings.Count(line[colon+1:], ".") > 0 {
+			line = line[colon+1:]
+		}
+		return parseIpRange(line)
+	default:
+		addr, err := parseBlocklistAddr(line)
+		if err != nil {
+			return
+		}
+		return ipRange{first: addr, last: addr}, true
+	}
+}
+
+func parseIpRange(s string) (rng ipRange, ok bool) {
+	first, last, found := strings.Cut(s, "-")
+	if !found {
+		return
+	}
+	var err error
+	if rng.first, err = parseBlocklistAddr(first); err != nil {
+		return
+	}
+	if rng.last, err = parseBlocklistAddr(last); err != nil {
+		return
+	}
+	if rng.first.BitLen() != rng.last.BitLen() || rng.first.Compare(rng.last) > 0 {
+		return
+	}
+	return rng, true
+}
+
+// parseBlocklistAddr parses an IP, the leading zeros of the IPv4 parts used by the DAT format are allowed.
+func parseBlocklistAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if parts := strings.Split(s, "."); len(parts) == 4 {
+		for i, part := range parts {
+			if trimmed := strings.TrimLeft(part, "0"); trimmed != "" {
+				parts[i] = trimmed
+			} else {
+				parts[i] = "0"
+			}
+		}
+		s = strings.Join(parts, ".")
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return addr, err
+	}
+	return addr.Unmap(), nil
+}
+
+func prefixLast(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
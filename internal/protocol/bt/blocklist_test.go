@@ -0,0 +1,138 @@
+package bt
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/mse"
+)
+
+const testBlocklist = `# comment
+Some Org: Inc:1.2.3.0-1.2.3.255
+001.002.004.000 - 001.002.004.010 , 000 , dat range
+005.006.007.000 - 005.006.007.255 , 200 , allowed by level
+10.0.0.0/8
+10.1.0.0/16
+2001:db8::/32
+9.9.9.9
+not an ip
+`
+
+func TestParseBlocklist(t *testing.T) {
+	ranges, err := parseBlocklist(strings.NewReader(testBlocklist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.2.3.0/24 and 1.2.4.0-10 are adjacent and 10.1.0.0/16 is inside 10.0.0.0/8
+	if len(ranges) != 4 {
+		t.Errorf("parseBlocklist() got %d ranges, want 4: %v", len(ranges), ranges)
+	}
+
+	b := &Blocklist{}
+	b.ranges.Store(&ranges)
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"1.2.3.0", true},
+		{"1.2.4.10", true},
+		{"1.2.4.11", false},
+		{"5.6.7.8", false},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"9.9.9.9", true},
+		{"9.9.9.8", false},
+		{"::ffff:10.1.2.3", true},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+	}
+	for _, tt := range tests {
+		if _, got := b.Lookup(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Lookup(%s) got = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := parseBlocklist(strings.NewReader("# empty\n")); err != ErrInvalidBlocklist {
+		t.Errorf("parseBlocklist() empty got = %v, want %v", err, ErrInvalidBlocklist)
+	}
+}
+
+func TestBlocklist_Load(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(file, []byte("1.1.1.0/24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := &Blocklist{Source: file}
+	if err := b.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Lookup(net.ParseIP("1.1.1.1")); !ok || b.NumRanges() != 1 {
+		t.Errorf("Load() file got %d ranges", b.NumRanges())
+	}
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("Test:2.2.2.0-2.2.2.255\n3.3.3.3\n"))
+	}))
+	defer server.Close()
+	b = &Blocklist{Source: server.URL}
+	if err := b.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Lookup(net.ParseIP("2.2.2.2")); !ok || b.NumRanges() != 2 {
+		t.Errorf("Load() url got %d ranges", b.NumRanges())
+	}
+	// the previous list is kept when reloading fails
+	status = http.StatusNotFound
+	if err := b.Load(context.Background()); err == nil {
+		t.Error("Load() should fail by the response status")
+	}
+	if b.NumRanges() != 2 {
+		t.Errorf("Load() failed should keep the list, got %d ranges", b.NumRanges())
+	}
+
+	// the client looks up the banned peers and the block list together
+	list := &ipBanList{ips: make(map[netip.Addr]bool)}
+	list.add(netip.MustParseAddr("4.4.4.4"))
+	list.setBlocklist(b)
+	for _, ip := range []string{"4.4.4.4", "3.3.3.3"} {
+		if _, ok := list.Lookup(net.ParseIP(ip)); !ok {
+			t.Errorf("ipBanList.Lookup(%s) should be blocked", ip)
+		}
+	}
+	if _, ok := list.Lookup(net.ParseIP("5.5.5.5")); ok || list.NumRanges() != 3 {
+		t.Errorf("ipBanList got %d ranges", list.NumRanges())
+	}
+}
+
+func TestApplyEncryption(t *testing.T) {
+	tests := []struct {
+		encryption string
+		policy     torrent.HeaderObfuscationPolicy
+		provides   mse.CryptoMethod
+		selected   mse.CryptoMethod
+	}{
+		{EncryptionPrefer, torrent.HeaderObfuscationPolicy{Preferred: true}, mse.AllSupportedCrypto, mse.CryptoMethodPlaintext},
+		{EncryptionRequire, torrent.HeaderObfuscationPolicy{Preferred: true, RequirePreferred: true}, mse.CryptoMethodRC4, mse.CryptoMethodRC4},
+		{EncryptionDisable, torrent.HeaderObfuscationPolicy{RequirePreferred: true}, mse.CryptoMethodPlaintext, mse.CryptoMethodPlaintext},
+	}
+	for _, tt := range tests {
+		cfg := torrent.NewDefaultClientConfig()
+		applyEncryption(cfg, tt.encryption)
+		if cfg.HeaderObfuscationPolicy != tt.policy || cfg.CryptoProvides != tt.provides {
+			t.Errorf("applyEncryption(%s) got = %+v, %d", tt.encryption, cfg.HeaderObfuscationPolicy, cfg.CryptoProvides)
+		}
+		if got := cfg.CryptoSelector(mse.AllSupportedCrypto); got != tt.selected {
+			t.Errorf("applyEncryption(%s) selected = %d, want %d", tt.encryption, got, tt.selected)
+		}
+	}
+}
@@ -1,5 +1,14 @@
 package bt
 
+const (
+	// EncryptionPrefer prefers encrypted connections but accepts plaintext peers, the default
+	EncryptionPrefer = "prefer"
+	// EncryptionRequire only accepts RC4 encrypted connections
+	EncryptionRequire = "require"
+	// EncryptionDisable only accepts plaintext connections
+	EncryptionDisable = "disable"
+)
+
 type config struct {
 	ListenPort int      `json:"listenPort"`
 	Trackers   []string `json:"trackers"`
@@ -9,4 +18,20 @@ type config struct {
 	SeedRatio float64 `json:"seedRatio"`
 	// SeedTime is the time in seconds to seed after downloading is complete.
 	SeedTime int64 `json:"seedTime"`
+	// MaxSeeding is the max number of torrents seeding at the same time, the others wait in a queue, 0 means no limit.
+	MaxSeeding int `json:"maxSeeding"`
+	// SeedRotateInterval is the time in seconds a torrent seeds before giving its slot to a queued torrent, 0 means no rotation.
+	SeedRotateInterval int64 `json:"seedRotateInterval"`
+	// MetadataTimeout is the time in seconds to wait for the metadata of a magnet from the peers, 0 means no timeout.
+	MetadataTimeout int64 `json:"metadataTimeout"`
+	// Blocklist is a local path or a http(s) URL of an IP block list in the P2P, DAT or CIDR format.
+	Blocklist string `json:"blocklist"`
+	// BlocklistInterval is the time in seconds to reload the block list.
+	BlocklistInterval int64 `json:"blocklistInterval"`
+	// Encryption is the protocol encryption policy, one of prefer, require and disable.
+	Encryption string `json:"encryption"`
+	// DisableDHT disables the DHT, so peers are only found by trackers, PEX and manually added peers.
+	DisableDHT bool `json:"disableDHT"`
+	// DisablePEX disables the peer exchange with connected peers.
+	DisablePEX bool `json:"disablePEX"`
 }
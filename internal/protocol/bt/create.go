@@ -0,0 +1,163 @@
+package bt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const minPieceLength = 16 * 1024
+
+var (
+	errInvalidPieceLength = errors.New("piece length must be a power of 2 of at least 16KiB")
+	errEmptyContent       = errors.New("no content to create the torrent from")
+)
+
+// CreateTorrent creates a torrent from a local file or folder, the pieces are hashed from the content.
+// Hashing a large folder takes a while, it stops with the context error once ctx is done.
+func CreateTorrent(ctx context.Context, opts *bt.CreateTorrentOpts) (*bt.CreateTorrentResult, error) {
+	if opts.PieceLength != 0 && (opts.PieceLength < minPieceLength || opts.PieceLength&(opts.PieceLength-1) != 0) {
+		return nil, errInvalidPieceLength
+	}
+	if _, err := os.Stat(opts.Path); err != nil {
+		return nil, err
+	}
+
+	info := metainfo.Info{PieceLength: opts.PieceLength}
+	if opts.Private {
+		private := true
+		info.Private = &private
+	}
+	if err := buildInfo(ctx, &info, opts.Path); err != nil {
+		return nil, err
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	mi := &metainfo.MetaInfo{
+		InfoBytes:    infoBytes,
+		CreatedBy:    "Gopeed " + base.Version,
+		CreationDate: time.Now().Unix(),
+		Comment:      opts.Comment,
+		UrlList:      opts.WebSeeds,
+	}
+	if len(opts.Trackers) > 0 {
+		mi.Announce = opts.Trackers[0]
+		// one tracker per tier, like the trackers added to the downloading torrents
+		for _, tracker := range opts.Trackers {
+			mi.AnnounceList = append(mi.AnnounceList, []string{tracker})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := mi.Write(&buf); err != nil {
+		return nil, err
+	}
+	infoHash := mi.HashInfoBytes()
+	return &bt.CreateTorrentResult{
+		Name:     info.BestName(),
+		InfoHash: infoHash.HexString(),
+		Magnet:   mi.Magnet(&infoHash, &info).String(),
+		Torrent:  buf.Bytes(),
+	}, nil
+}
+
+// buildInfo is metainfo.Info.BuildFromFilePath with the file reads bound to ctx.
+func buildInfo(ctx context.Context, info *metainfo.Info, root string) error {
+	info.Name = filepath.Base(root)
+	if info.Name == "." || info.Name == ".." || info.Name == string(filepath.Separator) {
+		info.Name = metainfo.NoName
+	}
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			// directories are implicit in torrent files
+			return nil
+		}
+		if path == root {
+			info.Length = fi.Size()
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info.Files = append(info.Files, metainfo.FileInfo{
+			Path:   strings.Split(relPath, string(filepath.Separator)),
+			Length: fi.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if info.TotalLength() == 0 {
+		return errEmptyContent
+	}
+	sort.Slice(info.Files, func(i, j int) bool {
+		return strings.Join(info.Files[i].BestPath(), "/") < strings.Join(info.Files[j].BestPath(), "/")
+	})
+	if info.PieceLength == 0 {
+		info.PieceLength = metainfo.ChoosePieceLength(info.TotalLength())
+	}
+	err = info.GeneratePieces(func(fi metainfo.FileInfo) (io.ReadCloser, error) {
+		f, err := os.Open(filepath.Join(root, filepath.Join(fi.BestPath()...)))
+		if err != nil {
+			return nil, err
+		}
+		return &ctxReader{ctx: ctx, ReadCloser: f}, nil
+	})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// ctxReader fails the reads once ctx is done, so the piece hashing stops early.
+type ctxReader struct {
+	ctx context.Context
+	io.ReadCloser
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// ExportTorrent exports the metadata of the torrent as a .torrent file, so a resolved magnet can be archived.
+func (f *Fetcher) ExportTorrent() (*bt.TorrentMetadata, error) {
+	if !f.torrentReady.Load() {
+		return nil, ErrTorrentNotReady
+	}
+	mi := f.torrent.Metainfo()
+	mi.CreatedBy = "Gopeed " + base.Version
+	mi.CreationDate = time.Now().Unix()
+	var buf bytes.Buffer
+	if err := mi.Write(&buf); err != nil {
+		return nil, err
+	}
+	infoHash := f.torrent.InfoHash()
+	return &bt.TorrentMetadata{
+		Name:     f.torrent.Name(),
+		InfoHash: infoHash.HexString(),
+		Magnet:   mi.Magnet(&infoHash, f.torrent.Info()).String(),
+		Torrent:  buf.Bytes(),
+	}, nil
+}
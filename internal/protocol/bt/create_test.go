@@ -0,0 +1,175 @@
+package bt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestCreateTorrent(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "dataset")
+	writeTestFile(t, filepath.Join(root, "a.bin"), 100*1024)
+	writeTestFile(t, filepath.Join(root, "sub", "b.bin"), 50*1024)
+
+	result, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{
+		Path:        root,
+		PieceLength: 32 * 1024,
+		Trackers:    []string{"udp://tracker1.example.com:80/announce", "udp://tracker2.example.com:80/announce"},
+		Private:     true,
+		WebSeeds:    []string{"https://example.com/dataset/"},
+		Comment:     "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Name != "dataset" {
+		t.Errorf("CreateTorrent() name got = %s, want dataset", result.Name)
+	}
+	if !strings.HasPrefix(result.Magnet, "magnet:?xt=urn:btih:"+result.InfoHash) {
+		t.Errorf("CreateTorrent() magnet got = %s", result.Magnet)
+	}
+
+	mi, err := metainfo.Load(bytes.NewReader(result.Torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PieceLength != 32*1024 || info.NumPieces() != 5 {
+		t.Errorf("CreateTorrent() pieces got = %d x %d", info.NumPieces(), info.PieceLength)
+	}
+	if info.Private == nil || !*info.Private {
+		t.Error("CreateTorrent() should be private")
+	}
+	if len(info.Files) != 2 || info.TotalLength() != 150*1024 {
+		t.Errorf("CreateTorrent() files got = %d, length %d", len(info.Files), info.TotalLength())
+	}
+	wantTrackers := metainfo.AnnounceList{{"udp://tracker1.example.com:80/announce"}, {"udp://tracker2.example.com:80/announce"}}
+	if mi.Announce != wantTrackers[0][0] || !reflect.DeepEqual(mi.AnnounceList, wantTrackers) {
+		t.Errorf("CreateTorrent() trackers got = %s, %v", mi.Announce, mi.AnnounceList)
+	}
+	if !reflect.DeepEqual([]string(mi.UrlList), []string{"https://example.com/dataset/"}) || mi.Comment != "test" {
+		t.Errorf("CreateTorrent() web seeds got = %v, comment %s", mi.UrlList, mi.Comment)
+	}
+	if mi.HashInfoBytes().HexString() != result.InfoHash {
+		t.Errorf("CreateTorrent() info hash got = %s, want %s", result.InfoHash, mi.HashInfoBytes().HexString())
+	}
+}
+
+func TestCreateTorrent_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: dir, PieceLength: 1000 * 1024}); err != errInvalidPieceLength {
+		t.Errorf("CreateTorrent() piece length got = %v, want %v", err, errInvalidPieceLength)
+	}
+	if _, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: dir, PieceLength: 8 * 1024}); err != errInvalidPieceLength {
+		t.Errorf("CreateTorrent() small piece length got = %v, want %v", err, errInvalidPieceLength)
+	}
+	if _, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: dir}); err != errEmptyContent {
+		t.Errorf("CreateTorrent() empty folder got = %v, want %v", err, errEmptyContent)
+	}
+	if _, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: filepath.Join(dir, "not-exist")}); !os.IsNotExist(err) {
+		t.Errorf("CreateTorrent() not exist got = %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "canceled.bin"), 64*1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CreateTorrent(ctx, &bt.CreateTorrentOpts{Path: dir}); err != context.Canceled {
+		t.Errorf("CreateTorrent() canceled got = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCreateTorrent_Seed(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "single.bin")
+	writeTestFile(t, file, 200*1024)
+
+	result, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := buildFetcher().(*Fetcher)
+	err = f.Resolve(&base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+	}, &base.Options{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// the local content is verified, so the torrent is complete without downloading
+	for i := 0; i < 50 && !f.isDone(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !f.isDone() {
+		t.Errorf("seed torrent should be complete, got %d of %d", f.torrent.BytesCompleted(), f.meta.Res.Size)
+	}
+}
+
+func writeTestFile(t *testing.T, name string, size int) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(i % 251)
+	}
+	if err := os.WriteFile(name, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetcher_ExportTorrent(t *testing.T) {
+	if _, err := (&Fetcher{}).ExportTorrent(); err != ErrTorrentNotReady {
+		t.Errorf("ExportTorrent() not ready got = %v, want %v", err, ErrTorrentNotReady)
+	}
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "export.bin"), 64*1024)
+	result, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: filepath.Join(dir, "export.bin")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := buildFetcher().(*Fetcher)
+	err = f.Resolve(&base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+		Extra: &bt.ReqExtra{
+			Trackers: []string{"udp://tracker.example.com:80/announce"},
+		},
+	}, &base.Options{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	metadata, err := f.ExportTorrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Name != "export.bin" || metadata.InfoHash != result.InfoHash || !strings.HasPrefix(metadata.Magnet, "magnet:?xt=urn:btih:"+result.InfoHash) {
+		t.Errorf("ExportTorrent() got = %+v", metadata)
+	}
+	mi, err := metainfo.Load(bytes.NewReader(metadata.Torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.HashInfoBytes().HexString() != result.InfoHash {
+		t.Errorf("ExportTorrent() info hash got = %s, want %s", mi.HashInfoBytes().HexString(), result.InfoHash)
+	}
+	if trackers := mi.UpvertedAnnounceList(); len(trackers) != 1 || trackers[0][0] != "udp://tracker.example.com:80/announce" {
+		t.Errorf("ExportTorrent() trackers got = %v", trackers)
+	}
+}
@@ -3,6 +3,7 @@ package bt
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
@@ -15,14 +16,18 @@ import (
 
 	"github.com/GopeedLab/gopeed/internal/controller"
 	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/internal/httpclient"
 	"github.com/GopeedLab/gopeed/pkg/base"
 	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
 	"github.com/GopeedLab/gopeed/pkg/util"
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/mse"
 	"github.com/anacrolix/torrent/storage"
 )
 
+var ErrMetadataTimeout = errors.New("fetch torrent metadata timeout, no peer has the metadata of the magnet")
+
 var (
 	cfg       *torrent.ClientConfig
 	client    *torrent.Client
@@ -41,9 +46,15 @@ type Fetcher struct {
 
 	torrentReady    atomic.Bool
 	torrentUpload   atomic.Bool
+	seedQueued      atomic.Bool
 	torrentDropCtx  context.Context
 	torrentDropFunc func()
 	uploadDoneCh    chan any
+
+	sequentialRunning atomic.Bool
+
+	trackerLock   sync.Mutex
+	trackerStatus map[string]*bt.Tracker
 }
 
 func (f *Fetcher) Setup(ctl *controller.Controller) {
@@ -76,7 +87,27 @@ func (f *Fetcher) initClient() (err error) {
 	cfg.Bep20 = fmt.Sprintf("-GP%s-", parseBep20())
 	cfg.ExtendedHandshakeClientVersion = fmt.Sprintf("Gopeed %s", base.Version)
 	cfg.ListenPort = f.config.ListenPort
+	// every torrent has its own storage, the default one keeps the piece completion in memory instead of
+	// creating a .torrent.db in the working directory
+	cfg.DefaultStorage = storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir:   cfg.DataDir,
+		PieceCompletion: storage.NewMapPieceCompletion(),
+	})
 	cfg.HTTPProxy = f.ctl.GetProxy(f.meta.Req.Proxy)
+	// web seeds are requested like the HTTP downloads, through the same proxy
+	webClient, err := httpclient.NewClient(httpclient.Options{
+		Transport: httpclient.TransportOptions{
+			Proxy: cfg.HTTPProxy,
+		},
+	})
+	if err != nil {
+		return
+	}
+	cfg.WebTransport = webClient.Transport
+	cfg.IPBlocklist = bannedIPs
+	cfg.NoDHT = f.config.DisableDHT
+	cfg.DisablePEX = f.config.DisablePEX
+	applyEncryption(cfg, f.config.Encryption)
 	dnsResolver := &DnsCacheResolver{RefreshTimeout: 5 * time.Minute}
 	cfg.TrackerDialContext = dnsResolver.DialContext
 	client, err = torrent.NewClient(cfg)
@@ -88,20 +119,52 @@ func (f *Fetcher) initClient() (err error) {
 	go func() {
 		dnsResolver.Run(closeCtx)
 	}()
+	bannedIPs.setBlocklist(nil)
+	if f.config.Blocklist != "" {
+		blocklist := &Blocklist{
+			Source:          f.config.Blocklist,
+			RefreshInterval: time.Duration(f.config.BlocklistInterval) * time.Second,
+			HttpClient:      webClient,
+		}
+		bannedIPs.setBlocklist(blocklist)
+		go blocklist.Run(closeCtx)
+	}
 	return
 }
 
+// applyEncryption sets the header obfuscation and the stream cipher of the connections by the encryption policy,
+// prefer keeps the defaults of the library.
+func applyEncryption(cfg *torrent.ClientConfig, encryption string) {
+	switch encryption {
+	case EncryptionRequire:
+		cfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: true, RequirePreferred: true}
+		cfg.CryptoProvides = mse.CryptoMethodRC4
+		cfg.CryptoSelector = func(provided mse.CryptoMethod) mse.CryptoMethod {
+			return provided & mse.CryptoMethodRC4
+		}
+	case EncryptionDisable:
+		cfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: false, RequirePreferred: true}
+		cfg.CryptoProvides = mse.CryptoMethodPlaintext
+		cfg.CryptoSelector = func(provided mse.CryptoMethod) mse.CryptoMethod {
+			return provided & mse.CryptoMethodPlaintext
+		}
+	}
+}
+
 func (f *Fetcher) Resolve(req *base.Request, opts *base.Options) error {
 	f.meta.Req = req
 	f.meta.Opts = opts
 	if f.meta.Opts == nil {
 		f.meta.Opts = &base.Options{}
 	}
+	if err := base.ParseOptExtra[bt.OptsExtra](f.meta.Opts); err != nil {
+		return err
+	}
 	if err := f.addTorrent(req, false); err != nil {
 		return err
 	}
 	f.updateRes()
-	return nil
+	return f.excludeSkipped()
 }
 
 func (f *Fetcher) Start() (err error) {
@@ -122,19 +185,19 @@ func (f *Fetcher) Start() (err error) {
 		}
 		f.data.Progress = make(fetcher.Progress, len(f.meta.Opts.SelectFiles))
 	}
-	if len(f.meta.Opts.SelectFiles) == len(files) {
-		f.torrent.DownloadAll()
-	} else {
-		for _, selectIndex := range f.meta.Opts.SelectFiles {
-			file := files[selectIndex]
-			file.Download()
-		}
-	}
+	f.applyFiles()
 	f.torrent.AllowDataDownload()
+	if f.sequential() && f.sequentialRunning.CompareAndSwap(false, true) {
+		go f.sequentialLoop()
+	}
 	return
 }
 
 func (f *Fetcher) Pause() (err error) {
+	// a created task that is queued or not started yet has no torrent
+	if f.torrent == nil {
+		return
+	}
 	f.torrent.DisallowDataDownload()
 	return
 }
@@ -142,8 +205,16 @@ func (f *Fetcher) Pause() (err error) {
 func (f *Fetcher) Close() (err error) {
 	f.safeDrop()
 	f.torrentDropFunc()
-	f.uploadDoneCh <- nil
-	if len(client.Torrents()) == 0 {
+	seeds.leave(f)
+	// the fetcher may be closed again, e.g. deleting a task that stopped seeding
+	select {
+	case f.uploadDoneCh <- nil:
+	default:
+	}
+	lock.Lock()
+	idle := client != nil && len(client.Torrents()) == 0
+	lock.Unlock()
+	if idle {
 		err = closeClient()
 	}
 	return nil
@@ -169,14 +240,21 @@ func (f *Fetcher) Stats() any {
 	} else {
 		stats = torrent.TorrentStats{}
 	}
-	return &bt.Stats{
+	result := &bt.Stats{
 		TotalPeers:       stats.TotalPeers,
 		ActivePeers:      stats.ActivePeers,
 		ConnectedSeeders: stats.ConnectedSeeders,
 		SeedBytes:        f.data.SeedBytes,
 		SeedRatio:        f.seedRadio(),
 		SeedTime:         f.data.SeedTime,
+		SeedQueued:       f.seedQueued.Load(),
+	}
+	if f.torrentReady.Load() {
+		result.WebSeeds = len(f.torrent.WebseedPeerConns())
+		result.Files = f.statsFiles()
+		result.PieceCount, result.Pieces = f.pieceBitfield()
 	}
+	return result
 }
 
 func (f *Fetcher) Progress() fetcher.Progress {
@@ -233,12 +311,23 @@ func (f *Fetcher) isDone() bool {
 }
 
 // Patch modifies the BT task settings.
-// Invalid file indices are silently ignored.
+// Invalid file indices are silently ignored, file priorities in opts.Extra are merged into the current ones.
 func (f *Fetcher) Patch(req *base.Request, opts *base.Options) error {
 	if opts == nil {
 		return nil
 	}
 
+	if opts.Extra != nil {
+		if err := base.ParseOptExtra[bt.OptsExtra](opts); err != nil {
+			return err
+		}
+		if patch := opts.Extra.(*bt.OptsExtra); len(patch.FilePriorities) > 0 {
+			if err := f.patchFilePriorities(patch.FilePriorities); err != nil {
+				return err
+			}
+		}
+	}
+
 	if opts.SelectFiles != nil {
 		selectFiles := opts.SelectFiles
 
@@ -256,32 +345,59 @@ func (f *Fetcher) Patch(req *base.Request, opts *base.Options) error {
 			}
 		}
 
-		if f.torrent != nil {
-			files := f.torrent.Files()
-
-			// Cancel all current file downloads first
-			f.torrent.CancelPieces(0, f.torrent.NumPieces())
-
-			// Apply new file selection
-			if len(validSelectFiles) == len(files) {
-				f.torrent.DownloadAll()
-			} else {
-				for _, selectIndex := range validSelectFiles {
-					file := files[selectIndex]
-					file.Download()
-				}
-			}
-		}
-
 		f.meta.Opts.SelectFiles = validSelectFiles
+		if err := f.excludeSkipped(); err != nil {
+			return err
+		}
 		// Recalculate the resource size based on new selection
 		if f.meta.Res != nil {
-			f.meta.Res.CalcSize(validSelectFiles)
+			f.meta.Res.CalcSize(f.meta.Opts.SelectFiles)
 		}
 		// Reset progress tracking for new file selection
-		f.data.Progress = make(fetcher.Progress, len(validSelectFiles))
+		f.data.Progress = make(fetcher.Progress, len(f.meta.Opts.SelectFiles))
+
+		if f.torrent != nil {
+			// Cancel the piece priorities of the previous selection, then apply the new file selection
+			f.torrent.CancelPieces(0, f.torrent.NumPieces())
+			f.applyFiles()
+		}
+	}
+
+	return nil
+}
+
+func (f *Fetcher) patchFilePriorities(priorities map[int]bt.FilePriority) error {
+	if f.meta.Opts.Extra == nil {
+		f.meta.Opts.Extra = &bt.OptsExtra{}
+	}
+	if err := base.ParseOptExtra[bt.OptsExtra](f.meta.Opts); err != nil {
+		return err
+	}
+	extra := f.meta.Opts.Extra.(*bt.OptsExtra)
+	merged := make(map[int]bt.FilePriority, len(extra.FilePriorities)+len(priorities))
+	for index, priority := range extra.FilePriorities {
+		merged[index] = priority
+	}
+	for index, priority := range priorities {
+		switch priority {
+		case bt.FilePrioritySkip, bt.FilePriorityLow, bt.FilePriorityNormal, bt.FilePriorityHigh:
+			merged[index] = priority
+		default:
+			return fmt.Errorf("invalid file priority: %s", priority)
+		}
 	}
+	extra.FilePriorities = merged
 
+	oldSelectFiles := len(f.meta.Opts.SelectFiles)
+	if err := f.excludeSkipped(); err != nil {
+		return err
+	}
+	if len(f.meta.Opts.SelectFiles) != oldSelectFiles {
+		f.data.Progress = make(fetcher.Progress, len(f.meta.Opts.SelectFiles))
+	}
+	if f.torrent != nil && f.torrentReady.Load() {
+		f.applyFiles()
+	}
 	return nil
 }
 
@@ -317,18 +433,22 @@ func (f *Fetcher) doUpload(fromUpload bool) {
 	if !f.torrentUpload.CompareAndSwap(false, true) {
 		return
 	}
+	defer seeds.leave(f)
 
 	// Check and update seed data
 	lastData := &fetcherData{
 		SeedBytes: f.data.SeedBytes,
 		SeedTime:  f.data.SeedTime,
 	}
-	var doneTime int64 = 0
+	// seedDuration is the time seeding after downloading is complete, the time waiting in the seeding queue is not included
+	var seedDuration time.Duration
+	var lastTick time.Time
+	uploading := true
 	for {
 		select {
 		case <-f.torrentDropCtx.Done():
 			return
-		case <-time.After(time.Second):
+		case now := <-time.After(time.Second):
 			if !f.torrentReady.Load() {
 				continue
 			}
@@ -340,28 +460,40 @@ func (f *Fetcher) doUpload(fromUpload bool) {
 			if !fromUpload && !f.isDone() {
 				continue
 			}
-			if doneTime == 0 {
-				doneTime = time.Now().Unix()
+
+			active := seeds.seeding(f, f.config.MaxSeeding, time.Duration(f.config.SeedRotateInterval)*time.Second, now)
+			if active != uploading {
+				if active {
+					f.torrent.AllowDataUpload()
+				} else {
+					f.torrent.DisallowDataUpload()
+				}
+				uploading = active
 			}
-			f.data.SeedTime = lastData.SeedTime + time.Now().Unix() - doneTime
+			f.seedQueued.Store(!active)
+			if active && !lastTick.IsZero() {
+				seedDuration += now.Sub(lastTick)
+			}
+			lastTick = now
+			f.data.SeedTime = lastData.SeedTime + int64(seedDuration/time.Second)
 
+			seedKeep, seedRatio, seedTime := f.seedConfig()
 			// If the seed forever is true, keep seeding
-			if f.config.SeedKeep {
+			if seedKeep {
 				continue
 			}
 
 			// If the seed ratio is reached, stop seeding
-			if f.config.SeedRatio > 0 {
-				seedRadio := f.seedRadio()
-				if seedRadio >= f.config.SeedRatio {
+			if seedRatio > 0 {
+				if f.seedRadio() >= seedRatio {
 					f.Close()
 					break
 				}
 			}
 
 			// If the seed time is reached, stop seeding
-			if f.config.SeedTime > 0 {
-				if f.data.SeedTime >= f.config.SeedTime {
+			if seedTime > 0 {
+				if f.data.SeedTime >= seedTime {
 					f.Close()
 					break
 				}
@@ -446,6 +578,13 @@ func (f *Fetcher) addTorrent(req *base.Request, fromUpload bool) (err error) {
 			return
 		}
 	}
+	if req.Extra != nil {
+		for _, webSeed := range req.Extra.(*bt.ReqExtra).WebSeeds {
+			if schema := util.ParseSchema(webSeed); schema == "HTTP" || schema == "HTTPS" {
+				spec.Webseeds = append(spec.Webseeds, webSeed)
+			}
+		}
+	}
 	spec.Storage = storage.NewFileOpts(storage.NewFileClientOpts{
 		ClientBaseDir: cfg.DataDir,
 		TorrentDirMaker: func(baseDir string, info *metainfo.Info, infoHash metainfo.Hash) string {
@@ -482,13 +621,35 @@ func (f *Fetcher) addTorrent(req *base.Request, fromUpload bool) (err error) {
 			f.torrent.AddTrackers(announceList)
 		}
 	}
-	<-f.torrent.GotInfo()
+	if err = f.waitInfo(); err != nil {
+		f.safeDrop()
+		return
+	}
 	f.torrentReady.Store(true)
 
 	go f.doUpload(fromUpload)
 	return
 }
 
+// waitInfo waits for the metadata of the torrent, which is fetched from the peers for a magnet,
+// until the metadata timeout of the config or the fetcher is closed.
+func (f *Fetcher) waitInfo() error {
+	var timeout <-chan time.Time
+	if f.config.MetadataTimeout > 0 {
+		timer := time.NewTimer(time.Duration(f.config.MetadataTimeout) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-f.torrent.GotInfo():
+		return nil
+	case <-timeout:
+		return ErrMetadataTimeout
+	case <-f.torrentDropCtx.Done():
+		return f.torrentDropCtx.Err()
+	}
+}
+
 func (f *Fetcher) seedRadio() float64 {
 	var bytesRead int64
 	if f.Meta().Res != nil {
@@ -581,11 +742,17 @@ func (fm *FetcherManager) AutoRename() bool {
 
 func (fm *FetcherManager) DefaultConfig() any {
 	return &config{
-		ListenPort: 0,
-		Trackers:   []string{},
-		SeedKeep:   false,
-		SeedRatio:  1.0,
-		SeedTime:   120 * 60,
+		ListenPort:      0,
+		Trackers:        []string{},
+		SeedKeep:        false,
+		SeedRatio:       1.0,
+		SeedTime:        120 * 60,
+		MetadataTimeout: 120,
+		// a seeding torrent gives its slot to a queued torrent every 30 minutes
+		SeedRotateInterval: 30 * 60,
+		// the block list is reloaded daily
+		BlocklistInterval: 24 * 60 * 60,
+		Encryption:        EncryptionPrefer,
 	}
 }
 
@@ -596,6 +763,7 @@ func (fm *FetcherManager) Store(f fetcher.Fetcher) (data any, err error) {
 
 func (fm *FetcherManager) Restore() (v any, f func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher) {
 	return &fetcherData{}, func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher {
+		base.ParseOptExtra[bt.OptsExtra](meta.Opts)
 		return &Fetcher{
 			meta: meta,
 			data: v.(*fetcherData),
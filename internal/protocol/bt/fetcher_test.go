@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GopeedLab/gopeed/internal/controller"
 	"github.com/GopeedLab/gopeed/internal/fetcher"
@@ -56,6 +57,31 @@ func TestFetcher_Config(t *testing.T) {
 	doResolve(t, buildConfigFetcher(nil))
 }
 
+func TestFetcher_MetadataTimeout(t *testing.T) {
+	f := new(FetcherManager).Build().(*Fetcher)
+	ctl := controller.NewController()
+	ctl.GetConfig = func(v any) {
+		json.Unmarshal([]byte(test.ToJson(config{MetadataTimeout: 1, DisableDHT: true})), v)
+	}
+	f.Setup(ctl)
+	defer f.Close()
+
+	// nobody has the metadata of a random info hash
+	start := time.Now()
+	err := f.Resolve(&base.Request{
+		URL: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567",
+	}, &base.Options{Path: t.TempDir()})
+	if err != ErrMetadataTimeout {
+		t.Fatalf("Resolve() got = %v, want %v", err, ErrMetadataTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Resolve() should time out in about 1s, got %s", elapsed)
+	}
+	if _, ok := client.Torrent(f.torrent.InfoHash()); ok {
+		t.Error("Resolve() timeout should drop the torrent")
+	}
+}
+
 func TestFetcher_ResolveWithProxy(t *testing.T) {
 	usr, pwd := "admin", "123"
 	proxyListener := test.StartSocks5Server(usr, pwd)
@@ -0,0 +1,118 @@
+package bt
+
+import (
+	"errors"
+
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent"
+)
+
+var errAllFilesSkipped = errors.New("all files are skipped")
+
+func (f *Fetcher) filePriority(index int) bt.FilePriority {
+	if f.meta.Opts == nil {
+		return bt.FilePriorityNormal
+	}
+	extra, ok := f.meta.Opts.Extra.(*bt.OptsExtra)
+	if !ok || extra.FilePriorities == nil {
+		return bt.FilePriorityNormal
+	}
+	if p, ok := extra.FilePriorities[index]; ok && p != "" {
+		return p
+	}
+	return bt.FilePriorityNormal
+}
+
+// piecePriority maps the file priority to the piece priority, only the order matters for the piece requests,
+// so the normal priority is mapped above the lowest download priority.
+func piecePriority(p bt.FilePriority) torrent.PiecePriority {
+	switch p {
+	case bt.FilePrioritySkip:
+		return torrent.PiecePriorityNone
+	case bt.FilePriorityLow:
+		return torrent.PiecePriorityNormal
+	case bt.FilePriorityHigh:
+		return torrent.PiecePriorityReadahead
+	default:
+		return torrent.PiecePriorityHigh
+	}
+}
+
+// excludeSkipped removes the skipped files from the selected files, an empty selection means all files.
+func (f *Fetcher) excludeSkipped() error {
+	current := f.meta.Opts.SelectFiles
+	if len(current) == 0 && f.meta.Res != nil {
+		current = make([]int, len(f.meta.Res.Files))
+		for i := range current {
+			current[i] = i
+		}
+	}
+	selectFiles := make([]int, 0, len(current))
+	for _, index := range current {
+		if f.filePriority(index) != bt.FilePrioritySkip {
+			selectFiles = append(selectFiles, index)
+		}
+	}
+	if len(selectFiles) == 0 && len(current) > 0 {
+		return errAllFilesSkipped
+	}
+	if len(selectFiles) != len(current) {
+		f.meta.Opts.SelectFiles = selectFiles
+		if f.meta.Res != nil {
+			f.meta.Res.CalcSize(selectFiles)
+		}
+	}
+	return nil
+}
+
+// applyFiles sets the priority of the files, the files not selected are not downloaded.
+func (f *Fetcher) applyFiles() {
+	selected := make(map[int]bool, len(f.meta.Opts.SelectFiles))
+	for _, index := range f.meta.Opts.SelectFiles {
+		selected[index] = true
+	}
+	for i, file := range f.torrent.Files() {
+		prio := torrent.PiecePriorityNone
+		if selected[i] {
+			prio = piecePriority(f.filePriority(i))
+		}
+		file.SetPriority(prio)
+	}
+}
+
+// pieceBitfield returns the completed pieces as a bitfield, the high bit of the first byte is the first piece.
+func (f *Fetcher) pieceBitfield() (int, []byte) {
+	count := f.torrent.NumPieces()
+	bitfield := make([]byte, (count+7)/8)
+	index := 0
+	for _, run := range f.torrent.PieceStateRuns() {
+		if run.Complete {
+			for i := index; i < index+run.Length; i++ {
+				bitfield[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+		index += run.Length
+	}
+	return count, bitfield
+}
+
+func (f *Fetcher) statsFiles() []*bt.StatsFile {
+	files := f.torrent.Files()
+	stats := make([]*bt.StatsFile, len(files))
+	selected := make(map[int]bool, len(f.meta.Opts.SelectFiles))
+	for _, index := range f.meta.Opts.SelectFiles {
+		selected[index] = true
+	}
+	for i, file := range files {
+		priority := f.filePriority(i)
+		if !selected[i] {
+			priority = bt.FilePrioritySkip
+		}
+		stats[i] = &bt.StatsFile{
+			Completed: file.BytesCompleted(),
+			Size:      file.Length(),
+			Priority:  priority,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,116 @@
+package bt
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent"
+)
+
+func TestFetcher_FilePriorities(t *testing.T) {
+	f := buildFetcher().(*Fetcher)
+	err := f.Resolve(&base.Request{
+		URL: "./testdata/test.torrent",
+	}, &base.Options{
+		Path: t.TempDir(),
+		Extra: map[string]any{"filePriorities": map[string]any{
+			"1": "skip",
+			"2": "high",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if want := []int{0, 2}; !reflect.DeepEqual(f.meta.Opts.SelectFiles, want) {
+		t.Errorf("Resolve() skipped file should be deselected, got = %v, want %v", f.meta.Opts.SelectFiles, want)
+	}
+	if want := f.meta.Res.Files[0].Size + f.meta.Res.Files[2].Size; f.meta.Res.Size != want {
+		t.Errorf("Resolve() size got = %d, want %d", f.meta.Res.Size, want)
+	}
+
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	files := f.torrent.Files()
+	wants := []torrent.PiecePriority{torrent.PiecePriorityHigh, torrent.PiecePriorityNone, torrent.PiecePriorityReadahead}
+	for i, want := range wants {
+		if got := files[i].Priority(); got != want {
+			t.Errorf("Start() file %d priority got = %v, want %v", i, got, want)
+		}
+	}
+
+	// patch merges the priorities into the current ones
+	if err := f.Patch(nil, &base.Options{Extra: map[string]any{"filePriorities": map[string]any{"0": "low"}}}); err != nil {
+		t.Fatal(err)
+	}
+	extra := f.meta.Opts.Extra.(*bt.OptsExtra)
+	wantPriorities := map[int]bt.FilePriority{0: bt.FilePriorityLow, 1: bt.FilePrioritySkip, 2: bt.FilePriorityHigh}
+	if !reflect.DeepEqual(extra.FilePriorities, wantPriorities) {
+		t.Errorf("Patch() priorities got = %v, want %v", extra.FilePriorities, wantPriorities)
+	}
+	if got := files[0].Priority(); got != torrent.PiecePriorityNormal {
+		t.Errorf("Patch() file 0 priority got = %v, want %v", got, torrent.PiecePriorityNormal)
+	}
+	if err := f.Patch(nil, &base.Options{Extra: map[string]any{"filePriorities": map[string]any{"0": "urgent"}}}); err == nil {
+		t.Error("Patch() invalid priority should fail")
+	}
+	if err := f.Patch(nil, &base.Options{Extra: map[string]any{"filePriorities": map[string]any{"0": "skip", "2": "skip"}}}); err != errAllFilesSkipped {
+		t.Errorf("Patch() all files skipped got = %v, want %v", err, errAllFilesSkipped)
+	}
+}
+
+func TestFetcher_ResolveAllSkipped(t *testing.T) {
+	f := buildFetcher().(*Fetcher)
+	err := f.Resolve(&base.Request{
+		URL: "./testdata/test.torrent",
+	}, &base.Options{
+		Path:        t.TempDir(),
+		SelectFiles: []int{1},
+		Extra:       &bt.OptsExtra{FilePriorities: map[int]bt.FilePriority{1: bt.FilePrioritySkip}},
+	})
+	defer f.Close()
+	if err != errAllFilesSkipped {
+		t.Errorf("Resolve() got = %v, want %v", err, errAllFilesSkipped)
+	}
+}
+
+func TestFetcher_StatsPieces(t *testing.T) {
+	f := buildFetcher().(*Fetcher)
+	err := f.Resolve(&base.Request{
+		URL: "./testdata/test.torrent",
+	}, &base.Options{
+		Path:        t.TempDir(),
+		SelectFiles: []int{0, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// wait for the piece completion check
+	time.Sleep(500 * time.Millisecond)
+
+	stats := f.Stats().(*bt.Stats)
+	if stats.PieceCount != f.torrent.NumPieces() {
+		t.Errorf("Stats() piece count got = %d, want %d", stats.PieceCount, f.torrent.NumPieces())
+	}
+	if got, want := len(stats.Pieces), (stats.PieceCount+7)/8; got != want {
+		t.Errorf("Stats() bitfield length got = %d, want %d", got, want)
+	}
+	if len(stats.Files) != 3 {
+		t.Fatalf("Stats() files got = %d, want 3", len(stats.Files))
+	}
+	if stats.Files[0].Size != f.meta.Res.Files[0].Size || stats.Files[0].Priority != bt.FilePriorityNormal {
+		t.Errorf("Stats() file 0 got = %+v", stats.Files[0])
+	}
+	if stats.Files[1].Priority != bt.FilePrioritySkip {
+		t.Errorf("Stats() deselected file priority got = %v, want %v", stats.Files[1].Priority, bt.FilePrioritySkip)
+	}
+}
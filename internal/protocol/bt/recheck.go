@@ -0,0 +1,87 @@
+package bt
+
+import (
+	"context"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+// Recheck re-hashes the pieces of the selected files, the damaged pieces are marked incomplete
+// and downloaded again on the next start. A torrent not loaded is loaded for the recheck only.
+func (f *Fetcher) Recheck() (*base.Recheck, error) {
+	loaded := f.torrentReady.Load() && f.torrentDropCtx.Err() == nil
+	if !loaded {
+		// the torrent was dropped, e.g. the task stopped seeding
+		if f.torrentDropCtx.Err() != nil {
+			f.torrentDropCtx, f.torrentDropFunc = context.WithCancel(context.Background())
+		}
+		if err := f.addTorrent(f.meta.Req, false); err != nil {
+			return nil, err
+		}
+		f.torrent.DisallowDataDownload()
+		defer func() {
+			f.torrentReady.Store(false)
+			f.safeDrop()
+		}()
+	}
+
+	files := f.torrent.Files()
+	selectFiles := f.meta.Opts.SelectFiles
+	if len(selectFiles) == 0 {
+		selectFiles = make([]int, len(files))
+		for i := range files {
+			selectFiles[i] = i
+		}
+	}
+	// the pieces of the selected files, a piece may be shared by the adjacent files
+	var pieces []int
+	seen := make(map[int]bool)
+	for _, index := range selectFiles {
+		if index < 0 || index >= len(files) {
+			continue
+		}
+		file := files[index]
+		for i := file.BeginPieceIndex(); i < file.EndPieceIndex(); i++ {
+			if !seen[i] {
+				seen[i] = true
+				pieces = append(pieces, i)
+			}
+		}
+	}
+	had := make(map[int]bool, len(pieces))
+	for _, i := range pieces {
+		had[i] = f.torrent.PieceState(i).Complete
+	}
+
+	for _, i := range pieces {
+		if err := f.torrent.Piece(i).VerifyDataContext(f.torrentDropCtx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &base.Recheck{
+		Time:            time.Now(),
+		Pieces:          len(pieces),
+		CorruptedPieces: make([]int, 0),
+	}
+	for _, i := range pieces {
+		if f.torrent.PieceState(i).Complete {
+			result.VerifiedPieces++
+		} else if had[i] {
+			result.CorruptedPieces = append(result.CorruptedPieces, i)
+		}
+	}
+	result.Intact = result.VerifiedPieces == len(pieces)
+	for i, index := range selectFiles {
+		if index < 0 || index >= len(files) {
+			continue
+		}
+		completed := files[index].BytesCompleted()
+		result.VerifiedBytes += completed
+		if i < len(f.data.Progress) {
+			f.data.Progress[i] = completed
+		}
+	}
+	return result, nil
+}
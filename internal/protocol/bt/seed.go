@@ -0,0 +1,89 @@
+package bt
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+// seeds is the seeding queue of the done torrents in the shared client.
+var seeds = &seedQueue{turns: make(map[*Fetcher]time.Time)}
+
+// seedQueue limits the number of torrents uploading at the same time, the first max fetchers upload and the
+// others wait. A fetcher that used its slot for the rotate interval moves to the end when others are waiting.
+type seedQueue struct {
+	lock     sync.Mutex
+	fetchers []*Fetcher
+	// turns are the times the uploading fetchers got their slots
+	turns map[*Fetcher]time.Time
+}
+
+// seeding joins the fetcher to the queue if it's not in it yet, and returns whether the fetcher can upload now.
+func (q *seedQueue) seeding(f *Fetcher, max int, rotate time.Duration, now time.Time) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if !slices.Contains(q.fetchers, f) {
+		q.fetchers = append(q.fetchers, f)
+	}
+	if max <= 0 {
+		max = len(q.fetchers)
+	}
+	if rotate > 0 && len(q.fetchers) > max {
+		// each expired fetcher moves to the end once, the waiting fetchers shifted into the slots have no turn yet
+		for i := 0; i < max; {
+			turn, ok := q.turns[q.fetchers[i]]
+			if !ok || now.Sub(turn) < rotate {
+				i++
+				continue
+			}
+			expired := q.fetchers[i]
+			delete(q.turns, expired)
+			q.fetchers = append(append(q.fetchers[:i], q.fetchers[i+1:]...), expired)
+		}
+	}
+	for i, qf := range q.fetchers {
+		if i >= max {
+			delete(q.turns, qf)
+			continue
+		}
+		if _, ok := q.turns[qf]; !ok {
+			q.turns[qf] = now
+		}
+	}
+	return slices.Index(q.fetchers, f) < max
+}
+
+func (q *seedQueue) leave(f *Fetcher) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if i := slices.Index(q.fetchers, f); i >= 0 {
+		q.fetchers = slices.Delete(q.fetchers, i, i+1)
+	}
+	delete(q.turns, f)
+}
+
+// seedConfig returns the seed limits of the task, the options extra overrides the protocol config.
+func (f *Fetcher) seedConfig() (keep bool, ratio float64, seedTime int64) {
+	keep, ratio, seedTime = f.config.SeedKeep, f.config.SeedRatio, f.config.SeedTime
+	if f.meta.Opts == nil {
+		return
+	}
+	extra, ok := f.meta.Opts.Extra.(*bt.OptsExtra)
+	if !ok {
+		return
+	}
+	if extra.SeedKeep != nil {
+		keep = *extra.SeedKeep
+	}
+	if extra.SeedRatio != nil {
+		ratio = *extra.SeedRatio
+	}
+	if extra.SeedTime != nil {
+		seedTime = *extra.SeedTime
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package bt
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+func TestSeedQueue(t *testing.T) {
+	q := &seedQueue{turns: make(map[*Fetcher]time.Time)}
+	f1, f2, f3 := &Fetcher{}, &Fetcher{}, &Fetcher{}
+	start := time.Now()
+	rotate := 10 * time.Second
+
+	seeding := func(f *Fetcher, now time.Time) bool {
+		return q.seeding(f, 2, rotate, now)
+	}
+	if !seeding(f1, start) || !seeding(f2, start) || seeding(f3, start) {
+		t.Fatal("seeding() the first fetchers should get the slots")
+	}
+	if seeding(f3, start.Add(rotate-time.Second)) || !seeding(f1, start.Add(rotate-time.Second)) {
+		t.Error("seeding() slots should be kept before the rotate interval")
+	}
+
+	// both slots expired, the waiting fetcher gets a slot and the expired fetchers take turns
+	now := start.Add(rotate)
+	if !seeding(f3, now) || !seeding(f1, now) || seeding(f2, now) {
+		t.Errorf("seeding() after rotation got = %v, %v, %v", seeding(f1, now), seeding(f2, now), seeding(f3, now))
+	}
+
+	// a leaving fetcher frees its slot
+	q.leave(f3)
+	if !seeding(f2, now) {
+		t.Error("seeding() should get the slot of the left fetcher")
+	}
+
+	// no limit
+	if !q.seeding(f3, 0, rotate, now) || len(q.turns) != 3 {
+		t.Errorf("seeding() without limit got %d turns", len(q.turns))
+	}
+}
+
+func TestFetcher_SeedConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "seed.bin")
+	writeTestFile(t, file, 64*1024)
+	result, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seedTime := int64(1)
+	seedKeep := false
+	f := buildFetcher().(*Fetcher)
+	err = f.Resolve(&base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+	}, &base.Options{
+		Path: dir,
+		Extra: map[string]any{
+			"seedTime": seedTime,
+			"seedKeep": seedKeep,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if keep, ratio, st := f.seedConfig(); keep || st != seedTime || ratio != f.config.SeedRatio {
+		t.Errorf("seedConfig() got = %v, %v, %d", keep, ratio, st)
+	}
+
+	// the local content is complete, so the seeding stops after the seed time of the task
+	done := make(chan any)
+	go func() {
+		f.WaitUpload()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Errorf("seeding should stop by the task seed time, seed time %d", f.data.SeedTime)
+	}
+}
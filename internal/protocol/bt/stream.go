@@ -0,0 +1,77 @@
+package bt
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+const (
+	// sequentialPieces is how many incomplete pieces from the head of the selected files are prioritized in sequential mode
+	sequentialPieces = 16
+	// streamReadahead is how many bytes after the read position are prioritized while streaming
+	streamReadahead = 16 * 1024 * 1024
+)
+
+func (f *Fetcher) sequential() bool {
+	if f.meta.Opts == nil {
+		return false
+	}
+	extra, ok := f.meta.Opts.Extra.(*bt.OptsExtra)
+	return ok && extra.Sequential
+}
+
+// sequentialLoop keeps raising the priority of the first incomplete pieces until the torrent is dropped.
+func (f *Fetcher) sequentialLoop() {
+	for {
+		select {
+		case <-f.torrentDropCtx.Done():
+			return
+		case <-time.After(time.Second):
+			if f.torrentReady.Load() && !f.isDone() {
+				f.prioritizeHead()
+			}
+		}
+	}
+}
+
+// prioritizeHead raises the priority of the first incomplete pieces of the selected files in file order.
+func (f *Fetcher) prioritizeHead() {
+	files := f.torrent.Files()
+	n := 0
+	for _, selectIndex := range f.meta.Opts.SelectFiles {
+		if selectIndex < 0 || selectIndex >= len(files) {
+			continue
+		}
+		file := files[selectIndex]
+		for i := file.BeginPieceIndex(); i < file.EndPieceIndex(); i++ {
+			if n >= sequentialPieces {
+				return
+			}
+			if f.torrent.PieceState(i).Complete {
+				continue
+			}
+			// one level above the file priority, so the head is downloaded before the rest of the file
+			f.torrent.Piece(i).SetPriority(piecePriority(f.filePriority(selectIndex)) + 1)
+			n++
+		}
+	}
+}
+
+// Stream opens a reader of the file, the pieces at the read position are downloaded first.
+func (f *Fetcher) Stream(ctx context.Context, fileIndex int) (io.ReadSeekCloser, error) {
+	if !f.torrentReady.Load() {
+		return nil, fetcher.ErrStreamNotSupported
+	}
+	files := f.torrent.Files()
+	if fileIndex < 0 || fileIndex >= len(files) {
+		return nil, fetcher.ErrStreamNotSupported
+	}
+	reader := files[fileIndex].NewReader()
+	reader.SetContext(ctx)
+	reader.SetReadahead(streamReadahead)
+	return reader, nil
+}
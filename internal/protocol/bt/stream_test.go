@@ -0,0 +1,100 @@
+package bt
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent"
+)
+
+func TestFetcher_Sequential(t *testing.T) {
+	f := buildFetcher().(*Fetcher)
+	err := f.Resolve(&base.Request{
+		URL: "./testdata/test.torrent",
+	}, &base.Options{
+		Path:        t.TempDir(),
+		SelectFiles: []int{1, 2},
+		Extra:       map[string]any{"sequential": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if extra, ok := f.meta.Opts.Extra.(*bt.OptsExtra); !ok || !extra.Sequential || !f.sequential() {
+		t.Fatalf("Resolve() should parse the sequential option, got %#v", f.meta.Opts.Extra)
+	}
+
+	// the priority is only effective after the data download is allowed and the piece completion is checked
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	files := f.torrent.Files()
+	first := files[1].BeginPieceIndex()
+	// the head is one level above the normal file priority
+	want := piecePriority(bt.FilePriorityNormal) + 1
+	var got torrent.PiecePriority
+	for i := 0; i < 50 && got != want; i++ {
+		f.prioritizeHead()
+		got = f.torrent.PieceState(first).Priority
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got != want {
+		t.Errorf("prioritizeHead() first piece of the first selected file got = %v, want %v", got, want)
+	}
+	high := 0
+	for i := 0; i < f.torrent.NumPieces(); i++ {
+		if f.torrent.PieceState(i).Priority == want {
+			high++
+		}
+	}
+	if high > sequentialPieces {
+		t.Errorf("prioritizeHead() should prioritize at most %d pieces, got %d", sequentialPieces, high)
+	}
+}
+
+func TestFetcher_Stream(t *testing.T) {
+	f := buildFetcher().(*Fetcher)
+	if _, err := f.Stream(context.Background(), 0); err != fetcher.ErrStreamNotSupported {
+		t.Errorf("Stream() before resolve got = %v, want %v", err, fetcher.ErrStreamNotSupported)
+	}
+	err := f.Resolve(&base.Request{
+		URL: "./testdata/test.torrent",
+	}, &base.Options{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Stream(context.Background(), 3); err != fetcher.ErrStreamNotSupported {
+		t.Errorf("Stream() invalid file got = %v, want %v", err, fetcher.ErrStreamNotSupported)
+	}
+	reader, err := f.Stream(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != f.meta.Res.Files[2].Size {
+		t.Errorf("Stream() size got = %d, want %d", size, f.meta.Res.Files[2].Size)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled, err := f.Stream(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer canceled.Close()
+	if _, err := canceled.Read(make([]byte, 10)); err == nil {
+		t.Error("Read() with a done ctx should fail without the data")
+	}
+}
@@ -0,0 +1,458 @@
+package bt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+	"github.com/anacrolix/torrent/tracker"
+	trHttp "github.com/anacrolix/torrent/tracker/http"
+)
+
+// announceTimeout is the timeout of an announce made by Reannounce or AddTrackers
+const announceTimeout = 15 * time.Second
+
+var (
+	ErrTorrentNotReady   = errors.New("torrent is not ready")
+	ErrInvalidPeerAddr   = errors.New("invalid peer address, must be ip:port")
+	ErrPeerBanned        = errors.New("peer is banned")
+	ErrInvalidTrackerUrl = errors.New("invalid tracker url")
+)
+
+// bannedIPs is the IP block list of the client, shared by all torrents and kept until the process exits.
+var bannedIPs = &ipBanList{ips: make(map[netip.Addr]bool)}
+
+type ipBanList struct {
+	lock sync.RWMutex
+	ips  map[netip.Addr]bool
+	// blocklist is the block list of the config, nil if not set
+	blocklist iplist.Ranger
+}
+
+func (l *ipBanList) Lookup(ip net.IP) (r iplist.Range, ok bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if ok && l.banned(addr) {
+		return iplist.Range{First: ip, Last: ip, Description: "banned"}, true
+	}
+	l.lock.RLock()
+	blocklist := l.blocklist
+	l.lock.RUnlock()
+	if blocklist != nil {
+		return blocklist.Lookup(ip)
+	}
+	return r, false
+}
+
+func (l *ipBanList) NumRanges() int {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	n := len(l.ips)
+	if l.blocklist != nil {
+		n += l.blocklist.NumRanges()
+	}
+	return n
+}
+
+func (l *ipBanList) setBlocklist(blocklist iplist.Ranger) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.blocklist = blocklist
+}
+
+func (l *ipBanList) banned(addr netip.Addr) bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.ips[addr.Unmap()]
+}
+
+func (l *ipBanList) add(addr netip.Addr) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.ips[addr.Unmap()] = true
+}
+
+// Peers returns the connected peers of the torrent.
+func (f *Fetcher) Peers() ([]*bt.Peer, error) {
+	if !f.torrentReady.Load() {
+		return nil, ErrTorrentNotReady
+	}
+	numPieces := f.torrent.NumPieces()
+	conns := f.torrent.PeerConns()
+	peers := make([]*bt.Peer, 0, len(conns))
+	for _, conn := range conns {
+		stats := conn.Stats()
+		clientName, _ := conn.PeerClientName.Load().(string)
+		peer := &bt.Peer{
+			Addr:              conn.RemoteAddr.String(),
+			Client:            clientName,
+			Network:           conn.Network,
+			Source:            string(conn.Discovery),
+			PrefersEncryption: conn.PeerPrefersEncryption,
+			DownloadSpeed:     int64(stats.DownloadRate),
+			UploadSpeed:       int64(stats.LastWriteUploadRate),
+			Downloaded:        stats.BytesReadUsefulData.Int64(),
+			Uploaded:          stats.BytesWrittenData.Int64(),
+		}
+		if numPieces > 0 {
+			peer.Progress = float64(stats.RemotePieceCount) / float64(numPieces)
+			peer.Seeder = stats.RemotePieceCount >= numPieces
+		}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Addr < peers[j].Addr
+	})
+	return peers, nil
+}
+
+// AddPeer connects to a peer by ip:port.
+func (f *Fetcher) AddPeer(addr string) error {
+	if !f.torrentReady.Load() {
+		return ErrTorrentNotReady
+	}
+	addrPort, err := netip.ParseAddrPort(addr)
+	if err != nil || addrPort.Port() == 0 {
+		return ErrInvalidPeerAddr
+	}
+	if bannedIPs.banned(addrPort.Addr()) {
+		return ErrPeerBanned
+	}
+	f.torrent.AddPeers([]torrent.PeerInfo{{
+		Addr:    net.TCPAddrFromAddrPort(addrPort),
+		Source:  torrent.PeerSourceDirect,
+		Trusted: true,
+	}})
+	return nil
+}
+
+// BanPeer bans the IP of a peer by ip or ip:port for all torrents and closes its connections,
+// the ban is kept until the process exits.
+func (f *Fetcher) BanPeer(addr string) error {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(addr)
+		if err != nil {
+			return ErrInvalidPeerAddr
+		}
+		ip = addrPort.Addr()
+	}
+	bannedIPs.add(ip)
+
+	lock.Lock()
+	cl := client
+	lock.Unlock()
+	if !f.torrentReady.Load() || cl == nil {
+		return nil
+	}
+	for _, t := range cl.Torrents() {
+		for _, conn := range t.PeerConns() {
+			if remote, err := netip.ParseAddrPort(conn.RemoteAddr.String()); err == nil && bannedIPs.banned(remote.Addr()) {
+				conn.Close()
+			}
+		}
+	}
+	return nil
+}
+
+// Trackers returns the trackers of the torrent with the state of the announces made by the torrent client,
+// the announces made by Gopeed fill in the swarm counts the client doesn't keep.
+func (f *Fetcher) Trackers() ([]*bt.Tracker, error) {
+	if !f.torrentReady.Load() {
+		return nil, ErrTorrentNotReady
+	}
+	clientStatus := f.clientTrackerStatus()
+	f.trackerLock.Lock()
+	defer f.trackerLock.Unlock()
+	trackers := make([]*bt.Tracker, 0)
+	for tier, urls := range f.torrent.Metainfo().AnnounceList {
+		for _, u := range urls {
+			t := &bt.Tracker{}
+			if status, ok := f.trackerStatus[u]; ok {
+				*t = *status
+			}
+			if status, ok := clientStatus[normalizeTrackerUrl(u)]; ok {
+				t.Error = status.Error
+				if status.Error == "" {
+					t.Peers = status.Peers
+				}
+			}
+			t.URL = u
+			t.Tier = tier
+			trackers = append(trackers, t)
+		}
+	}
+	return trackers, nil
+}
+
+// clientTrackerStatus returns the state of the last announces made by the torrent client keyed by the normalized url,
+// a tracker the client has not announced to yet is not included.
+// The client has no api for it, so it's read from the trackers table of the client status.
+func (f *Fetcher) clientTrackerStatus() map[string]*bt.Tracker {
+	lock.Lock()
+	cl := client
+	lock.Unlock()
+	if cl == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	cl.WriteStatus(&buf)
+	return parseTrackerStatus(buf.Bytes(), f.torrent.InfoHash().HexString())
+}
+
+// parseTrackerStatus parses the "Enabled trackers" table of the torrent with the info hash from the client status,
+// a row is like `"udp://tracker.example.com:80/announce"  next ann: 29m0s, last ann: 12 peers`,
+// the last announce is "never", the number of peers or the error.
+func parseTrackerStatus(status []byte, infoHash string) map[string]*bt.Tracker {
+	const (
+		infoHashPrefix = "Infohash: "
+		tableHeader    = "Enabled trackers:"
+		lastAnnSep     = ", last ann: "
+	)
+	result := make(map[string]*bt.Tracker)
+	inTorrent, inTable := false, false
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, infoHashPrefix) {
+			if inTorrent {
+				break
+			}
+			inTorrent = line == infoHashPrefix+infoHash
+			continue
+		}
+		if !inTorrent {
+			continue
+		}
+		if !inTable {
+			inTable = line == tableHeader
+			continue
+		}
+		row := strings.TrimLeft(line, " ")
+		if len(row) == len(line) {
+			// the table is indented
+			break
+		}
+		quoted, err := strconv.QuotedPrefix(row)
+		if err != nil {
+			// the header row
+			continue
+		}
+		u, _ := strconv.Unquote(quoted)
+		_, lastAnn, ok := strings.Cut(row[len(quoted):], lastAnnSep)
+		if !ok || lastAnn == "never" {
+			continue
+		}
+		t := &bt.Tracker{URL: u}
+		if peers, found := strings.CutSuffix(lastAnn, " peers"); found {
+			if n, err := strconv.Atoi(peers); err == nil {
+				t.Peers = n
+				result[u] = t
+				continue
+			}
+		}
+		t.Error = lastAnn
+		result[u] = t
+	}
+	return result
+}
+
+// normalizeTrackerUrl returns the url like the torrent client prints it.
+func normalizeTrackerUrl(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return parsed.String()
+}
+
+// AddTrackers adds the trackers to the torrent and announces to them, the trackers are kept in the request
+// so they are added again after restarting.
+func (f *Fetcher) AddTrackers(urls []string) error {
+	if !f.torrentReady.Load() {
+		return ErrTorrentNotReady
+	}
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Host == "" {
+			return ErrInvalidTrackerUrl
+		}
+		switch parsed.Scheme {
+		case "http", "https", "udp":
+		default:
+			return ErrInvalidTrackerUrl
+		}
+	}
+
+	announceList := make([][]string, 0, len(urls))
+	for _, u := range urls {
+		announceList = append(announceList, []string{u})
+	}
+	f.torrent.AddTrackers(announceList)
+
+	extra := f.reqExtra()
+	for _, u := range urls {
+		if !slices.Contains(extra.Trackers, u) {
+			extra.Trackers = append(extra.Trackers, u)
+		}
+	}
+	go f.announce(urls)
+	return nil
+}
+
+// RemoveTrackers removes the trackers from the torrent, the trackers of the torrent file and the config
+// are added again after restarting.
+func (f *Fetcher) RemoveTrackers(urls []string) error {
+	if !f.torrentReady.Load() {
+		return ErrTorrentNotReady
+	}
+	announceList := make([][]string, 0)
+	for _, tier := range f.torrent.Metainfo().AnnounceList {
+		kept := make([]string, 0, len(tier))
+		for _, u := range tier {
+			if !slices.Contains(urls, u) {
+				kept = append(kept, u)
+			}
+		}
+		if len(kept) > 0 {
+			announceList = append(announceList, kept)
+		}
+	}
+	f.torrent.ModifyTrackers(announceList)
+
+	extra := f.reqExtra()
+	kept := make([]string, 0, len(extra.Trackers))
+	for _, u := range extra.Trackers {
+		if !slices.Contains(urls, u) {
+			kept = append(kept, u)
+		}
+	}
+	extra.Trackers = kept
+
+	f.trackerLock.Lock()
+	defer f.trackerLock.Unlock()
+	for _, u := range urls {
+		delete(f.trackerStatus, u)
+	}
+	return nil
+}
+
+// Reannounce announces to all trackers of the torrent in the background, the peers returned are added to the torrent.
+func (f *Fetcher) Reannounce() error {
+	trackers, err := f.Trackers()
+	if err != nil {
+		return err
+	}
+	urls := make([]string, 0, len(trackers))
+	for _, t := range trackers {
+		urls = append(urls, t.URL)
+	}
+	go f.announce(urls)
+	return nil
+}
+
+func (f *Fetcher) announce(urls []string) {
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			status := &bt.Tracker{URL: u}
+			resp, err := f.announceTracker(u)
+			status.LastAnnounce = time.Now()
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				status.Seeders = int(resp.Seeders)
+				status.Leechers = int(resp.Leechers)
+				status.Peers = len(resp.Peers)
+				status.Interval = int(resp.Interval)
+				f.addTrackerPeers(resp.Peers)
+			}
+
+			f.trackerLock.Lock()
+			defer f.trackerLock.Unlock()
+			if f.trackerStatus == nil {
+				f.trackerStatus = make(map[string]*bt.Tracker)
+			}
+			f.trackerStatus[u] = status
+		}(u)
+	}
+	wg.Wait()
+}
+
+func (f *Fetcher) announceTracker(u string) (resp tracker.AnnounceResponse, err error) {
+	ctx, cancel := context.WithTimeout(f.torrentDropCtx, announceTimeout)
+	defer cancel()
+
+	// the shared client is closed when the last torrent is dropped, which may happen while announcing
+	lock.Lock()
+	cl, clientCfg := client, cfg
+	lock.Unlock()
+	if cl == nil {
+		err = ErrTorrentNotReady
+		return
+	}
+
+	trackerClient, err := tracker.NewClient(u, tracker.NewClientOpts{
+		Http: trHttp.NewClientOpts{
+			Proxy: clientCfg.HTTPProxy,
+		},
+	})
+	if err != nil {
+		return
+	}
+	defer trackerClient.Close()
+
+	stats := f.torrentStats()
+	return trackerClient.Announce(ctx, tracker.AnnounceRequest{
+		InfoHash:   f.torrent.InfoHash(),
+		PeerId:     cl.PeerID(),
+		Downloaded: stats.BytesReadUsefulData.Int64(),
+		Left:       f.torrent.BytesMissing(),
+		Uploaded:   stats.BytesWrittenData.Int64(),
+		NumWant:    -1,
+		Port:       uint16(cl.LocalPort()),
+	}, tracker.AnnounceOpt{
+		UserAgent: clientCfg.HTTPUserAgent,
+	})
+}
+
+func (f *Fetcher) addTrackerPeers(peers []tracker.Peer) {
+	infos := make([]torrent.PeerInfo, 0, len(peers))
+	for _, peer := range peers {
+		addrPort, ok := peer.ToNetipAddrPort()
+		if !ok {
+			continue
+		}
+		infos = append(infos, torrent.PeerInfo{
+			Addr:   net.TCPAddrFromAddrPort(addrPort),
+			Source: torrent.PeerSourceTracker,
+		})
+	}
+	if len(infos) > 0 {
+		f.torrent.AddPeers(infos)
+	}
+}
+
+func (f *Fetcher) reqExtra() *bt.ReqExtra {
+	if f.meta.Req.Extra == nil {
+		f.meta.Req.Extra = &bt.ReqExtra{}
+	}
+	base.ParseReqExtra[bt.ReqExtra](f.meta.Req)
+	return f.meta.Req.Extra.(*bt.ReqExtra)
+}
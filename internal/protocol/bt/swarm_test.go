@@ -0,0 +1,221 @@
+package bt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// buildSwarmFetcher resolves a torrent of a local file into an empty directory, so it can't complete without peers.
+func buildSwarmFetcher(t *testing.T) (*Fetcher, string, *bt.CreateTorrentResult) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "swarm.bin"), 256*1024)
+	result, err := CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: filepath.Join(dir, "swarm.bin"), PieceLength: minPieceLength})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := buildFetcher().(*Fetcher)
+	err = f.Resolve(&base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+	}, &base.Options{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, dir, result
+}
+
+func TestFetcher_Peers(t *testing.T) {
+	t.Cleanup(func() {
+		bannedIPs.lock.Lock()
+		bannedIPs.ips = make(map[netip.Addr]bool)
+		bannedIPs.lock.Unlock()
+	})
+	f, dir, result := buildSwarmFetcher(t)
+
+	// a separate client seeds the content without the last piece, so the download doesn't complete
+	// and the connection is kept
+	file, err := os.OpenFile(filepath.Join(dir, "swarm.bin"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt(make([]byte, minPieceLength), 256*1024-minPieceLength); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	seedCfg := torrent.NewDefaultClientConfig()
+	seedCfg.ListenPort = 0
+	seedCfg.NoDHT = true
+	seedCfg.Seed = true
+	seedCfg.DataDir = dir
+	seedCfg.DefaultStorage = storage.NewFile(dir)
+	seedClient, err := torrent.NewClient(seedCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer seedClient.Close()
+	mi, err := metainfo.Load(bytes.NewReader(result.Torrent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedTorrent, err := seedClient.AddTorrent(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-seedTorrent.GotInfo()
+	seedAddr := "127.0.0.1:" + strconv.Itoa(seedClient.LocalPort())
+
+	if err := f.AddPeer("127.0.0.1"); err != ErrInvalidPeerAddr {
+		t.Errorf("AddPeer() without port got = %v, want %v", err, ErrInvalidPeerAddr)
+	}
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddPeer(seedAddr); err != nil {
+		t.Fatal(err)
+	}
+	var peers []*bt.Peer
+	for i := 0; i < 100 && len(peers) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if peers, err = f.Peers(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(peers) != 1 || peers[0].Source != torrent.PeerSourceDirect {
+		t.Fatalf("Peers() got = %+v, want the added peer", peers)
+	}
+
+	if err := f.BanPeer(seedAddr); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50 && len(peers) > 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		peers, _ = f.Peers()
+	}
+	if len(peers) != 0 {
+		t.Errorf("BanPeer() should close the connection, got = %+v", peers)
+	}
+	if err := f.AddPeer(seedAddr); err != ErrPeerBanned {
+		t.Errorf("AddPeer() banned got = %v, want %v", err, ErrPeerBanned)
+	}
+	if err := f.BanPeer("not-an-ip"); err != ErrInvalidPeerAddr {
+		t.Errorf("BanPeer() invalid got = %v, want %v", err, ErrInvalidPeerAddr)
+	}
+}
+
+func TestFetcher_Trackers(t *testing.T) {
+	f, _, _ := buildSwarmFetcher(t)
+	if _, err := (&Fetcher{}).Trackers(); err != ErrTorrentNotReady {
+		t.Errorf("Trackers() not ready got = %v, want %v", err, ErrTorrentNotReady)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := bencode.Marshal(map[string]any{
+			"interval":   1800,
+			"complete":   3,
+			"incomplete": 2,
+			"peers":      "",
+		})
+		w.Write(buf)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+	okTracker := "http://" + listener.Addr().String() + "/announce"
+	// nothing listens on the closed listener
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	badTracker := "http://" + closed.Addr().String() + "/announce"
+	closed.Close()
+
+	if err := f.AddTrackers([]string{"ftp://example.com/announce"}); err != ErrInvalidTrackerUrl {
+		t.Errorf("AddTrackers() invalid got = %v, want %v", err, ErrInvalidTrackerUrl)
+	}
+	if err := f.AddTrackers([]string{okTracker, badTracker}); err != nil {
+		t.Fatal(err)
+	}
+	if extra := f.meta.Req.Extra.(*bt.ReqExtra); len(extra.Trackers) != 2 {
+		t.Errorf("AddTrackers() should keep the trackers in the request, got = %v", extra.Trackers)
+	}
+
+	var trackers []*bt.Tracker
+	announced := func() bool {
+		trackers, _ = f.Trackers()
+		for _, tr := range trackers {
+			if tr.LastAnnounce.IsZero() {
+				return false
+			}
+		}
+		return len(trackers) == 2
+	}
+	for i := 0; i < 100 && !announced(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !announced() {
+		t.Fatalf("AddTrackers() should announce, got = %+v", trackers)
+	}
+	for _, tr := range trackers {
+		switch tr.URL {
+		case okTracker:
+			if tr.Error != "" || tr.Seeders != 3 || tr.Leechers != 2 || tr.Interval != 1800 {
+				t.Errorf("Trackers() ok tracker got = %+v", tr)
+			}
+		case badTracker:
+			if tr.Error == "" {
+				t.Errorf("Trackers() bad tracker should have an error, got = %+v", tr)
+			}
+		}
+	}
+
+	// a tracker only announced by the torrent client reports the state of the client announce
+	closed, _ = net.Listen("tcp", "127.0.0.1:0")
+	clientTracker := "http://" + closed.Addr().String() + "/announce"
+	closed.Close()
+	f.torrent.AddTrackers([][]string{{clientTracker}})
+	clientAnnounced := func() bool {
+		trackers, _ = f.Trackers()
+		for _, tr := range trackers {
+			if tr.URL == clientTracker {
+				return tr.Error != ""
+			}
+		}
+		return false
+	}
+	for i := 0; i < 100 && !clientAnnounced(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !clientAnnounced() {
+		t.Errorf("Trackers() client tracker should have an error, got = %+v", trackers)
+	}
+
+	if err := f.Reannounce(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RemoveTrackers([]string{badTracker, clientTracker}); err != nil {
+		t.Fatal(err)
+	}
+	trackers, _ = f.Trackers()
+	if len(trackers) != 1 || trackers[0].URL != okTracker {
+		t.Errorf("RemoveTrackers() got = %+v", trackers)
+	}
+	if extra := f.meta.Req.Extra.(*bt.ReqExtra); len(extra.Trackers) != 1 || extra.Trackers[0] != okTracker {
+		t.Errorf("RemoveTrackers() should remove the tracker from the request, got = %v", extra.Trackers)
+	}
+}
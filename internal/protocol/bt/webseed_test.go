@@ -0,0 +1,86 @@
+package bt
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+// startWebSeedServer creates a torrent of a folder and serves the folder over HTTP as its web seed.
+func startWebSeedServer(t *testing.T, embed bool) (string, *bt.CreateTorrentResult) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "mirror")
+	writeTestFile(t, filepath.Join(root, "a.bin"), 300*1024)
+	writeTestFile(t, filepath.Join(root, "sub", "b.bin"), 100*1024)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.FileServer(http.Dir(dir))}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	webSeed := "http://" + listener.Addr().String() + "/"
+
+	opts := &bt.CreateTorrentOpts{Path: root}
+	if embed {
+		opts.WebSeeds = []string{webSeed}
+	}
+	result, err := CreateTorrent(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webSeed, result
+}
+
+func downloadFromWebSeed(t *testing.T, req *base.Request, result *bt.CreateTorrentResult) {
+	f := buildFetcher().(*Fetcher)
+	dir := t.TempDir()
+	if err := f.Resolve(req, &base.Options{Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100 && !f.isDone(); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if !f.isDone() {
+		t.Fatalf("download from the web seed got %d of %d", f.torrent.BytesCompleted(), f.meta.Res.Size)
+	}
+	if got := f.Stats().(*bt.Stats).WebSeeds; got != 1 {
+		t.Errorf("Stats() web seeds got = %d, want 1", got)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, result.Name, "sub", "b.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 100*1024 || buf[1000] != byte(1000%251) {
+		t.Error("download from the web seed got unexpected data")
+	}
+}
+
+func TestFetcher_WebSeedReqExtra(t *testing.T) {
+	webSeed, result := startWebSeedServer(t, false)
+	downloadFromWebSeed(t, &base.Request{
+		URL:   "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+		Extra: &bt.ReqExtra{WebSeeds: []string{webSeed, "ftp://ignored.example.com/"}},
+	}, result)
+}
+
+func TestFetcher_WebSeedEmbedded(t *testing.T) {
+	_, result := startWebSeedServer(t, true)
+	downloadFromWebSeed(t, &base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+	}, result)
+}
@@ -12,4 +12,8 @@ type config struct {
 	ServerAddr string `json:"serverAddr"`
 	ServerMet  string `json:"serverMet"`
 	NodesDat   string `json:"nodesDat"`
+	// DisableDHT disables the KAD network, so sources are only found by the servers.
+	DisableDHT bool `json:"disableDHT"`
+	// DisableUPnP disables the UPnP port mapping of the listen ports.
+	DisableUPnP bool `json:"disableUPnP"`
 }
@@ -54,6 +54,12 @@ type Fetcher struct {
 	meta    *fetcher.FetcherMeta
 	handle  goed2k.TransferHandle
 
+	// lock guards data, it's updated by Wait and Recheck
+	lock sync.Mutex
+	data *fetcherData
+	// parts is the last part snapshots of the transfer, used to find the parts failed the hash verification
+	parts []goed2k.PieceSnapshot
+
 	waitCtx    context.Context
 	waitCancel context.CancelFunc
 }
@@ -63,6 +69,9 @@ func (f *Fetcher) Setup(ctl *controller.Controller) {
 	if f.meta == nil {
 		f.meta = &fetcher.FetcherMeta{}
 	}
+	if f.data == nil {
+		f.data = &fetcherData{}
+	}
 	f.waitCtx, f.waitCancel = context.WithCancel(context.Background())
 	f.ctl.GetConfig(&f.config)
 }
@@ -118,11 +127,18 @@ func (f *Fetcher) Start() error {
 		Size:       link.NumberValue,
 		FilePath:   targetPath,
 	}
+	f.lock.Lock()
+	// the parts verified by the last recheck
+	atp.ResumeData = f.data.ResumeData
+	f.lock.Unlock()
 	handle, err = client.AddTransfer(atp)
 	if err != nil {
 		return err
 	}
 	f.handle = handle
+	f.lock.Lock()
+	f.data.ResumeData = nil
+	f.lock.Unlock()
 	if handle.IsValid() && handle.IsPaused() {
 		if err := client.ResumeTransfer(handle.GetHash()); err != nil {
 			return err
@@ -211,22 +227,29 @@ func (f *Fetcher) Meta() *fetcher.FetcherMeta {
 }
 
 func (f *Fetcher) Stats() any {
+	f.lock.Lock()
+	stats := &ped2k.Stats{
+		CorruptedParts:    f.data.CorruptedParts,
+		RedownloadedBytes: f.data.RedownloadedBytes,
+		Recheck:           f.data.Recheck,
+	}
+	f.lock.Unlock()
+
 	handle := f.currentHandle()
 	if !handle.IsValid() {
-		return &ped2k.Stats{}
+		return stats
 	}
 	status := handle.GetStatus()
-	return &ped2k.Stats{
-		State:         string(status.State),
-		ActivePeers:   handle.ActiveConnections(),
-		TotalPeers:    status.NumPeers,
-		DownloadRate:  status.DownloadRate,
-		Upload:        status.Upload,
-		UploadRate:    status.UploadRate,
-		TotalDone:     status.TotalDone,
-		TotalReceived: status.TotalReceived,
-		TotalWanted:   status.TotalWanted,
-	}
+	stats.State = string(status.State)
+	stats.ActivePeers = handle.ActiveConnections()
+	stats.TotalPeers = status.NumPeers
+	stats.DownloadRate = status.DownloadRate
+	stats.Upload = status.Upload
+	stats.UploadRate = status.UploadRate
+	stats.TotalDone = status.TotalDone
+	stats.TotalReceived = status.TotalReceived
+	stats.TotalWanted = status.TotalWanted
+	return stats
 }
 
 func (f *Fetcher) Progress() fetcher.Progress {
@@ -273,6 +296,7 @@ func (f *Fetcher) Wait() error {
 				if transfer.Removed || transfer.State == goed2k.Finished {
 					return nil
 				}
+				f.trackParts()
 			}
 		}
 	}
@@ -322,6 +346,15 @@ type FetcherManager struct {
 	mu         sync.Mutex
 	client     *goed2k.Client
 	stateStore *clientStateStore
+
+	// cfg is the config the client started with
+	cfg *config
+	// serverErrs are the last connect errors of the servers by address, removed when a connect succeeds
+	serverErrs   map[string]string
+	serverMetErr string
+	nodesDatErr  string
+	// searches are the keyword searches kept for polling, oldest first
+	searches []*search
 }
 
 func (fm *FetcherManager) SetStateStore(store fetcher.ProtocolStateStore) {
@@ -377,14 +410,22 @@ func (fm *FetcherManager) DefaultConfig() any {
 }
 
 func (fm *FetcherManager) Store(f fetcher.Fetcher) (any, error) {
-	return nil, nil
+	_f := f.(*Fetcher)
+	_f.lock.Lock()
+	defer _f.lock.Unlock()
+	if _f.data == nil {
+		return nil, nil
+	}
+	data := *_f.data
+	return &data, nil
 }
 
 func (fm *FetcherManager) Restore() (v any, f func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher) {
-	return nil, func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher {
+	return &fetcherData{}, func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher {
 		return &Fetcher{
 			manager: fm,
 			meta:    meta,
+			data:    v.(*fetcherData),
 		}
 	}
 }
@@ -397,6 +438,10 @@ func (fm *FetcherManager) Close() error {
 		fm.client.Close()
 		fm.client = nil
 	}
+	for _, s := range fm.searches {
+		s.snapshot.State = goed2k.SearchStateStopped
+	}
+	fm.searches = nil
 	return nil
 }
 
@@ -467,8 +512,8 @@ func (fm *FetcherManager) initClient(cfg *config) (*goed2k.Client, error) {
 	settings := goed2k.NewSettings()
 	settings.ListenPort = cfg.ListenPort
 	settings.UDPPort = cfg.UDPPort
-	settings.EnableDHT = true
-	settings.EnableUPnP = true
+	settings.EnableDHT = !cfg.DisableDHT
+	settings.EnableUPnP = !cfg.DisableUPnP
 	settings.ReconnectToServer = true
 
 	client := goed2k.NewClient(settings)
@@ -480,26 +525,10 @@ func (fm *FetcherManager) initClient(cfg *config) (*goed2k.Client, error) {
 		return nil, err
 	}
 	fm.client = client
+	fm.cfg = cfg
 	// Bootstrap is best-effort: downloads can still proceed later even if
-	// server list or DHT initialization fails during startup.
-	bootstrapClient(client, cfg)
+	// server list or DHT initialization fails during startup, the errors are
+	// kept and reported by Servers and Kad.
+	fm.bootstrap(client, cfg)
 	return fm.client, nil
 }
-
-func bootstrapClient(client *goed2k.Client, cfg *config) {
-	for _, serverAddr := range splitCommaList(cfg.ServerAddr) {
-		go func(serverAddr string) {
-			_ = client.Connect(serverAddr)
-		}(serverAddr)
-	}
-	for _, source := range splitCommaList(cfg.ServerMet) {
-		go func(source string) {
-			_ = client.ConnectServerMet(source)
-		}(source)
-	}
-	if cfg.NodesDat != "" {
-		go func() {
-			_ = client.LoadDHTNodesDat(cfg.NodesDat)
-		}()
-	}
-}
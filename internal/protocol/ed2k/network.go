@@ -0,0 +1,261 @@
+package ed2k
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	ped2k "github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/monkeyWie/goed2k"
+)
+
+var (
+	ErrInvalidServerAddr = errors.New("invalid server address, must be host:port")
+	ErrServerNotFound    = errors.New("server not found")
+	ErrKadDisabled       = errors.New("kad is disabled")
+	ErrClientNotStarted  = errors.New("ed2k client is not started")
+	ErrTransferNotReady  = errors.New("transfer is not ready")
+)
+
+// Start starts the shared client by the protocol config of the controller if it's not started yet,
+// the client is started by the first task otherwise.
+func (fm *FetcherManager) Start(ctl *controller.Controller) error {
+	var cfg *config
+	ctl.GetConfig(&cfg)
+	_, err := fm.initClient(cfg)
+	return err
+}
+
+// Servers returns the known servers with the connection status and the last connect error,
+// and the error of the last server.met load.
+func (fm *FetcherManager) Servers() (*ped2k.ServerList, error) {
+	client := fm.currentClient()
+	if client == nil {
+		return nil, ErrClientNotStarted
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	snapshots := client.ServerStatuses()
+	servers := make([]*ped2k.Server, 0, len(snapshots))
+	seen := make(map[string]bool, len(snapshots))
+	for _, s := range snapshots {
+		seen[s.Identifier] = true
+		servers = append(servers, &ped2k.Server{
+			Addr:         s.Identifier,
+			Configured:   s.Configured,
+			Connected:    s.Connected,
+			LoggedIn:     s.HandshakeCompleted,
+			Primary:      s.Primary,
+			IDClass:      s.IDClass(),
+			LastReceive:  s.MillisecondsSinceLastReceive,
+			DownloadRate: s.DownloadRate,
+			UploadRate:   s.UploadRate,
+			Error:        fm.serverErrs[s.Identifier],
+		})
+	}
+	// servers failed before they were added, e.g. the address can't be resolved
+	for addr, err := range fm.serverErrs {
+		if !seen[addr] {
+			servers = append(servers, &ped2k.Server{Addr: addr, IDClass: "UNKNOWN", Error: err})
+		}
+	}
+	return &ped2k.ServerList{Servers: servers, ServerMetError: fm.serverMetErr}, nil
+}
+
+// ConnectServer connects to a server by host:port and adds it to the connect list.
+func (fm *FetcherManager) ConnectServer(addr string) error {
+	client := fm.currentClient()
+	if client == nil {
+		return ErrClientNotStarted
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return ErrInvalidServerAddr
+	}
+	return fm.connectServer(client, addr)
+}
+
+// DisconnectServer disconnects from a server and removes it from the connect list. The client can only drop all
+// the servers at once, so the other servers in the connect list are reconnected.
+func (fm *FetcherManager) DisconnectServer(addr string) error {
+	client := fm.currentClient()
+	if client == nil {
+		return ErrClientNotStarted
+	}
+	var others []string
+	found := false
+	for _, s := range client.ServerStatuses() {
+		if s.Identifier == addr {
+			found = true
+			continue
+		}
+		if s.Configured {
+			others = append(others, s.Identifier)
+		}
+	}
+	fm.mu.Lock()
+	_, failed := fm.serverErrs[addr]
+	delete(fm.serverErrs, addr)
+	fm.mu.Unlock()
+	if !found {
+		if failed {
+			return nil
+		}
+		return ErrServerNotFound
+	}
+
+	client.Session().DisconnectFrom()
+	for _, other := range others {
+		go fm.connectServer(client, other)
+	}
+	return nil
+}
+
+// RefreshServerMet loads the server.met sources of the config and connects to the servers in them.
+func (fm *FetcherManager) RefreshServerMet() error {
+	client := fm.currentClient()
+	if client == nil {
+		return ErrClientNotStarted
+	}
+	return fm.loadServerMet(client, fm.config().ServerMet)
+}
+
+// RefreshNodesDat loads the nodes.dat sources of the config to bootstrap the KAD network.
+func (fm *FetcherManager) RefreshNodesDat() error {
+	client := fm.currentClient()
+	if client == nil {
+		return ErrClientNotStarted
+	}
+	cfg := fm.config()
+	if cfg.DisableDHT {
+		return ErrKadDisabled
+	}
+	return fm.loadNodesDat(client, cfg.NodesDat)
+}
+
+// Kad returns the status of the KAD network.
+func (fm *FetcherManager) Kad() (*ped2k.Kad, error) {
+	client := fm.currentClient()
+	if client == nil {
+		return nil, ErrClientNotStarted
+	}
+	status := client.DHTStatus()
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return &ped2k.Kad{
+		Enabled:       client.GetDHTTracker() != nil,
+		Bootstrapped:  status.Bootstrapped,
+		Firewalled:    status.Firewalled,
+		LiveNodes:     status.LiveNodes,
+		KnownNodes:    status.KnownNodes,
+		ListenPort:    status.ListenPort,
+		NodesDatError: fm.nodesDatErr,
+	}, nil
+}
+
+// Sources returns the peers of the transfer.
+func (f *Fetcher) Sources() ([]*ped2k.Source, error) {
+	handle := f.currentHandle()
+	if !handle.IsValid() {
+		return nil, ErrTransferNotReady
+	}
+	peers := handle.GetPeersInfo()
+	sources := make([]*ped2k.Source, 0, len(peers))
+	for _, p := range peers {
+		sources = append(sources, &ped2k.Source{
+			Addr:          p.Endpoint.String(),
+			Client:        strings.TrimSpace(p.ModName + " " + p.StrModVersion),
+			From:          p.SourceLabels(),
+			DownloadSpeed: p.DownloadSpeed,
+			UploadSpeed:   p.UploadSpeed,
+			Pieces:        p.RemotePieces.Count(),
+			FailCount:     p.FailCount,
+		})
+	}
+	return sources, nil
+}
+
+// bootstrap connects to the servers and loads the server.met and nodes.dat sources of the config in the background.
+func (fm *FetcherManager) bootstrap(client *goed2k.Client, cfg *config) {
+	for _, serverAddr := range splitCommaList(cfg.ServerAddr) {
+		go fm.connectServer(client, serverAddr)
+	}
+	if cfg.ServerMet != "" {
+		go fm.loadServerMet(client, cfg.ServerMet)
+	}
+	if cfg.NodesDat != "" && !cfg.DisableDHT {
+		go fm.loadNodesDat(client, cfg.NodesDat)
+	}
+}
+
+func (fm *FetcherManager) connectServer(client *goed2k.Client, addr string) error {
+	err := client.Connect(addr)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.serverErrs == nil {
+		fm.serverErrs = make(map[string]string)
+	}
+	if err != nil {
+		fm.serverErrs[addr] = err.Error()
+	} else {
+		delete(fm.serverErrs, addr)
+	}
+	return err
+}
+
+// loadServerMet loads the comma separated server.met sources, and connects to the servers in the background.
+// An error is returned only if no source is loaded.
+func (fm *FetcherManager) loadServerMet(client *goed2k.Client, sources string) error {
+	var errs []error
+	loaded := false
+	for _, source := range splitCommaList(sources) {
+		entries, err := client.LoadServerMet(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+		loaded = true
+		for _, entry := range entries {
+			if addr := entry.Address(); addr != "" {
+				go fm.connectServer(client, addr)
+			}
+		}
+	}
+	err := errors.Join(errs...)
+	if loaded {
+		err = nil
+	} else if err == nil {
+		err = errors.New("server.met source is empty")
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.serverMetErr = ""
+	if err != nil {
+		fm.serverMetErr = err.Error()
+	}
+	return err
+}
+
+func (fm *FetcherManager) loadNodesDat(client *goed2k.Client, sources string) error {
+	err := client.LoadDHTNodesDat(sources)
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.nodesDatErr = ""
+	if err != nil {
+		fm.nodesDatErr = err.Error()
+	}
+	return err
+}
+
+func (fm *FetcherManager) config() *config {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.cfg == nil {
+		return fm.DefaultConfig().(*config)
+	}
+	return fm.cfg
+}
@@ -0,0 +1,96 @@
+package ed2k
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+)
+
+func TestFetcherManager_Network(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			// the connections are kept open like a server waiting for the login
+			if _, err := listener.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	fm := &FetcherManager{}
+	defer fm.Close()
+	if _, err := fm.Servers(); !errors.Is(err, ErrClientNotStarted) {
+		t.Fatalf("Servers() before start error = %v", err)
+	}
+	ctl := controller.NewController()
+	ctl.GetConfig = func(v any) {
+		*(v.(**config)) = &config{DisableDHT: true, DisableUPnP: true}
+	}
+	if err := fm.Start(ctl); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fm.ConnectServer("bad"); !errors.Is(err, ErrInvalidServerAddr) {
+		t.Errorf("ConnectServer() invalid address error = %v", err)
+	}
+	addr := listener.Addr().String()
+	if err := fm.ConnectServer(addr); err != nil {
+		t.Fatal(err)
+	}
+	// a closed port, the connect error is kept
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	if err := fm.ConnectServer(closedAddr); err == nil {
+		t.Error("ConnectServer() closed port should fail")
+	}
+
+	list, err := fm.Servers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := make(map[string]*ed2k.Server)
+	for _, s := range list.Servers {
+		servers[s.Addr] = s
+	}
+	if s := servers[addr]; s == nil || !s.Connected || !s.Configured || s.Error != "" {
+		t.Errorf("Servers() got = %+v, want connected", s)
+	}
+	if s := servers[closedAddr]; s == nil || s.Error == "" {
+		t.Errorf("Servers() got = %+v, want connect error", s)
+	}
+
+	if err := fm.DisconnectServer("127.0.0.1:1"); !errors.Is(err, ErrServerNotFound) {
+		t.Errorf("DisconnectServer() unknown server error = %v", err)
+	}
+	if err := fm.DisconnectServer(addr); err != nil {
+		t.Fatal(err)
+	}
+	list, _ = fm.Servers()
+	for _, s := range list.Servers {
+		if s.Addr == addr {
+			t.Errorf("Servers() got disconnected server %+v", s)
+		}
+	}
+
+	if err := fm.RefreshServerMet(); err == nil {
+		t.Errorf("RefreshServerMet() without sources error = %v", err)
+	}
+	if list, _ = fm.Servers(); list.ServerMetError == "" {
+		t.Error("Servers() should report the server.met error")
+	}
+	if err := fm.RefreshNodesDat(); !errors.Is(err, ErrKadDisabled) {
+		t.Errorf("RefreshNodesDat() error = %v", err)
+	}
+	kad, err := fm.Kad()
+	if err != nil || kad.Enabled {
+		t.Errorf("Kad() got = %+v, %v", kad, err)
+	}
+}
@@ -0,0 +1,213 @@
+package ed2k
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/monkeyWie/goed2k"
+	gprotocol "github.com/monkeyWie/goed2k/protocol"
+)
+
+// ErrHashsetUnknown is returned when the part hashes are not received yet and the file doesn't match the ed2k hash as a whole,
+// the corrupted parts can't be found then.
+var ErrHashsetUnknown = errors.New("part hashes are unknown, the file can't be verified by parts")
+
+type fetcherData struct {
+	// CorruptedParts and RedownloadedBytes count the parts failed the part hash verification while downloading
+	CorruptedParts    int
+	RedownloadedBytes int64
+	// Hashes is the part hashset of the file, kept to recheck the file after the transfer is removed from the client
+	Hashes []gprotocol.Hash
+	// Recheck is the result of the last recheck
+	Recheck *base.Recheck
+	// ResumeData are the parts verified by the last recheck, used to add the transfer on the next start
+	ResumeData *gprotocol.TransferResumeData
+}
+
+// Recheck re-hashes the file by the part hashes, the transfer is restored with the verified parts only,
+// so the corrupted parts are downloaded again on the next start. The transfer must not be running.
+func (f *Fetcher) Recheck() (*base.Recheck, error) {
+	link, err := parseLink(f.meta.Req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if f.meta.Res == nil {
+		f.meta.Res = buildResource(link)
+	}
+	numParts := int(goed2k.DivCeil(link.NumberValue, goed2k.PieceSize))
+
+	handle := f.currentHandle()
+	var resume *gprotocol.TransferResumeData
+	if handle.IsValid() {
+		resume = handle.GetResumeData()
+	} else {
+		f.lock.Lock()
+		resume = f.data.ResumeData
+		f.lock.Unlock()
+	}
+
+	partHashes, readParts, err := hashParts(f.meta.SingleFilepath(), link.NumberValue, numParts)
+	if err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	hashes := f.data.Hashes
+	f.lock.Unlock()
+	switch {
+	case resume != nil && len(resume.Hashes) >= numParts && numParts > 1:
+		hashes = resume.Hashes
+	case len(hashes) >= numParts && numParts > 1:
+		// the hashset kept by the last recheck
+	case numParts == 1:
+		hashes = []gprotocol.Hash{link.Hash}
+	case readParts == numParts && matchHashSet(partHashes, link.Hash):
+		hashes = partHashes
+	default:
+		return nil, ErrHashsetUnknown
+	}
+
+	// the parts downloaded before, all the parts with data if the transfer state is lost
+	had := make([]bool, numParts)
+	for i := range had {
+		if resume != nil && resume.Pieces.Len() == numParts {
+			had[i] = resume.Pieces.GetBit(i)
+		} else {
+			had[i] = i < readParts
+		}
+	}
+
+	result := &base.Recheck{
+		Time:            time.Now(),
+		Pieces:          numParts,
+		CorruptedPieces: make([]int, 0),
+	}
+	pieces := gprotocol.NewBitField(numParts)
+	for i := 0; i < numParts; i++ {
+		if i < readParts && partHashes[i].Equal(hashes[i]) {
+			pieces.SetBit(i)
+			result.VerifiedPieces++
+			result.VerifiedBytes += min(goed2k.PieceSize, link.NumberValue-int64(i)*goed2k.PieceSize)
+		} else if had[i] {
+			result.CorruptedPieces = append(result.CorruptedPieces, i)
+		}
+	}
+	result.Intact = result.VerifiedPieces == numParts
+
+	resumeData := &gprotocol.TransferResumeData{
+		Hashes: hashes[:numParts],
+		Pieces: pieces,
+	}
+	if resume != nil {
+		// keep the blocks of the parts still downloading
+		for _, block := range resume.DownloadedBlocks {
+			if block.PieceIndex < numParts && !pieces.GetBit(block.PieceIndex) && !had[block.PieceIndex] {
+				resumeData.DownloadedBlocks = append(resumeData.DownloadedBlocks, block)
+			}
+		}
+		resumeData.Peers = resume.Peers
+	}
+
+	f.lock.Lock()
+	f.data.Hashes = resumeData.Hashes
+	f.data.Recheck = result
+	f.data.ResumeData = resumeData
+	f.parts = nil
+	f.lock.Unlock()
+
+	// restore the transfer with the verified parts, it's added again by the next start otherwise
+	if handle.IsValid() {
+		client, err := f.getClient()
+		if err != nil {
+			return nil, err
+		}
+		if err := client.RemoveTransfer(link.Hash, false); err != nil {
+			return nil, err
+		}
+		handle, err = client.AddTransfer(goed2k.AddTransferParams{
+			Hash:       link.Hash,
+			CreateTime: handle.GetCreateTime(),
+			Size:       link.NumberValue,
+			FilePath:   f.meta.SingleFilepath(),
+			Paused:     true,
+			ResumeData: resumeData,
+		})
+		if err != nil {
+			return nil, err
+		}
+		f.handle = handle
+		f.lock.Lock()
+		f.data.ResumeData = nil
+		f.lock.Unlock()
+	}
+	return result, nil
+}
+
+// trackParts finds the parts failed the part hash verification, the client drops the data of such a part
+// and downloads it again, so a part with finished blocks becomes missing without being finished.
+func (f *Fetcher) trackParts() {
+	handle := f.currentHandle()
+	if !handle.IsValid() {
+		return
+	}
+	parts := handle.PieceSnapshots()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for i, part := range parts {
+		if i >= len(f.parts) {
+			break
+		}
+		last := f.parts[i]
+		if last.State == goed2k.PieceSnapshotDownloading && last.BlocksDone > 0 && part.State == goed2k.PieceSnapshotMissing {
+			f.data.CorruptedParts++
+			f.data.RedownloadedBytes += part.TotalBytes
+		}
+	}
+	f.parts = parts
+}
+
+// hashParts hashes the parts of the file, the parts are read until the end of the file.
+func hashParts(path string, size int64, numParts int) (hashes []gprotocol.Hash, readParts int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, goed2k.PieceSize)
+	for i := 0; i < numParts; i++ {
+		partSize := min(goed2k.PieceSize, size-int64(i)*goed2k.PieceSize)
+		if _, err := io.ReadFull(file, buf[:partSize]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, 0, err
+		}
+		hash, err := gprotocol.HashFromData(buf[:partSize])
+		if err != nil {
+			return nil, 0, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, len(hashes), nil
+}
+
+// matchHashSet checks the part hashes by the ed2k hash, the hashset of a file with the size of a multiple of
+// the part size has an extra hash of the empty part in some clients.
+func matchHashSet(hashes []gprotocol.Hash, hash gprotocol.Hash) bool {
+	if gprotocol.HashFromHashSet(hashes).Equal(hash) {
+		return true
+	}
+	empty, err := gprotocol.HashFromData(nil)
+	if err != nil {
+		return false
+	}
+	return gprotocol.HashFromHashSet(append(hashes[:len(hashes):len(hashes)], empty)).Equal(hash)
+}
@@ -0,0 +1,121 @@
+package ed2k
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/monkeyWie/goed2k"
+	gprotocol "github.com/monkeyWie/goed2k/protocol"
+)
+
+func TestFetcher_Recheck(t *testing.T) {
+	// two parts, the last part is smaller
+	content := make([]byte, goed2k.PieceSize+1000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	var partHashes []gprotocol.Hash
+	for _, part := range [][]byte{content[:goed2k.PieceSize], content[goed2k.PieceSize:]} {
+		hash, err := gprotocol.HashFromData(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partHashes = append(partHashes, hash)
+	}
+	link := fmt.Sprintf("ed2k://|file|test.bin|%d|%s|/", len(content), gprotocol.HashFromHashSet(partHashes).String())
+
+	dir := t.TempDir()
+	f := (&FetcherManager{}).Build().(*Fetcher)
+	f.Setup(controller.NewController())
+	if err := f.Resolve(&base.Request{URL: link}, &base.Options{Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "test.bin")
+
+	// nothing downloaded
+	result, err := f.Recheck()
+	if !errors.Is(err, ErrHashsetUnknown) {
+		t.Fatalf("Recheck() missing file without hashset error = %v", err)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = f.Recheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Intact || result.Pieces != 2 || result.VerifiedPieces != 2 || result.VerifiedBytes != int64(len(content)) || len(result.CorruptedPieces) != 0 {
+		t.Errorf("Recheck() intact file got = %+v", result)
+	}
+
+	// the hashset is kept, so the corrupted part can be found
+	corrupted := slices.Clone(content)
+	corrupted[10] ^= 0xff
+	if err := os.WriteFile(path, corrupted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = f.Recheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Intact || result.VerifiedPieces != 1 || result.VerifiedBytes != 1000 || !slices.Equal(result.CorruptedPieces, []int{0}) {
+		t.Errorf("Recheck() corrupted file got = %+v", result)
+	}
+	f.lock.Lock()
+	pieces := f.data.ResumeData.Pieces
+	f.lock.Unlock()
+	if pieces.GetBit(0) || !pieces.GetBit(1) {
+		t.Errorf("Recheck() resume pieces got = %v", pieces.Bits())
+	}
+	if stats := f.Stats().(*ed2k.Stats); stats.Recheck != result {
+		t.Errorf("Stats() recheck got = %+v", stats.Recheck)
+	}
+}
+
+func TestFetcher_Recheck_SinglePart(t *testing.T) {
+	content := []byte("ed2k single part file")
+	hash, err := gprotocol.HashFromData(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := fmt.Sprintf("ed2k://|file|test.txt|%d|%s|/", len(content), hash.String())
+
+	dir := t.TempDir()
+	f := (&FetcherManager{}).Build().(*Fetcher)
+	f.Setup(controller.NewController())
+	if err := f.Resolve(&base.Request{URL: link}, &base.Options{Path: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the part hash is the file hash, the transfer state is lost so the part with data was downloaded before
+	path := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(path, []byte("ed2k single part fill"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := f.Recheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Intact || result.VerifiedPieces != 0 || !slices.Equal(result.CorruptedPieces, []int{0}) {
+		t.Errorf("Recheck() corrupted file got = %+v", result)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = f.Recheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Intact || result.VerifiedPieces != 1 {
+		t.Errorf("Recheck() intact file got = %+v", result)
+	}
+}
@@ -0,0 +1,241 @@
+package ed2k
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	ped2k "github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/monkeyWie/goed2k"
+)
+
+const (
+	searchPollInterval = time.Second
+	// maxSearches is the number of the searches kept for polling, the oldest searches are removed first
+	maxSearches = 20
+)
+
+var (
+	ErrSearchQueryRequired = errors.New("search query is required")
+	ErrInvalidSearchScope  = errors.New("invalid search scope, must be server or kad")
+	ErrSearchNotFound      = errors.New("search not found")
+	// ErrSearchRunning is returned when a search is started while another one is running, the client runs one search at a time
+	ErrSearchRunning = errors.New("another search is running")
+)
+
+// search is a keyword search started by the client, the snapshot is polled from the client until the search ends.
+type search struct {
+	id        string
+	opts      *ped2k.SearchOpts
+	handle    goed2k.SearchHandle
+	startedAt time.Time
+	snapshot  goed2k.SearchSnapshot
+}
+
+// Search starts a keyword search on the connected servers and the KAD network, the results are polled by GetSearch.
+func (fm *FetcherManager) Search(opts *ped2k.SearchOpts) (*ped2k.Search, error) {
+	client := fm.currentClient()
+	if client == nil {
+		return nil, ErrClientNotStarted
+	}
+	if opts == nil || strings.TrimSpace(opts.Query) == "" {
+		return nil, ErrSearchQueryRequired
+	}
+	var scope goed2k.SearchScope
+	switch opts.Scope {
+	case "":
+		scope = goed2k.SearchScopeAll
+	case ped2k.SearchScopeServer:
+		scope = goed2k.SearchScopeServer
+	case ped2k.SearchScopeKad:
+		scope = goed2k.SearchScopeDHT
+	default:
+		return nil, ErrInvalidSearchScope
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for _, s := range fm.searches {
+		if s.snapshot.State == goed2k.SearchStateRunning {
+			return nil, ErrSearchRunning
+		}
+	}
+	handle, err := client.StartSearch(goed2k.SearchParams{
+		Query:              opts.Query,
+		Scope:              scope,
+		MinSize:            opts.MinSize,
+		MaxSize:            opts.MaxSize,
+		MinSources:         opts.MinSources,
+		MinCompleteSources: opts.MinCompleteSources,
+		FileType:           opts.FileType,
+		Extension:          opts.Extension,
+	})
+	if err != nil {
+		return nil, err
+	}
+	id, err := gonanoid.New()
+	if err != nil {
+		return nil, err
+	}
+	s := &search{
+		id:        id,
+		opts:      opts,
+		handle:    handle,
+		startedAt: time.Now(),
+		snapshot:  handle.Snapshot(),
+	}
+	fm.searches = append(fm.searches, s)
+	if len(fm.searches) > maxSearches {
+		fm.searches = fm.searches[len(fm.searches)-maxSearches:]
+	}
+	go fm.pollSearch(s)
+	return s.toSearch(), nil
+}
+
+// GetSearch returns a search with the results found so far.
+func (fm *FetcherManager) GetSearch(id string) (*ped2k.Search, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	s := fm.findSearch(id)
+	if s == nil {
+		return nil, ErrSearchNotFound
+	}
+	return s.toSearch(), nil
+}
+
+// GetSearches returns the searches kept for polling, newest first, without the results.
+func (fm *FetcherManager) GetSearches() []*ped2k.Search {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	searches := make([]*ped2k.Search, 0, len(fm.searches))
+	for i := len(fm.searches) - 1; i >= 0; i-- {
+		search := fm.searches[i].toSearch()
+		search.Results = nil
+		searches = append(searches, search)
+	}
+	return searches
+}
+
+// DeleteSearch stops a search if it's running and removes it.
+func (fm *FetcherManager) DeleteSearch(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for i, s := range fm.searches {
+		if s.id == id {
+			if s.snapshot.State == goed2k.SearchStateRunning {
+				if err := s.handle.Stop(); err != nil {
+					return err
+				}
+			}
+			fm.searches = append(fm.searches[:i], fm.searches[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSearchNotFound
+}
+
+// pollSearch updates the snapshot of the search until it ends or it's stopped.
+func (fm *FetcherManager) pollSearch(s *search) {
+	ticker := time.NewTicker(searchPollInterval)
+	defer ticker.Stop()
+	for {
+		fm.mu.Lock()
+		running := s.snapshot.State == goed2k.SearchStateRunning
+		fm.mu.Unlock()
+		if !running {
+			return
+		}
+		<-ticker.C
+
+		snapshot := s.handle.Snapshot()
+		fm.mu.Lock()
+		switch {
+		case s.snapshot.State != goed2k.SearchStateRunning:
+			// stopped by closing the client
+		case snapshot.ID != s.handle.ID():
+			// stopped by deleting the search
+			s.snapshot.State = goed2k.SearchStateStopped
+		default:
+			s.snapshot = snapshot
+		}
+		fm.mu.Unlock()
+	}
+}
+
+func (fm *FetcherManager) findSearch(id string) *search {
+	for _, s := range fm.searches {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *search) toSearch() *ped2k.Search {
+	results := make([]*ped2k.SearchResult, 0, len(s.snapshot.Results))
+	for _, r := range s.snapshot.Results {
+		if result := toSearchResult(r); matchSearchResult(s.opts, result) {
+			results = append(results, result)
+		}
+	}
+	return &ped2k.Search{
+		ID:        s.id,
+		Opts:      s.opts,
+		State:     strings.ToLower(string(s.snapshot.State)),
+		Error:     s.snapshot.Error,
+		StartedAt: s.startedAt,
+		Results:   results,
+	}
+}
+
+func toSearchResult(r goed2k.SearchResult) *ped2k.SearchResult {
+	var from []string
+	if r.Source&goed2k.SearchResultServer != 0 {
+		from = append(from, ped2k.SearchScopeServer)
+	}
+	if r.Source&goed2k.SearchResultKAD != 0 {
+		from = append(from, ped2k.SearchScopeKad)
+	}
+	return &ped2k.SearchResult{
+		Link:            r.ED2KLink(),
+		Name:            r.FileName,
+		Size:            r.FileSize,
+		Hash:            r.Hash.String(),
+		Sources:         r.Sources,
+		CompleteSources: r.CompleteSources,
+		FileType:        r.FileType,
+		Extension:       r.Extension,
+		From:            from,
+	}
+}
+
+// matchSearchResult applies the filters of the options, the KAD network only searches by keyword,
+// and the results without a valid link can't be downloaded.
+func matchSearchResult(opts *ped2k.SearchOpts, r *ped2k.SearchResult) bool {
+	if r.Link == "" {
+		return false
+	}
+	if r.Size < opts.MinSize || (opts.MaxSize > 0 && r.Size > opts.MaxSize) {
+		return false
+	}
+	if r.Sources < opts.MinSources || r.CompleteSources < opts.MinCompleteSources {
+		return false
+	}
+	// the servers filter by the file type already, even if the type is not in the result
+	if opts.FileType != "" && !strings.EqualFold(r.FileType, opts.FileType) && (r.FileType != "" || !slices.Contains(r.From, ped2k.SearchScopeServer)) {
+		return false
+	}
+	if opts.Extension != "" {
+		ext := r.Extension
+		if ext == "" {
+			ext = filepath.Ext(r.Name)
+		}
+		if !strings.EqualFold(strings.TrimPrefix(ext, "."), strings.TrimPrefix(opts.Extension, ".")) {
+			return false
+		}
+	}
+	return true
+}
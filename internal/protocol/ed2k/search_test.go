@@ -0,0 +1,108 @@
+package ed2k
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+)
+
+func TestFetcherManager_Search(t *testing.T) {
+	fm := &FetcherManager{}
+	defer fm.Close()
+	ctl := controller.NewController()
+	ctl.GetConfig = func(v any) {
+		*(v.(**config)) = &config{DisableDHT: true, DisableUPnP: true}
+	}
+	if err := fm.Start(ctl); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fm.Search(&ed2k.SearchOpts{Query: " "}); !errors.Is(err, ErrSearchQueryRequired) {
+		t.Errorf("Search() empty query error = %v", err)
+	}
+	if _, err := fm.Search(&ed2k.SearchOpts{Query: "test", Scope: "web"}); !errors.Is(err, ErrInvalidSearchScope) {
+		t.Errorf("Search() invalid scope error = %v", err)
+	}
+
+	// no server is connected and kad is disabled
+	search, err := fm.Search(&ed2k.SearchOpts{Query: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if search.State != ed2k.SearchStateFailed || search.Error == "" {
+		t.Errorf("Search() got state %s, error %q", search.State, search.Error)
+	}
+	got, err := fm.GetSearch(search.ID)
+	if err != nil || got.ID != search.ID {
+		t.Errorf("GetSearch() got = %v, %v", got, err)
+	}
+	// a failed search doesn't block the next one
+	next, err := fm.Search(&ed2k.SearchOpts{Query: "test", Scope: ed2k.SearchScopeServer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searches := fm.GetSearches(); len(searches) != 2 || searches[0].ID != next.ID {
+		t.Errorf("GetSearches() got %d searches", len(searches))
+	}
+
+	if err := fm.DeleteSearch(search.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fm.GetSearch(search.ID); !errors.Is(err, ErrSearchNotFound) {
+		t.Errorf("GetSearch() deleted error = %v", err)
+	}
+	if err := fm.DeleteSearch(search.ID); !errors.Is(err, ErrSearchNotFound) {
+		t.Errorf("DeleteSearch() deleted error = %v", err)
+	}
+}
+
+func TestMatchSearchResult(t *testing.T) {
+	result := func() *ed2k.SearchResult {
+		return &ed2k.SearchResult{
+			Link:            "ed2k://|file|a.mp3|100|8867C5E54405FF9452225B66EFEE690A|/",
+			Name:            "a.mp3",
+			Size:            100,
+			Sources:         5,
+			CompleteSources: 2,
+			FileType:        "Audio",
+			From:            []string{ed2k.SearchScopeKad},
+		}
+	}
+	tests := []struct {
+		name   string
+		opts   *ed2k.SearchOpts
+		modify func(r *ed2k.SearchResult)
+		want   bool
+	}{
+		{"no filter", &ed2k.SearchOpts{}, nil, true},
+		{"no link", &ed2k.SearchOpts{}, func(r *ed2k.SearchResult) { r.Link = "" }, false},
+		{"size in range", &ed2k.SearchOpts{MinSize: 100, MaxSize: 100}, nil, true},
+		{"too small", &ed2k.SearchOpts{MinSize: 101}, nil, false},
+		{"too large", &ed2k.SearchOpts{MaxSize: 99}, nil, false},
+		{"sources", &ed2k.SearchOpts{MinSources: 5, MinCompleteSources: 2}, nil, true},
+		{"few sources", &ed2k.SearchOpts{MinSources: 6}, nil, false},
+		{"few complete sources", &ed2k.SearchOpts{MinCompleteSources: 3}, nil, false},
+		{"file type", &ed2k.SearchOpts{FileType: "audio"}, nil, true},
+		{"other file type", &ed2k.SearchOpts{FileType: "Video"}, nil, false},
+		{"kad without file type", &ed2k.SearchOpts{FileType: "Audio"}, func(r *ed2k.SearchResult) { r.FileType = "" }, false},
+		{"server without file type", &ed2k.SearchOpts{FileType: "Audio"}, func(r *ed2k.SearchResult) {
+			r.FileType = ""
+			r.From = []string{ed2k.SearchScopeServer}
+		}, true},
+		{"extension by name", &ed2k.SearchOpts{Extension: ".MP3"}, nil, true},
+		{"other extension", &ed2k.SearchOpts{Extension: "avi"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := result()
+			if tt.modify != nil {
+				tt.modify(r)
+			}
+			if got := matchSearchResult(tt.opts, r); got != tt.want {
+				t.Errorf("matchSearchResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
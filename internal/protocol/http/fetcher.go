@@ -32,6 +32,11 @@ const (
 	// When a connection finishes its chunk, it can "steal" work from slow connections.
 	stealThresholdSeconds = 3          // Only steal if victim needs > 3 seconds to finish
 	stealMinChunkSize     = 512 * 1024 // Min steal size: 512KB (avoid tiny chunks)
+
+	// Sequential mode parameters
+	// Each connection downloads a window right after the previous one, so the head of the file arrives first.
+	sequentialWindowSize = 4 * 1024 * 1024 // Window size: 4MB
+	streamPollInterval   = 100 * time.Millisecond
 )
 
 // ============================================================================
@@ -259,6 +264,8 @@ type Fetcher struct {
 	fileMu       sync.Mutex
 	redirectURL  string
 	redirectLock sync.Mutex
+	// headerChecksum is the checksum of the file in the response headers, used to recheck the file
+	headerChecksum string
 
 	// Lifecycle control
 	ctx    context.Context
@@ -271,6 +278,10 @@ type Fetcher struct {
 	// Resolve connection control
 	resolveCtx    context.Context
 	resolveCancel context.CancelFunc
+
+	// Streaming readers, the read position is downloaded first
+	streams   atomic.Int32
+	streamPos atomic.Int64
 }
 
 func (f *Fetcher) Setup(ctl *controller.Controller) {
@@ -330,6 +341,11 @@ func (f *Fetcher) Resolve(req *base.Request, opts *base.Options) error {
 		opts.Extra = &fhttp.OptsExtra{}
 	}
 	extra := opts.Extra.(*fhttp.OptsExtra)
+	if extra.Checksum != "" {
+		if _, _, err := parseChecksum(extra.Checksum); err != nil {
+			return err
+		}
+	}
 	if extra.Connections <= 0 {
 		extra.Connections = f.config.Connections
 		if extra.Connections <= 0 {
@@ -422,6 +438,7 @@ func (f *Fetcher) Resolve(req *base.Request, opts *base.Options) error {
 
 	// Save redirect URL for later connections
 	f.redirectURL = resp.Request.URL.String()
+	f.headerChecksum = parseHeaderChecksum(resp)
 
 	// IMPORTANT: Keep the response body open for downloading in Start phase
 	// This is crucial for one-time URLs that can only be accessed once
@@ -756,7 +773,9 @@ func (f *Fetcher) startResolveDownload() {
 			Chunk: newChunk(0, 0), // For non-range, end doesn't matter
 		}
 		conn.ctx, conn.cancel = context.WithCancel(f.ctx)
+		f.connMu.Lock()
 		f.connections = append(f.connections, conn)
+		f.connMu.Unlock()
 
 		f.wg.Add(1)
 		// Use the resolve response directly
@@ -840,16 +859,26 @@ func (f *Fetcher) expandConnections() {
 		// Find the connection with most remaining work
 		var maxRemainConn *connection
 		var maxRemain int64
+		var splitPoint int64
 
-		for _, conn := range f.connections {
-			if conn.Completed || conn.State == connFailed {
-				continue
+		if f.sequential() {
+			// Sequential mode: split right after the head so the file is downloaded in order
+			maxRemainConn, splitPoint = f.sequentialSplit(nil)
+		} else {
+			for _, conn := range f.connections {
+				if conn.Completed || conn.State == connFailed {
+					continue
+				}
+				remain := conn.Chunk.remain()
+				// Only split if remaining work is at least 2x the minimum split size
+				if remain > maxRemain && remain > minSplitSize*2 {
+					maxRemainConn = conn
+					maxRemain = remain
+				}
 			}
-			remain := conn.Chunk.remain()
-			// Only split if remaining work is at least 2x the minimum split size
-			if remain > maxRemain && remain > minSplitSize*2 {
-				maxRemainConn = conn
-				maxRemain = remain
+			if maxRemainConn != nil {
+				// Split the work: new connection takes the latter half
+				splitPoint = maxRemainConn.Chunk.End - maxRemainConn.Chunk.remain()/2
 			}
 		}
 
@@ -858,8 +887,6 @@ func (f *Fetcher) expandConnections() {
 			break
 		}
 
-		// Split the work: new connection takes the latter half
-		splitPoint := maxRemainConn.Chunk.End - maxRemainConn.Chunk.remain()/2
 		newChunk := newChunk(splitPoint+1, maxRemainConn.Chunk.End)
 		maxRemainConn.Chunk.End = splitPoint
 
@@ -1198,8 +1225,11 @@ func (f *Fetcher) runConnectionWithResolveResp(conn *connection) {
 			}
 			f.fileMu.Unlock()
 
+			// Downloaded is read under connMu by the progress and the stream readers
+			f.connMu.Lock()
 			conn.Chunk.Downloaded += int64(n)
 			conn.Downloaded += int64(n)
+			f.connMu.Unlock()
 		}
 		if err != nil {
 			if err == io.EOF {
@@ -1293,8 +1323,10 @@ func (f *Fetcher) runConnectionFallback(conn *connection) {
 					}
 					f.fileMu.Unlock()
 
+					f.connMu.Lock()
 					conn.Chunk.Downloaded += int64(n)
 					conn.Downloaded += int64(n)
+					f.connMu.Unlock()
 				}
 				if err != nil {
 					if err == io.EOF {
@@ -1379,6 +1411,18 @@ func (f *Fetcher) helpOtherConnection(helper *connection) bool {
 	f.connMu.Lock()
 	defer f.connMu.Unlock()
 
+	if f.sequential() {
+		victim, splitPoint := f.sequentialSplit(helper)
+		if victim == nil {
+			return false
+		}
+		helper.Chunk.Begin = splitPoint + 1
+		helper.Chunk.End = victim.Chunk.End
+		helper.Chunk.Downloaded = 0
+		victim.Chunk.End = splitPoint
+		return true
+	}
+
 	// Find the connection with longest remaining time
 	var slowestConn *connection
 	var maxRemainSeconds int64
@@ -1745,6 +1789,7 @@ func (f *Fetcher) Stats() any {
 	}
 	return &fhttp.Stats{
 		Connections: statsConnections,
+		Checksum:    f.checksum(),
 	}
 }
 
@@ -16,6 +16,7 @@ import (
 type fetcherData struct {
 	Connections []*connection
 	RedirectURL string // Saved redirect URL for resume
+	Checksum    string // Checksum in the response headers for recheck
 }
 
 // ============================================================================
@@ -78,6 +79,7 @@ func (fm *FetcherManager) Store(f fetcher.Fetcher) (data any, err error) {
 	return &fetcherData{
 		Connections: _f.connections,
 		RedirectURL: redirectURL,
+		Checksum:    _f.headerChecksum,
 	}, nil
 }
 
@@ -96,6 +98,7 @@ func (fm *FetcherManager) Restore() (v any, f func(meta *fetcher.FetcherMeta, v
 		if fd.RedirectURL != "" {
 			fetcher.redirectURL = fd.RedirectURL
 		}
+		fetcher.headerChecksum = fd.Checksum
 		return fetcher
 	}
 }
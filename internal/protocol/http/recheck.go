@@ -0,0 +1,194 @@
+package http
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	fhttp "github.com/GopeedLab/gopeed/pkg/protocol/http"
+)
+
+var (
+	ErrInvalidChecksum = errors.New("invalid checksum, must be algorithm:hex and the algorithm is md5, sha1, sha256 or sha512")
+	ErrNotDownloaded   = errors.New("the file is not downloaded completely, it can only be verified as a whole")
+)
+
+// checksumAlgorithms are the supported algorithms by preference, the digest names are the names in the Digest header
+var checksumAlgorithms = []struct {
+	name   string
+	digest string
+	new    func() hash.Hash
+}{
+	{"sha512", "sha-512", sha512.New},
+	{"sha256", "sha-256", sha256.New},
+	{"sha1", "sha", sha1.New},
+	{"md5", "md5", md5.New},
+}
+
+// md5ETag matches a strong ETag that is the md5 of the content, e.g. the ETag of a single part upload of S3
+var md5ETag = regexp.MustCompile(`^"([0-9a-fA-F]{32})"$`)
+
+// Recheck hashes the file by the checksum of the options or the response headers, the file can only be verified
+// as a whole, so a damaged file is downloaded again from the start.
+func (f *Fetcher) Recheck() (*base.Recheck, error) {
+	checksum := f.checksum()
+	if checksum == "" {
+		return nil, fmt.Errorf("%w: no checksum of the file", fetcher.ErrRecheckNotSupported)
+	}
+	newHash, want, err := parseChecksum(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if !f.downloaded() {
+		return nil, ErrNotDownloaded
+	}
+
+	result := &base.Recheck{
+		Time:            time.Now(),
+		Pieces:          1,
+		CorruptedPieces: make([]int, 0),
+	}
+	file, err := os.Open(f.meta.SingleFilepath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if file != nil {
+		defer file.Close()
+		h := newHash()
+		size, err := io.Copy(h, file)
+		if err != nil {
+			return nil, err
+		}
+		if hex.EncodeToString(h.Sum(nil)) == want {
+			result.VerifiedPieces = 1
+			result.VerifiedBytes = size
+			result.Intact = true
+			return result, nil
+		}
+	}
+	result.CorruptedPieces = append(result.CorruptedPieces, 0)
+
+	// download the file again on the next start
+	f.connMu.Lock()
+	f.connections = nil
+	f.resolveConn = nil
+	f.connMu.Unlock()
+	f.resolveDataPos.Store(0)
+	select {
+	case <-f.doneCh:
+	default:
+	}
+	f.setState(stateResolved)
+	return result, nil
+}
+
+// downloaded returns true if all the data is downloaded.
+func (f *Fetcher) downloaded() bool {
+	if f.getState() == stateDone {
+		return true
+	}
+	f.connMu.Lock()
+	defer f.connMu.Unlock()
+	if len(f.connections) == 0 {
+		return false
+	}
+	var total int64
+	completed := true
+	for _, conn := range f.connections {
+		total += conn.Downloaded
+		completed = completed && conn.Completed
+	}
+	if f.meta.Res.Size > 0 {
+		return total >= f.meta.Res.Size
+	}
+	return completed
+}
+
+// checksum returns the checksum of the options, or the checksum in the response headers.
+func (f *Fetcher) checksum() string {
+	if f.meta.Opts != nil {
+		if extra, ok := f.meta.Opts.Extra.(*fhttp.OptsExtra); ok && extra.Checksum != "" {
+			return extra.Checksum
+		}
+	}
+	return f.headerChecksum
+}
+
+// parseChecksum parses a checksum in the format of algorithm:hex.
+func parseChecksum(checksum string) (newHash func() hash.Hash, want string, err error) {
+	name, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return nil, "", ErrInvalidChecksum
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, algorithm := range checksumAlgorithms {
+		if algorithm.name == name {
+			if _, err := hex.DecodeString(value); err != nil || len(value) != algorithm.new().Size()*2 {
+				return nil, "", ErrInvalidChecksum
+			}
+			return algorithm.new, value, nil
+		}
+	}
+	return nil, "", ErrInvalidChecksum
+}
+
+// parseHeaderChecksum finds the checksum of the full content in the Repr-Digest, Digest, Content-MD5 and ETag headers
+// of a response, the strongest algorithm is preferred. An encoded content is not the file, so it has no checksum.
+func parseHeaderChecksum(resp *http.Response) string {
+	if resp.StatusCode != base.HttpCodeOK {
+		return ""
+	}
+	if encoding := resp.Header.Get(base.HttpHeaderContentEncoding); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return ""
+	}
+
+	digests := make(map[string][]byte)
+	// Repr-Digest: sha-256=:base64:, Digest: sha-256=base64
+	for _, header := range []string{base.HttpHeaderReprDigest, base.HttpHeaderDigest} {
+		for _, value := range resp.Header.Values(header) {
+			for _, item := range strings.Split(value, ",") {
+				name, encoded, ok := strings.Cut(strings.TrimSpace(item), "=")
+				if !ok {
+					continue
+				}
+				name = strings.ToLower(strings.TrimSpace(name))
+				if _, exist := digests[name]; exist {
+					continue
+				}
+				if sum, err := base64.StdEncoding.DecodeString(strings.Trim(strings.TrimSpace(encoded), ":")); err == nil {
+					digests[name] = sum
+				}
+			}
+		}
+	}
+	if _, exist := digests["md5"]; !exist {
+		if sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(resp.Header.Get(base.HttpHeaderContentMD5))); err == nil && len(sum) > 0 {
+			digests["md5"] = sum
+		} else if match := md5ETag.FindStringSubmatch(resp.Header.Get(base.HttpHeaderETag)); match != nil {
+			sum, _ = hex.DecodeString(match[1])
+			digests["md5"] = sum
+		}
+	}
+
+	for _, algorithm := range checksumAlgorithms {
+		if sum, ok := digests[algorithm.digest]; ok && len(sum) == algorithm.new().Size() {
+			return algorithm.name + ":" + hex.EncodeToString(sum)
+		}
+	}
+	return ""
+}
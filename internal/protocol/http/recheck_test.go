@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseHeaderChecksum(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   string
+	}{
+		{"no checksum", 200, nil, ""},
+		{"digest", 200, map[string]string{"Digest": "MD5=kAFQmDzST7DWlj99KOF/cg==, SHA-256=n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="},
+			"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{"repr digest", 200, map[string]string{"Repr-Digest": "sha-256=:n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg=:"},
+			"sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{"content md5", 200, map[string]string{"Content-MD5": "CY9rzUYh03PK3k6DJie09g=="}, "md5:098f6bcd4621d373cade4e832627b4f6"},
+		{"md5 etag", 200, map[string]string{"ETag": `"098f6bcd4621d373cade4e832627b4f6"`}, "md5:098f6bcd4621d373cade4e832627b4f6"},
+		{"weak etag", 200, map[string]string{"ETag": `W/"098f6bcd4621d373cade4e832627b4f6"`}, ""},
+		{"multipart etag", 200, map[string]string{"ETag": `"098f6bcd4621d373cade4e832627b4f6-2"`}, ""},
+		{"partial content", 206, map[string]string{"Content-MD5": "CY9rzUYh03PK3k6DJie09g=="}, ""},
+		{"encoded content", 200, map[string]string{"Content-MD5": "CY9rzUYh03PK3k6DJie09g==", "Content-Encoding": "gzip"}, ""},
+		{"invalid digest", 200, map[string]string{"Digest": "sha-256=invalid"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+			for k, v := range tt.header {
+				resp.Header.Set(k, v)
+			}
+			if got := parseHeaderChecksum(resp); got != tt.want {
+				t.Errorf("parseHeaderChecksum() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		checksum string
+		wantErr  bool
+	}{
+		{"md5:098f6bcd4621d373cade4e832627b4f6", false},
+		{"SHA256:9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08", false},
+		{"098f6bcd4621d373cade4e832627b4f6", true},
+		{"crc32:d87f7e0c", true},
+		{"sha1:098f6bcd4621d373cade4e832627b4f6", true},
+		{"md5:not-hex", true},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseChecksum(tt.checksum); (err != nil) != tt.wantErr {
+			t.Errorf("parseChecksum(%q) error = %v, wantErr %v", tt.checksum, err, tt.wantErr)
+		}
+	}
+}
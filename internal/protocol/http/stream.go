@@ -0,0 +1,190 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	fhttp "github.com/GopeedLab/gopeed/pkg/protocol/http"
+)
+
+// sequential returns whether the chunks are allocated from the head of the file,
+// it's enabled by the Sequential option or while the file is streamed.
+func (f *Fetcher) sequential() bool {
+	if f.streams.Load() > 0 {
+		return true
+	}
+	if f.meta.Opts == nil {
+		return false
+	}
+	extra, ok := f.meta.Opts.Extra.(*fhttp.OptsExtra)
+	return ok && extra.Sequential
+}
+
+// sequentialSplit picks the connection to split in sequential mode, the connection keeps the data up to the
+// returned split point. The remaining data nearest to the stream position is split first, a connection
+// far behind the stream position is split at the stream position directly. Must hold connMu.
+func (f *Fetcher) sequentialSplit(helper *connection) (*connection, int64) {
+	var focus int64
+	if f.streams.Load() > 0 {
+		focus = f.streamPos.Load()
+	}
+
+	var victim *connection
+	var victimPos int64
+	victimBehind := false
+	for _, conn := range f.connections {
+		if conn == helper || conn.Completed || conn.State == connFailed {
+			continue
+		}
+		if conn.Chunk.remain() < sequentialWindowSize*2 {
+			continue
+		}
+		pos := conn.Chunk.Begin + conn.Chunk.Downloaded
+		if focus > pos+sequentialWindowSize && focus <= conn.Chunk.End-sequentialWindowSize {
+			return conn, focus - 1
+		}
+		// Prefer the data after the stream position, then the data nearest to the head
+		behind := conn.Chunk.End < focus
+		if victim == nil || (victimBehind && !behind) || (victimBehind == behind && pos < victimPos) {
+			victim = conn
+			victimPos = pos
+			victimBehind = behind
+		}
+	}
+	if victim == nil {
+		return nil, 0
+	}
+	return victim, victimPos + sequentialWindowSize - 1
+}
+
+// available returns how many bytes are downloaded continuously from the offset.
+func (f *Fetcher) available(offset int64) int64 {
+	size := f.meta.Res.Size
+	if f.getState() == stateDone {
+		return size - offset
+	}
+
+	f.connMu.Lock()
+	defer f.connMu.Unlock()
+
+	if len(f.connections) == 0 {
+		return 0
+	}
+	if !f.meta.Res.Range {
+		// Without range support, the only connection downloads from the head
+		return max(f.connections[0].Downloaded-offset, 0)
+	}
+	// The data not downloaded yet is exactly the remaining chunks of the connections
+	end := size
+	for _, conn := range f.connections {
+		if conn.Chunk == nil || conn.Chunk.remain() <= 0 {
+			continue
+		}
+		begin := conn.Chunk.Begin + conn.Chunk.Downloaded
+		if offset >= begin && offset <= conn.Chunk.End {
+			return 0
+		}
+		if begin > offset && begin < end {
+			end = begin
+		}
+	}
+	return end - offset
+}
+
+// Stream opens a reader of the downloading file, the chunks are allocated sequentially from the read position
+// until the reader is closed.
+func (f *Fetcher) Stream(ctx context.Context, fileIndex int) (io.ReadSeekCloser, error) {
+	if fileIndex != 0 || f.meta.Res == nil || f.meta.Res.Size <= 0 {
+		return nil, fetcher.ErrStreamNotSupported
+	}
+	f.streams.Add(1)
+	return &streamReader{
+		f:    f,
+		ctx:  ctx,
+		size: f.meta.Res.Size,
+	}, nil
+}
+
+type streamReader struct {
+	f      *Fetcher
+	ctx    context.Context
+	file   *os.File
+	pos    int64
+	size   int64
+	closed bool
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	r.f.streamPos.Store(r.pos)
+
+	for {
+		if n := r.f.available(r.pos); n > 0 {
+			if r.file == nil {
+				file, err := os.Open(r.f.meta.SingleFilepath())
+				if err != nil {
+					return 0, err
+				}
+				r.file = file
+			}
+			if int64(len(p)) > n {
+				p = p[:n]
+			}
+			read, err := r.file.ReadAt(p, r.pos)
+			r.pos += int64(read)
+			if err == io.EOF && read > 0 {
+				err = nil
+			}
+			return read, err
+		}
+		if r.f.getState() == stateError {
+			return 0, errors.New("download failed")
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-time.After(streamPollInterval):
+		}
+	}
+}
+
+func (r *streamReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *streamReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.f.streams.Add(-1)
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
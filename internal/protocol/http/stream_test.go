@@ -0,0 +1,216 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/internal/test"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/http"
+)
+
+func TestFetcher_SequentialSplit(t *testing.T) {
+	const w = sequentialWindowSize
+	f := &Fetcher{meta: &fetcher.FetcherMeta{
+		Res:  &base.Resource{Size: 100 * w, Range: true},
+		Opts: &base.Options{Extra: &http.OptsExtra{Sequential: true}},
+	}}
+	head := &connection{ID: 0, Chunk: &chunk{Begin: 0, End: 50*w - 1, Downloaded: w}}
+	tail := &connection{ID: 1, Chunk: &chunk{Begin: 50 * w, End: 100*w - 1}}
+	f.connections = []*connection{tail, head}
+
+	victim, splitPoint := f.sequentialSplit(nil)
+	if victim != head || splitPoint != 2*w-1 {
+		t.Errorf("sequentialSplit() got = %d, %d, want head window", victim.ID, splitPoint)
+	}
+	victim, _ = f.sequentialSplit(head)
+	if victim != tail {
+		t.Errorf("sequentialSplit() should skip the helper, got = %d", victim.ID)
+	}
+
+	// the stream position far ahead of a connection starts a new range from it
+	f.streams.Add(1)
+	f.streamPos.Store(20 * w)
+	victim, splitPoint = f.sequentialSplit(nil)
+	if victim != head || splitPoint != 20*w-1 {
+		t.Errorf("sequentialSplit() stream position got = %d, %d", victim.ID, splitPoint)
+	}
+	// the data after the stream position is preferred
+	f.streamPos.Store(60 * w)
+	head.Chunk.End = 10*w - 1
+	victim, _ = f.sequentialSplit(nil)
+	if victim != tail {
+		t.Errorf("sequentialSplit() should prefer the data after the stream position, got = %d", victim.ID)
+	}
+	f.streams.Add(-1)
+
+	f.meta.Opts.Extra = &http.OptsExtra{}
+	if f.sequential() {
+		t.Error("sequential() should be disabled without the option or a stream")
+	}
+}
+
+func TestFetcher_Available(t *testing.T) {
+	f := &Fetcher{meta: &fetcher.FetcherMeta{Res: &base.Resource{Size: 100, Range: true}}}
+	if got := f.available(0); got != 0 {
+		t.Errorf("available() before start got = %d, want 0", got)
+	}
+	f.connections = []*connection{
+		{Chunk: &chunk{Begin: 10, End: 49, Downloaded: 20}},
+		{Chunk: &chunk{Begin: 50, End: 79, Downloaded: 30}},
+		{Chunk: &chunk{Begin: 80, End: 99, Downloaded: 5}},
+	}
+	tests := []struct {
+		offset int64
+		want   int64
+	}{
+		{0, 30},
+		{29, 1},
+		{30, 0},
+		{49, 0},
+		{50, 35},
+		{84, 1},
+		{85, 0},
+	}
+	for _, tt := range tests {
+		if got := f.available(tt.offset); got != tt.want {
+			t.Errorf("available(%d) got = %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+
+	f.setState(stateDone)
+	if got := f.available(30); got != 70 {
+		t.Errorf("available() done got = %d, want 70", got)
+	}
+}
+
+func TestFetcher_DownloadSequential(t *testing.T) {
+	listener := test.StartTestFileServer()
+	defer listener.Close()
+
+	f := buildFetcher()
+	f.Resolve(&base.Request{
+		URL: "http://" + listener.Addr().String() + "/" + test.BuildName,
+	}, &base.Options{
+		Name:  test.DownloadName,
+		Path:  test.Dir,
+		Extra: &http.OptsExtra{Connections: 4, Sequential: true},
+	})
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	want := test.FileMd5(test.BuildFile)
+	got := test.FileMd5(test.DownloadFile)
+	if want != got {
+		t.Errorf("Download() got = %v, want %v", got, want)
+	}
+}
+
+func TestFetcher_Stream(t *testing.T) {
+	listener := test.StartTestLowSpeedServer(time.Nanosecond)
+	defer listener.Close()
+
+	f := downloadReady(listener, 4, t).(*Fetcher)
+	if _, err := f.Stream(context.Background(), 1); err != fetcher.ErrStreamNotSupported {
+		t.Errorf("Stream() invalid file got = %v, want %v", err, fetcher.ErrStreamNotSupported)
+	}
+	reader, err := f.Stream(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	offset := int64(test.BuildSize / 2)
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, 1024)
+	if _, err := io.ReadFull(reader, got); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, len(got))
+	file, err := os.Open(test.BuildFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.ReadAt(want, offset); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("Stream() read at the middle got unexpected data")
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	hash := md5.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != test.FileMd5(test.BuildFile) {
+		t.Errorf("Stream() whole file got = %v, want %v", got, test.FileMd5(test.BuildFile))
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if f.streams.Load() != 0 {
+		t.Errorf("Stream() close should release the stream, got %d", f.streams.Load())
+	}
+	if err := f.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetcher_StreamNoRange(t *testing.T) {
+	listener := test.StartTestNoRangeSlowServer(time.Millisecond)
+	defer listener.Close()
+
+	f := downloadReady(listener, 4, t).(*Fetcher)
+	reader, err := f.Stream(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the only connection downloads from the head, the reads follow it
+	hash := md5.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != test.FileMd5(test.BuildFile) {
+		t.Errorf("Stream() whole file got = %v, want %v", got, test.FileMd5(test.BuildFile))
+	}
+	if err := f.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetcher_StreamCancel(t *testing.T) {
+	f := &Fetcher{meta: &fetcher.FetcherMeta{Res: &base.Resource{Size: 100, Range: true}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	reader, err := f.Stream(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.Read(make([]byte, 10)); err != context.DeadlineExceeded {
+		t.Errorf("Read() should block until the ctx is done, got = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package base
+
+import (
+	"slices"
+	"time"
+)
+
+// AccessScope is a permission granted to an access token or user.
+type AccessScope string
+
+const (
+	// AccessScopeRead allows reading tasks and server info
+	AccessScopeRead AccessScope = "read"
+	// AccessScopeCreate allows resolving and creating tasks
+	AccessScopeCreate AccessScope = "create"
+	// AccessScopeManage allows patching, pausing, continuing and deleting tasks
+	AccessScopeManage AccessScope = "manage"
+	// AccessScopeConfig allows reading and changing the downloader config
+	AccessScopeConfig AccessScope = "config"
+	// AccessScopeExtension allows installing, configuring and removing extensions
+	AccessScopeExtension AccessScope = "extension"
+	// AccessScopeAdmin includes all scopes and allows managing access tokens and users
+	AccessScopeAdmin AccessScope = "admin"
+)
+
+// AccessToken is a named API token, only the sha256 hash of the token is stored.
+type AccessToken struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Hint is the beginning of the token, used to identify a token without revealing it
+	Hint         string        `json:"hint"`
+	Hash         string        `json:"hash,omitempty"`
+	Scopes       []AccessScope `json:"scopes"`
+	DownloadDirs []string      `json:"downloadDirs"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// AccessUser is a web login user.
+type AccessUser struct {
+	Username string `json:"username"`
+	// Password is stored as a bcrypt hash, a plain password is hashed when the user is put
+	Password     string        `json:"password,omitempty"`
+	Scopes       []AccessScope `json:"scopes"`
+	DownloadDirs []string      `json:"downloadDirs"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// Principal is the authenticated identity of an API request.
+type Principal struct {
+	// Name is the token name or the username
+	Name   string        `json:"name"`
+	Kind   PrincipalKind `json:"kind"`
+	Scopes []AccessScope `json:"scopes"`
+	// DownloadDirs restricts the download directories like DownloaderConfig.WhiteDownloadDirs, empty means no restriction
+	DownloadDirs []string `json:"downloadDirs"`
+}
+
+type PrincipalKind string
+
+const (
+	// PrincipalKindAdmin is the legacy single api token, web auth user or an unauthenticated local server
+	PrincipalKindAdmin PrincipalKind = "admin"
+	PrincipalKindToken PrincipalKind = "token"
+	PrincipalKindUser  PrincipalKind = "user"
+)
+
+// AdminPrincipal returns a principal with all permissions.
+func AdminPrincipal(name string) *Principal {
+	return &Principal{
+		Name:   name,
+		Kind:   PrincipalKindAdmin,
+		Scopes: []AccessScope{AccessScopeAdmin},
+	}
+}
+
+func (p *Principal) HasScope(scope AccessScope) bool {
+	return slices.Contains(p.Scopes, AccessScopeAdmin) || slices.Contains(p.Scopes, scope)
+}
+
+// AuditEntry is a record of a state-changing API call.
+type AuditEntry struct {
+	ID            string        `json:"id"`
+	Time          time.Time     `json:"time"`
+	Principal     string        `json:"principal"`
+	PrincipalKind PrincipalKind `json:"principalKind"`
+	IP            string        `json:"ip"`
+	Method        string        `json:"method"`
+	// Route is the route template, e.g. /api/v1/tasks/{id}
+	Route string `json:"route"`
+	Path  string `json:"path"`
+	// Params is a summary of the path variables, query and body, sensitive values are redacted
+	Params map[string]any `json:"params,omitempty"`
+	// Status is the http status code of the response
+	Status int `json:"status"`
+	// Code is the result code of the response
+	Code int `json:"code"`
+}
+
+// AuditPage is a page of audit entries ordered from newest to oldest.
+type AuditPage struct {
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	Entries  []*AuditEntry `json:"entries"`
+}
@@ -23,6 +23,11 @@ const (
 	HttpHeaderContentDisposition = "Content-Disposition"
 	HttpHeaderUserAgent          = "User-Agent"
 	HttpHeaderLastModified       = "Last-Modified"
+	HttpHeaderContentEncoding    = "Content-Encoding"
+	HttpHeaderDigest             = "Digest"
+	HttpHeaderReprDigest         = "Repr-Digest"
+	HttpHeaderContentMD5         = "Content-MD5"
+	HttpHeaderETag               = "ETag"
 
 	HttpHeaderBytes       = "bytes"
 	HttpHeaderRangeFormat = "bytes=%d-%d"
@@ -0,0 +1,31 @@
+package base
+
+// ExtensionIndex is a json catalog of extension packages, it can be hosted as a static file to install and
+// upgrade extensions without git access.
+type ExtensionIndex struct {
+	Extensions []*ExtensionIndexEntry `json:"extensions"`
+}
+
+type ExtensionIndexEntry struct {
+	// Identity is the identity of the extension, author@name or name
+	Identity    string                   `json:"identity"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description"`
+	Versions    []*ExtensionIndexVersion `json:"versions"`
+}
+
+type ExtensionIndexVersion struct {
+	Version string `json:"version"`
+	// URL is the .zip or .tgz package of the version, a relative url is resolved against the index url
+	URL string `json:"url"`
+	// Checksum is the checksum of the package in the format of algorithm:hex, the algorithm is sha256 or sha512
+	Checksum string `json:"checksum"`
+}
+
+// ExtensionLimits overrides the global extension execution limits for an extension, 0 means using the global limit.
+type ExtensionLimits struct {
+	// HookTimeout is the max seconds to run a hook, -1 means no timeout
+	HookTimeout int `json:"hookTimeout"`
+	// MaxConcurrentFetches is the max open fetch requests of the extension across its running hooks, -1 means unlimited
+	MaxConcurrentFetches int `json:"maxConcurrentFetches"`
+}
@@ -0,0 +1,61 @@
+package base
+
+import "time"
+
+// Feed is a RSS or Atom feed subscription, new items matching one of the rules are downloaded.
+type Feed struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Interval is the poll interval in seconds, defaults to 30 minutes
+	Interval int         `json:"interval"`
+	Disabled bool        `json:"disabled"`
+	Rules    []*FeedRule `json:"rules"`
+	// LastPoll is the time of the last poll, LastError is its error, empty if it succeeded
+	LastPoll  time.Time `json:"lastPoll"`
+	LastError string    `json:"lastError"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// FeedRule selects the feed items to download, the first matched rule of a feed is used.
+type FeedRule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+	// Include and Exclude are regular expressions matched against the item title case-insensitively,
+	// an empty Include matches all items
+	Include string `json:"include"`
+	Exclude string `json:"exclude"`
+	// MinSize and MaxSize limit the item size in bytes, 0 means no limit, items of unknown size are not limited
+	MinSize int64 `json:"minSize"`
+	MaxSize int64 `json:"maxSize"`
+	// DedupEpisodes downloads each episode once, the episode is detected from the title, e.g. S01E02 or 1x02
+	DedupEpisodes bool `json:"dedupEpisodes"`
+	// Path is the download directory, empty means the default download directory
+	Path string `json:"path"`
+	// Labels are set to the request of the created tasks
+	Labels map[string]string `json:"labels"`
+	// Paused leaves the created tasks ready to be continued manually instead of starting or queueing them
+	Paused bool `json:"paused"`
+}
+
+// FeedItem is a seen item of a feed, items are only processed the first time they are seen,
+// except a failed task creation which is retried on the next polls a few times.
+type FeedItem struct {
+	FeedID string `json:"feedId"`
+	// Key identifies the item in the feed, it's the guid, the link or the title of the item
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Size        int64     `json:"size"`
+	Episode     string    `json:"episode"`
+	PublishedAt time.Time `json:"publishedAt"`
+	SeenAt      time.Time `json:"seenAt"`
+	// RuleID is the matched rule, empty if no rule matched
+	RuleID string `json:"ruleId"`
+	// TaskID is the created task, Error is set if the task creation failed
+	TaskID string `json:"taskId"`
+	Error  string `json:"error"`
+	// Attempts is the number of times the task creation was tried
+	Attempts int `json:"attempts"`
+}
@@ -193,6 +193,20 @@ type CreateTaskBatchItem struct {
 	Opts *Options `json:"opts"`
 }
 
+// Recheck is the result of verifying the downloaded data of a task, the pieces are the bt pieces,
+// the ed2k parts, or the whole file for http.
+type Recheck struct {
+	Time   time.Time `json:"time"`
+	Pieces int       `json:"pieces"`
+	// VerifiedPieces and VerifiedBytes are the pieces matching the hashes
+	VerifiedPieces int   `json:"verifiedPieces"`
+	VerifiedBytes  int64 `json:"verifiedBytes"`
+	// CorruptedPieces are the indexes of the downloaded pieces not matching the hashes, they are downloaded again
+	CorruptedPieces []int `json:"corruptedPieces"`
+	// Intact is true if all the pieces are verified
+	Intact bool `json:"intact"`
+}
+
 // DownloaderStoreConfig is the config that can restore the downloader.
 type DownloaderStoreConfig struct {
 	FirstLoad bool `json:"-"` // FirstLoad is the flag that the config is first time init and not from store
@@ -207,6 +221,8 @@ type DownloaderStoreConfig struct {
 	AutoTorrent                *AutoTorrentConfig     `json:"autoTorrent"`                // AutoTorrent is the auto torrent task creation configuration
 	Archive                    *ArchiveConfig         `json:"archive"`                    // Archive is the archive extraction configuration
 	AutoDeleteMissingFileTasks bool                   `json:"autoDeleteMissingFileTasks"` // AutoDeleteMissingFileTasks enables automatic deletion of tasks with missing files
+	Audit                      *AuditConfig           `json:"audit"`                      // Audit is the audit log retention configuration
+	Extension                  *ExtensionConfig       `json:"extension"`                  // Extension is the extension execution limits configuration
 }
 
 func (cfg *DownloaderStoreConfig) Init() *DownloaderStoreConfig {
@@ -237,6 +253,18 @@ func (cfg *DownloaderStoreConfig) Init() *DownloaderStoreConfig {
 			DeleteAfterExtract: false,
 		}
 	}
+	if cfg.Audit == nil {
+		cfg.Audit = &AuditConfig{
+			RetentionDays: 90,
+			MaxEntries:    10000,
+		}
+	}
+	if cfg.Extension == nil {
+		cfg.Extension = &ExtensionConfig{
+			HookTimeout:          30,
+			MaxConcurrentFetches: 8,
+		}
+	}
 	return cfg
 }
 
@@ -271,6 +299,12 @@ func (cfg *DownloaderStoreConfig) Merge(beforeCfg *DownloaderStoreConfig) *Downl
 	if cfg.Archive == nil {
 		cfg.Archive = beforeCfg.Archive
 	}
+	if cfg.Audit == nil {
+		cfg.Audit = beforeCfg.Audit
+	}
+	if cfg.Extension == nil {
+		cfg.Extension = beforeCfg.Extension
+	}
 	return cfg
 }
 
@@ -298,6 +332,39 @@ type ArchiveConfig struct {
 	DeleteAfterExtract bool `json:"deleteAfterExtract"` // DeleteAfterExtract deletes the archive after successful extraction
 }
 
+// AuditConfig is the audit log retention configuration
+type AuditConfig struct {
+	RetentionDays int `json:"retentionDays"` // RetentionDays is how long audit entries are kept, 0 means forever
+	MaxEntries    int `json:"maxEntries"`    // MaxEntries is the max number of kept audit entries, 0 means unlimited
+}
+
+// ExtensionConfig is the extension execution limits configuration, an extension can override them
+type ExtensionConfig struct {
+	HookTimeout          int `json:"hookTimeout"`          // HookTimeout is the max seconds to run an extension hook, 0 means no timeout
+	MaxConcurrentFetches int `json:"maxConcurrentFetches"` // MaxConcurrentFetches is the max open fetch requests of an extension across its running hooks, 0 means unlimited
+	ProgressInterval     int `json:"progressInterval"`     // ProgressInterval is the min seconds between two onProgress hooks of a task, 0 means 5 seconds
+	// IndexURL is the url or the local path of the extension index, a json catalog to install and upgrade
+	// extensions without git access, empty means no index
+	IndexURL string `json:"indexUrl"`
+	// SignaturePolicy is how to handle a package that isn't signed by a trusted publisher key, empty means allowUnsigned
+	SignaturePolicy ExtensionSignaturePolicy `json:"signaturePolicy"`
+	// TrustedKeys are the base64 encoded ed25519 public keys of the trusted extension publishers
+	TrustedKeys []string `json:"trustedKeys"`
+}
+
+// ExtensionSignaturePolicy is how to handle an extension package that isn't signed by a trusted publisher key,
+// a package with an invalid signature is always rejected.
+type ExtensionSignaturePolicy string
+
+const (
+	// ExtensionSignatureAllowUnsigned installs the package silently
+	ExtensionSignatureAllowUnsigned ExtensionSignaturePolicy = "allowUnsigned"
+	// ExtensionSignatureWarn installs the package with a warning
+	ExtensionSignatureWarn ExtensionSignaturePolicy = "warn"
+	// ExtensionSignatureDeny rejects the package, and blocks the installed extensions that are not trusted any more
+	ExtensionSignatureDeny ExtensionSignaturePolicy = "deny"
+)
+
 type DownloaderProxyConfig struct {
 	Enable bool `json:"enable"`
 	// System is the flag that use system proxy
@@ -28,6 +28,14 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        false,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
 			},
 		},
 		{
@@ -49,6 +57,14 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        false,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
 			},
 		},
 		{
@@ -74,6 +90,14 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        false,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
 			},
 		},
 		{
@@ -99,6 +123,14 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        false,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
 			},
 		},
 		{
@@ -123,6 +155,46 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        false,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
+			},
+		},
+		{
+			"Init Audit",
+			&DownloaderStoreConfig{
+				Audit: &AuditConfig{
+					RetentionDays: 0,
+					MaxEntries:    100,
+				},
+			},
+			&DownloaderStoreConfig{
+				MaxRunning:     5,
+				ProtocolConfig: map[string]any{},
+				Proxy:          &DownloaderProxyConfig{},
+				Webhook:        &WebhookConfig{},
+				Script:         &ScriptConfig{},
+				AutoTorrent: &AutoTorrentConfig{
+					Enable:              false,
+					DeleteAfterDownload: false,
+				},
+				Archive: &ArchiveConfig{
+					AutoExtract:        false,
+					DeleteAfterExtract: false,
+				},
+				Audit: &AuditConfig{
+					RetentionDays: 0,
+					MaxEntries:    100,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
 			},
 		},
 		{
@@ -132,6 +204,10 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        true,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
 			},
 			&DownloaderStoreConfig{
 				MaxRunning:     5,
@@ -147,6 +223,44 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 					AutoExtract:        true,
 					DeleteAfterExtract: false,
 				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
+			},
+		},
+		{
+			"Init Extension",
+			&DownloaderStoreConfig{
+				Extension: &ExtensionConfig{
+					HookTimeout: 0,
+				},
+			},
+			&DownloaderStoreConfig{
+				MaxRunning:     5,
+				ProtocolConfig: map[string]any{},
+				Proxy:          &DownloaderProxyConfig{},
+				Webhook:        &WebhookConfig{},
+				Script:         &ScriptConfig{},
+				AutoTorrent: &AutoTorrentConfig{
+					Enable:              false,
+					DeleteAfterDownload: false,
+				},
+				Archive: &ArchiveConfig{
+					AutoExtract:        false,
+					DeleteAfterExtract: false,
+				},
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+				Extension: &ExtensionConfig{
+					HookTimeout: 0,
+				},
 			},
 		},
 	}
@@ -163,6 +277,8 @@ func TestDownloaderStoreConfig_Init(t *testing.T) {
 				Script:         tt.fields.Script,
 				AutoTorrent:    tt.fields.AutoTorrent,
 				Archive:        tt.fields.Archive,
+				Audit:          tt.fields.Audit,
+				Extension:      tt.fields.Extension,
 			}
 			if got := cfg.Init(); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Init() = %v, want %v", got, tt.want)
@@ -415,6 +531,84 @@ func TestDownloaderStoreConfig_Merge(t *testing.T) {
 				},
 			},
 		},
+		{
+			"Merge Audit No Override",
+			&DownloaderStoreConfig{
+				Audit: &AuditConfig{
+					MaxEntries: 100,
+				},
+			},
+			args{
+				beforeCfg: &DownloaderStoreConfig{
+					Audit: &AuditConfig{
+						RetentionDays: 90,
+						MaxEntries:    10000,
+					},
+				},
+			},
+			&DownloaderStoreConfig{
+				Audit: &AuditConfig{
+					MaxEntries: 100,
+				},
+			},
+		},
+		{
+			"Merge Audit Override",
+			&DownloaderStoreConfig{},
+			args{
+				beforeCfg: &DownloaderStoreConfig{
+					Audit: &AuditConfig{
+						RetentionDays: 90,
+						MaxEntries:    10000,
+					},
+				},
+			},
+			&DownloaderStoreConfig{
+				Audit: &AuditConfig{
+					RetentionDays: 90,
+					MaxEntries:    10000,
+				},
+			},
+		},
+		{
+			"Merge Extension No Override",
+			&DownloaderStoreConfig{
+				Extension: &ExtensionConfig{
+					HookTimeout: 10,
+				},
+			},
+			args{
+				beforeCfg: &DownloaderStoreConfig{
+					Extension: &ExtensionConfig{
+						HookTimeout:          30,
+						MaxConcurrentFetches: 8,
+					},
+				},
+			},
+			&DownloaderStoreConfig{
+				Extension: &ExtensionConfig{
+					HookTimeout: 10,
+				},
+			},
+		},
+		{
+			"Merge Extension Override",
+			&DownloaderStoreConfig{},
+			args{
+				beforeCfg: &DownloaderStoreConfig{
+					Extension: &ExtensionConfig{
+						HookTimeout:          30,
+						MaxConcurrentFetches: 8,
+					},
+				},
+			},
+			&DownloaderStoreConfig{
+				Extension: &ExtensionConfig{
+					HookTimeout:          30,
+					MaxConcurrentFetches: 8,
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -428,6 +622,8 @@ func TestDownloaderStoreConfig_Merge(t *testing.T) {
 				Webhook:        tt.fields.Webhook,
 				AutoTorrent:    tt.fields.AutoTorrent,
 				Archive:        tt.fields.Archive,
+				Audit:          tt.fields.Audit,
+				Extension:      tt.fields.Extension,
 			}
 			if got := cfg.Merge(tt.args.beforeCfg); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Merge() = %v, want %v", got, tt.want)
@@ -0,0 +1,314 @@
+package download
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/util"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	// accessConfigKey is the key of access control data in the config bucket
+	accessConfigKey = "access"
+	// accessTokenPrefix makes generated tokens easy to recognize in config files and logs
+	accessTokenPrefix = "gpd_"
+)
+
+var (
+	ErrAccessTokenNotFound  = errors.New("access token not found")
+	ErrAccessUserNotFound   = errors.New("access user not found")
+	ErrAccessUserInvalid    = errors.New("access user username and password are required")
+	ErrAccessScopeInvalid   = errors.New("invalid access scope")
+	ErrDownloadDirForbidden = errors.New("download directory is not allowed")
+)
+
+var accessScopes = []base.AccessScope{base.AccessScopeRead, base.AccessScopeCreate, base.AccessScopeManage, base.AccessScopeConfig, base.AccessScopeExtension, base.AccessScopeAdmin}
+
+type accessData struct {
+	Tokens []*base.AccessToken `json:"tokens"`
+	Users  []*base.AccessUser  `json:"users"`
+}
+
+type accessManager struct {
+	lock *sync.RWMutex
+	data *accessData
+}
+
+func (d *Downloader) loadAccess() error {
+	var data accessData
+	if _, err := d.storage.Get(bucketConfig, accessConfigKey, &data); err != nil {
+		return err
+	}
+	d.access = &accessManager{
+		lock: &sync.RWMutex{},
+		data: &data,
+	}
+	return nil
+}
+
+func (d *Downloader) saveAccess() error {
+	return d.storage.Put(bucketConfig, accessConfigKey, d.access.data)
+}
+
+func validateScopes(scopes []base.AccessScope) error {
+	if len(scopes) == 0 {
+		return ErrAccessScopeInvalid
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(accessScopes, scope) {
+			return ErrAccessScopeInvalid
+		}
+	}
+	return nil
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAccessToken creates a named token, the plain token is only returned here and can't be retrieved later.
+func (d *Downloader) CreateAccessToken(name string, scopes []base.AccessScope, downloadDirs []string) (string, *base.AccessToken, error) {
+	if err := validateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+	id, err := gonanoid.New()
+	if err != nil {
+		return "", nil, err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := accessTokenPrefix + hex.EncodeToString(buf)
+	at := &base.AccessToken{
+		ID:           id,
+		Name:         name,
+		Hint:         token[:len(accessTokenPrefix)+6],
+		Hash:         hashAccessToken(token),
+		Scopes:       scopes,
+		DownloadDirs: downloadDirs,
+		CreatedAt:    time.Now(),
+	}
+
+	d.access.lock.Lock()
+	defer d.access.lock.Unlock()
+	d.access.data.Tokens = append(d.access.data.Tokens, at)
+	if err := d.saveAccess(); err != nil {
+		d.access.data.Tokens = d.access.data.Tokens[:len(d.access.data.Tokens)-1]
+		return "", nil, err
+	}
+	return token, publicAccessToken(at), nil
+}
+
+// GetAccessTokens returns all tokens without their hashes.
+func (d *Downloader) GetAccessTokens() []*base.AccessToken {
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+
+	tokens := make([]*base.AccessToken, 0, len(d.access.data.Tokens))
+	for _, at := range d.access.data.Tokens {
+		tokens = append(tokens, publicAccessToken(at))
+	}
+	return tokens
+}
+
+func (d *Downloader) RevokeAccessToken(id string) error {
+	d.access.lock.Lock()
+	defer d.access.lock.Unlock()
+
+	for i, at := range d.access.data.Tokens {
+		if at.ID == id {
+			d.access.data.Tokens = slices.Delete(slices.Clone(d.access.data.Tokens), i, i+1)
+			return d.saveAccess()
+		}
+	}
+	return ErrAccessTokenNotFound
+}
+
+// AuthenticateAccessToken returns the principal of the token, or nil if the token is unknown.
+func (d *Downloader) AuthenticateAccessToken(token string) *base.Principal {
+	if token == "" {
+		return nil
+	}
+	hash := hashAccessToken(token)
+
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+	for _, at := range d.access.data.Tokens {
+		if subtle.ConstantTimeCompare([]byte(at.Hash), []byte(hash)) == 1 {
+			return &base.Principal{
+				Name:         at.Name,
+				Kind:         base.PrincipalKindToken,
+				Scopes:       at.Scopes,
+				DownloadDirs: at.DownloadDirs,
+			}
+		}
+	}
+	return nil
+}
+
+// PutAccessUser creates or replaces a user by username.
+func (d *Downloader) PutAccessUser(user *base.AccessUser) error {
+	if user.Username == "" || user.Password == "" {
+		return ErrAccessUserInvalid
+	}
+	if err := validateScopes(user.Scopes); err != nil {
+		return err
+	}
+	user = util.DeepClone(user)
+	if !util.IsPasswordHash(user.Password) {
+		hash, err := util.HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
+
+	d.access.lock.Lock()
+	defer d.access.lock.Unlock()
+
+	users := slices.Clone(d.access.data.Users)
+	idx := slices.IndexFunc(users, func(u *base.AccessUser) bool {
+		return u.Username == user.Username
+	})
+	if idx >= 0 {
+		user.CreatedAt = users[idx].CreatedAt
+		users[idx] = user
+	} else {
+		user.CreatedAt = time.Now()
+		users = append(users, user)
+	}
+	old := d.access.data.Users
+	d.access.data.Users = users
+	if err := d.saveAccess(); err != nil {
+		d.access.data.Users = old
+		return err
+	}
+	return nil
+}
+
+// GetAccessUsers returns all users without their passwords.
+func (d *Downloader) GetAccessUsers() []*base.AccessUser {
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+
+	users := make([]*base.AccessUser, 0, len(d.access.data.Users))
+	for _, u := range d.access.data.Users {
+		users = append(users, publicAccessUser(u))
+	}
+	return users
+}
+
+func (d *Downloader) DeleteAccessUser(username string) error {
+	d.access.lock.Lock()
+	defer d.access.lock.Unlock()
+
+	for i, u := range d.access.data.Users {
+		if u.Username == username {
+			d.access.data.Users = slices.Delete(slices.Clone(d.access.data.Users), i, i+1)
+			return d.saveAccess()
+		}
+	}
+	return ErrAccessUserNotFound
+}
+
+// HasAccessTokens returns true if any access token is stored.
+func (d *Downloader) HasAccessTokens() bool {
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+	return len(d.access.data.Tokens) > 0
+}
+
+// HasAccessUsers returns true if any access user is stored.
+func (d *Downloader) HasAccessUsers() bool {
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+	return len(d.access.data.Users) > 0
+}
+
+// AuthenticateAccessUser returns the principal of the user, or nil if the credentials don't match.
+func (d *Downloader) AuthenticateAccessUser(username string, password string) *base.Principal {
+	d.access.lock.RLock()
+	defer d.access.lock.RUnlock()
+
+	for _, u := range d.access.data.Users {
+		if u.Username == username && util.CheckPassword(u.Password, password) {
+			return &base.Principal{
+				Name:         u.Username,
+				Kind:         base.PrincipalKindUser,
+				Scopes:       u.Scopes,
+				DownloadDirs: u.DownloadDirs,
+			}
+		}
+	}
+	return nil
+}
+
+// CheckDownloadDir checks if the download directory of the options matches one of the dirs patterns,
+// an empty options path falls back to the default download directory. Empty dirs means no restriction.
+func (d *Downloader) CheckDownloadDir(opts *base.Options, dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	path := ""
+	if opts != nil {
+		path = opts.Path
+	}
+	if path == "" {
+		cfg, err := d.GetConfig()
+		if err != nil {
+			return err
+		}
+		path = cfg.DownloadDir
+	}
+	if !matchDownloadDir(dirs, util.ReplacePathPlaceholders(path)) {
+		return ErrDownloadDirForbidden
+	}
+	return nil
+}
+
+// matchDownloadDir matches the absolute path against the dir patterns, the path is cleaned first,
+// so a path like /data/x/../../etc can't escape a pattern like /data/*.
+func matchDownloadDir(dirs []string, path string) bool {
+	if path != "" {
+		path = absPath(path)
+	}
+	for _, dir := range dirs {
+		if match, err := filepath.Match(absPath(dir), path); match && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath returns the absolute path, the path is only cleaned if it can't be resolved.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// publicAccessToken returns a copy of the token without the hash.
+func publicAccessToken(t *base.AccessToken) *base.AccessToken {
+	c := *t
+	c.Hash = ""
+	return &c
+}
+
+// publicAccessUser returns a copy of the user without the password hash.
+func publicAccessUser(u *base.AccessUser) *base.AccessUser {
+	c := *u
+	c.Password = ""
+	return &c
+}
@@ -0,0 +1,158 @@
+package download
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/util"
+)
+
+func TestDownloader_AccessToken(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		if _, _, err := downloader.CreateAccessToken("bad", []base.AccessScope{"unknown"}, nil); !errors.Is(err, ErrAccessScopeInvalid) {
+			t.Errorf("CreateAccessToken() got = %v, want %v", err, ErrAccessScopeInvalid)
+		}
+
+		token, info, err := downloader.CreateAccessToken("nas", []base.AccessScope{base.AccessScopeRead, base.AccessScopeCreate}, []string{"/downloads/*"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Hash != "" {
+			t.Errorf("CreateAccessToken() hash should not be returned")
+		}
+
+		principal := downloader.AuthenticateAccessToken(token)
+		if principal == nil || principal.Name != "nas" || principal.Kind != base.PrincipalKindToken {
+			t.Fatalf("AuthenticateAccessToken() got = %v, want nas token", principal)
+		}
+		if !principal.HasScope(base.AccessScopeCreate) || principal.HasScope(base.AccessScopeConfig) {
+			t.Errorf("AuthenticateAccessToken() got scopes = %v", principal.Scopes)
+		}
+		if downloader.AuthenticateAccessToken(token+"x") != nil {
+			t.Errorf("AuthenticateAccessToken() wrong token should not be authenticated")
+		}
+
+		tokens := downloader.GetAccessTokens()
+		if len(tokens) != 1 || tokens[0].ID != info.ID || tokens[0].Hash != "" {
+			t.Errorf("GetAccessTokens() got = %v", tokens)
+		}
+
+		if err := downloader.RevokeAccessToken(info.ID); err != nil {
+			t.Fatal(err)
+		}
+		if downloader.AuthenticateAccessToken(token) != nil {
+			t.Errorf("AuthenticateAccessToken() revoked token should not be authenticated")
+		}
+		if err := downloader.RevokeAccessToken(info.ID); !errors.Is(err, ErrAccessTokenNotFound) {
+			t.Errorf("RevokeAccessToken() got = %v, want %v", err, ErrAccessTokenNotFound)
+		}
+	})
+}
+
+func TestDownloader_AccessTokenPersist(t *testing.T) {
+	storageDir := t.TempDir()
+	downloader := NewDownloader(&DownloaderConfig{
+		Storage:    NewBoltStorage(storageDir),
+		StorageDir: storageDir,
+	})
+	if err := downloader.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer downloader.Clear()
+
+	token, _, err := downloader.CreateAccessToken("persist", []base.AccessScope{base.AccessScopeRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := downloader.PutAccessUser(&base.AccessUser{Username: "alice", Password: "secret", Scopes: []base.AccessScope{base.AccessScopeManage}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload access data from storage
+	if err := downloader.loadAccess(); err != nil {
+		t.Fatal(err)
+	}
+	if downloader.AuthenticateAccessToken(token) == nil {
+		t.Errorf("AuthenticateAccessToken() token should be restored from storage")
+	}
+	if downloader.AuthenticateAccessUser("alice", "secret") == nil {
+		t.Errorf("AuthenticateAccessUser() user should be restored from storage")
+	}
+}
+
+func TestDownloader_AccessUser(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		if err := downloader.PutAccessUser(&base.AccessUser{Username: "alice", Scopes: []base.AccessScope{base.AccessScopeRead}}); !errors.Is(err, ErrAccessUserInvalid) {
+			t.Errorf("PutAccessUser() got = %v, want %v", err, ErrAccessUserInvalid)
+		}
+		if err := downloader.PutAccessUser(&base.AccessUser{Username: "alice", Password: "secret", Scopes: []base.AccessScope{base.AccessScopeRead}}); err != nil {
+			t.Fatal(err)
+		}
+		if stored := downloader.access.data.Users[0].Password; !util.IsPasswordHash(stored) {
+			t.Errorf("PutAccessUser() password should be stored as a hash, got %s", stored)
+		}
+		if downloader.AuthenticateAccessUser("alice", "wrong") != nil {
+			t.Errorf("AuthenticateAccessUser() wrong password should not be authenticated")
+		}
+
+		// replace the user
+		if err := downloader.PutAccessUser(&base.AccessUser{Username: "alice", Password: "secret2", Scopes: []base.AccessScope{base.AccessScopeAdmin}}); err != nil {
+			t.Fatal(err)
+		}
+		principal := downloader.AuthenticateAccessUser("alice", "secret2")
+		if principal == nil || !principal.HasScope(base.AccessScopeConfig) {
+			t.Errorf("AuthenticateAccessUser() got = %v, want admin user", principal)
+		}
+		users := downloader.GetAccessUsers()
+		if len(users) != 1 || users[0].Password != "" {
+			t.Errorf("GetAccessUsers() got = %v", users)
+		}
+
+		if err := downloader.DeleteAccessUser("alice"); err != nil {
+			t.Fatal(err)
+		}
+		if downloader.AuthenticateAccessUser("alice", "secret2") != nil {
+			t.Errorf("AuthenticateAccessUser() deleted user should not be authenticated")
+		}
+	})
+}
+
+func TestDownloader_CheckDownloadDir(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		cfg, _ := downloader.GetConfig()
+		cfg.DownloadDir = "/data/default"
+
+		tests := []struct {
+			name string
+			opts *base.Options
+			dirs []string
+			want error
+		}{
+			{"no restriction", &base.Options{Path: "/etc"}, nil, nil},
+			{"allowed", &base.Options{Path: "/data/alice"}, []string{"/data/alice"}, nil},
+			{"pattern", &base.Options{Path: "/data/alice"}, []string{"/data/*"}, nil},
+			{"forbidden", &base.Options{Path: "/data/bob"}, []string{"/data/alice"}, ErrDownloadDirForbidden},
+			{"default dir", nil, []string{"/data/alice"}, ErrDownloadDirForbidden},
+			{"default dir allowed", &base.Options{}, []string{"/data/default"}, nil},
+			{"parent", &base.Options{Path: "/data/.."}, []string{"/data/*"}, ErrDownloadDirForbidden},
+			{"traversal", &base.Options{Path: "/data/x/../../etc"}, []string{"/data/*"}, ErrDownloadDirForbidden},
+			{"traversal inside", &base.Options{Path: "/data/x/../alice"}, []string{"/data/alice"}, nil},
+			{"trailing slash", &base.Options{Path: "/data/alice/"}, []string{"/data/alice"}, nil},
+		}
+		for _, tt := range tests {
+			if err := downloader.CheckDownloadDir(tt.opts, tt.dirs); !errors.Is(err, tt.want) {
+				t.Errorf("CheckDownloadDir() %s got = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+	})
+}
+
+func setupAccessTest(t *testing.T, fn func(downloader *Downloader)) {
+	downloader := NewDownloader(nil)
+	if err := downloader.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer downloader.Clear()
+	fn(downloader)
+}
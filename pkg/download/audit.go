@@ -0,0 +1,122 @@
+package download
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	defaultAuditPageSize = 20
+	maxAuditPageSize     = 500
+)
+
+type auditLog struct {
+	lock *sync.Mutex
+	// entries are ordered from oldest to newest
+	entries []*base.AuditEntry
+}
+
+func (d *Downloader) loadAudit() error {
+	var entries []*base.AuditEntry
+	if err := d.storage.List(bucketAudit, &entries); err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	d.audit = &auditLog{
+		lock:    &sync.Mutex{},
+		entries: entries,
+	}
+
+	d.audit.lock.Lock()
+	defer d.audit.lock.Unlock()
+	return d.pruneAudit(time.Now())
+}
+
+// RecordAudit stores an audit entry and removes the entries out of retention.
+func (d *Downloader) RecordAudit(entry *base.AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	suffix, err := gonanoid.New(8)
+	if err != nil {
+		return err
+	}
+	// ids sort by time, so the storage order doesn't matter
+	entry.ID = fmt.Sprintf("%020d-%s", entry.Time.UnixNano(), suffix)
+
+	d.audit.lock.Lock()
+	defer d.audit.lock.Unlock()
+
+	if err := d.storage.Put(bucketAudit, entry.ID, entry); err != nil {
+		return err
+	}
+	idx := sort.Search(len(d.audit.entries), func(i int) bool {
+		return d.audit.entries[i].ID > entry.ID
+	})
+	d.audit.entries = append(d.audit.entries, nil)
+	copy(d.audit.entries[idx+1:], d.audit.entries[idx:])
+	d.audit.entries[idx] = entry
+	return d.pruneAudit(time.Now())
+}
+
+// GetAuditLog returns a page of audit entries from newest to oldest, page starts from 1.
+func (d *Downloader) GetAuditLog(page int, pageSize int) *base.AuditPage {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultAuditPageSize
+	}
+	if pageSize > maxAuditPageSize {
+		pageSize = maxAuditPageSize
+	}
+
+	d.audit.lock.Lock()
+	defer d.audit.lock.Unlock()
+
+	total := len(d.audit.entries)
+	result := &base.AuditPage{
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+		Entries:  make([]*base.AuditEntry, 0),
+	}
+	for i := total - 1 - (page-1)*pageSize; i >= 0 && len(result.Entries) < pageSize; i-- {
+		result.Entries = append(result.Entries, d.audit.entries[i])
+	}
+	return result
+}
+
+// pruneAudit removes the entries out of the retention days or over the max entries, must hold the audit lock.
+func (d *Downloader) pruneAudit(now time.Time) error {
+	cfg := d.cfg.DownloaderStoreConfig.Audit
+	if cfg == nil {
+		// the config may be replaced by a client that doesn't know the audit config
+		cfg = (&base.DownloaderStoreConfig{}).Init().Audit
+	}
+	n := 0
+	if cfg.MaxEntries > 0 && len(d.audit.entries) > cfg.MaxEntries {
+		n = len(d.audit.entries) - cfg.MaxEntries
+	}
+	if cfg.RetentionDays > 0 {
+		deadline := now.AddDate(0, 0, -cfg.RetentionDays)
+		for n < len(d.audit.entries) && d.audit.entries[n].Time.Before(deadline) {
+			n++
+		}
+	}
+	for i := 0; i < n; i++ {
+		if err := d.storage.Delete(bucketAudit, d.audit.entries[i].ID); err != nil {
+			d.audit.entries = d.audit.entries[i:]
+			return err
+		}
+	}
+	d.audit.entries = d.audit.entries[n:]
+	return nil
+}
@@ -0,0 +1,101 @@
+package download
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func TestDownloader_AuditLog(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		for i := 0; i < 5; i++ {
+			if err := downloader.RecordAudit(&base.AuditEntry{
+				Principal: "alice",
+				Method:    "DELETE",
+				Route:     "/api/v1/tasks/{id}",
+				Path:      fmt.Sprintf("/api/v1/tasks/%d", i),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		page := downloader.GetAuditLog(1, 2)
+		if page.Total != 5 || len(page.Entries) != 2 {
+			t.Fatalf("GetAuditLog() got total = %d, entries = %d", page.Total, len(page.Entries))
+		}
+		if page.Entries[0].Path != "/api/v1/tasks/4" || page.Entries[1].Path != "/api/v1/tasks/3" {
+			t.Errorf("GetAuditLog() should be ordered from newest, got %s, %s", page.Entries[0].Path, page.Entries[1].Path)
+		}
+		page = downloader.GetAuditLog(3, 2)
+		if len(page.Entries) != 1 || page.Entries[0].Path != "/api/v1/tasks/0" {
+			t.Errorf("GetAuditLog() last page got = %v", page.Entries)
+		}
+		page = downloader.GetAuditLog(4, 2)
+		if len(page.Entries) != 0 {
+			t.Errorf("GetAuditLog() out of range page got = %v", page.Entries)
+		}
+		page = downloader.GetAuditLog(0, 0)
+		if page.Page != 1 || page.PageSize != defaultAuditPageSize || len(page.Entries) != 5 {
+			t.Errorf("GetAuditLog() default page got = %d, %d, %d", page.Page, page.PageSize, len(page.Entries))
+		}
+	})
+}
+
+func TestDownloader_AuditLogRetention(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		cfg, _ := downloader.GetConfig()
+		cfg.Audit = &base.AuditConfig{RetentionDays: 7, MaxEntries: 3}
+
+		old := &base.AuditEntry{Path: "old", Time: time.Now().AddDate(0, 0, -8)}
+		if err := downloader.RecordAudit(old); err != nil {
+			t.Fatal(err)
+		}
+		if page := downloader.GetAuditLog(1, 10); page.Total != 0 {
+			t.Errorf("RecordAudit() entry out of retention days should be removed, got %d", page.Total)
+		}
+
+		for i := 0; i < 5; i++ {
+			if err := downloader.RecordAudit(&base.AuditEntry{Path: fmt.Sprint(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		page := downloader.GetAuditLog(1, 10)
+		if page.Total != 3 || page.Entries[2].Path != "2" {
+			t.Errorf("RecordAudit() should keep the newest %d entries, got %v", cfg.Audit.MaxEntries, page.Entries)
+		}
+		var stored []*base.AuditEntry
+		if err := downloader.storage.List(bucketAudit, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if len(stored) != 3 {
+			t.Errorf("RecordAudit() pruned entries should be deleted from storage, got %d", len(stored))
+		}
+	})
+}
+
+func TestDownloader_AuditLogPersist(t *testing.T) {
+	storageDir := t.TempDir()
+	downloader := NewDownloader(&DownloaderConfig{
+		Storage:    NewBoltStorage(storageDir),
+		StorageDir: storageDir,
+	})
+	if err := downloader.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer downloader.Clear()
+
+	for i := 0; i < 3; i++ {
+		if err := downloader.RecordAudit(&base.AuditEntry{Principal: "bob", Path: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := downloader.loadAudit(); err != nil {
+		t.Fatal(err)
+	}
+	page := downloader.GetAuditLog(1, 10)
+	if page.Total != 3 || page.Entries[0].Path != "2" || page.Entries[0].Principal != "bob" {
+		t.Errorf("loadAudit() got = %v", page.Entries)
+	}
+}
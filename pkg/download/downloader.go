@@ -20,6 +20,7 @@ import (
 	"github.com/GopeedLab/gopeed/internal/fetcher"
 	"github.com/GopeedLab/gopeed/internal/logger"
 	"github.com/GopeedLab/gopeed/pkg/base"
+	fetchapi "github.com/GopeedLab/gopeed/pkg/download/engine/inject/fetch"
 	"github.com/GopeedLab/gopeed/pkg/protocol/http"
 	"github.com/GopeedLab/gopeed/pkg/util"
 	gonanoid "github.com/matoous/go-nanoid/v2"
@@ -40,6 +41,12 @@ const (
 	bucketExtension = "extension"
 	// downloader extension storage bucket
 	bucketExtensionStorage = "extension_storage"
+	// api audit log bucket
+	bucketAudit = "audit"
+	// rss feed subscription bucket
+	bucketFeed = "feed"
+	// rss feed seen item bucket
+	bucketFeedItem = "feed_item"
 )
 
 var (
@@ -112,7 +119,13 @@ type Downloader struct {
 	claimedExtractions sync.Map
 
 	extensions []*Extension
-	blob       *internalblob.Registry
+	// fetchLimiters are the fetch limiters by extension identity, shared by the engines of the extension hooks
+	fetchLimiters     map[string]*fetchapi.Limiter
+	fetchLimitersLock sync.Mutex
+	blob              *internalblob.Registry
+	access            *accessManager
+	audit             *auditLog
+	feed              *feedManager
 }
 
 func NewDownloader(cfg *DownloaderConfig) *Downloader {
@@ -131,7 +144,8 @@ func NewDownloader(cfg *DownloaderConfig) *Downloader {
 		fetcherMapLock:     &sync.RWMutex{},
 		checkDuplicateLock: &sync.Mutex{},
 
-		extensions: make([]*Extension, 0),
+		extensions:    make([]*Extension, 0),
+		fetchLimiters: make(map[string]*fetchapi.Limiter),
 	}
 
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
@@ -147,7 +161,7 @@ func (d *Downloader) Setup() error {
 	d.blob = internalblob.NewRegistry("")
 
 	// setup storage
-	if err := d.storage.Setup([]string{bucketTask, bucketSave, bucketProtocolState, bucketConfig, bucketExtension, bucketExtensionStorage}); err != nil {
+	if err := d.storage.Setup([]string{bucketTask, bucketSave, bucketProtocolState, bucketConfig, bucketExtension, bucketExtensionStorage, bucketAudit, bucketFeed, bucketFeedItem}); err != nil {
 		return err
 	}
 	// load config from storage
@@ -165,6 +179,18 @@ func (d *Downloader) Setup() error {
 	}
 	// init default config
 	d.cfg.DownloaderStoreConfig.Init()
+	// load access tokens and users
+	if err := d.loadAccess(); err != nil {
+		return err
+	}
+	// load audit log
+	if err := d.loadAudit(); err != nil {
+		return err
+	}
+	// load feed subscriptions
+	if err := d.loadFeeds(); err != nil {
+		return err
+	}
 	// init protocol config, if not exist, use default config
 	for _, fm := range d.cfg.FetchManagers {
 		protocol := fm.Name()
@@ -179,6 +205,19 @@ func (d *Downloader) Setup() error {
 		}
 	}
 
+	// load extensions from storage, the tasks of the extension protocols depend on them
+	var extensions []*Extension
+	if err = d.storage.List(bucketExtension, &extensions); err != nil {
+		return err
+	}
+	if extensions == nil {
+		extensions = make([]*Extension, 0)
+	}
+	for _, ext := range extensions {
+		d.checkExtensionIntegrity(ext)
+	}
+	d.extensions = extensions
+
 	// load tasks from storage
 	var tasks []*Task
 	if err = d.storage.List(bucketTask, &tasks); err != nil {
@@ -207,16 +246,6 @@ func (d *Downloader) Setup() error {
 		return d.tasks[i].CreatedAt.Before(d.tasks[j].CreatedAt)
 	})
 
-	// load extensions from storage
-	var extensions []*Extension
-	if err = d.storage.List(bucketExtension, &extensions); err != nil {
-		return err
-	}
-	if extensions == nil {
-		extensions = make([]*Extension, 0)
-	}
-	d.extensions = extensions
-
 	// Auto-cleanup non-existing tasks on startup
 	d.cleanupNonExistingTasks()
 
@@ -278,6 +307,7 @@ func (d *Downloader) Setup() error {
 						task.statusLock.Unlock()
 						// Listener callbacks may Pause/Continue and acquire statusLock.
 						d.emit(EventKeyProgress, task)
+						d.triggerOnProgress(task)
 
 						// store fetcher progress when download/upload data changed
 						if !downloadDataChanged && !uploadDataChanged {
@@ -290,6 +320,9 @@ func (d *Downloader) Setup() error {
 			time.Sleep(time.Millisecond * time.Duration(d.cfg.RefreshInterval))
 		}
 	}()
+
+	// poll feed subscriptions
+	go d.runFeeds()
 	return nil
 }
 
@@ -346,6 +379,9 @@ func (d *Downloader) parseFm(url string) (fetcher.FetcherManager, error) {
 			}
 		}
 	}
+	if fm := d.parseExtensionFm(url); fm != nil {
+		return fm, nil
+	}
 	return nil, ErrUnSupportedProtocol
 }
 
@@ -492,7 +528,14 @@ func (d *Downloader) remainRunningCount() int {
 	return d.cfg.MaxRunning - runningCount
 }
 
-func (d *Downloader) CreateDirect(req *base.Request, opts *base.Options) (taskId string, err error) {
+// CreateDirect creates a task from the request, dirs are the download directory patterns of the creator,
+// a path changed by an extension onCreate hook must match one of them, empty dirs means no extra restriction.
+func (d *Downloader) CreateDirect(req *base.Request, opts *base.Options, dirs ...string) (taskId string, err error) {
+	return d.createDirect(req, opts, true, dirs)
+}
+
+// createDirect creates a task from the request, the task is left ready instead of being started or queued if start is false.
+func (d *Downloader) createDirect(req *base.Request, opts *base.Options, start bool, dirs []string) (taskId string, err error) {
 	ensureRequestRawURL(req)
 	var fetcher fetcher.Fetcher
 	fetcher, err = d.buildFetcher(req.URL)
@@ -504,17 +547,17 @@ func (d *Downloader) CreateDirect(req *base.Request, opts *base.Options) (taskId
 	if err != nil {
 		return
 	}
-	return d.doCreate(fetcher, initOpt)
+	return d.doCreate(fetcher, initOpt, start, dirs)
 }
 
-func (d *Downloader) CreateDirectBatch(req *base.CreateTaskBatch) (taskId []string, err error) {
+func (d *Downloader) CreateDirectBatch(req *base.CreateTaskBatch, dirs ...string) (taskId []string, err error) {
 	taskIds := make([]string, 0)
 	for _, ir := range req.Reqs {
 		opts := ir.Opts
 		if opts == nil {
 			opts = req.Opts
 		}
-		taskId, err := d.CreateDirect(ir.Req, opts.Clone())
+		taskId, err := d.CreateDirect(ir.Req, opts.Clone(), dirs...)
 		if err != nil {
 			return nil, err
 		}
@@ -523,7 +566,7 @@ func (d *Downloader) CreateDirectBatch(req *base.CreateTaskBatch) (taskId []stri
 	return taskIds, nil
 }
 
-func (d *Downloader) Create(rrId string) (taskId string, err error) {
+func (d *Downloader) Create(rrId string, dirs ...string) (taskId string, err error) {
 	d.fetcherMapLock.RLock()
 	fetcher, ok := d.fetcherCache[rrId]
 	d.fetcherMapLock.RUnlock()
@@ -535,7 +578,7 @@ func (d *Downloader) Create(rrId string) (taskId string, err error) {
 		delete(d.fetcherCache, rrId)
 		d.fetcherMapLock.Unlock()
 	}()
-	return d.doCreate(fetcher, nil)
+	return d.doCreate(fetcher, nil, true, dirs)
 }
 
 // Patch modifies task-specific data based on the protocol.
@@ -683,6 +726,7 @@ func (d *Downloader) Continue(filter *TaskFilter) (err error) {
 	// are allowed to call back into Downloader methods that acquire d.lock.
 	for _, task := range pausedTasks {
 		d.emit(EventKeyPause, task)
+		d.triggerOnPause(task)
 	}
 
 	for _, task := range realContinueTasks {
@@ -853,6 +897,7 @@ func (d *Downloader) doDelete(task *Task, force bool) (err error) {
 			}
 		}
 		d.emit(EventKeyDelete, task)
+		d.triggerOnDelete(task, force)
 		task = nil
 		return nil
 	}()
@@ -1001,10 +1046,16 @@ func (d *Downloader) GetTasksByFilter(filter *TaskFilter) []*Task {
 		}
 		return true
 	}
+	dirMatch := func(task *Task) bool {
+		if len(filter.DownloadDirs) == 0 {
+			return true
+		}
+		return d.CheckDownloadDir(task.Meta.Opts, filter.DownloadDirs) == nil
+	}
 
 	tasks := make([]*Task, 0)
 	for _, task := range d.tasks {
-		if idMatch(task) && statusMatch(task) && notStatusMatch(task) {
+		if idMatch(task) && statusMatch(task) && notStatusMatch(task) && dirMatch(task) {
 			tasks = append(tasks, task)
 		}
 	}
@@ -1279,6 +1330,12 @@ func (d *Downloader) restoreTask(task *Task) error {
 }
 
 func (d *Downloader) restoreFetcher(task *Task) error {
+	// the extension of the protocol may be installed after the task is loaded
+	if task.fetcherManager == nil {
+		if err := d.assignFetcherManager(task); err != nil {
+			return err
+		}
+	}
 	v, f := task.fetcherManager.Restore()
 	if v != nil {
 		err := d.storage.Pop(bucketSave, task.ID, v)
@@ -1303,11 +1360,12 @@ func (d *Downloader) restoreFetcher(task *Task) error {
 	return nil
 }
 
-func (d *Downloader) doCreate(f fetcher.Fetcher, opts *base.Options) (taskId string, err error) {
+func (d *Downloader) doCreate(f fetcher.Fetcher, opts *base.Options, start bool, dirs []string) (taskId string, err error) {
 	if f.Meta().Opts == nil {
 		f.Meta().Opts = opts
 	}
 	ensureRequestRawURL(f.Meta().Req)
+	d.triggerOnCreate(f.Meta(), dirs)
 
 	fm, err := d.parseFm(f.Meta().Req.URL)
 	if err != nil {
@@ -1339,6 +1397,9 @@ func (d *Downloader) doCreate(f fetcher.Fetcher, opts *base.Options) (taskId str
 		defer d.lock.Unlock()
 
 		d.tasks = append(d.tasks, task)
+		if !start {
+			return
+		}
 
 		remainRunningCount := d.remainRunningCount()
 		if remainRunningCount == 0 {
@@ -1371,17 +1432,8 @@ func (d *Downloader) initOptions(opts *base.Options) (*base.Options, error) {
 	opts.Path = util.ReplacePathPlaceholders(opts.Path)
 
 	// if enable white download directory, check if the download directory is in the white list
-	if len(d.cfg.WhiteDownloadDirs) > 0 {
-		inWhiteList := false
-		for _, dir := range d.cfg.WhiteDownloadDirs {
-			if match, err := filepath.Match(dir, opts.Path); match && err == nil {
-				inWhiteList = true
-				break
-			}
-		}
-		if !inWhiteList {
-			return nil, errors.New("download directory is not in white list")
-		}
+	if len(d.cfg.WhiteDownloadDirs) > 0 && !matchDownloadDir(d.cfg.WhiteDownloadDirs, opts.Path) {
+		return nil, errors.New("download directory is not in white list")
 	}
 	return opts, nil
 }
@@ -1527,6 +1579,7 @@ func (d *Downloader) doPause(task *Task) (err error) {
 	}
 	if handled {
 		d.emit(EventKeyPause, task)
+		d.triggerOnPause(task)
 	}
 	return nil
 }
@@ -1852,6 +1905,9 @@ func (d *Downloader) performExtraction(task *Task, archivePath string, destDir s
 	})
 
 	d.handleExtractionResult(task, extractErr, []string{archivePath}, opts.DeleteAfterExtract)
+	if extractErr == nil {
+		d.triggerOnExtractDone(task, destDir)
+	}
 }
 
 // performMultiPartExtraction performs extraction for a multi-part archive
@@ -1880,6 +1936,9 @@ func (d *Downloader) performMultiPartExtraction(task *Task, firstPartPath string
 
 	// Update status for all related multi-part tasks
 	d.updateMultiPartTasksStatus(task, extractErr)
+	if extractErr == nil {
+		d.triggerOnExtractDone(task, destDir)
+	}
 
 	// Release the claim so future downloads of the same archive can be extracted
 	d.releaseMultiPartExtractionClaim(fullBaseName)
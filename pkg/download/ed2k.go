@@ -0,0 +1,139 @@
+package download
+
+import (
+	"errors"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	"github.com/GopeedLab/gopeed/internal/protocol/ed2k"
+	ped2k "github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+)
+
+var (
+	ErrEd2kNotSupported = errors.New("ed2k protocol is not supported")
+	ErrTaskNotEd2k      = errors.New("task is not an ed2k task")
+)
+
+// GetEd2kServers returns the ed2k servers known by the client with the connection status and the last connect error.
+func (d *Downloader) GetEd2kServers() (*ped2k.ServerList, error) {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return nil, err
+	}
+	return fm.Servers()
+}
+
+// ConnectEd2kServer connects to an ed2k server by host:port.
+func (d *Downloader) ConnectEd2kServer(addr string) error {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return err
+	}
+	return fm.ConnectServer(addr)
+}
+
+// DisconnectEd2kServer disconnects from an ed2k server, it's not reconnected until connected again.
+func (d *Downloader) DisconnectEd2kServer(addr string) error {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return err
+	}
+	return fm.DisconnectServer(addr)
+}
+
+// RefreshEd2kServerMet reloads the server.met of the ed2k config and connects to the servers in it.
+func (d *Downloader) RefreshEd2kServerMet() error {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return err
+	}
+	return fm.RefreshServerMet()
+}
+
+// RefreshEd2kNodesDat reloads the nodes.dat of the ed2k config to bootstrap the KAD network.
+func (d *Downloader) RefreshEd2kNodesDat() error {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return err
+	}
+	return fm.RefreshNodesDat()
+}
+
+// GetEd2kKad returns the status of the KAD network.
+func (d *Downloader) GetEd2kKad() (*ped2k.Kad, error) {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return nil, err
+	}
+	return fm.Kad()
+}
+
+// SearchEd2k starts a keyword search on the connected ed2k servers and the KAD network, the search runs
+// in the background and the results are polled by GetEd2kSearch.
+func (d *Downloader) SearchEd2k(opts *ped2k.SearchOpts) (*ped2k.Search, error) {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return nil, err
+	}
+	return fm.Search(opts)
+}
+
+// GetEd2kSearch returns an ed2k search with the results found so far.
+func (d *Downloader) GetEd2kSearch(id string) (*ped2k.Search, error) {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return nil, err
+	}
+	return fm.GetSearch(id)
+}
+
+// GetEd2kSearches returns the recent ed2k searches without the results, newest first.
+func (d *Downloader) GetEd2kSearches() ([]*ped2k.Search, error) {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return nil, err
+	}
+	return fm.GetSearches(), nil
+}
+
+// DeleteEd2kSearch stops an ed2k search if it's running and removes it.
+func (d *Downloader) DeleteEd2kSearch(id string) error {
+	fm, err := d.ed2kManager()
+	if err != nil {
+		return err
+	}
+	return fm.DeleteSearch(id)
+}
+
+// GetTaskSources returns the sources of a running ed2k task.
+func (d *Downloader) GetTaskSources(id string) ([]*ped2k.Source, error) {
+	task := d.GetTask(id)
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	if task.Protocol != "ed2k" {
+		return nil, ErrTaskNotEd2k
+	}
+	f, ok := task.fetcher.(*ed2k.Fetcher)
+	if !ok {
+		return nil, ed2k.ErrTransferNotReady
+	}
+	return f.Sources()
+}
+
+// ed2kManager returns the ed2k fetcher manager with the shared client started, so the servers can be managed
+// before any ed2k task is started.
+func (d *Downloader) ed2kManager() (*ed2k.FetcherManager, error) {
+	for _, fm := range d.cfg.FetchManagers {
+		if m, ok := fm.(*ed2k.FetcherManager); ok {
+			ctl := controller.NewController()
+			ctl.GetConfig = func(v any) {
+				d.getProtocolConfig(m.Name(), v)
+			}
+			if err := m.Start(ctl); err != nil {
+				return nil, err
+			}
+			return m, nil
+		}
+	}
+	return nil, ErrEd2kNotSupported
+}
@@ -0,0 +1,79 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	ped2k "github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	gprotocol "github.com/monkeyWie/goed2k/protocol"
+)
+
+func TestDownloader_Ed2k(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		// no bootstrap from the internet
+		downloader.cfg.ProtocolConfig["ed2k"] = map[string]any{
+			"disableDHT":  true,
+			"disableUPnP": true,
+		}
+		kad, err := downloader.GetEd2kKad()
+		if err != nil || kad.Enabled {
+			t.Errorf("GetEd2kKad() got = %v, %v", kad, err)
+		}
+		servers, err := downloader.GetEd2kServers()
+		if err != nil || len(servers.Servers) != 0 {
+			t.Errorf("GetEd2kServers() got = %v, %v", servers, err)
+		}
+		if _, err := downloader.SearchEd2k(&ped2k.SearchOpts{}); !errors.Is(err, ed2k.ErrSearchQueryRequired) {
+			t.Errorf("SearchEd2k() empty query error = %v", err)
+		}
+
+		file := filepath.Join(t.TempDir(), "seed.txt")
+		if err := os.WriteFile(file, []byte("seed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file, Seed: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.GetTaskSources("not-exist"); err != ErrTaskNotFound {
+			t.Errorf("GetTaskSources() not found got = %v, want %v", err, ErrTaskNotFound)
+		}
+		if _, err := downloader.GetTaskSources(created.TaskID); err != ErrTaskNotEd2k {
+			t.Errorf("GetTaskSources() bt task got = %v, want %v", err, ErrTaskNotEd2k)
+		}
+
+		// a file downloaded before the task is created
+		content := []byte("ed2k content")
+		hash, err := gprotocol.HashFromData(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "ed2k.txt"), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		taskID, err := downloader.createDirect(&base.Request{
+			URL: fmt.Sprintf("ed2k://|file|ed2k.txt|%d|%s|/", len(content), hash.String()),
+		}, &base.Options{Path: dir}, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := downloader.RecheckTask(taskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Intact || result.VerifiedBytes != int64(len(content)) {
+			t.Errorf("RecheckTask() got = %+v", result)
+		}
+		if stats, _ := downloader.Stats(taskID); stats.(*ped2k.Stats).Recheck == nil {
+			t.Errorf("Stats() recheck is nil")
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package engine
 
 import (
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
+	"net/url"
 	"sync"
 
 	"github.com/GopeedLab/gopeed/pkg/base"
@@ -36,7 +38,12 @@ type JSFunction func(goja.FunctionCall) goja.Value
 // RunString executes the script and returns the go type value
 // if script result is promise, it will be resolved
 func (e *Engine) RunString(script string) (value any, err error) {
-	return e.runOnLoop(func(runtime *goja.Runtime) (goja.Value, error) {
+	return e.RunStringContext(context.Background(), script)
+}
+
+// RunStringContext is like RunString, the script is interrupted when the context is done
+func (e *Engine) RunStringContext(ctx context.Context, script string) (value any, err error) {
+	return e.runOnLoop(ctx, func(runtime *goja.Runtime) (goja.Value, error) {
 		return runtime.RunString(script)
 	})
 }
@@ -44,7 +51,12 @@ func (e *Engine) RunString(script string) (value any, err error) {
 // CallFunction calls the function and returns the go type value
 // if function result is promise, it will be resolved
 func (e *Engine) CallFunction(fn any, args ...any) (value any, err error) {
-	return e.runOnLoop(func(runtime *goja.Runtime) (goja.Value, error) {
+	return e.CallFunctionContext(context.Background(), fn, args...)
+}
+
+// CallFunctionContext is like CallFunction, the function is interrupted when the context is done
+func (e *Engine) CallFunctionContext(ctx context.Context, fn any, args ...any) (value any, err error) {
+	return e.runOnLoop(ctx, func(runtime *goja.Runtime) (goja.Value, error) {
 		var jsArgs []goja.Value
 		for _, arg := range args {
 			jsArgs = append(jsArgs, runtime.ToValue(arg))
@@ -65,7 +77,7 @@ func (e *Engine) CallFunction(fn any, args ...any) (value any, err error) {
 	})
 }
 
-func (e *Engine) runOnLoop(fn func(runtime *goja.Runtime) (goja.Value, error)) (any, error) {
+func (e *Engine) runOnLoop(ctx context.Context, fn func(runtime *goja.Runtime) (goja.Value, error)) (any, error) {
 	type result struct {
 		value any
 		err   error
@@ -129,7 +141,15 @@ func (e *Engine) runOnLoop(fn func(runtime *goja.Runtime) (goja.Value, error)) (
 	if !ok {
 		return nil, errors.New("engine loop terminated")
 	}
-	res := <-ch
+	var res result
+	select {
+	case res = <-ch:
+	case <-ctx.Done():
+		// stop a running script, e.g. an infinite loop, a pending promise is just abandoned
+		err := context.Cause(ctx)
+		e.Runtime.Interrupt(err)
+		return nil, err
+	}
 	if res.err != nil {
 		return nil, res.err
 	}
@@ -161,6 +181,12 @@ func (e *Engine) addCleanup(cleanup func()) {
 type Config struct {
 	ProxyConfig  *base.DownloaderProxyConfig
 	StreamConfig *stream.Config
+	// MaxConcurrentFetches is the max number of the fetch requests running at the same time, 0 means unlimited
+	MaxConcurrentFetches int
+	// FetchLimiter limits the fetch requests together with other engines, it takes precedence over MaxConcurrentFetches
+	FetchLimiter *fetchapi.Limiter
+	// CheckFetchURL checks if a fetch request can access the url, nil means any url
+	CheckFetchURL func(u *url.URL) error
 }
 
 func NewEngine(cfg *Config) *Engine {
@@ -203,9 +229,15 @@ func NewEngine(cfg *Config) *Engine {
 		if err := stream.Enable(runtime, loop, cfg.StreamConfig); err != nil {
 			return
 		}
+		fetchLimiter := cfg.FetchLimiter
+		if fetchLimiter == nil {
+			fetchLimiter = fetchapi.NewLimiter(cfg.MaxConcurrentFetches)
+		}
 		if err := fetchapi.Enable(runtime, loop, &fetchapi.Config{
 			ProxyHandler:    cfg.ProxyConfig.ToHandler(),
 			RegisterCleanup: engine.addCleanup,
+			Limiter:         fetchLimiter,
+			CheckURL:        cfg.CheckFetchURL,
 		}); err != nil {
 			return
 		}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -9,8 +10,10 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -109,6 +112,62 @@ func TestCallFunction_AwaitedPromiseBlocksUntilSettled(t *testing.T) {
 	}
 }
 
+func TestRunStringContext_Interrupt(t *testing.T) {
+	engine := NewEngine(nil)
+	defer engine.Close()
+	timeoutErr := errors.New("test timeout")
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 100*time.Millisecond, timeoutErr)
+	defer cancel()
+	if _, err := engine.RunStringContext(ctx, `while (true) {}`); !errors.Is(err, timeoutErr) {
+		t.Fatalf("expect timeout error for an infinite loop, but got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeoutCause(context.Background(), 100*time.Millisecond, timeoutErr)
+	defer cancel()
+	if _, err := engine.CallFunctionContext(ctx, JSFunction(func(call goja.FunctionCall) goja.Value {
+		promise, _, _ := engine.Runtime.NewPromise()
+		return engine.Runtime.ToValue(promise)
+	})); !errors.Is(err, timeoutErr) {
+		t.Fatalf("expect timeout error for a pending promise, but got %v", err)
+	}
+}
+
+func TestFetch_MaxConcurrent(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	engine := NewEngine(&Config{MaxConcurrentFetches: 2})
+	defer engine.Close()
+	value, err := engine.RunString(fmt.Sprintf(`
+(async () => {
+	const texts = await Promise.all([1, 2, 3, 4, 5].map(async () => (await fetch('%s')).text()));
+	return texts.join(',');
+})()
+`, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "ok,ok,ok,ok,ok" {
+		t.Fatalf("unexpected fetch result: %v", value)
+	}
+	if got := maxRunning.Load(); got != 2 {
+		t.Fatalf("expect max 2 concurrent fetches, but got %d", got)
+	}
+}
+
 func TestFetch(t *testing.T) {
 	server := startServer()
 	defer server.Close()
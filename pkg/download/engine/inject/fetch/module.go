@@ -31,13 +31,17 @@ var script string
 type Config struct {
 	ProxyHandler    func(*http.Request) (*url.URL, error)
 	RegisterCleanup func(func())
+	// Limiter limits the requests open at the same time, the others wait for a free slot, nil means unlimited
+	Limiter *Limiter
+	// CheckURL checks if a request or a redirect can access the url, nil means any url
+	CheckURL func(u *url.URL) error
 }
 
 func Enable(runtime *goja.Runtime, loop *eventloop.EventLoop, cfg *Config) error {
 	if cfg == nil {
 		cfg = &Config{}
 	}
-	registry := newRegistry()
+	registry := newRegistry(cfg.Limiter)
 	if cfg.RegisterCleanup != nil {
 		cfg.RegisterCleanup(registry.CloseAll)
 	}
@@ -54,7 +58,7 @@ func Enable(runtime *goja.Runtime, loop *eventloop.EventLoop, cfg *Config) error
 		fingerprint := util.SafeGet[string](runtime, FingerprintMagicKey)
 		promise, resolve, reject := runtime.NewPromise()
 		go func() {
-			metadata, err := registry.Open(fingerprint, cfg.ProxyHandler, request)
+			metadata, err := registry.Open(fingerprint, cfg.ProxyHandler, cfg.CheckURL, request)
 			ok := loop.RunOnLoop(func(runtime *goja.Runtime) {
 				if err != nil {
 					reject(runtime.NewGoError(err))
@@ -247,6 +251,30 @@ type registry struct {
 	cancel     context.CancelFunc
 	jar        http.CookieJar
 	closed     bool
+	// limiter limits the open requests, nil means unlimited
+	limiter *Limiter
+}
+
+// Limiter limits the fetch requests open at the same time, it can be shared by several runtimes.
+type Limiter struct {
+	max   int
+	slots chan struct{}
+}
+
+// NewLimiter returns a limiter of max open requests, nil if max is not positive which means unlimited.
+func NewLimiter(max int) *Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return &Limiter{max: max, slots: make(chan struct{}, max)}
+}
+
+// Max returns the max open requests, 0 means unlimited.
+func (l *Limiter) Max() int {
+	if l == nil {
+		return 0
+	}
+	return l.max
 }
 
 type operation struct {
@@ -257,21 +285,24 @@ type operation struct {
 	body      io.ReadCloser
 	pending   error
 	closed    bool
+	release   func()
 	closeOnce sync.Once
 }
 
-func newRegistry() *registry {
+func newRegistry(limiter *Limiter) *registry {
 	ctx, cancel := context.WithCancel(context.Background())
 	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	return &registry{
+	r := &registry{
 		operations: make(map[string]*operation),
 		ctx:        ctx,
 		cancel:     cancel,
 		jar:        jar,
+		limiter:    limiter,
 	}
+	return r
 }
 
-func (r *registry) Open(fingerprint string, proxyHandler func(*http.Request) (*url.URL, error), metadata *request) (*responseMetadata, error) {
+func (r *registry) Open(fingerprint string, proxyHandler func(*http.Request) (*url.URL, error), checkURL func(*url.URL) error, metadata *request) (*responseMetadata, error) {
 	ctx, cancel := context.WithCancel(r.ctx)
 	op := &operation{ctx: ctx, cancel: cancel}
 	if err := r.add(metadata.ID, op); err != nil {
@@ -284,6 +315,9 @@ func (r *registry) Open(fingerprint string, proxyHandler func(*http.Request) (*u
 			r.Close(metadata.ID)
 		}
 	}()
+	if err := r.acquire(op); err != nil {
+		return nil, err
+	}
 
 	contentType, body, contentLength, err := buildBody(metadata.Body)
 	if err != nil {
@@ -297,6 +331,11 @@ func (r *registry) Open(fingerprint string, proxyHandler func(*http.Request) (*u
 	if err != nil {
 		return nil, err
 	}
+	if checkURL != nil {
+		if err := checkURL(httpRequest.URL); err != nil {
+			return nil, err
+		}
+	}
 	for _, header := range metadata.Headers {
 		httpRequest.Header.Add(header[0], header[1])
 	}
@@ -318,7 +357,12 @@ func (r *registry) Open(fingerprint string, proxyHandler func(*http.Request) (*u
 	client, err := httpclient.NewClient(httpclient.Options{
 		Client: httpclient.ClientOptions{
 			Jar: jar,
-			CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if checkURL != nil {
+					if err := checkURL(req.URL); err != nil {
+						return err
+					}
+				}
 				switch metadata.Redirect {
 				case "manual":
 					return http.ErrUseLastResponse
@@ -387,6 +431,29 @@ func (r *registry) add(id string, op *operation) error {
 	return nil
 }
 
+// acquire waits for a free slot, the slot is held until the operation is closed.
+func (r *registry) acquire(op *operation) error {
+	if r.limiter == nil {
+		return nil
+	}
+	slots := r.limiter.slots
+	select {
+	case slots <- struct{}{}:
+	case <-op.ctx.Done():
+		return op.ctx.Err()
+	}
+	op.stateMu.Lock()
+	defer op.stateMu.Unlock()
+	if op.closed {
+		<-slots
+		return context.Canceled
+	}
+	op.release = func() {
+		<-slots
+	}
+	return nil
+}
+
 func (r *registry) Read(id string, chunkSize int) ([]byte, bool, error) {
 	op := r.get(id)
 	if op == nil {
@@ -492,10 +559,14 @@ func (o *operation) close() {
 		o.stateMu.Lock()
 		o.closed = true
 		body := o.body
+		release := o.release
 		o.stateMu.Unlock()
 		if body != nil {
 			_ = body.Close()
 		}
+		if release != nil {
+			release()
+		}
 	})
 }
 
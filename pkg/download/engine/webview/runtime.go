@@ -75,6 +75,8 @@ type Cookie struct {
 type Runtime struct {
 	opener    Opener
 	available bool
+	// denied is the error of opening a page when the runtime is not permitted
+	denied error
 
 	mu      sync.Mutex
 	pageSeq uint64
@@ -92,11 +94,22 @@ func NewRuntime(opener Opener, available bool) *Runtime {
 	}
 }
 
+// NewDeniedRuntime returns an unavailable runtime, opening a page fails with the error.
+func NewDeniedRuntime(err error) *Runtime {
+	return &Runtime{
+		denied: err,
+		pages:  make(map[string]Page),
+	}
+}
+
 func (r *Runtime) IsAvailable() bool {
 	return r != nil && r.available && r.opener != nil
 }
 
 func (r *Runtime) Open(opts ...map[string]any) (*PageHandle, error) {
+	if r != nil && r.denied != nil {
+		return nil, r.denied
+	}
 	if !r.IsAvailable() {
 		return nil, ErrUnavailable
 	}
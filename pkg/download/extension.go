@@ -2,6 +2,7 @@ package download
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,8 +10,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/GopeedLab/gopeed/internal/fetcher"
 	"github.com/GopeedLab/gopeed/internal/logger"
 	"github.com/GopeedLab/gopeed/pkg/base"
 	"github.com/GopeedLab/gopeed/pkg/download/engine"
@@ -37,18 +40,28 @@ var (
 type ActivationEvent string
 
 const (
-	EventOnResolve ActivationEvent = "onResolve"
-	EventOnStart   ActivationEvent = "onStart"
-	EventOnError   ActivationEvent = "onError"
-	EventOnDone    ActivationEvent = "onDone"
+	EventOnResolve     ActivationEvent = "onResolve"
+	EventOnCreate      ActivationEvent = "onCreate"
+	EventOnStart       ActivationEvent = "onStart"
+	EventOnProgress    ActivationEvent = "onProgress"
+	EventOnPause       ActivationEvent = "onPause"
+	EventOnError       ActivationEvent = "onError"
+	EventOnDone        ActivationEvent = "onDone"
+	EventOnDelete      ActivationEvent = "onDelete"
+	EventOnExtractDone ActivationEvent = "onExtractDone"
 )
 
 func (d *Downloader) InstallExtensionByGit(url string) (*Extension, error) {
 	return d.fetchExtensionByGit(url, d.InstallExtensionByFolder)
 }
 
+// PreviewExtensionByGit fetches the extension of a git repository without installing it, see PreviewExtensionByFolder.
+func (d *Downloader) PreviewExtensionByGit(url string) (*Extension, error) {
+	return d.fetchExtensionByGit(url, d.PreviewExtensionByFolder)
+}
+
 func (d *Downloader) InstallExtensionByFolder(path string, devMode bool) (*Extension, error) {
-	ext, err := d.parseExtensionByPath(path)
+	ext, err := d.PreviewExtensionByFolder(path, devMode)
 	if err != nil {
 		return nil, err
 	}
@@ -76,16 +89,48 @@ func (d *Downloader) InstallExtensionByFolder(path string, devMode bool) (*Exten
 	if err = d.storage.Put(bucketExtension, installedExt.Identity, installedExt); err != nil {
 		return nil, err
 	}
+	d.Logger.Info().Msgf("extension installed: %s %s, permissions: %s", installedExt.Identity, installedExt.Version, installedExt.Permissions)
 	return installedExt, nil
 }
 
-// UpgradeCheckExtension Check if there is a new version for the extension.
+// PreviewExtensionByFolder parses and verifies an extension package like the installation but doesn't install it,
+// so the permissions requested by the extension can be reviewed before it runs.
+func (d *Downloader) PreviewExtensionByFolder(path string, devMode bool) (*Extension, error) {
+	ext, err := d.parseExtensionByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkProtocolConflict(ext); err != nil {
+		return nil, err
+	}
+	if err = d.verifyExtensionPackage(ext, path, devMode); err != nil {
+		return nil, err
+	}
+	return ext, nil
+}
+
+// UpgradeCheckExtension Check if there is a new version for the extension, the extension index is checked before the git repository.
 func (d *Downloader) UpgradeCheckExtension(identity string) (newVersion string, err error) {
 	ext, err := d.GetExtension(identity)
 	if err != nil {
 		return
 	}
 	installUrl := ext.buildInstallUrl()
+	// the extension index is consulted first, it works without git access
+	indexVersion, err := d.findExtensionIndexVersion(ext.Identity)
+	if err != nil {
+		if installUrl == "" {
+			return
+		}
+		d.Logger.Warn().Err(err).Msgf("extension index unavailable, check %s by git", ext.Identity)
+		err = nil
+	}
+	if indexVersion != nil {
+		if compareExtensionVersion(indexVersion.Version, ext.Version) > 0 {
+			newVersion = indexVersion.Version
+		}
+		return
+	}
 	if installUrl == "" {
 		return
 	}
@@ -108,6 +153,20 @@ func (d *Downloader) UpgradeExtension(identity string) error {
 		return err
 	}
 	installUrl := ext.buildInstallUrl()
+	indexVersion, err := d.findExtensionIndexVersion(ext.Identity)
+	if err != nil {
+		if installUrl == "" {
+			return err
+		}
+		d.Logger.Warn().Err(err).Msgf("extension index unavailable, upgrade %s by git", ext.Identity)
+	}
+	if indexVersion != nil {
+		if compareExtensionVersion(indexVersion.Version, ext.Version) <= 0 {
+			return nil
+		}
+		_, err = d.fetchExtensionIndexVersion(ext.Identity, indexVersion, d.InstallExtensionByFolder)
+		return err
+	}
 	if installUrl == "" {
 		return nil
 	}
@@ -163,6 +222,9 @@ func (d *Downloader) DeleteExtension(identity string) error {
 	if err = d.storage.Delete(bucketExtensionStorage, identity); err != nil {
 		return err
 	}
+	d.fetchLimitersLock.Lock()
+	delete(d.fetchLimiters, identity)
+	d.fetchLimitersLock.Unlock()
 	return nil
 }
 
@@ -278,6 +340,39 @@ func (d *Downloader) triggerOnResolve(req *base.Request) (res *base.Resource, er
 	return
 }
 
+// triggerOnCreate lets the extensions adjust the options of a task being created, e.g. the path and the name.
+// A changed path must be allowed by the WhiteDownloadDirs and match one of the dirs of the creator.
+func (d *Downloader) triggerOnCreate(meta *fetcher.FetcherMeta, dirs []string) {
+	if meta.Opts == nil {
+		return
+	}
+	originPath := meta.Opts.Path
+	ctx := &OnCreateContext{
+		Req:  cloneRequest(meta.Req),
+		Opts: meta.Opts,
+	}
+	if meta.Res != nil {
+		ctx.Res = util.DeepClone(meta.Res)
+	}
+	doTrigger(d,
+		EventOnCreate,
+		meta.Req,
+		ctx,
+		func(ext *Extension, gopeed *Instance, ctx *OnCreateContext) {
+			ctx.Opts.Name = util.SafeFilename(ctx.Opts.Name)
+			if ctx.Opts.Path == originPath {
+				return
+			}
+			ctx.Opts.Path = util.ReplacePathPlaceholders(ctx.Opts.Path)
+			if ctx.Opts.Path == "" || (len(d.cfg.WhiteDownloadDirs) > 0 && !matchDownloadDir(d.cfg.WhiteDownloadDirs, ctx.Opts.Path)) ||
+				d.CheckDownloadDir(ctx.Opts, dirs) != nil {
+				gopeed.Logger.logger.Warn().Msgf("[%s] path not allowed: %s", ext.buildIdentity(), ctx.Opts.Path)
+				ctx.Opts.Path = originPath
+			}
+		},
+	)
+}
+
 func (d *Downloader) triggerOnStart(task *Task) {
 	doTrigger(d,
 		EventOnStart,
@@ -297,6 +392,70 @@ func (d *Downloader) triggerOnStart(task *Task) {
 	)
 }
 
+// triggerOnProgress runs the onProgress hooks of a running task at most once per progress interval.
+func (d *Downloader) triggerOnProgress(task *Task) {
+	if d.closed.Load() {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&task.progressHookAt)
+	if now-last < int64(d.extensionProgressInterval()) || !atomic.CompareAndSwapInt64(&task.progressHookAt, last, now) {
+		return
+	}
+	if !d.matchExtensions(EventOnProgress, task.Meta.Req) {
+		return
+	}
+	go doTrigger(d,
+		EventOnProgress,
+		task.Meta.Req,
+		&OnProgressContext{
+			Task: newOnDoneExtensionTask(task),
+		},
+		nil,
+	)
+}
+
+func (d *Downloader) triggerOnPause(task *Task) {
+	if d.closed.Load() || !d.matchExtensions(EventOnPause, task.Meta.Req) {
+		return
+	}
+	go doTrigger(d,
+		EventOnPause,
+		task.Meta.Req,
+		&OnPauseContext{
+			Task: newOnDoneExtensionTask(task),
+		},
+		nil,
+	)
+}
+
+func (d *Downloader) triggerOnDelete(task *Task, force bool) {
+	if d.closed.Load() || !d.matchExtensions(EventOnDelete, task.Meta.Req) {
+		return
+	}
+	go doTrigger(d,
+		EventOnDelete,
+		task.Meta.Req,
+		&OnDeleteContext{
+			Task:  newOnDoneExtensionTask(task),
+			Force: force,
+		},
+		nil,
+	)
+}
+
+func (d *Downloader) triggerOnExtractDone(task *Task, dir string) {
+	doTrigger(d,
+		EventOnExtractDone,
+		task.Meta.Req,
+		&OnExtractDoneContext{
+			Task: newOnDoneExtensionTask(task),
+			Dir:  dir,
+		},
+		nil,
+	)
+}
+
 func (d *Downloader) triggerOnError(task *Task, err error) {
 	doTrigger(d,
 		EventOnError,
@@ -320,14 +479,29 @@ func (d *Downloader) triggerOnDone(task *Task) {
 	)
 }
 
+// matchExtensions returns true if any enabled extension has a script for the event and the request.
+func (d *Downloader) matchExtensions(event ActivationEvent, req *base.Request) bool {
+	for _, ext := range d.extensions {
+		if !ext.active() {
+			continue
+		}
+		for _, script := range ext.Scripts {
+			if script.match(event, req) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func doTrigger[T any](d *Downloader, event ActivationEvent, req *base.Request, ctx T, handler func(ext *Extension, gopeed *Instance, ctx T)) error {
 	// init extension global object
 	gopeed := &Instance{
 		Events: make(InstanceEvents),
 	}
-	var err error
+	var err, hookErr error
 	for _, ext := range d.extensions {
-		if ext.Disabled {
+		if !ext.active() {
 			continue
 		}
 		for _, script := range ext.Scripts {
@@ -336,8 +510,9 @@ func doTrigger[T any](d *Downloader, event ActivationEvent, req *base.Request, c
 				gopeed.Logger = newInstanceLogger(ext, d.ExtensionLogger)
 				gopeed.Settings = parseSettings(ext.Settings)
 				gopeed.Storage = &ContextStorage{
-					storage:  d.storage,
-					identity: ext.buildIdentity(),
+					storage:     d.storage,
+					identity:    ext.buildIdentity(),
+					permissions: ext.Permissions,
 				}
 				scriptFilePath := filepath.Join(d.ExtensionPath(ext), script.Entry)
 				if _, err = os.Stat(scriptFilePath); os.IsNotExist(err) {
@@ -362,24 +537,44 @@ func doTrigger[T any](d *Downloader, event ActivationEvent, req *base.Request, c
 					if req.Labels == nil {
 						req.Labels = make(map[string]string)
 					}
-					engine, session := d.newExtensionEngine()
+					timeout, _ := d.extensionLimits(ext)
+					hookCtx, cancel := extensionHookContext(timeout)
+					defer cancel()
+					engine, session := d.newExtensionEngine(ext)
 					defer session.CloseIfIdle()
+					// a timed out hook may still be running, so the engine is discarded
+					checkTimeout := func() {
+						if errors.Is(err, ErrExtensionTimeout) {
+							session.Terminate()
+							err = &ExtensionError{Identity: ext.buildIdentity(), Event: event, Err: err}
+							if hookErr == nil {
+								hookErr = err
+							}
+						}
+					}
 					gopeed.Runtime = &InstanceRuntime{
-						WebView: d.newExtensionWebViewRuntime(session),
+						WebView: d.newExtensionWebViewRuntime(session, ext.Permissions),
 					}
 					err = injectGopeed(engine.Runtime, gopeed)
 					if err != nil {
 						gopeed.Logger.logger.Error().Err(err).Msgf("[%s] engine inject failed", ext.buildIdentity())
 						return
 					}
-					_, err = engine.RunString(string(scriptBuf))
+					_, err = engine.RunStringContext(hookCtx, string(scriptBuf))
 					if err != nil {
+						checkTimeout()
 						gopeed.Logger.logger.Error().Err(err).Msgf("[%s] run script failed", ext.buildIdentity())
 						return
 					}
 					if fn, ok := gopeed.Events[event]; ok {
-						_, err = engine.CallFunction(fn, ctx)
+						revoke := func() {}
+						if pc, ok := any(ctx).(permissionContext); ok {
+							revoke = pc.grant(ext.Permissions)
+						}
+						_, err = engine.CallFunctionContext(hookCtx, fn, ctx)
+						revoke()
 						if err != nil {
+							checkTimeout()
 							gopeed.Logger.logger.Error().Err(err).Msgf("[%s] call function failed: %s", ext.buildIdentity(), event)
 							return
 						}
@@ -392,11 +587,11 @@ func doTrigger[T any](d *Downloader, event ActivationEvent, req *base.Request, c
 		}
 	}
 
-	// Only return MessageError
+	// Only return MessageError, or the error of a hook that failed to finish
 	if me, ok := gojautil.AssertError[*gojaerror.MessageError](err); ok {
 		return me
 	}
-	return nil
+	return hookErr
 }
 
 func (d *Downloader) ExtensionPath(ext *Extension) string {
@@ -421,9 +616,21 @@ type Extension struct {
 	// Repository git repository info
 	Repository *Repository `json:"repository"`
 	Scripts    []*Script   `json:"scripts"`
-	Settings   []*Setting  `json:"settings"`
+	// Protocols are the url schemes downloaded by the extension
+	Protocols []*Protocol `json:"protocols"`
+	Settings  []*Setting  `json:"settings"`
+	// Permissions the extension requests, nil means full access for the legacy extensions
+	Permissions *Permissions `json:"permissions"`
 	// Disabled if true, this extension will be ignored
 	Disabled bool `json:"disabled"`
+	// Limits overrides the global execution limits, nil means using the global limits
+	Limits *base.ExtensionLimits `json:"limits"`
+	// Signer is the trusted publisher key that signed the package, empty means it's not verified
+	Signer string `json:"signer"`
+	// Files are the sha256 hashes of the installed files, used to detect tampering at load time
+	Files map[string]string `json:"files"`
+	// IntegrityError is why the extension is blocked at load time, e.g. its files are tampered
+	IntegrityError string `json:"integrityError"`
 
 	DevMode bool `json:"devMode"`
 	// DevPath is the local path of extension source code
@@ -443,9 +650,19 @@ func (e *Extension) validate() error {
 	if e.Version == "" {
 		return fmt.Errorf("extension version is required")
 	}
+	for _, protocol := range e.Protocols {
+		if err := protocol.validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// active returns true if the extension is enabled and not blocked by the integrity check.
+func (e *Extension) active() bool {
+	return !e.Disabled && e.IntegrityError == ""
+}
+
 func (e *Extension) buildIdentity() string {
 	if e.Author == "" {
 		return e.Name
@@ -479,6 +696,11 @@ func (e *Extension) update(newExt *Extension) error {
 	e.Homepage = newExt.Homepage
 	e.Repository = newExt.Repository
 	e.Scripts = newExt.Scripts
+	e.Protocols = newExt.Protocols
+	e.Permissions = newExt.Permissions
+	e.Signer = newExt.Signer
+	e.Files = newExt.Files
+	e.IntegrityError = ""
 	// merge settings
 	// if new setting not exist in old settings, append it
 	for _, newSetting := range newExt.Settings {
@@ -629,10 +851,22 @@ func (h InstanceEvents) OnResolve(fn engine.JSFunction) {
 	h.register(EventOnResolve, fn)
 }
 
+func (h InstanceEvents) OnCreate(fn engine.JSFunction) {
+	h.register(EventOnCreate, fn)
+}
+
 func (h InstanceEvents) OnStart(fn engine.JSFunction) {
 	h.register(EventOnStart, fn)
 }
 
+func (h InstanceEvents) OnProgress(fn engine.JSFunction) {
+	h.register(EventOnProgress, fn)
+}
+
+func (h InstanceEvents) OnPause(fn engine.JSFunction) {
+	h.register(EventOnPause, fn)
+}
+
 func (h InstanceEvents) OnError(fn engine.JSFunction) {
 	h.register(EventOnError, fn)
 }
@@ -641,6 +875,14 @@ func (h InstanceEvents) OnDone(fn engine.JSFunction) {
 	h.register(EventOnDone, fn)
 }
 
+func (h InstanceEvents) OnDelete(fn engine.JSFunction) {
+	h.register(EventOnDelete, fn)
+}
+
+func (h InstanceEvents) OnExtractDone(fn engine.JSFunction) {
+	h.register(EventOnExtractDone, fn)
+}
+
 type ExtensionInfo struct {
 	Identity string `json:"identity"`
 	Name     string `json:"name"`
@@ -704,10 +946,25 @@ type OnResolveContext struct {
 	Res *base.Resource `json:"res"`
 }
 
+// OnCreateContext is the context of a task being created, only the options can be modified.
+type OnCreateContext struct {
+	Req  *base.Request  `json:"req"`
+	Res  *base.Resource `json:"res"`
+	Opts *base.Options  `json:"opts"`
+}
+
 type OnStartContext struct {
 	Task *ExtensionTask `json:"task"`
 }
 
+type OnProgressContext struct {
+	Task *Task `json:"task"`
+}
+
+type OnPauseContext struct {
+	Task *Task `json:"task"`
+}
+
 type OnErrorContext struct {
 	Task  *OnErrorExtensionTask `json:"task"`
 	Error error                 `json:"error"`
@@ -717,11 +974,25 @@ type OnDoneContext struct {
 	Task *Task `json:"task"`
 }
 
+type OnDeleteContext struct {
+	Task *Task `json:"task"`
+	// Force is true if the downloaded files are deleted as well
+	Force bool `json:"force"`
+}
+
+// OnExtractDoneContext is the context of a task whose archive is extracted to the dir.
+type OnExtractDoneContext struct {
+	Task *Task  `json:"task"`
+	Dir  string `json:"dir"`
+}
+
 // ExtensionTask is a wrapper of Task, it's used to interact with extension scripts.
 // Avoid extension scripts modifying task directly, use ExtensionTask to encapsulate task,
 // only some fields can be modified, such as request info.
 type ExtensionTask struct {
 	*Task
+
+	permissions *Permissions
 }
 
 // OnErrorExtensionTask adds error-recovery controls to ExtensionTask.
@@ -758,11 +1029,18 @@ func newOnDoneExtensionTask(task *Task) *Task {
 }
 
 // SetUrl replaces the task request URL.
-func (t *ExtensionTask) SetUrl(url string) {
+func (t *ExtensionTask) SetUrl(url string) error {
+	if err := t.permissions.checkTask(); err != nil {
+		return err
+	}
 	t.Meta.Req.URL = url
+	return nil
 }
 
 func (t *OnErrorExtensionTask) Continue() error {
+	if err := t.permissions.checkTask(); err != nil {
+		return err
+	}
 	return t.download.Continue(&TaskFilter{
 		IDs: []string{t.ID},
 	})
@@ -805,41 +1083,57 @@ func tryParse(val any, settingType SettingType) any {
 }
 
 type ContextStorage struct {
-	storage  Storage
-	identity string
+	storage     Storage
+	identity    string
+	permissions *Permissions
 }
 
-func (s *ContextStorage) Get(key string) any {
+func (s *ContextStorage) Get(key string) (any, error) {
+	if err := s.permissions.checkStorage(); err != nil {
+		return nil, err
+	}
 	raw := s.getRawData()
 	if v, ok := raw[key]; ok {
-		return v
+		return v, nil
 	}
-	return nil
+	return nil, nil
 }
 
-func (s *ContextStorage) Set(key string, value string) {
+func (s *ContextStorage) Set(key string, value string) error {
+	if err := s.permissions.checkStorage(); err != nil {
+		return err
+	}
 	raw := s.getRawData()
 	raw[key] = value
-	s.storage.Put(bucketExtensionStorage, s.identity, raw)
+	return s.storage.Put(bucketExtensionStorage, s.identity, raw)
 }
 
-func (s *ContextStorage) Remove(key string) {
+func (s *ContextStorage) Remove(key string) error {
+	if err := s.permissions.checkStorage(); err != nil {
+		return err
+	}
 	raw := s.getRawData()
 	delete(raw, key)
-	s.storage.Put(bucketExtensionStorage, s.identity, raw)
+	return s.storage.Put(bucketExtensionStorage, s.identity, raw)
 }
 
-func (s *ContextStorage) Keys() []string {
+func (s *ContextStorage) Keys() ([]string, error) {
+	if err := s.permissions.checkStorage(); err != nil {
+		return nil, err
+	}
 	raw := s.getRawData()
 	keys := make([]string, 0)
 	for k := range raw {
 		keys = append(keys, k)
 	}
-	return keys
+	return keys, nil
 }
 
-func (s *ContextStorage) Clear() {
-	s.storage.Delete(bucketExtensionStorage, s.identity)
+func (s *ContextStorage) Clear() error {
+	if err := s.permissions.checkStorage(); err != nil {
+		return err
+	}
+	return s.storage.Delete(bucketExtensionStorage, s.identity)
 }
 
 func (s *ContextStorage) getRawData() map[string]string {
@@ -0,0 +1,308 @@
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mholt/archives"
+)
+
+const (
+	// maxExtensionArchiveSize is the max size of an extension archive
+	maxExtensionArchiveSize = 100 << 20
+	// maxExtensionExtractedSize and maxExtensionArchiveEntries limit the extracted content of an extension archive
+	maxExtensionExtractedSize  = 200 << 20
+	maxExtensionArchiveEntries = 10000
+	extensionFetchTimeout      = 5 * time.Minute
+)
+
+var (
+	ErrExtensionArchiveTooLarge     = errors.New("extension archive is too large")
+	ErrExtensionArchiveInvalidPath  = errors.New("extension archive contains a path outside of the extension")
+	ErrExtensionChecksumMismatch    = errors.New("extension archive checksum mismatch")
+	ErrExtensionInvalidChecksum     = errors.New("invalid extension checksum, must be algorithm:hex and the algorithm is sha256 or sha512")
+	ErrExtensionUnsupportedArchive  = errors.New("unsupported extension archive, only .zip, .tgz and .tar.gz are supported")
+	ErrExtensionUnsupportedLocation = errors.New("unsupported extension location, only http and https urls are supported")
+)
+
+var extensionArchiveSuffixes = []string{".zip", ".tgz", ".tar.gz"}
+
+// IsExtensionArchive returns true if the name is a supported extension archive, e.g. a url ending with .zip.
+func IsExtensionArchive(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	for _, suffix := range extensionArchiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallExtensionByArchive installs an extension from the content of a .zip or .tgz archive, the manifest can be
+// at the root of the archive or in its only top directory, e.g. the source archive of a git repository.
+func (d *Downloader) InstallExtensionByArchive(reader io.Reader) (*Extension, error) {
+	return d.fetchExtensionByArchive(reader, "", d.InstallExtensionByFolder)
+}
+
+// PreviewExtensionByArchive reads the extension in a .zip or .tgz archive without installing it.
+func (d *Downloader) PreviewExtensionByArchive(reader io.Reader) (*Extension, error) {
+	return d.fetchExtensionByArchive(reader, "", d.PreviewExtensionByFolder)
+}
+
+// InstallExtensionByURL downloads a .zip or .tgz archive and installs the extension in it,
+// the archive is verified if the checksum is not empty, in the format of algorithm:hex.
+func (d *Downloader) InstallExtensionByURL(url string, checksum string) (*Extension, error) {
+	return d.fetchExtensionByURL(url, checksum, d.InstallExtensionByFolder)
+}
+
+// PreviewExtensionByURL downloads a .zip or .tgz archive and reads the extension in it without installing it.
+func (d *Downloader) PreviewExtensionByURL(url string, checksum string) (*Extension, error) {
+	return d.fetchExtensionByURL(url, checksum, d.PreviewExtensionByFolder)
+}
+
+func (d *Downloader) fetchExtensionByURL(url string, checksum string, handler func(tempExtPath string, devMode bool) (*Extension, error)) (*Extension, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, ErrExtensionUnsupportedLocation
+	}
+	return d.fetchExtensionByLocation(url, checksum, handler)
+}
+
+// fetchExtensionByLocation fetches the extension archive from a http url or a local path.
+func (d *Downloader) fetchExtensionByLocation(location string, checksum string, handler func(tempExtPath string, devMode bool) (*Extension, error)) (*Extension, error) {
+	reader, err := d.openExtensionLocation(location, extensionFetchTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return d.fetchExtensionByArchive(reader, checksum, handler)
+}
+
+func (d *Downloader) fetchExtensionByArchive(reader io.Reader, checksum string, handler func(tempExtPath string, devMode bool) (*Extension, error)) (*Extension, error) {
+	var h hash.Hash
+	var want string
+	if checksum != "" {
+		var err error
+		if h, want, err = parseExtensionChecksum(checksum); err != nil {
+			return nil, err
+		}
+	}
+
+	tempDir := filepath.Join(d.cfg.StorageDir, tempExtensionsDir, fmt.Sprintf("archive_%d", time.Now().UnixNano()))
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	archivePath := filepath.Join(tempDir, "archive")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = file
+	if h != nil {
+		w = io.MultiWriter(file, h)
+	}
+	n, err := io.Copy(w, io.LimitReader(reader, maxExtensionArchiveSize+1))
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if n > maxExtensionArchiveSize {
+		return nil, ErrExtensionArchiveTooLarge
+	}
+	if h != nil && hex.EncodeToString(h.Sum(nil)) != want {
+		return nil, ErrExtensionChecksumMismatch
+	}
+
+	extDir := filepath.Join(tempDir, "extension")
+	if err := extractExtensionArchive(archivePath, extDir); err != nil {
+		if errors.Is(err, ErrExtensionArchiveTooLarge) || errors.Is(err, ErrExtensionArchiveInvalidPath) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrExtensionUnsupportedArchive, err)
+	}
+	return handler(findExtensionRoot(extDir), false)
+}
+
+// extractExtensionArchive extracts a zip or tar.gz archive, the other formats detected from the content,
+// e.g. rar, 7z or a single gz file, are rejected. The extracted size and the number of entries are limited,
+// and the entries outside of the destination directory are rejected.
+func extractExtensionArchive(archivePath string, destDir string) error {
+	info, err := openArchive(archivePath, "")
+	if err != nil {
+		return err
+	}
+	defer info.file.Close()
+
+	var extractor archives.Extractor
+	switch f := info.format.(type) {
+	case archives.Zip:
+		extractor = f
+	case archives.CompressedArchive:
+		_, gz := f.Compression.(archives.Gz)
+		_, tar := f.Extraction.(archives.Tar)
+		if gz && tar {
+			extractor = f
+		}
+	}
+	if extractor == nil {
+		return fmt.Errorf("archive format %s", info.format.Extension())
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+
+	entries := 0
+	remaining := int64(maxExtensionExtractedSize)
+	return extractor.Extract(context.Background(), info.input, func(ctx context.Context, fileInfo archives.FileInfo) error {
+		entries++
+		if entries > maxExtensionArchiveEntries {
+			return ErrExtensionArchiveTooLarge
+		}
+		return extractExtensionEntry(fileInfo, destDir, &remaining)
+	})
+}
+
+// extractExtensionEntry extracts a directory or a regular file of an extension archive, the other entries, e.g.
+// symlinks, are skipped. The modes in the archive are ignored, directories are 0755 and files are 0644.
+func extractExtensionEntry(fileInfo archives.FileInfo, destDir string, remaining *int64) error {
+	destPath, err := extensionArchivePath(destDir, fileInfo.NameInArchive)
+	if err != nil {
+		return err
+	}
+	if fileInfo.IsDir() {
+		return os.MkdirAll(destPath, 0755)
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	reader, err := fileInfo.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	file, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	// the size in the header can't be trusted, the extracted bytes are counted instead
+	if _, err := io.Copy(file, &limitedFile{File: reader, remaining: remaining}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// extensionArchivePath returns the extracted path of an archive entry. The archive can be built on any platform,
+// so absolute names, volume names and parent elements are rejected with both slash and backslash separators.
+func extensionArchivePath(destDir string, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || (len(slashed) >= 2 && slashed[1] == ':') ||
+		slices.Contains(strings.Split(slashed, "/"), "..") || !filepath.IsLocal(filepath.FromSlash(slashed)) {
+		return "", fmt.Errorf("%w: %s", ErrExtensionArchiveInvalidPath, name)
+	}
+	return filepath.Join(destDir, filepath.FromSlash(slashed)), nil
+}
+
+// limitedFile fails the reads with ErrExtensionArchiveTooLarge once the remaining bytes are used up.
+type limitedFile struct {
+	fs.File
+	remaining *int64
+}
+
+func (f *limitedFile) Read(p []byte) (int, error) {
+	if int64(len(p)) > *f.remaining+1 {
+		p = p[:*f.remaining+1]
+	}
+	n, err := f.File.Read(p)
+	*f.remaining -= int64(n)
+	if *f.remaining < 0 {
+		return n, ErrExtensionArchiveTooLarge
+	}
+	return n, err
+}
+
+// openExtensionLocation opens a http url or a local path, the local path is only used by the extension index.
+func (d *Downloader) openExtensionLocation(location string, timeout time.Duration) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "https://") && !strings.HasPrefix(location, "http://") {
+		return os.Open(strings.TrimPrefix(location, "file://"))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: d.cfg.Proxy.ToHandler(),
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("extension response status: %d", resp.StatusCode)
+	}
+	return &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// findExtensionRoot returns the directory of the manifest, which is the extracted directory or its only subdirectory.
+func findExtensionRoot(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err == nil {
+		return dir
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, entries[0].Name())
+}
+
+func parseExtensionChecksum(checksum string) (hash.Hash, string, error) {
+	algorithm, want, ok := strings.Cut(checksum, ":")
+	if !ok || want == "" {
+		return nil, "", ErrExtensionInvalidChecksum
+	}
+	switch strings.ToLower(algorithm) {
+	case "sha256":
+		return sha256.New(), strings.ToLower(want), nil
+	case "sha512":
+		return sha512.New(), strings.ToLower(want), nil
+	default:
+		return nil, "", ErrExtensionInvalidChecksum
+	}
+}
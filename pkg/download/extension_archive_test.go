@@ -0,0 +1,264 @@
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsExtensionArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"https://example.com/ext.zip", true},
+		{"https://example.com/ext.TGZ", true},
+		{"https://example.com/ext.tar.gz?token=1", true},
+		{"https://github.com/GopeedLab/gopeed-extension-samples#github-release-sample", false},
+		{"https://example.com/ext.gz", false},
+	}
+	for _, tt := range tests {
+		if got := IsExtensionArchive(tt.name); got != tt.want {
+			t.Errorf("IsExtensionArchive(%s) got = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDownloader_InstallExtensionByArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive func(t *testing.T, files map[string]string) []byte
+		prefix  string
+	}{
+		{"zip", buildTestZip, ""},
+		{"zipTopDir", buildTestZip, "sample-main/"},
+		{"tgz", buildTestTgz, ""},
+		{"tgzTopDir", buildTestTgz, "package/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDownloader(func(downloader *Downloader) {
+				data := tt.archive(t, archiveTestExtensionFiles(tt.prefix, "archive", "1.0.0"))
+				ext, err := downloader.InstallExtensionByArchive(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ext.Identity != "gopeed@archive" || ext.Version != "1.0.0" {
+					t.Errorf("InstallExtensionByArchive() got = %s %s", ext.Identity, ext.Version)
+				}
+			})
+		})
+	}
+
+	setupDownloader(func(downloader *Downloader) {
+		data := buildTestZip(t, map[string]string{"readme.md": "no manifest"})
+		if _, err := downloader.InstallExtensionByArchive(bytes.NewReader(data)); !errors.Is(err, ErrExtensionNoManifest) {
+			t.Errorf("InstallExtensionByArchive() error got = %v, want %v", err, ErrExtensionNoManifest)
+		}
+		if _, err := downloader.InstallExtensionByArchive(bytes.NewReader([]byte("not an archive"))); err == nil {
+			t.Errorf("InstallExtensionByArchive() invalid archive expect error")
+		}
+	})
+}
+
+func TestDownloader_InstallExtensionByArchive_Limits(t *testing.T) {
+	files := archiveTestExtensionFiles("", "archive", "1.0.0")
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	io.WriteString(gw, files["manifest.json"])
+	gw.Close()
+	var tarData bytes.Buffer
+	tw := tar.NewWriter(&tarData)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		io.WriteString(tw, content)
+	}
+	tw.Close()
+
+	manyFiles := make(map[string]string, maxExtensionArchiveEntries+1)
+	for i := 0; i <= maxExtensionArchiveEntries; i++ {
+		manyFiles[fmt.Sprintf("file%d.txt", i)] = ""
+	}
+	// a small archive of a large file
+	var bomb bytes.Buffer
+	bw := gzip.NewWriter(&bomb)
+	btw := tar.NewWriter(bw)
+	btw.WriteHeader(&tar.Header{Name: "bomb.bin", Mode: 0644, Size: maxExtensionExtractedSize + 1})
+	io.Copy(btw, io.LimitReader(zeroReader{}, maxExtensionExtractedSize+1))
+	btw.Close()
+	bw.Close()
+
+	withFile := func(name string, content string) map[string]string {
+		m := archiveTestExtensionFiles("", "archive", "1.0.0")
+		m[name] = content
+		return m
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"parent directory", buildTestZip(t, withFile("../../escape/", "")), ErrExtensionArchiveInvalidPath},
+		{"parent file", buildTestTgz(t, withFile("lib/../../escape.js", "")), ErrExtensionArchiveInvalidPath},
+		{"backslash parent", buildTestZip(t, withFile(`..\..\escape.js`, "")), ErrExtensionArchiveInvalidPath},
+		{"absolute", buildTestTgz(t, withFile("/escape.js", "")), ErrExtensionArchiveInvalidPath},
+		{"volume", buildTestZip(t, withFile("C:/escape.js", "")), ErrExtensionArchiveInvalidPath},
+		{"gz", gz.Bytes(), ErrExtensionUnsupportedArchive},
+		{"tar", tarData.Bytes(), ErrExtensionUnsupportedArchive},
+		{"7z", append([]byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C, 0, 4}, make([]byte, 64)...), ErrExtensionUnsupportedArchive},
+		{"too many entries", buildTestZip(t, manyFiles), ErrExtensionArchiveTooLarge},
+		{"too large extracted", bomb.Bytes(), ErrExtensionArchiveTooLarge},
+	}
+	setupDownloader(func(downloader *Downloader) {
+		for _, tt := range tests {
+			if _, err := downloader.InstallExtensionByArchive(bytes.NewReader(tt.data)); !errors.Is(err, tt.want) {
+				t.Errorf("InstallExtensionByArchive() %s error got = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+		if len(downloader.GetExtensions()) != 0 {
+			t.Errorf("InstallExtensionByArchive() installed a rejected archive")
+		}
+		if _, err := os.Stat(filepath.Join(downloader.cfg.StorageDir, tempExtensionsDir, "escape")); !os.IsNotExist(err) {
+			t.Errorf("InstallExtensionByArchive() created a directory outside of the extension, stat error = %v", err)
+		}
+	})
+}
+
+func TestExtractExtensionArchive_Modes(t *testing.T) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0777})
+	tw.WriteHeader(&tar.Header{Name: "lib/index.js", Typeflag: tar.TypeReg, Mode: 0777, Size: 2})
+	io.WriteString(tw, "{}")
+	tw.WriteHeader(&tar.Header{Name: "link.js", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0777})
+	tw.Close()
+	gw.Close()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive")
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destDir := filepath.Join(dir, "extension")
+	if err := extractExtensionArchive(archivePath, destDir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "lib")); err != nil || info.Mode().Perm()&0022 != 0 {
+		t.Errorf("extractExtensionArchive() directory got = %v, %v, want mode 0755", info, err)
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "lib", "index.js")); err != nil || info.Mode().Perm()&0133 != 0 {
+		t.Errorf("extractExtensionArchive() file got = %v, %v, want mode 0644", info, err)
+	}
+	if _, err := os.Lstat(filepath.Join(destDir, "link.js")); !os.IsNotExist(err) {
+		t.Errorf("extractExtensionArchive() symlink should be skipped, lstat error = %v", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestDownloader_InstallExtensionByURL(t *testing.T) {
+	data := buildTestTgz(t, archiveTestExtensionFiles("", "archive", "1.0.0"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		if _, err := downloader.InstallExtensionByURL(server.URL+"/ext.tgz", "sha256:"+hex.EncodeToString(make([]byte, 32))); !errors.Is(err, ErrExtensionChecksumMismatch) {
+			t.Errorf("InstallExtensionByURL() error got = %v, want %v", err, ErrExtensionChecksumMismatch)
+		}
+		if _, err := downloader.InstallExtensionByURL(server.URL+"/ext.tgz", "crc32:00"); !errors.Is(err, ErrExtensionInvalidChecksum) {
+			t.Errorf("InstallExtensionByURL() error got = %v, want %v", err, ErrExtensionInvalidChecksum)
+		}
+		if _, err := downloader.InstallExtensionByURL("/etc/ext.tgz", ""); !errors.Is(err, ErrExtensionUnsupportedLocation) {
+			t.Errorf("InstallExtensionByURL() error got = %v, want %v", err, ErrExtensionUnsupportedLocation)
+		}
+		if len(downloader.GetExtensions()) != 0 {
+			t.Fatalf("InstallExtensionByURL() installed a rejected archive")
+		}
+		ext, err := downloader.InstallExtensionByURL(server.URL+"/ext.tgz", sha256Checksum(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ext.Identity != "gopeed@archive" {
+			t.Errorf("InstallExtensionByURL() got = %s", ext.Identity)
+		}
+	})
+}
+
+func archiveTestExtensionFiles(prefix string, name string, version string) map[string]string {
+	return map[string]string{
+		prefix + "manifest.json": `{
+  "name": "` + name + `",
+  "author": "gopeed",
+  "title": "Archive",
+  "version": "` + version + `",
+  "scripts": [
+    {
+      "event": "onResolve",
+      "match": {
+        "urls": ["*://example.com/*"]
+      },
+      "entry": "index.js"
+    }
+  ]
+}`,
+		prefix + "index.js": `gopeed.events.onResolve((ctx) => {});`,
+	}
+}
+
+func buildTestZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTestTgz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -19,18 +19,19 @@ func (d *Downloader) NewExtensionEngine(ext *Extension, settings map[string]any)
 	if ext == nil {
 		return nil, fmt.Errorf("extension is nil")
 	}
-	engine, session := d.newExtensionEngine()
+	engine, session := d.newExtensionEngine(ext)
 	gopeed := &Instance{
 		Events:   make(InstanceEvents),
 		Info:     NewExtensionInfo(ext),
 		Logger:   newInstanceLogger(ext, d.ExtensionLogger),
 		Settings: settings,
 		Storage: &ContextStorage{
-			storage:  d.storage,
-			identity: ext.buildIdentity(),
+			storage:     d.storage,
+			identity:    ext.buildIdentity(),
+			permissions: ext.Permissions,
 		},
 		Runtime: &InstanceRuntime{
-			WebView: d.newExtensionWebViewRuntime(session),
+			WebView: d.newExtensionWebViewRuntime(session, ext.Permissions),
 		},
 	}
 	if err := injectGopeed(engine.Runtime, gopeed); err != nil {
@@ -72,6 +72,21 @@ func (s *engineSession) CloseIfIdle() {
 	}
 }
 
+// Terminate closes the session even if it's retained, the engine is closed in the background because
+// the loop may be still blocked by the script.
+func (s *engineSession) Terminate() {
+	s.mu.Lock()
+	shouldClose := !s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if shouldClose {
+		s.runClosers()
+		if s.engine != nil {
+			go s.engine.Close()
+		}
+	}
+}
+
 func (s *engineSession) OnClose(fn func()) {
 	if fn == nil {
 		return
@@ -96,10 +111,14 @@ func (s *engineSession) runClosers() {
 	}
 }
 
-func (d *Downloader) newExtensionEngine() (*engine.Engine, *engineSession) {
+func (d *Downloader) newExtensionEngine(ext *Extension) (*engine.Engine, *engineSession) {
+	fetchLimiter := d.extensionFetchLimiter(ext)
 	session := newEngineSession(nil)
 	engineCfg := &stream.Config{
 		CreateObjectURL: func(opts *stream.ObjectURLOptions, open stream.ObjectURLOpener) (string, error) {
+			if err := ext.Permissions.checkBlob(); err != nil {
+				return "", err
+			}
 			createOpts := &internalblob.CreateOptions{
 				Session: session,
 			}
@@ -120,14 +139,16 @@ func (d *Downloader) newExtensionEngine() (*engine.Engine, *engineSession) {
 		},
 	}
 	e := engine.NewEngine(&engine.Config{
-		ProxyConfig:  d.cfg.Proxy,
-		StreamConfig: engineCfg,
+		ProxyConfig:   d.cfg.Proxy,
+		StreamConfig:  engineCfg,
+		FetchLimiter:  fetchLimiter,
+		CheckFetchURL: ext.Permissions.checkURL,
 	})
 	session.SetEngine(e)
 	return e, session
 }
 
-func (d *Downloader) newExtensionWebViewRuntime(session *engineSession) *enginewebview.Runtime {
+func (d *Downloader) newExtensionWebViewRuntime(session *engineSession, permissions *Permissions) *enginewebview.Runtime {
 	var (
 		opener    enginewebview.Opener
 		available bool
@@ -137,6 +158,9 @@ func (d *Downloader) newExtensionWebViewRuntime(session *engineSession) *enginew
 		available = true
 	}
 	runtime := enginewebview.NewRuntime(opener, available)
+	if err := permissions.checkWebView(); err != nil {
+		runtime = enginewebview.NewDeniedRuntime(err)
+	}
 	session.OnClose(func() {
 		_ = runtime.Close()
 	})
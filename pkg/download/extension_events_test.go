@@ -0,0 +1,211 @@
+package download
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/internal/test"
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func TestDownloader_Extension_OnCreate(t *testing.T) {
+	listener := test.StartTestFileServer()
+	defer listener.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		installEventsTestExtension(t, downloader, "onCreate", "", `
+gopeed.events.onCreate((ctx) => {
+  ctx.opts.path = ctx.opts.path + "/videos";
+  ctx.opts.name = "renamed/" + ctx.opts.name;
+});`)
+		opts := newTestDownloadOpt(t)
+		originPath := opts.Path
+		id, err := downloader.CreateDirect(&base.Request{
+			URL: "http://" + listener.Addr().String() + "/" + test.BuildName,
+		}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := downloader.GetTask(id)
+		if want := originPath + "/videos"; task.Meta.Opts.Path != want {
+			t.Errorf("onCreate() path got = %s, want %s", task.Meta.Opts.Path, want)
+		}
+		if name := task.Meta.Opts.Name; strings.ContainsAny(name, `/\`) || !strings.HasPrefix(name, "renamed") {
+			t.Errorf("onCreate() name got = %s", name)
+		}
+	})
+}
+
+func TestDownloader_Extension_OnCreatePermission(t *testing.T) {
+	listener := test.StartTestFileServer()
+	defer listener.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		installEventsTestExtension(t, downloader, "onCreate", `"permissions": {},`, `
+gopeed.events.onCreate((ctx) => {
+  ctx.opts.path = ctx.opts.path + "/changed";
+});`)
+		opts := newTestDownloadOpt(t)
+		originPath := opts.Path
+		id, err := downloader.CreateDirect(&base.Request{
+			URL: "http://" + listener.Addr().String() + "/" + test.BuildName,
+		}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := downloader.GetTask(id).Meta.Opts.Path; got != originPath {
+			t.Errorf("onCreate() denied path got = %s, want %s", got, originPath)
+		}
+	})
+}
+
+func TestDownloader_Extension_OnCreateDownloadDirs(t *testing.T) {
+	listener := test.StartTestFileServer()
+	defer listener.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		installEventsTestExtension(t, downloader, "onCreate", "", `
+gopeed.events.onCreate((ctx) => {
+  ctx.opts.path = ctx.opts.path + "/videos";
+});`)
+		tests := []struct {
+			name    string
+			dirs    func(originPath string) []string
+			changed bool
+		}{
+			{"allowed", func(originPath string) []string { return []string{originPath, originPath + "/*"} }, true},
+			{"outside the creator dirs", func(originPath string) []string { return []string{originPath} }, false},
+		}
+		for _, tt := range tests {
+			opts := newTestDownloadOpt(t)
+			originPath := opts.Path
+			id, err := downloader.CreateDirect(&base.Request{
+				URL: "http://" + listener.Addr().String() + "/" + test.BuildName,
+			}, opts, tt.dirs(originPath)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := originPath
+			if tt.changed {
+				want = originPath + "/videos"
+			}
+			if got := downloader.GetTask(id).Meta.Opts.Path; got != want {
+				t.Errorf("onCreate() %s path got = %s, want %s", tt.name, got, want)
+			}
+		}
+	})
+}
+
+func TestDownloader_Extension_OnPauseDelete(t *testing.T) {
+	listener := test.StartTestSlowFileServer(time.Millisecond * 500)
+	defer listener.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		pauseExt := installEventsTestExtension(t, downloader, "onPause", "", `
+gopeed.events.onPause((ctx) => {
+  gopeed.storage.set("task", ctx.task.id);
+});`)
+		deleteExt := installEventsTestExtension(t, downloader, "onDelete", "", `
+gopeed.events.onDelete((ctx) => {
+  gopeed.storage.set("task", ctx.task.id + ":" + ctx.force);
+});`)
+		id, err := downloader.CreateDirect(&base.Request{
+			URL: "http://" + listener.Addr().String() + "/" + test.BuildName,
+		}, newTestDownloadOpt(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := downloader.Pause(&TaskFilter{IDs: []string{id}}); err != nil {
+			t.Fatal(err)
+		}
+		waitExtensionStorage(t, downloader, pauseExt, "task", id)
+		if err := downloader.Delete(&TaskFilter{IDs: []string{id}}, true); err != nil {
+			t.Fatal(err)
+		}
+		waitExtensionStorage(t, downloader, deleteExt, "task", id+":true")
+	})
+}
+
+func TestDownloader_Extension_OnProgress(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		ext := installEventsTestExtension(t, downloader, "onProgress", "", `
+gopeed.events.onProgress((ctx) => {
+  gopeed.storage.set("count", String(Number(gopeed.storage.get("count") || 0) + 1));
+});`)
+		task := NewTask()
+		task.Meta = &fetcher.FetcherMeta{
+			Req:  &base.Request{URL: "https://example.com/file"},
+			Opts: &base.Options{},
+		}
+		for i := 0; i < 3; i++ {
+			downloader.triggerOnProgress(task)
+		}
+		waitExtensionStorage(t, downloader, ext, "count", "1")
+		time.Sleep(time.Millisecond * 200)
+		if got := extensionStorageValue(downloader, ext, "count"); got != "1" {
+			t.Errorf("onProgress() throttled count got = %s, want 1", got)
+		}
+	})
+}
+
+func TestDownloader_Extension_OnExtractDone(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		ext := installEventsTestExtension(t, downloader, "onExtractDone", "", `
+gopeed.events.onExtractDone((ctx) => {
+  gopeed.storage.set("dir", ctx.task.id + ":" + ctx.dir);
+});`)
+		task := NewTask()
+		task.Meta = &fetcher.FetcherMeta{
+			Req:  &base.Request{URL: "https://example.com/file.zip"},
+			Opts: &base.Options{},
+		}
+		downloader.triggerOnExtractDone(task, "/extracted")
+		if got, want := extensionStorageValue(downloader, ext, "dir"), task.ID+":/extracted"; got != want {
+			t.Errorf("onExtractDone() got = %s, want %s", got, want)
+		}
+	})
+}
+
+func installEventsTestExtension(t *testing.T, downloader *Downloader, event string, permissions string, script string) *Extension {
+	return installTestExtension(t, downloader, map[string]string{
+		"manifest.json": `{
+  "name": "events-` + event + `",
+  "author": "gopeed",
+  "title": "Events",
+  "version": "0.0.1",
+  ` + permissions + `
+  "scripts": [
+    {
+      "event": "` + event + `",
+      "match": {
+        "urls": ["*://*/*"]
+      },
+      "entry": "index.js"
+    }
+  ]
+}`,
+		"index.js": script,
+	})
+}
+
+func extensionStorageValue(downloader *Downloader, ext *Extension, key string) string {
+	var data map[string]string
+	downloader.storage.Get(bucketExtensionStorage, ext.Identity, &data)
+	return data[key]
+}
+
+func waitExtensionStorage(t *testing.T, downloader *Downloader, ext *Extension, key string, want string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := extensionStorageValue(downloader, ext, key)
+		if got == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("extension storage %s got = %s, want %s", key, got, want)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,186 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+const (
+	// maxExtensionIndexSize is the max size of the extension index
+	maxExtensionIndexSize = 10 << 20
+	extensionIndexTimeout = 30 * time.Second
+)
+
+var (
+	ErrExtensionIndexNotConfigured = errors.New("extension index is not configured")
+	ErrExtensionIndexNotFound      = errors.New("extension not found in the index")
+)
+
+// latestExtensionIndexVersion returns the highest version of an index entry.
+func latestExtensionIndexVersion(e *base.ExtensionIndexEntry) *base.ExtensionIndexVersion {
+	var latest *base.ExtensionIndexVersion
+	for _, v := range e.Versions {
+		if v.URL == "" {
+			continue
+		}
+		if latest == nil || compareExtensionVersion(v.Version, latest.Version) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// GetExtensionIndex fetches the configured extension index, the package urls are resolved to absolute ones.
+func (d *Downloader) GetExtensionIndex() (*base.ExtensionIndex, error) {
+	indexURL := d.extensionIndexURL()
+	if indexURL == "" {
+		return nil, ErrExtensionIndexNotConfigured
+	}
+	reader, err := d.openExtensionLocation(indexURL, extensionIndexTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, maxExtensionIndexSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxExtensionIndexSize {
+		return nil, errors.New("extension index is too large")
+	}
+	var index base.ExtensionIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid extension index: %w", err)
+	}
+	if index.Extensions == nil {
+		index.Extensions = make([]*base.ExtensionIndexEntry, 0)
+	}
+	for _, entry := range index.Extensions {
+		for _, v := range entry.Versions {
+			v.URL = resolveExtensionIndexURL(indexURL, v.URL)
+		}
+	}
+	return &index, nil
+}
+
+// InstallExtensionByIndex installs the latest version of an extension in the extension index.
+func (d *Downloader) InstallExtensionByIndex(identity string) (*Extension, error) {
+	return d.fetchExtensionByIndex(identity, d.InstallExtensionByFolder)
+}
+
+// PreviewExtensionByIndex fetches the latest version of an extension in the extension index without installing it.
+func (d *Downloader) PreviewExtensionByIndex(identity string) (*Extension, error) {
+	return d.fetchExtensionByIndex(identity, d.PreviewExtensionByFolder)
+}
+
+func (d *Downloader) fetchExtensionByIndex(identity string, handler func(tempExtPath string, devMode bool) (*Extension, error)) (*Extension, error) {
+	version, err := d.findExtensionIndexVersion(identity)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, ErrExtensionIndexNotFound
+	}
+	return d.fetchExtensionIndexVersion(identity, version, handler)
+}
+
+func (d *Downloader) fetchExtensionIndexVersion(identity string, version *base.ExtensionIndexVersion, handler func(tempExtPath string, devMode bool) (*Extension, error)) (*Extension, error) {
+	return d.fetchExtensionByLocation(version.URL, version.Checksum, func(tempExtPath string, devMode bool) (*Extension, error) {
+		ext, err := d.parseExtensionByPath(tempExtPath)
+		if err != nil {
+			return nil, err
+		}
+		if ext.Identity != identity {
+			return nil, fmt.Errorf("extension package identity mismatch: got %s, want %s", ext.Identity, identity)
+		}
+		return handler(tempExtPath, devMode)
+	})
+}
+
+// findExtensionIndexVersion returns the latest version of an extension in the index,
+// nil means the index is not configured or the extension is not in it.
+func (d *Downloader) findExtensionIndexVersion(identity string) (*base.ExtensionIndexVersion, error) {
+	if d.extensionIndexURL() == "" {
+		return nil, nil
+	}
+	index, err := d.GetExtensionIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range index.Extensions {
+		if entry.Identity == identity {
+			return latestExtensionIndexVersion(entry), nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *Downloader) extensionIndexURL() string {
+	cfg := d.cfg.DownloaderStoreConfig.Extension
+	if cfg == nil {
+		return ""
+	}
+	return strings.TrimSpace(cfg.IndexURL)
+}
+
+// resolveExtensionIndexURL resolves a package url relative to the index url, which is a http url or a local path.
+func resolveExtensionIndexURL(indexURL string, ref string) string {
+	if ref == "" || strings.Contains(ref, "://") || filepath.IsAbs(ref) {
+		return ref
+	}
+	if strings.HasPrefix(indexURL, "https://") || strings.HasPrefix(indexURL, "http://") {
+		base, err := url.Parse(indexURL)
+		if err != nil {
+			return ref
+		}
+		u, err := base.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		return u.String()
+	}
+	return filepath.Join(filepath.Dir(strings.TrimPrefix(indexURL, "file://")), filepath.FromSlash(ref))
+}
+
+// compareExtensionVersion compares two semantic versions, a leading v is ignored and a pre-release
+// version is lower than its release, e.g. 1.0.0-beta < 1.0.0 < 1.0.1 < 1.1.
+func compareExtensionVersion(a string, b string) int {
+	a, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	b, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return strings.Compare(aPre, bPre)
+	}
+}
@@ -0,0 +1,157 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func TestCompareExtensionVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0.0", 0},
+		{"1.0.1", "1.0.0", 1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.1", "1.0.9", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+	}
+	for _, tt := range tests {
+		if got := compareExtensionVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareExtensionVersion(%s, %s) got = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDownloader_ExtensionIndex(t *testing.T) {
+	v1 := buildTestZip(t, archiveTestExtensionFiles("", "indexed", "1.0.0"))
+	v2 := buildTestTgz(t, archiveTestExtensionFiles("package/", "indexed", "1.2.0"))
+	other := buildTestZip(t, archiveTestExtensionFiles("", "other", "1.0.0"))
+	index := `{
+  "extensions": [
+    {
+      "identity": "gopeed@indexed",
+      "title": "Indexed",
+      "versions": [
+        {"version": "1.0.0", "url": "packages/indexed-1.0.0.zip", "checksum": "` + sha256Checksum(v1) + `"},
+        {"version": "1.2.0", "url": "packages/indexed-1.2.0.tgz", "checksum": "` + sha256Checksum(v2) + `"}
+      ]
+    },
+    {
+      "identity": "gopeed@spoofed",
+      "versions": [
+        {"version": "1.0.0", "url": "packages/other-1.0.0.zip"}
+      ]
+    }
+  ]
+}`
+	files := map[string][]byte{
+		"/index/index.json":                 []byte(index),
+		"/index/packages/indexed-1.0.0.zip": v1,
+		"/index/packages/indexed-1.2.0.tgz": v2,
+		"/index/packages/other-1.0.0.zip":   other,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		if _, err := downloader.GetExtensionIndex(); !errors.Is(err, ErrExtensionIndexNotConfigured) {
+			t.Errorf("GetExtensionIndex() error got = %v, want %v", err, ErrExtensionIndexNotConfigured)
+		}
+		setExtensionIndexURL(t, downloader, server.URL+"/index/index.json")
+
+		idx, err := downloader.GetExtensionIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := idx.Extensions[0].Versions[0].URL, server.URL+"/index/packages/indexed-1.0.0.zip"; got != want {
+			t.Errorf("GetExtensionIndex() url got = %s, want %s", got, want)
+		}
+
+		// install the old version to check the upgrade from the index
+		installed, err := downloader.InstallExtensionByURL(idx.Extensions[0].Versions[0].URL, idx.Extensions[0].Versions[0].Checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newVersion, err := downloader.UpgradeCheckExtension(installed.Identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newVersion != "1.2.0" {
+			t.Errorf("UpgradeCheckExtension() got = %s, want 1.2.0", newVersion)
+		}
+		if err := downloader.UpgradeExtension(installed.Identity); err != nil {
+			t.Fatal(err)
+		}
+		if installed.Version != "1.2.0" {
+			t.Errorf("UpgradeExtension() version got = %s, want 1.2.0", installed.Version)
+		}
+		if newVersion, err = downloader.UpgradeCheckExtension(installed.Identity); err != nil || newVersion != "" {
+			t.Errorf("UpgradeCheckExtension() after upgrade got = %s, %v", newVersion, err)
+		}
+
+		if _, err := downloader.InstallExtensionByIndex("gopeed@spoofed"); err == nil {
+			t.Errorf("InstallExtensionByIndex() identity mismatch expect error")
+		}
+		if _, err := downloader.InstallExtensionByIndex("gopeed@missing"); !errors.Is(err, ErrExtensionIndexNotFound) {
+			t.Errorf("InstallExtensionByIndex() error got = %v, want %v", err, ErrExtensionIndexNotFound)
+		}
+	})
+}
+
+func TestDownloader_ExtensionIndexLocal(t *testing.T) {
+	dir := t.TempDir()
+	data := buildTestZip(t, archiveTestExtensionFiles("", "local", "1.0.0"))
+	if err := os.WriteFile(filepath.Join(dir, "local.zip"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := `{"extensions": [{"identity": "gopeed@local", "versions": [{"version": "1.0.0", "url": "local.zip", "checksum": "` + sha256Checksum(data) + `"}]}]}`
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	setupDownloader(func(downloader *Downloader) {
+		setExtensionIndexURL(t, downloader, filepath.Join(dir, "index.json"))
+		ext, err := downloader.InstallExtensionByIndex("gopeed@local")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ext.Version != "1.0.0" {
+			t.Errorf("InstallExtensionByIndex() version got = %s, want 1.0.0", ext.Version)
+		}
+	})
+}
+
+func setExtensionIndexURL(t *testing.T, downloader *Downloader, indexURL string) {
+	cfg, err := downloader.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Extension = &base.ExtensionConfig{IndexURL: indexURL}
+	if err := downloader.PutConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sha256Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
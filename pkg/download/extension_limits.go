@@ -0,0 +1,99 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	fetchapi "github.com/GopeedLab/gopeed/pkg/download/engine/inject/fetch"
+)
+
+var ErrExtensionTimeout = errors.New("extension hook timed out")
+
+const defaultExtensionProgressInterval = 5 * time.Second
+
+// ExtensionError is the error of an extension hook that failed to finish, e.g. it timed out.
+type ExtensionError struct {
+	Identity string          `json:"identity"`
+	Event    ActivationEvent `json:"event"`
+	Err      error           `json:"-"`
+}
+
+func (e *ExtensionError) Error() string {
+	return fmt.Sprintf("extension %s %s: %v", e.Identity, e.Event, e.Err)
+}
+
+func (e *ExtensionError) Unwrap() error {
+	return e.Err
+}
+
+func (d *Downloader) UpdateExtensionLimits(identity string, limits *base.ExtensionLimits) error {
+	ext, err := d.GetExtension(identity)
+	if err != nil {
+		return err
+	}
+	if limits != nil && *limits == (base.ExtensionLimits{}) {
+		limits = nil
+	}
+	ext.Limits = limits
+	return d.storage.Put(bucketExtension, ext.Identity, ext)
+}
+
+// extensionLimits returns the execution limits of an extension, 0 means unlimited.
+func (d *Downloader) extensionLimits(ext *Extension) (timeout time.Duration, maxFetches int) {
+	cfg := d.cfg.DownloaderStoreConfig.Extension
+	if cfg == nil {
+		// the config may be replaced by a client that doesn't know the extension config
+		cfg = (&base.DownloaderStoreConfig{}).Init().Extension
+	}
+	hookTimeout, maxFetches := cfg.HookTimeout, cfg.MaxConcurrentFetches
+	if ext.Limits != nil {
+		if ext.Limits.HookTimeout != 0 {
+			hookTimeout = ext.Limits.HookTimeout
+		}
+		if ext.Limits.MaxConcurrentFetches != 0 {
+			maxFetches = ext.Limits.MaxConcurrentFetches
+		}
+	}
+	if hookTimeout > 0 {
+		timeout = time.Duration(hookTimeout) * time.Second
+	}
+	if maxFetches < 0 {
+		maxFetches = 0
+	}
+	return
+}
+
+// extensionFetchLimiter returns the fetch limiter of an extension, it's shared by the engines of all hooks
+// of the extension, so the limit holds for the hooks running at the same time. nil means unlimited.
+func (d *Downloader) extensionFetchLimiter(ext *Extension) *fetchapi.Limiter {
+	_, maxFetches := d.extensionLimits(ext)
+	d.fetchLimitersLock.Lock()
+	defer d.fetchLimitersLock.Unlock()
+	limiter, ok := d.fetchLimiters[ext.Identity]
+	// the running hooks keep the replaced limiter until they finish
+	if !ok || limiter.Max() != maxFetches {
+		limiter = fetchapi.NewLimiter(maxFetches)
+		d.fetchLimiters[ext.Identity] = limiter
+	}
+	return limiter
+}
+
+// extensionProgressInterval returns the min interval between two onProgress hooks of a task.
+func (d *Downloader) extensionProgressInterval() time.Duration {
+	cfg := d.cfg.DownloaderStoreConfig.Extension
+	if cfg == nil || cfg.ProgressInterval <= 0 {
+		return defaultExtensionProgressInterval
+	}
+	return time.Duration(cfg.ProgressInterval) * time.Second
+}
+
+// extensionHookContext returns the context of running a hook, it's done with ErrExtensionTimeout as the cause on timeout.
+func extensionHookContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeoutCause(context.Background(), timeout, ErrExtensionTimeout)
+}
@@ -0,0 +1,135 @@
+package download
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func TestDownloader_Extension_HookTimeout(t *testing.T) {
+	scripts := map[string]string{
+		"infinite loop":   `gopeed.events.onResolve((ctx) => { while (true) {} });`,
+		"pending promise": `gopeed.events.onResolve((ctx) => new Promise(() => {}));`,
+		"top level loop":  `while (true) {}`,
+	}
+	for name, script := range scripts {
+		t.Run(name, func(t *testing.T) {
+			setupDownloader(func(downloader *Downloader) {
+				ext := installLimitsTestExtension(t, downloader, script)
+				if err := downloader.UpdateExtensionLimits(ext.Identity, &base.ExtensionLimits{HookTimeout: 1}); err != nil {
+					t.Fatal(err)
+				}
+
+				startedAt := time.Now()
+				_, err := downloader.Resolve(&base.Request{URL: "https://example.com/test"}, nil)
+				var extErr *ExtensionError
+				if !errors.As(err, &extErr) {
+					t.Fatalf("expect ExtensionError, but got %v", err)
+				}
+				if extErr.Identity != ext.Identity || extErr.Event != EventOnResolve || !errors.Is(err, ErrExtensionTimeout) {
+					t.Fatalf("unexpected ExtensionError: %v", extErr)
+				}
+				if elapsed := time.Since(startedAt); elapsed > 3*time.Second {
+					t.Fatalf("hook timeout took %s", elapsed)
+				}
+			})
+		})
+	}
+}
+
+func TestDownloader_UpdateExtensionLimits(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		ext := installLimitsTestExtension(t, downloader, `gopeed.events.onResolve((ctx) => {});`)
+		cfg, err := downloader.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Extension = &base.ExtensionConfig{HookTimeout: 10, MaxConcurrentFetches: 4}
+		if err := downloader.PutConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+
+		if timeout, maxFetches := downloader.extensionLimits(ext); timeout != 10*time.Second || maxFetches != 4 {
+			t.Errorf("extensionLimits() global got = %s, %d", timeout, maxFetches)
+		}
+		if err := downloader.UpdateExtensionLimits(ext.Identity, &base.ExtensionLimits{HookTimeout: -1, MaxConcurrentFetches: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if timeout, maxFetches := downloader.extensionLimits(ext); timeout != 0 || maxFetches != 1 {
+			t.Errorf("extensionLimits() override got = %s, %d", timeout, maxFetches)
+		}
+		if err := downloader.UpdateExtensionLimits(ext.Identity, &base.ExtensionLimits{}); err != nil {
+			t.Fatal(err)
+		}
+		if ext.Limits != nil {
+			t.Errorf("UpdateExtensionLimits() zero limits got = %v, want nil", ext.Limits)
+		}
+		if err := downloader.UpdateExtensionLimits("not-exist", nil); err != ErrExtensionNotFound {
+			t.Errorf("UpdateExtensionLimits() not found got = %v, want %v", err, ErrExtensionNotFound)
+		}
+	})
+}
+
+func TestDownloader_Extension_FetchLimit(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	setupDownloader(func(downloader *Downloader) {
+		ext := installLimitsTestExtension(t, downloader, `gopeed.events.onResolve(async (ctx) => {
+  await Promise.all([1, 2, 3].map(async () => (await fetch("`+server.URL+`")).text()));
+  ctx.res = {
+    name: "limits",
+    files: [{ name: "a.txt", req: { url: "https://example.com/a.txt" } }],
+  };
+});`)
+		if err := downloader.UpdateExtensionLimits(ext.Identity, &base.ExtensionLimits{MaxConcurrentFetches: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		// the hooks of two resolves run at the same time and share the limit of the extension
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := downloader.Resolve(&base.Request{URL: "https://example.com/test"}, nil)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := maxRunning.Load(); got != 2 {
+			t.Errorf("expect max 2 concurrent fetches of the extension, but got %d", got)
+		}
+	})
+}
+
+func installLimitsTestExtension(t *testing.T, downloader *Downloader, script string) *Extension {
+	files := archiveTestExtensionFiles("", "limits", "0.0.1")
+	files["index.js"] = script
+	return installTestExtension(t, downloader, files)
+}
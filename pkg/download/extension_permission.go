@@ -0,0 +1,158 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/util"
+)
+
+var ErrExtensionPermissionDenied = errors.New("extension permission denied")
+
+// Permissions are the rights an extension requests in the manifest. An extension without permissions is a legacy
+// extension and has full access.
+type Permissions struct {
+	// Hosts are the urls that fetch and XMLHttpRequest can access, it uses the same match expressions as Match.Urls
+	Hosts []string `json:"hosts"`
+	// WebView allows opening web pages by gopeed.runtime.webview
+	WebView bool `json:"webview"`
+	// Blob allows creating object urls by gopeed.runtime.blob
+	Blob bool `json:"blob"`
+	// Storage allows using gopeed.storage
+	Storage bool `json:"storage"`
+	// Task allows modifying the request of the task in onStart and onError, continuing the task in onError and
+	// modifying the options in onCreate
+	Task bool `json:"task"`
+}
+
+// String describes the requested permissions.
+func (p *Permissions) String() string {
+	if p == nil {
+		return "unrestricted (no permissions declared)"
+	}
+	var rights []string
+	if len(p.Hosts) > 0 {
+		rights = append(rights, "fetch "+strings.Join(p.Hosts, " "))
+	}
+	if p.WebView {
+		rights = append(rights, "webview")
+	}
+	if p.Blob {
+		rights = append(rights, "blob")
+	}
+	if p.Storage {
+		rights = append(rights, "storage")
+	}
+	if p.Task {
+		rights = append(rights, "task")
+	}
+	if len(rights) == 0 {
+		return "none"
+	}
+	return strings.Join(rights, ", ")
+}
+
+// checkURL checks if fetch can access the url, redirects are checked as well.
+func (p *Permissions) checkURL(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+	for _, host := range p.Hosts {
+		if util.Match(host, u.String()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: fetch %s", ErrExtensionPermissionDenied, u.Host)
+}
+
+func (p *Permissions) check(name string, granted bool) error {
+	if p == nil || granted {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrExtensionPermissionDenied, name)
+}
+
+func (p *Permissions) checkWebView() error {
+	return p.check("webview", p != nil && p.WebView)
+}
+
+func (p *Permissions) checkBlob() error {
+	return p.check("blob", p != nil && p.Blob)
+}
+
+func (p *Permissions) checkStorage() error {
+	return p.check("storage", p != nil && p.Storage)
+}
+
+func (p *Permissions) checkTask() error {
+	return p.check("task", p != nil && p.Task)
+}
+
+// permissionContext is a hook context exposing the task, it's granted the permissions of each extension running the hook.
+type permissionContext interface {
+	// grant returns a revoke function to undo the changes not permitted
+	grant(permissions *Permissions) (revoke func())
+}
+
+func (c *OnStartContext) grant(permissions *Permissions) func() {
+	return c.Task.grant(permissions)
+}
+
+func (c *OnErrorContext) grant(permissions *Permissions) func() {
+	return c.Task.grant(permissions)
+}
+
+// grant shares the request with the task, so the request is restored when the task permission is not granted.
+func (t *ExtensionTask) grant(permissions *Permissions) func() {
+	t.permissions = permissions
+	if permissions.checkTask() == nil || t.Meta.Req == nil {
+		return func() {}
+	}
+	req := t.Meta.Req
+	origin := cloneRequest(req)
+	return func() {
+		*req = *origin
+	}
+}
+
+func (c *OnCreateContext) grant(permissions *Permissions) func() {
+	if permissions.checkTask() == nil {
+		return func() {}
+	}
+	opts := c.Opts
+	origin := cloneOptions(opts)
+	return func() {
+		*opts = *origin
+	}
+}
+
+// cloneRequest deep clones the request, the extra keeps its type.
+func cloneRequest(req *base.Request) *base.Request {
+	clone := util.DeepClone(req)
+	clone.Extra = cloneExtra(req.Extra)
+	return clone
+}
+
+// cloneOptions deep clones the options, the extra keeps its type.
+func cloneOptions(opts *base.Options) *base.Options {
+	clone := util.DeepClone(opts)
+	clone.Extra = cloneExtra(opts.Extra)
+	return clone
+}
+
+func cloneExtra(extra any) any {
+	v := reflect.ValueOf(extra)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return *util.DeepClone(&extra)
+	}
+	clone := reflect.New(v.Type().Elem())
+	if buf, err := json.Marshal(extra); err != nil || json.Unmarshal(buf, clone.Interface()) != nil {
+		return nil
+	}
+	return clone.Interface()
+}
@@ -0,0 +1,192 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	fhttp "github.com/GopeedLab/gopeed/pkg/protocol/http"
+)
+
+func TestDownloader_Extension_Permissions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://localhost/denied", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	script := `
+async function check(name, fn) {
+  try {
+    await fn();
+    return name + "_ok";
+  } catch (e) {
+    return String(e).includes("permission denied") ? name + "_denied" : name + "_error";
+  }
+}
+
+gopeed.events.onResolve(async (ctx) => {
+  const results = [
+    await check("fetch", async () => (await fetch("` + server.URL + `/allowed")).text()),
+    await check("xhr", () => new Promise((resolve, reject) => {
+      const xhr = new XMLHttpRequest();
+      xhr.open("GET", "` + server.URL + `/allowed");
+      xhr.onload = resolve;
+      xhr.onerror = () => reject(new Error("xhr failed"));
+      xhr.send();
+    })),
+    await check("redirect", async () => (await fetch("` + server.URL + `/redirect")).text()),
+    await check("storage", () => gopeed.storage.set("key", "value")),
+    await check("webview", () => gopeed.runtime.webview.open()),
+    await check("blob", () => gopeed.runtime.blob.createObjectURL(new Blob(["blob"]))),
+  ];
+  ctx.res = {
+    name: results.join("-"),
+    files: [{ name: "a.txt", req: { url: "https://example.com/a.txt" } }],
+  };
+});`
+	tests := []struct {
+		name        string
+		permissions string
+		want        string
+	}{
+		{"legacy", "", "fetch_ok-xhr_ok-redirect_error-storage_ok-webview_error-blob_ok"},
+		{"none", `"permissions": {},`, "fetch_denied-xhr_error-redirect_denied-storage_denied-webview_denied-blob_denied"},
+		{"granted", `"permissions": {"hosts": ["*://127.0.0.1/*"], "storage": true, "blob": true},`,
+			"fetch_ok-xhr_ok-redirect_denied-storage_ok-webview_denied-blob_ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDownloader(func(downloader *Downloader) {
+				extDir := t.TempDir()
+				manifest := `{
+  "name": "permissions",
+  "author": "gopeed",
+  "title": "Permissions",
+  "version": "0.0.1",
+  ` + tt.permissions + `
+  "scripts": [
+    {
+      "event": "onResolve",
+      "match": {
+        "urls": ["*://example.com/*"]
+      },
+      "entry": "index.js"
+    }
+  ]
+}`
+				if err := os.WriteFile(filepath.Join(extDir, "manifest.json"), []byte(manifest), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(extDir, "index.js"), []byte(script), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := downloader.InstallExtensionByFolder(extDir, false); err != nil {
+					t.Fatal(err)
+				}
+				rr, err := downloader.Resolve(&base.Request{URL: "https://example.com/test"}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rr.Res.Name != tt.want {
+					t.Errorf("Resolve() got = %s, want %s", rr.Res.Name, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestDownloader_PreviewExtension(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		ext, err := downloader.PreviewExtensionByFolder("./testdata/extensions/permissions", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &Permissions{Hosts: []string{"*://example.com/*"}, Storage: true}
+		if !reflect.DeepEqual(ext.Permissions, want) {
+			t.Errorf("PreviewExtensionByFolder() permissions got = %+v, want %+v", ext.Permissions, want)
+		}
+
+		files := make(map[string]string)
+		for _, name := range []string{"manifest.json", "index.js"} {
+			data, err := os.ReadFile(filepath.Join("./testdata/extensions/permissions", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[name] = string(data)
+		}
+		ext, err = downloader.PreviewExtensionByArchive(bytes.NewReader(buildTestZip(t, files)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ext.Permissions, want) {
+			t.Errorf("PreviewExtensionByArchive() permissions got = %+v, want %+v", ext.Permissions, want)
+		}
+
+		if len(downloader.GetExtensions()) != 0 {
+			t.Errorf("PreviewExtension() should not install the extension")
+		}
+		if _, err := os.Stat(downloader.ExtensionPath(ext)); !os.IsNotExist(err) {
+			t.Errorf("PreviewExtension() should not copy the extension, stat error = %v", err)
+		}
+	})
+}
+
+func TestExtensionTask_Grant(t *testing.T) {
+	task := NewTask()
+	task.Meta = &fetcher.FetcherMeta{
+		Req: &base.Request{
+			URL:   "https://example.com/file",
+			Extra: &fhttp.ReqExtra{Header: map[string]string{"User-Agent": "gopeed"}},
+		},
+		Opts: &base.Options{},
+	}
+
+	ctx := &OnStartContext{Task: newOnStartExtensionTask(task)}
+	revoke := ctx.grant(&Permissions{})
+	if err := ctx.Task.SetUrl("https://example.com/changed"); !errors.Is(err, ErrExtensionPermissionDenied) {
+		t.Errorf("SetUrl() error = %v, want %v", err, ErrExtensionPermissionDenied)
+	}
+	ctx.Task.Meta.Req.URL = "https://example.com/changed"
+	ctx.Task.Meta.Req.Extra.(*fhttp.ReqExtra).Header["User-Agent"] = "changed"
+	revoke()
+	if task.Meta.Req.URL != "https://example.com/file" {
+		t.Errorf("grant() denied url got = %s", task.Meta.Req.URL)
+	}
+	if extra, ok := task.Meta.Req.Extra.(*fhttp.ReqExtra); !ok || extra.Header["User-Agent"] != "gopeed" {
+		t.Errorf("grant() denied extra got = %#v", task.Meta.Req.Extra)
+	}
+
+	revoke = ctx.grant(&Permissions{Task: true})
+	if err := ctx.Task.SetUrl("https://example.com/changed"); err != nil {
+		t.Fatal(err)
+	}
+	revoke()
+	if task.Meta.Req.URL != "https://example.com/changed" {
+		t.Errorf("grant() url got = %s", task.Meta.Req.URL)
+	}
+}
+
+func TestPermissions_String(t *testing.T) {
+	var legacy *Permissions
+	if got := legacy.String(); !strings.Contains(got, "unrestricted") {
+		t.Errorf("String() legacy got = %s", got)
+	}
+	if got := (&Permissions{}).String(); got != "none" {
+		t.Errorf("String() none got = %s", got)
+	}
+	if got := (&Permissions{Hosts: []string{"*://*.example.com/*"}, Storage: true}).String(); got != "fetch *://*.example.com/*, storage" {
+		t.Errorf("String() got = %s", got)
+	}
+}
@@ -0,0 +1,518 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	gohttp "net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/GopeedLab/gopeed/internal/controller"
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/internal/protocol/http"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/download/engine"
+	gojaerror "github.com/GopeedLab/gopeed/pkg/download/engine/inject/error"
+	gojautil "github.com/GopeedLab/gopeed/pkg/download/engine/util"
+	fhttp "github.com/GopeedLab/gopeed/pkg/protocol/http"
+	"github.com/GopeedLab/gopeed/pkg/util"
+	"github.com/dop251/goja"
+)
+
+// the protocol hooks are registered by gopeed.protocol, they are only called for the schemes declared in the manifest
+const (
+	eventProtocolResolve ActivationEvent = "protocol.onResolve"
+	eventProtocolOpen    ActivationEvent = "protocol.onOpen"
+)
+
+var (
+	ErrExtensionProtocolConflict = errors.New("extension protocol scheme is already registered")
+	ErrExtensionProtocolInvalid  = errors.New("extension protocol resource invalid")
+)
+
+var protocolSchemeRegex = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// Protocol is a url scheme downloaded by an extension, the entry script registers the resolve and open handlers
+// by gopeed.protocol. The file is served to the downloader as a blob, so the blob permission is required if the
+// extension declares the permissions.
+type Protocol struct {
+	// Scheme is the url scheme, e.g. myservice for myservice://
+	Scheme string `json:"scheme"`
+	// Entry js script file path
+	Entry string `json:"entry"`
+}
+
+func (p *Protocol) validate() error {
+	if !protocolSchemeRegex.MatchString(p.Scheme) {
+		return fmt.Errorf("extension protocol scheme is invalid: %s", p.Scheme)
+	}
+	if p.Entry == "" {
+		return fmt.Errorf("extension protocol entry is required: %s", p.Scheme)
+	}
+	return nil
+}
+
+// OnProtocolOpenContext is the context of opening the file of an extension protocol task, the offset and the
+// end are the byte range to read, the end is -1 if it's read to the end of the file.
+type OnProtocolOpenContext struct {
+	Req    *base.Request  `json:"req"`
+	Res    *base.Resource `json:"res"`
+	Offset int64          `json:"offset"`
+	End    int64          `json:"end"`
+}
+
+// checkProtocolConflict checks if the schemes of the extension are handled by the downloader or another extension.
+func (d *Downloader) checkProtocolConflict(ext *Extension) error {
+	for _, protocol := range ext.Protocols {
+		for _, fm := range d.cfg.FetchManagers {
+			for _, filter := range fm.Filters() {
+				if filter.Match(protocol.Scheme + "://") {
+					return fmt.Errorf("%w: %s", ErrExtensionProtocolConflict, protocol.Scheme)
+				}
+			}
+		}
+		for _, installed := range d.extensions {
+			if installed.Identity == ext.Identity {
+				continue
+			}
+			for _, p := range installed.Protocols {
+				if p.Scheme == protocol.Scheme {
+					return fmt.Errorf("%w: %s by %s", ErrExtensionProtocolConflict, protocol.Scheme, installed.Identity)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseExtensionFm returns the fetcher manager of the enabled extension that handles the url scheme.
+func (d *Downloader) parseExtensionFm(url string) fetcher.FetcherManager {
+	for _, ext := range d.extensions {
+		if !ext.active() {
+			continue
+		}
+		for _, protocol := range ext.Protocols {
+			fm := &extensionFetcherManager{
+				d:        d,
+				identity: ext.Identity,
+				scheme:   protocol.Scheme,
+			}
+			for _, filter := range fm.Filters() {
+				if filter.Match(url) {
+					return fm
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// extensionFetcherManager builds the fetchers of an extension protocol, the extension is looked up on each use
+// so that an upgraded extension takes effect on the existing tasks.
+type extensionFetcherManager struct {
+	d        *Downloader
+	identity string
+	scheme   string
+}
+
+func (fm *extensionFetcherManager) Name() string {
+	return fm.scheme
+}
+
+func (fm *extensionFetcherManager) Filters() []*fetcher.SchemeFilter {
+	return []*fetcher.SchemeFilter{
+		{
+			Type:    fetcher.FilterTypeUrl,
+			Pattern: fm.scheme,
+		},
+	}
+}
+
+func (fm *extensionFetcherManager) Build() fetcher.Fetcher {
+	return &extensionFetcher{fm: fm}
+}
+
+func (fm *extensionFetcherManager) ParseName(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "" || name == "/" || name == "." {
+		name = parsed.Host
+	}
+	return name
+}
+
+func (fm *extensionFetcherManager) AutoRename() bool {
+	return true
+}
+
+func (fm *extensionFetcherManager) DefaultConfig() any {
+	return nil
+}
+
+func (fm *extensionFetcherManager) Store(f fetcher.Fetcher) (any, error) {
+	ef := f.(*extensionFetcher)
+	if ef.http == nil {
+		return nil, nil
+	}
+	return new(http.FetcherManager).Store(ef.http)
+}
+
+func (fm *extensionFetcherManager) Restore() (v any, f func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher) {
+	v, restore := new(http.FetcherManager).Restore()
+	return v, func(meta *fetcher.FetcherMeta, v any) fetcher.Fetcher {
+		ef := &extensionFetcher{fm: fm, meta: meta}
+		if meta.Res != nil {
+			ef.http = restore(ef.blobMeta(), v)
+		}
+		return ef
+	}
+}
+
+func (fm *extensionFetcherManager) Close() error {
+	return nil
+}
+
+func (fm *extensionFetcherManager) extension() (*Extension, *Protocol, error) {
+	ext := fm.d.getExtension(fm.identity)
+	if ext == nil || !ext.active() {
+		return nil, nil, fmt.Errorf("%w: %s", ErrExtensionNotFound, fm.identity)
+	}
+	for _, protocol := range ext.Protocols {
+		if protocol.Scheme == fm.scheme {
+			return ext, protocol, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: %s doesn't handle %s", ErrUnSupportedProtocol, fm.identity, fm.scheme)
+}
+
+// extensionFetcher resolves the resource by the extension, and downloads the file from a blob url opened by the
+// extension with the http fetcher, so that the ranges, connections and resuming of http are reused.
+type extensionFetcher struct {
+	fm   *extensionFetcherManager
+	ctl  *controller.Controller
+	meta *fetcher.FetcherMeta
+	// http downloads the blob url, it's built on the first start
+	http fetcher.Fetcher
+
+	blobLock sync.Mutex
+	blobURL  string
+}
+
+func (f *extensionFetcher) Setup(ctl *controller.Controller) {
+	f.ctl = ctl
+	if f.meta == nil {
+		f.meta = &fetcher.FetcherMeta{}
+	}
+	if f.http != nil {
+		f.http.Setup(f.httpController())
+	}
+}
+
+// httpController reads the http protocol config, and never sends the loopback blob url through a proxy.
+func (f *extensionFetcher) httpController() *controller.Controller {
+	ctl := controller.NewController()
+	ctl.GetConfig = func(v any) {
+		f.fm.d.getProtocolConfig("http", v)
+	}
+	ctl.GetProxy = func(requestProxy *base.RequestProxy) func(*gohttp.Request) (*url.URL, error) {
+		return nil
+	}
+	if f.ctl != nil {
+		ctl.FileController = f.ctl.FileController
+	}
+	return ctl
+}
+
+func (f *extensionFetcher) Resolve(req *base.Request, opts *base.Options) error {
+	f.meta.Req = req
+	f.meta.Opts = opts
+	if f.meta.Opts == nil {
+		f.meta.Opts = &base.Options{}
+	}
+	if err := f.initOpts(); err != nil {
+		return err
+	}
+	res, err := f.fm.d.resolveExtensionProtocol(f.fm, req)
+	if err != nil {
+		return err
+	}
+	f.meta.Res = res
+	return nil
+}
+
+// initOpts fills the http options, the connections default to the http protocol config.
+func (f *extensionFetcher) initOpts() error {
+	opts := f.meta.Opts
+	if err := base.ParseOptExtra[fhttp.OptsExtra](opts); err != nil {
+		return err
+	}
+	if opts.Extra == nil {
+		opts.Extra = &fhttp.OptsExtra{}
+	}
+	extra := opts.Extra.(*fhttp.OptsExtra)
+	if extra.Connections <= 0 {
+		var cfg struct {
+			Connections int `json:"connections"`
+		}
+		f.fm.d.getProtocolConfig("http", &cfg)
+		extra.Connections = max(cfg.Connections, 1)
+	}
+	return nil
+}
+
+// blobMeta is the meta of the http fetcher, it shares the resource and the options with the task.
+func (f *extensionFetcher) blobMeta() *fetcher.FetcherMeta {
+	return &fetcher.FetcherMeta{
+		Req:  &base.Request{},
+		Res:  f.meta.Res,
+		Opts: f.meta.Opts,
+	}
+}
+
+func (f *extensionFetcher) Start() error {
+	if err := f.initOpts(); err != nil {
+		return err
+	}
+	blobURL, err := f.openBlob()
+	if err != nil {
+		return err
+	}
+	// the first start resolves the blob url by http, so that the response is reused for downloading
+	if f.http == nil {
+		f.http = new(http.FetcherManager).Build()
+		f.http.Setup(f.httpController())
+		if err := f.http.Resolve(&base.Request{URL: blobURL}, f.meta.Opts); err != nil {
+			f.http = nil
+			return err
+		}
+	} else if err := f.http.Patch(&base.Request{URL: blobURL}, nil); err != nil {
+		return err
+	}
+	f.http.Meta().Res = f.meta.Res
+	f.http.Meta().Opts = f.meta.Opts
+	return f.http.Start()
+}
+
+// openBlob opens the file by the extension if the blob url of the last start is released.
+func (f *extensionFetcher) openBlob() (string, error) {
+	f.blobLock.Lock()
+	defer f.blobLock.Unlock()
+	if f.blobURL != "" && f.fm.d.blob.IsURL(f.blobURL) {
+		return f.blobURL, nil
+	}
+	blobURL, err := f.fm.d.openExtensionProtocol(f.fm, f.meta)
+	if err != nil {
+		return "", err
+	}
+	if err := f.fm.d.blob.Acquire(blobURL); err != nil {
+		_ = f.fm.d.blob.Revoke(blobURL)
+		return "", err
+	}
+	f.blobURL = blobURL
+	return blobURL, nil
+}
+
+// releaseBlob releases the blob url, the extension engine is closed with it.
+func (f *extensionFetcher) releaseBlob() {
+	f.blobLock.Lock()
+	blobURL := f.blobURL
+	f.blobURL = ""
+	f.blobLock.Unlock()
+	if blobURL != "" {
+		_ = f.fm.d.blob.Release(blobURL)
+	}
+}
+
+func (f *extensionFetcher) Patch(req *base.Request, opts *base.Options) error {
+	if req == nil {
+		return nil
+	}
+	if req.URL != "" {
+		f.meta.Req.URL = req.URL
+		f.releaseBlob()
+	}
+	if req.Extra != nil {
+		f.meta.Req.Extra = req.Extra
+	}
+	if req.Labels != nil {
+		if f.meta.Req.Labels == nil {
+			f.meta.Req.Labels = make(map[string]string)
+		}
+		for k, v := range req.Labels {
+			f.meta.Req.Labels[k] = v
+		}
+	}
+	return nil
+}
+
+func (f *extensionFetcher) Pause() error {
+	defer f.releaseBlob()
+	if f.http == nil {
+		return nil
+	}
+	return f.http.Pause()
+}
+
+func (f *extensionFetcher) Close() error {
+	defer f.releaseBlob()
+	if f.http == nil {
+		return nil
+	}
+	return f.http.Close()
+}
+
+func (f *extensionFetcher) Stats() any {
+	if f.http == nil {
+		return nil
+	}
+	return f.http.Stats()
+}
+
+func (f *extensionFetcher) Meta() *fetcher.FetcherMeta {
+	return f.meta
+}
+
+func (f *extensionFetcher) Progress() fetcher.Progress {
+	if f.http == nil {
+		return nil
+	}
+	return f.http.Progress()
+}
+
+func (f *extensionFetcher) Wait() error {
+	defer f.releaseBlob()
+	return f.http.Wait()
+}
+
+// resolveExtensionProtocol calls the resolve handler of the protocol, the resource must have exactly one file.
+func (d *Downloader) resolveExtensionProtocol(fm *extensionFetcherManager, req *base.Request) (*base.Resource, error) {
+	ctx := &OnResolveContext{
+		Req: cloneRequest(req),
+	}
+	if err := d.runExtensionProtocol(fm, eventProtocolResolve, func(e *engine.Engine, fn engine.JSFunction, run func(fn any, args ...any) error) error {
+		return run(fn, ctx)
+	}); err != nil {
+		return nil, err
+	}
+	res := ctx.Res
+	if res == nil || len(res.Files) != 1 || res.Files[0].Name == "" {
+		return nil, fmt.Errorf("%w: exactly one named file is required", ErrExtensionProtocolInvalid)
+	}
+	file := res.Files[0]
+	file.Name = util.SafeFilename(file.Name)
+	file.Req = nil
+	if file.Size < 0 {
+		file.Size = 0
+	}
+	// a range resource must have the size, so that the blob can be read by ranges
+	res.Range = res.Range && file.Size > 0
+	res.Name = ""
+	res.CalcSize(nil)
+	return res, nil
+}
+
+// openExtensionProtocol calls the open handler of the protocol by a range-aware blob url, the engine lives with the blob.
+func (d *Downloader) openExtensionProtocol(fm *extensionFetcherManager, meta *fetcher.FetcherMeta) (string, error) {
+	var blobURL string
+	err := d.runExtensionProtocol(fm, eventProtocolOpen, func(e *engine.Engine, open engine.JSFunction, run func(fn any, args ...any) error) error {
+		req := cloneRequest(meta.Req)
+		res := util.DeepClone(meta.Res)
+		return run(engine.JSFunction(func(goja.FunctionCall) goja.Value {
+			vm := e.Runtime
+			create, ok := goja.AssertFunction(vm.Get("__gopeed_blob_create_object_url"))
+			if !ok {
+				panic(vm.NewGoError(fmt.Errorf("blob runtime is not available")))
+			}
+			opener := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+				request := call.Argument(0).ToObject(vm)
+				return open(goja.FunctionCall{
+					This: goja.Undefined(),
+					Arguments: []goja.Value{vm.ToValue(&OnProtocolOpenContext{
+						Req:    req,
+						Res:    res,
+						Offset: request.Get("offset").ToInteger(),
+						End:    request.Get("end").ToInteger(),
+					})},
+				})
+			})
+			value, err := create(goja.Undefined(), opener, vm.ToValue(map[string]any{
+				"size":  res.Size,
+				"range": res.Range,
+			}))
+			if err != nil {
+				panic(err)
+			}
+			blobURL = value.String()
+			return value
+		}))
+	})
+	return blobURL, err
+}
+
+// runExtensionProtocol loads the entry script of the protocol and calls the handler of the event within the hook
+// timeout, the engine is closed after the call unless it's retained, e.g. by a blob url.
+func (d *Downloader) runExtensionProtocol(fm *extensionFetcherManager, event ActivationEvent,
+	call func(e *engine.Engine, fn engine.JSFunction, run func(fn any, args ...any) error) error) (err error) {
+	ext, protocol, err := fm.extension()
+	if err != nil {
+		return err
+	}
+	scriptBuf, err := os.ReadFile(filepath.Join(d.ExtensionPath(ext), protocol.Entry))
+	if err != nil {
+		return err
+	}
+	timeout, _ := d.extensionLimits(ext)
+	hookCtx, cancel := extensionHookContext(timeout)
+	defer cancel()
+	e, session := d.newExtensionEngine(ext)
+	defer session.CloseIfIdle()
+	defer func() {
+		// a timed out hook may still be running, so the engine is discarded
+		if errors.Is(err, ErrExtensionTimeout) {
+			session.Terminate()
+			err = &ExtensionError{Identity: ext.Identity, Event: event, Err: err}
+		}
+	}()
+	gopeed := &Instance{
+		Events:   make(InstanceEvents),
+		Info:     NewExtensionInfo(ext),
+		Logger:   newInstanceLogger(ext, d.ExtensionLogger),
+		Settings: parseSettings(ext.Settings),
+		Storage: &ContextStorage{
+			storage:     d.storage,
+			identity:    ext.Identity,
+			permissions: ext.Permissions,
+		},
+		Runtime: &InstanceRuntime{
+			WebView: d.newExtensionWebViewRuntime(session, ext.Permissions),
+		},
+	}
+	if err = injectGopeed(e.Runtime, gopeed); err != nil {
+		return err
+	}
+	if _, err = e.RunStringContext(hookCtx, string(scriptBuf)); err != nil {
+		return err
+	}
+	fn, ok := gopeed.Events[event]
+	if !ok {
+		return fmt.Errorf("%w: %s doesn't register %s", ErrUnSupportedProtocol, ext.Identity, event)
+	}
+	err = call(e, fn, func(fn any, args ...any) error {
+		_, err := e.CallFunctionContext(hookCtx, fn, args...)
+		return err
+	})
+	if err != nil {
+		gopeed.Logger.logger.Error().Err(err).Msgf("[%s] call protocol function failed: %s", ext.Identity, event)
+		if me, ok := gojautil.AssertError[*gojaerror.MessageError](err); ok {
+			return me
+		}
+	}
+	return err
+}
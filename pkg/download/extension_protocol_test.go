@@ -0,0 +1,149 @@
+package download
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+const protocolTestScript = `
+const data = "0123456789".repeat(100000);
+
+gopeed.protocol.onResolve((ctx) => {
+  if (!ctx.req.url.startsWith("myservice://files/")) {
+    throw new MessageError("file not found");
+  }
+  ctx.res = {
+    range: true,
+    files: [{ name: ctx.req.url.substring("myservice://files/".length), size: data.length }],
+  };
+});
+
+gopeed.protocol.onOpen((ctx) => {
+  const end = ctx.end >= 0 ? ctx.end + 1 : data.length;
+  const chunk = new TextEncoder().encode(data.slice(ctx.offset, end));
+  return new ReadableStream({
+    start(controller) {
+      controller.enqueue(chunk);
+      controller.close();
+    },
+  });
+});`
+
+func TestDownloader_Extension_Protocol(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		installProtocolTestExtension(t, downloader, "protocol", "myservice")
+		want := strings.Repeat("0123456789", 100000)
+
+		rr, err := downloader.Resolve(&base.Request{URL: "myservice://files/a.txt"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rr.Res.Files) != 1 || rr.Res.Files[0].Name != "a.txt" || rr.Res.Size != int64(len(want)) || !rr.Res.Range {
+			t.Fatalf("Resolve() got = %+v", rr.Res)
+		}
+
+		done := make(chan *Task, 1)
+		downloader.Listener(func(event *Event) {
+			switch event.Key {
+			case EventKeyDone:
+				done <- event.Task
+			case EventKeyError:
+				t.Errorf("download error: %v", event.Err)
+				done <- event.Task
+			}
+		})
+		dir := t.TempDir()
+		if _, err := downloader.CreateDirect(&base.Request{URL: "myservice://files/b.txt"}, &base.Options{Path: dir}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case task := <-done:
+			if task.Protocol != "myservice" {
+				t.Errorf("task protocol got = %s, want myservice", task.Protocol)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("download timeout")
+		}
+		got, err := os.ReadFile(filepath.Join(dir, "b.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("downloaded file size got = %d, want %d", len(got), len(want))
+		}
+
+		if _, err := downloader.Resolve(&base.Request{URL: "myservice://other"}, nil); err == nil || err.Error() != "file not found" {
+			t.Errorf("Resolve() error got = %v, want file not found", err)
+		}
+	})
+}
+
+func TestDownloader_Extension_ProtocolDisabled(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		ext := installProtocolTestExtension(t, downloader, "protocol", "myservice")
+		if err := downloader.SwitchExtension(ext.Identity, false); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.Resolve(&base.Request{URL: "myservice://files/a.txt"}, nil); !errors.Is(err, ErrUnSupportedProtocol) {
+			t.Errorf("Resolve() error got = %v, want %v", err, ErrUnSupportedProtocol)
+		}
+	})
+}
+
+func TestDownloader_Extension_ProtocolConflict(t *testing.T) {
+	setupDownloader(func(downloader *Downloader) {
+		installProtocolTestExtension(t, downloader, "protocol", "myservice")
+		tests := []struct {
+			name   string
+			scheme string
+			want   error
+		}{
+			{"builtin", "https", ErrExtensionProtocolConflict},
+			{"extension", "myservice", ErrExtensionProtocolConflict},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				dir := writeProtocolTestExtension(t, "other", tt.scheme)
+				if _, err := downloader.InstallExtensionByFolder(dir, false); !errors.Is(err, tt.want) {
+					t.Errorf("InstallExtensionByFolder() error got = %v, want %v", err, tt.want)
+				}
+			})
+		}
+		dir := writeProtocolTestExtension(t, "invalid", "My Service")
+		if _, err := downloader.InstallExtensionByFolder(dir, false); err == nil {
+			t.Errorf("InstallExtensionByFolder() invalid scheme expect error")
+		}
+	})
+}
+
+func installProtocolTestExtension(t *testing.T, downloader *Downloader, name string, scheme string) *Extension {
+	return installTestExtension(t, downloader, protocolTestExtensionFiles(name, scheme))
+}
+
+func writeProtocolTestExtension(t *testing.T, name string, scheme string) string {
+	return writeTestExtension(t, protocolTestExtensionFiles(name, scheme))
+}
+
+func protocolTestExtensionFiles(name string, scheme string) map[string]string {
+	return map[string]string{
+		"manifest.json": `{
+  "name": "` + name + `",
+  "author": "gopeed",
+  "title": "Protocol",
+  "version": "0.0.1",
+  "protocols": [
+    {
+      "scheme": "` + scheme + `",
+      "entry": "protocol.js"
+    }
+  ]
+}`,
+		"protocol.js": protocolTestScript,
+	}
+}
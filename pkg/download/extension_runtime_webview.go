@@ -16,6 +16,9 @@ func injectGopeed(vm *goja.Runtime, gopeed *Instance) error {
 	if err := gopeedObject.Set("events", newJSEventsRuntime(vm, gopeed.Events)); err != nil {
 		return err
 	}
+	if err := gopeedObject.Set("protocol", newJSProtocolRuntime(vm, gopeed.Events)); err != nil {
+		return err
+	}
 	if err := gopeedObject.Set("info", gopeed.Info); err != nil {
 		return err
 	}
@@ -73,33 +76,65 @@ func newJSBlobRuntime(vm *goja.Runtime) *goja.Object {
 
 func newJSEventsRuntime(vm *goja.Runtime, events InstanceEvents) *goja.Object {
 	obj := vm.NewObject()
-	register := func(event ActivationEvent, call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			panic(vm.ToValue(fmt.Errorf("missing handler")))
-		}
-		fnValue := call.Argument(0)
-		exported, ok := fnValue.Export().(func(goja.FunctionCall) goja.Value)
-		if !ok {
-			panic(vm.ToValue(fmt.Errorf("handler must be a function")))
-		}
-		events.register(event, engine.JSFunction(exported))
-		return goja.Undefined()
-	}
+	register := newJSEventRegister(vm, events)
 	_ = obj.Set("onResolve", func(call goja.FunctionCall) goja.Value {
 		return register(EventOnResolve, call)
 	})
+	_ = obj.Set("onCreate", func(call goja.FunctionCall) goja.Value {
+		return register(EventOnCreate, call)
+	})
 	_ = obj.Set("onStart", func(call goja.FunctionCall) goja.Value {
 		return register(EventOnStart, call)
 	})
+	_ = obj.Set("onProgress", func(call goja.FunctionCall) goja.Value {
+		return register(EventOnProgress, call)
+	})
+	_ = obj.Set("onPause", func(call goja.FunctionCall) goja.Value {
+		return register(EventOnPause, call)
+	})
 	_ = obj.Set("onError", func(call goja.FunctionCall) goja.Value {
 		return register(EventOnError, call)
 	})
 	_ = obj.Set("onDone", func(call goja.FunctionCall) goja.Value {
 		return register(EventOnDone, call)
 	})
+	_ = obj.Set("onDelete", func(call goja.FunctionCall) goja.Value {
+		return register(EventOnDelete, call)
+	})
+	_ = obj.Set("onExtractDone", func(call goja.FunctionCall) goja.Value {
+		return register(EventOnExtractDone, call)
+	})
+	return obj
+}
+
+// newJSProtocolRuntime registers the handlers of the protocols declared in the manifest.
+func newJSProtocolRuntime(vm *goja.Runtime, events InstanceEvents) *goja.Object {
+	obj := vm.NewObject()
+	register := newJSEventRegister(vm, events)
+	_ = obj.Set("onResolve", func(call goja.FunctionCall) goja.Value {
+		return register(eventProtocolResolve, call)
+	})
+	_ = obj.Set("onOpen", func(call goja.FunctionCall) goja.Value {
+		return register(eventProtocolOpen, call)
+	})
 	return obj
 }
 
+func newJSEventRegister(vm *goja.Runtime, events InstanceEvents) func(event ActivationEvent, call goja.FunctionCall) goja.Value {
+	return func(event ActivationEvent, call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) == 0 {
+			panic(vm.ToValue(fmt.Errorf("missing handler")))
+		}
+		fnValue := call.Argument(0)
+		exported, ok := fnValue.Export().(func(goja.FunctionCall) goja.Value)
+		if !ok {
+			panic(vm.ToValue(fmt.Errorf("handler must be a function")))
+		}
+		events.register(event, engine.JSFunction(exported))
+		return goja.Undefined()
+	}
+}
+
 func newJSWebViewRuntime(vm *goja.Runtime, runtime *enginewebview.Runtime) *goja.Object {
 	obj := vm.NewObject()
 	_ = obj.Set("isAvailable", func(goja.FunctionCall) goja.Value {
@@ -0,0 +1,242 @@
+package download
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+// extensionSignatureFile is the hash list and the signature of an extension package
+const extensionSignatureFile = "signature.json"
+
+var (
+	ErrExtensionSignatureInvalid = errors.New("extension signature is invalid")
+	ErrExtensionUntrusted        = errors.New("extension is not signed by a trusted publisher key")
+	ErrExtensionTampered         = errors.New("extension files are tampered")
+)
+
+// extensionSignature is the content of signature.json, the signature is over the hash list of all the
+// other packaged files, so a modified, removed or injected file invalidates it.
+type extensionSignature struct {
+	// Files are the sha256 hex hashes of the packaged files, keyed by the slash separated relative path
+	Files map[string]string `json:"files"`
+	// PublicKey is the base64 encoded ed25519 public key of the publisher
+	PublicKey string `json:"publicKey"`
+	// Signature is the base64 encoded ed25519 signature of the hash list
+	Signature string `json:"signature"`
+}
+
+// message returns the signed content, one "hash  path" line per file sorted by the path, like sha256sum.
+func (s *extensionSignature) message() []byte {
+	paths := make([]string, 0, len(s.Files))
+	for p := range s.Files {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	var b strings.Builder
+	for _, p := range paths {
+		b.WriteString(s.Files[p])
+		b.WriteString("  ")
+		b.WriteString(p)
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// SignExtension signs the files of an extension package with the publisher private key,
+// the hash list and the signature are written to signature.json in the package.
+func SignExtension(path string, privateKey ed25519.PrivateKey) error {
+	files, err := hashExtensionFiles(path)
+	if err != nil {
+		return err
+	}
+	delete(files, extensionSignatureFile)
+	sig := &extensionSignature{
+		Files:     files,
+		PublicKey: base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+	}
+	sig.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, sig.message()))
+	data, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(path, extensionSignatureFile), data, 0644)
+}
+
+// verifyExtensionPackage verifies the package signature by the signature policy before it's installed,
+// and records the file hashes to detect tampering of the installed files at load time.
+func (d *Downloader) verifyExtensionPackage(ext *Extension, path string, devMode bool) error {
+	files, err := hashExtensionFiles(path)
+	if err != nil {
+		return err
+	}
+	signer, err := verifyExtensionSignature(path, files)
+	if err != nil {
+		return err
+	}
+	if signer != "" && d.trustedExtensionKey(signer) {
+		ext.Signer = signer
+	} else {
+		switch d.extensionSignaturePolicy() {
+		case base.ExtensionSignatureDeny:
+			return ErrExtensionUntrusted
+		case base.ExtensionSignatureWarn:
+			d.Logger.Warn().Msgf("extension %s is not signed by a trusted publisher key", ext.Identity)
+		}
+	}
+	// the files of dev mode are changed during development
+	if !devMode {
+		ext.Files = files
+	}
+	return nil
+}
+
+// verifyExtensionSignature returns the publisher key of a valid signature, empty means the package is not signed.
+func verifyExtensionSignature(path string, files map[string]string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(path, extensionSignatureFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	var sig extensionSignature
+	if err := json.Unmarshal(data, &sig); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrExtensionSignatureInvalid, err)
+	}
+	// every packaged file must be listed with the same hash, an unlisted file may be injected
+	if len(sig.Files) != len(files)-1 {
+		return "", fmt.Errorf("%w: the packaged files don't match the hash list", ErrExtensionSignatureInvalid)
+	}
+	for name, hash := range files {
+		if name != extensionSignatureFile && sig.Files[name] != hash {
+			return "", fmt.Errorf("%w: %s is modified", ErrExtensionSignatureInvalid, name)
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("%w: invalid public key", ErrExtensionSignatureInvalid)
+	}
+	signature, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil || !ed25519.Verify(key, sig.message(), signature) {
+		return "", fmt.Errorf("%w: signature mismatch", ErrExtensionSignatureInvalid)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// checkExtensionIntegrity blocks an installed extension if its files are changed since the install,
+// or it's not trusted by the current signature policy.
+func (d *Downloader) checkExtensionIntegrity(ext *Extension) {
+	ext.IntegrityError = ""
+	if err := d.verifyInstalledExtension(ext); err != nil {
+		ext.IntegrityError = err.Error()
+		d.Logger.Error().Err(err).Msgf("extension blocked: %s", ext.Identity)
+	}
+}
+
+func (d *Downloader) verifyInstalledExtension(ext *Extension) error {
+	// the extensions installed before the file hashes were recorded can't be checked
+	if !ext.DevMode && ext.Files != nil {
+		files, err := hashExtensionFiles(d.ExtensionPath(ext))
+		if err != nil {
+			return err
+		}
+		for name, hash := range files {
+			if ext.Files[name] != hash {
+				return fmt.Errorf("%w: %s", ErrExtensionTampered, name)
+			}
+		}
+		for name := range ext.Files {
+			if _, ok := files[name]; !ok {
+				return fmt.Errorf("%w: %s", ErrExtensionTampered, name)
+			}
+		}
+	}
+	if d.extensionSignaturePolicy() == base.ExtensionSignatureDeny && (ext.Signer == "" || !d.trustedExtensionKey(ext.Signer)) {
+		return ErrExtensionUntrusted
+	}
+	return nil
+}
+
+func (d *Downloader) extensionSignaturePolicy() base.ExtensionSignaturePolicy {
+	cfg := d.cfg.DownloaderStoreConfig.Extension
+	if cfg == nil || cfg.SignaturePolicy == "" {
+		return base.ExtensionSignatureAllowUnsigned
+	}
+	return cfg.SignaturePolicy
+}
+
+func (d *Downloader) trustedExtensionKey(publicKey string) bool {
+	cfg := d.cfg.DownloaderStoreConfig.Extension
+	if cfg == nil {
+		return false
+	}
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return false
+	}
+	for _, trustedKey := range cfg.TrustedKeys {
+		trusted, err := base64.StdEncoding.DecodeString(strings.TrimSpace(trustedKey))
+		if err == nil && bytes.Equal(trusted, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashExtensionFiles returns the sha256 hashes of the files in the extension directory,
+// keyed by the slash separated relative path, the ignored directories are skipped like installing.
+func hashExtensionFiles(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && slices.Contains(extensionIgnoreDirs, entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
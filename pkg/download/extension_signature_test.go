@@ -0,0 +1,149 @@
+package download
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func TestDownloader_ExtensionSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKey := base64.StdEncoding.EncodeToString(publicKey)
+
+	tests := []struct {
+		name       string
+		policy     base.ExtensionSignaturePolicy
+		key        ed25519.PrivateKey
+		tamper     func(t *testing.T, dir string)
+		wantErr    error
+		wantSigner string
+	}{
+		{"unsignedAllow", "", nil, nil, nil, ""},
+		{"unsignedWarn", base.ExtensionSignatureWarn, nil, nil, nil, ""},
+		{"unsignedDeny", base.ExtensionSignatureDeny, nil, nil, ErrExtensionUntrusted, ""},
+		{"trustedDeny", base.ExtensionSignatureDeny, privateKey, nil, nil, trustedKey},
+		{"untrustedWarn", base.ExtensionSignatureWarn, otherKey, nil, nil, ""},
+		{"untrustedDeny", base.ExtensionSignatureDeny, otherKey, nil, ErrExtensionUntrusted, ""},
+		{"modifiedAllow", "", privateKey, func(t *testing.T, dir string) {
+			writeSignatureTestFile(t, dir, "index.js", `gopeed.events.onResolve((ctx) => { steal(); });`)
+		}, ErrExtensionSignatureInvalid, ""},
+		{"injectedAllow", "", privateKey, func(t *testing.T, dir string) {
+			writeSignatureTestFile(t, dir, "inject.js", `steal();`)
+		}, ErrExtensionSignatureInvalid, ""},
+		{"removedAllow", "", privateKey, func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, "index.js")); err != nil {
+				t.Fatal(err)
+			}
+		}, ErrExtensionSignatureInvalid, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDownloader(func(downloader *Downloader) {
+				setExtensionSignatureConfig(t, downloader, tt.policy, trustedKey)
+				dir := writeSignatureTestExtension(t, tt.key)
+				if tt.tamper != nil {
+					tt.tamper(t, dir)
+				}
+				ext, err := downloader.InstallExtensionByFolder(dir, false)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("InstallExtensionByFolder() error got = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					if len(downloader.GetExtensions()) != 0 {
+						t.Errorf("InstallExtensionByFolder() installed a rejected package")
+					}
+					return
+				}
+				if ext.Signer != tt.wantSigner {
+					t.Errorf("InstallExtensionByFolder() signer got = %s, want %s", ext.Signer, tt.wantSigner)
+				}
+			})
+		})
+	}
+}
+
+func TestDownloader_ExtensionIntegrity(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKey := base64.StdEncoding.EncodeToString(publicKey)
+
+	setupDownloader(func(downloader *Downloader) {
+		setExtensionSignatureConfig(t, downloader, "", trustedKey)
+		signed, err := downloader.InstallExtensionByFolder(writeSignatureTestExtension(t, privateKey), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		downloader.checkExtensionIntegrity(signed)
+		if signed.IntegrityError != "" || !downloader.matchExtensions(EventOnResolve, &base.Request{URL: "https://example.com/file"}) {
+			t.Fatalf("checkExtensionIntegrity() untouched got = %s", signed.IntegrityError)
+		}
+
+		// the files under the extension path are changed after the install
+		writeSignatureTestFile(t, downloader.ExtensionPath(signed), "index.js", `steal();`)
+		downloader.checkExtensionIntegrity(signed)
+		if signed.IntegrityError == "" {
+			t.Fatal("checkExtensionIntegrity() tampered expect error")
+		}
+		if downloader.matchExtensions(EventOnResolve, &base.Request{URL: "https://example.com/file"}) {
+			t.Errorf("matchExtensions() tampered extension should be blocked")
+		}
+
+		// reinstalling the package restores the extension
+		if _, err := downloader.InstallExtensionByFolder(writeSignatureTestExtension(t, privateKey), false); err != nil {
+			t.Fatal(err)
+		}
+		downloader.checkExtensionIntegrity(signed)
+		if signed.IntegrityError != "" {
+			t.Errorf("checkExtensionIntegrity() reinstalled got = %s", signed.IntegrityError)
+		}
+
+		// the installed extension is blocked if the key isn't trusted by the deny policy any more
+		setExtensionSignatureConfig(t, downloader, base.ExtensionSignatureDeny)
+		downloader.checkExtensionIntegrity(signed)
+		if signed.IntegrityError != ErrExtensionUntrusted.Error() {
+			t.Errorf("checkExtensionIntegrity() untrusted got = %s, want %s", signed.IntegrityError, ErrExtensionUntrusted)
+		}
+	})
+}
+
+func setExtensionSignatureConfig(t *testing.T, downloader *Downloader, policy base.ExtensionSignaturePolicy, trustedKeys ...string) {
+	cfg, err := downloader.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Extension = &base.ExtensionConfig{SignaturePolicy: policy, TrustedKeys: trustedKeys}
+	if err := downloader.PutConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeSignatureTestExtension writes an extension package, it's signed if the key is not nil.
+func writeSignatureTestExtension(t *testing.T, key ed25519.PrivateKey) string {
+	dir := writeTestExtension(t, archiveTestExtensionFiles("", "signed", "1.0.0"))
+	if key != nil {
+		if err := SignExtension(dir, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeSignatureTestFile(t *testing.T, dir string, name string, content string) {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1910,6 +1910,29 @@ func setupDownloader(fn func(downloader *Downloader)) {
 	fn(downloader)
 }
 
+// writeTestExtension writes the files of an extension package to a temp dir and returns the dir.
+func writeTestExtension(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func installTestExtension(t *testing.T, downloader *Downloader, files map[string]string) *Extension {
+	ext, err := downloader.InstallExtensionByFolder(writeTestExtension(t, files), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ext
+}
+
 func newTestExtensionEngineDownloader() (*Downloader, func(), error) {
 	downloader := NewDownloader(&DownloaderConfig{
 		Storage: NewMemStorage(),
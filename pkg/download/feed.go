@@ -0,0 +1,735 @@
+package download
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	// defaultFeedInterval is the default poll interval of a feed in seconds
+	defaultFeedInterval = 30 * 60
+	// minFeedInterval is the min poll interval of a feed in seconds
+	minFeedInterval = 60
+	// feedCheckInterval is how often the due feeds are checked
+	feedCheckInterval = 10 * time.Second
+	feedTimeout       = 30 * time.Second
+	// maxFeedSize limits the size of a feed document and a torrent enclosure
+	maxFeedSize = 10 * 1024 * 1024
+	// maxFeedItems is the max seen items kept per feed, the oldest items not in the feed anymore are removed
+	maxFeedItems = 1000
+	// maxFeedItemAttempts is how many times the task of a matched item is tried to be created,
+	// a failed item is retried on the next polls, e.g. when the torrent enclosure was not reachable
+	maxFeedItemAttempts = 3
+)
+
+var (
+	ErrFeedNotFound     = errors.New("feed not found")
+	ErrFeedRuleNotFound = errors.New("feed rule not found")
+	ErrFeedInvalid      = errors.New("feed url must be a http or https url")
+	ErrFeedRuleInvalid  = errors.New("invalid feed rule")
+)
+
+// episodeRegexps detect the season and episode of an item title, e.g. S01E02 or 1x02.
+var episodeRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bS(\d{1,3})[ ._-]?E(\d{1,4})\b`),
+	regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`),
+}
+
+type feedManager struct {
+	lock  *sync.RWMutex
+	feeds []*base.Feed
+	// items are the seen items by feed id, ordered from oldest to newest
+	items map[string][]*base.FeedItem
+	// pollLock makes sure a feed is not polled concurrently by the loop and a manual refresh
+	pollLock *sync.Mutex
+}
+
+func (d *Downloader) loadFeeds() error {
+	var feeds []*base.Feed
+	if err := d.storage.List(bucketFeed, &feeds); err != nil {
+		return err
+	}
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return feeds[i].CreatedAt.Before(feeds[j].CreatedAt)
+	})
+	var items []*base.FeedItem
+	if err := d.storage.List(bucketFeedItem, &items); err != nil {
+		return err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].SeenAt.Before(items[j].SeenAt)
+	})
+	d.feed = &feedManager{
+		lock:     &sync.RWMutex{},
+		feeds:    feeds,
+		items:    make(map[string][]*base.FeedItem),
+		pollLock: &sync.Mutex{},
+	}
+	for _, item := range items {
+		d.feed.items[item.FeedID] = append(d.feed.items[item.FeedID], item)
+	}
+	return nil
+}
+
+// runFeeds polls the due feeds until the downloader is closed.
+func (d *Downloader) runFeeds() {
+	for !d.closed.Load() {
+		for _, feed := range d.GetFeeds() {
+			if d.closed.Load() {
+				return
+			}
+			if feed.Disabled || time.Since(feed.LastPoll) < time.Duration(feed.Interval)*time.Second {
+				continue
+			}
+			if err := d.RefreshFeed(feed.ID); err != nil && !errors.Is(err, ErrFeedNotFound) {
+				d.Logger.Warn().Err(err).Msgf("feed poll failed: %s", feed.URL)
+			}
+		}
+		time.Sleep(feedCheckInterval)
+	}
+}
+
+func validateFeed(feed *base.Feed) error {
+	u, err := url.Parse(feed.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrFeedInvalid
+	}
+	if feed.Interval == 0 {
+		feed.Interval = defaultFeedInterval
+	}
+	if feed.Interval < minFeedInterval {
+		feed.Interval = minFeedInterval
+	}
+	if feed.Rules == nil {
+		feed.Rules = make([]*base.FeedRule, 0)
+	}
+	for _, rule := range feed.Rules {
+		if err := validateFeedRule(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFeedRule(rule *base.FeedRule) error {
+	if rule == nil {
+		return ErrFeedRuleInvalid
+	}
+	for _, expr := range []string{rule.Include, rule.Exclude} {
+		if _, err := compileFeedRegexp(expr); err != nil {
+			return fmt.Errorf("%w: %s", ErrFeedRuleInvalid, err.Error())
+		}
+	}
+	if rule.MinSize < 0 || rule.MaxSize < 0 || (rule.MaxSize > 0 && rule.MinSize > rule.MaxSize) {
+		return fmt.Errorf("%w: size limit", ErrFeedRuleInvalid)
+	}
+	if rule.ID == "" {
+		id, err := gonanoid.New()
+		if err != nil {
+			return err
+		}
+		rule.ID = id
+	}
+	return nil
+}
+
+func compileFeedRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("(?i)" + expr)
+}
+
+func (d *Downloader) saveFeed(feed *base.Feed) error {
+	return d.storage.Put(bucketFeed, feed.ID, feed)
+}
+
+func (d *Downloader) findFeed(id string) (int, *base.Feed) {
+	for i, feed := range d.feed.feeds {
+		if feed.ID == id {
+			return i, feed
+		}
+	}
+	return -1, nil
+}
+
+// CreateFeed subscribes a feed, the feed is polled by the interval from now on.
+func (d *Downloader) CreateFeed(feed *base.Feed) (*base.Feed, error) {
+	if err := validateFeed(feed); err != nil {
+		return nil, err
+	}
+	id, err := gonanoid.New()
+	if err != nil {
+		return nil, err
+	}
+	feed.ID = id
+	feed.LastPoll = time.Time{}
+	feed.LastError = ""
+	feed.CreatedAt = time.Now()
+
+	d.feed.lock.Lock()
+	defer d.feed.lock.Unlock()
+	if err := d.saveFeed(feed); err != nil {
+		return nil, err
+	}
+	d.feed.feeds = append(d.feed.feeds, feed)
+	return cloneFeed(feed), nil
+}
+
+// GetFeeds returns all feeds ordered by creation time.
+func (d *Downloader) GetFeeds() []*base.Feed {
+	d.feed.lock.RLock()
+	defer d.feed.lock.RUnlock()
+
+	feeds := make([]*base.Feed, 0, len(d.feed.feeds))
+	for _, feed := range d.feed.feeds {
+		feeds = append(feeds, cloneFeed(feed))
+	}
+	return feeds
+}
+
+func (d *Downloader) GetFeed(id string) (*base.Feed, error) {
+	d.feed.lock.RLock()
+	defer d.feed.lock.RUnlock()
+
+	_, feed := d.findFeed(id)
+	if feed == nil {
+		return nil, ErrFeedNotFound
+	}
+	return cloneFeed(feed), nil
+}
+
+// UpdateFeed replaces the settings and the rules of a feed, the poll state and the seen items are kept.
+func (d *Downloader) UpdateFeed(id string, update *base.Feed) (*base.Feed, error) {
+	if err := validateFeed(update); err != nil {
+		return nil, err
+	}
+	return d.modifyFeed(id, func(feed *base.Feed) error {
+		feed.Name = update.Name
+		feed.URL = update.URL
+		feed.Interval = update.Interval
+		feed.Disabled = update.Disabled
+		feed.Rules = update.Rules
+		return nil
+	})
+}
+
+// DeleteFeed unsubscribes a feed and removes its seen items, the created tasks are kept.
+func (d *Downloader) DeleteFeed(id string) error {
+	d.feed.lock.Lock()
+	defer d.feed.lock.Unlock()
+
+	i, _ := d.findFeed(id)
+	if i < 0 {
+		return ErrFeedNotFound
+	}
+	if err := d.storage.Delete(bucketFeed, id); err != nil {
+		return err
+	}
+	for _, item := range d.feed.items[id] {
+		if err := d.storage.Delete(bucketFeedItem, feedItemStoreKey(item)); err != nil {
+			return err
+		}
+	}
+	d.feed.feeds = append(d.feed.feeds[:i], d.feed.feeds[i+1:]...)
+	delete(d.feed.items, id)
+	return nil
+}
+
+// AddFeedRule appends a rule to a feed.
+func (d *Downloader) AddFeedRule(feedID string, rule *base.FeedRule) (*base.FeedRule, error) {
+	rule.ID = ""
+	if err := validateFeedRule(rule); err != nil {
+		return nil, err
+	}
+	if _, err := d.modifyFeed(feedID, func(feed *base.Feed) error {
+		feed.Rules = append(feed.Rules, rule)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateFeedRule replaces a rule of a feed and keeps its position.
+func (d *Downloader) UpdateFeedRule(feedID string, ruleID string, rule *base.FeedRule) (*base.FeedRule, error) {
+	rule.ID = ruleID
+	if err := validateFeedRule(rule); err != nil {
+		return nil, err
+	}
+	if _, err := d.modifyFeed(feedID, func(feed *base.Feed) error {
+		for i, r := range feed.Rules {
+			if r.ID == ruleID {
+				feed.Rules[i] = rule
+				return nil
+			}
+		}
+		return ErrFeedRuleNotFound
+	}); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (d *Downloader) DeleteFeedRule(feedID string, ruleID string) error {
+	_, err := d.modifyFeed(feedID, func(feed *base.Feed) error {
+		for i, r := range feed.Rules {
+			if r.ID == ruleID {
+				feed.Rules = append(feed.Rules[:i:i], feed.Rules[i+1:]...)
+				return nil
+			}
+		}
+		return ErrFeedRuleNotFound
+	})
+	return err
+}
+
+// modifyFeed changes a copy of the feed and replaces the feed only if it's stored successfully.
+func (d *Downloader) modifyFeed(id string, fn func(feed *base.Feed) error) (*base.Feed, error) {
+	d.feed.lock.Lock()
+	defer d.feed.lock.Unlock()
+
+	i, feed := d.findFeed(id)
+	if feed == nil {
+		return nil, ErrFeedNotFound
+	}
+	feed = cloneFeed(feed)
+	if err := fn(feed); err != nil {
+		return nil, err
+	}
+	if err := d.saveFeed(feed); err != nil {
+		return nil, err
+	}
+	d.feed.feeds[i] = feed
+	return cloneFeed(feed), nil
+}
+
+// GetFeedItems returns the seen items of a feed from newest to oldest.
+func (d *Downloader) GetFeedItems(feedID string) ([]*base.FeedItem, error) {
+	d.feed.lock.RLock()
+	defer d.feed.lock.RUnlock()
+
+	if _, feed := d.findFeed(feedID); feed == nil {
+		return nil, ErrFeedNotFound
+	}
+	seen := d.feed.items[feedID]
+	items := make([]*base.FeedItem, 0, len(seen))
+	for i := len(seen) - 1; i >= 0; i-- {
+		item := *seen[i]
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+// RefreshFeed polls a feed now, the new items are matched against the rules and the matched items are downloaded.
+func (d *Downloader) RefreshFeed(id string) error {
+	d.feed.pollLock.Lock()
+	defer d.feed.pollLock.Unlock()
+
+	feed, err := d.GetFeed(id)
+	if err != nil {
+		return err
+	}
+	entries, pollErr := d.fetchFeed(feed.URL)
+	if _, err := d.modifyFeed(id, func(f *base.Feed) error {
+		f.LastPoll = time.Now()
+		f.LastError = ""
+		if pollErr != nil {
+			f.LastError = pollErr.Error()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if pollErr != nil {
+		return pollErr
+	}
+
+	// feeds list the newest items first, so the older episodes are processed first
+	for i := len(entries) - 1; i >= 0; i-- {
+		if d.closed.Load() {
+			return nil
+		}
+		d.processFeedEntry(feed, entries[i])
+	}
+	return d.pruneFeedItems(feed.ID, entries)
+}
+
+func (d *Downloader) processFeedEntry(feed *base.Feed, entry *feedEntry) {
+	d.feed.lock.RLock()
+	seen := d.feed.items[feed.ID]
+	var failed *base.FeedItem
+	for _, item := range seen {
+		if item.Key == entry.key {
+			if item.Error == "" || item.Attempts >= maxFeedItemAttempts {
+				d.feed.lock.RUnlock()
+				return
+			}
+			failed = item
+			break
+		}
+	}
+	item := &base.FeedItem{
+		FeedID:      feed.ID,
+		Key:         entry.key,
+		Title:       entry.title,
+		URL:         entry.url,
+		Size:        entry.size,
+		Episode:     parseEpisode(entry.title),
+		PublishedAt: entry.published,
+		SeenAt:      time.Now(),
+	}
+	if failed != nil {
+		item.SeenAt = failed.SeenAt
+		item.Attempts = failed.Attempts
+	}
+	rule := matchFeedRule(feed.Rules, item, seen)
+	d.feed.lock.RUnlock()
+
+	if rule != nil {
+		item.RuleID = rule.ID
+		item.Attempts++
+		taskID, err := d.createFeedTask(rule, entry)
+		if err != nil {
+			item.Error = err.Error()
+			d.Logger.Warn().Err(err).Msgf("feed item download failed: %s", entry.url)
+		}
+		item.TaskID = taskID
+	}
+
+	d.feed.lock.Lock()
+	defer d.feed.lock.Unlock()
+	// the feed may be deleted while the task is created
+	if _, f := d.findFeed(feed.ID); f == nil {
+		return
+	}
+	if err := d.storage.Put(bucketFeedItem, feedItemStoreKey(item), item); err != nil {
+		d.Logger.Warn().Err(err).Msgf("feed item save failed: %s", item.Key)
+		return
+	}
+	items := d.feed.items[feed.ID]
+	for i, seenItem := range items {
+		if seenItem.Key == item.Key {
+			items[i] = item
+			return
+		}
+	}
+	d.feed.items[feed.ID] = append(items, item)
+}
+
+// pruneFeedItems removes the oldest seen items over the limit, the items still in the feed are always kept.
+func (d *Downloader) pruneFeedItems(feedID string, entries []*feedEntry) error {
+	d.feed.lock.Lock()
+	defer d.feed.lock.Unlock()
+
+	items := d.feed.items[feedID]
+	if len(items) <= maxFeedItems {
+		return nil
+	}
+	current := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		current[entry.key] = true
+	}
+	remove := len(items) - maxFeedItems
+	kept := make([]*base.FeedItem, 0, maxFeedItems)
+	for _, item := range items {
+		if remove > 0 && !current[item.Key] {
+			if err := d.storage.Delete(bucketFeedItem, feedItemStoreKey(item)); err != nil {
+				return err
+			}
+			remove--
+			continue
+		}
+		kept = append(kept, item)
+	}
+	d.feed.items[feedID] = kept
+	return nil
+}
+
+// matchFeedRule returns the first enabled rule matching the item, seen are the seen items of the feed.
+func matchFeedRule(rules []*base.FeedRule, item *base.FeedItem, seen []*base.FeedItem) *base.FeedRule {
+	for _, rule := range rules {
+		if rule.Disabled {
+			continue
+		}
+		include, err := compileFeedRegexp(rule.Include)
+		if err != nil || (include != nil && !include.MatchString(item.Title)) {
+			continue
+		}
+		exclude, err := compileFeedRegexp(rule.Exclude)
+		if err != nil || (exclude != nil && exclude.MatchString(item.Title)) {
+			continue
+		}
+		if item.Size > 0 && ((rule.MinSize > 0 && item.Size < rule.MinSize) || (rule.MaxSize > 0 && item.Size > rule.MaxSize)) {
+			continue
+		}
+		if rule.DedupEpisodes && item.Episode != "" && hasFeedEpisode(seen, rule.ID, item.Episode) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+func hasFeedEpisode(items []*base.FeedItem, ruleID string, episode string) bool {
+	for _, item := range items {
+		if item.RuleID == ruleID && item.Episode == episode && item.TaskID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEpisode returns the normalized episode of a title, e.g. S01E02, empty if not found.
+func parseEpisode(title string) string {
+	for _, re := range episodeRegexps {
+		if m := re.FindStringSubmatch(title); m != nil {
+			season, _ := strconv.Atoi(m[1])
+			episode, _ := strconv.Atoi(m[2])
+			return fmt.Sprintf("S%02dE%02d", season, episode)
+		}
+	}
+	return ""
+}
+
+func (d *Downloader) createFeedTask(rule *base.FeedRule, entry *feedEntry) (string, error) {
+	if entry.url == "" {
+		return "", errors.New("feed item has no download url")
+	}
+	reqUrl := entry.url
+	if entry.isTorrent() {
+		// the torrent file is downloaded by the feed client, so the bt fetcher doesn't need the feed proxy and cookies
+		data, err := d.fetchFeedData(entry.url)
+		if err != nil {
+			return "", err
+		}
+		reqUrl = "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	req := &base.Request{URL: reqUrl}
+	if len(rule.Labels) > 0 {
+		req.Labels = make(map[string]string, len(rule.Labels))
+		for k, v := range rule.Labels {
+			req.Labels[k] = v
+		}
+	}
+	return d.createDirect(req, &base.Options{Path: rule.Path}, !rule.Paused, nil)
+}
+
+func feedItemStoreKey(item *base.FeedItem) string {
+	sum := sha1.Sum([]byte(item.Key))
+	return item.FeedID + "_" + hex.EncodeToString(sum[:])
+}
+
+func cloneFeed(feed *base.Feed) *base.Feed {
+	clone := *feed
+	clone.Rules = make([]*base.FeedRule, 0, len(feed.Rules))
+	for _, rule := range feed.Rules {
+		r := *rule
+		clone.Rules = append(clone.Rules, &r)
+	}
+	return &clone
+}
+
+func (d *Downloader) feedClient() *http.Client {
+	return &http.Client{
+		Timeout: feedTimeout,
+		Transport: &http.Transport{
+			Proxy: d.cfg.Proxy.ToHandler(),
+		},
+	}
+}
+
+func (d *Downloader) fetchFeedData(u string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.feedClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed response status: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedSize {
+		return nil, errors.New("feed response is too large")
+	}
+	return data, nil
+}
+
+func (d *Downloader) fetchFeed(u string) ([]*feedEntry, error) {
+	data, err := d.fetchFeedData(u)
+	if err != nil {
+		return nil, err
+	}
+	return parseFeed(data)
+}
+
+// feedEntry is an item of a RSS feed or an entry of an Atom feed.
+type feedEntry struct {
+	key       string
+	title     string
+	url       string
+	mimeType  string
+	size      int64
+	published time.Time
+}
+
+func (e *feedEntry) isTorrent() bool {
+	if strings.HasPrefix(e.url, "magnet:") {
+		return false
+	}
+	if e.mimeType == "application/x-bittorrent" {
+		return true
+	}
+	u, err := url.Parse(e.url)
+	return err == nil && strings.EqualFold(path.Ext(u.Path), ".torrent")
+}
+
+type feedDoc struct {
+	Channel struct {
+		Items []*rssItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 items are siblings of the channel
+	Items   []*rssItem   `xml:"item"`
+	Entries []*atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	GUID      string `xml:"guid"`
+	PubDate   string `xml:"pubDate"`
+	Date      string `xml:"date"`
+	Enclosure *struct {
+		URL    string `xml:"url,attr"`
+		Length string `xml:"length,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	// ContentLength and MagnetURI are defined by the torrent RSS namespace used by many trackers
+	ContentLength string `xml:"contentLength"`
+	MagnetURI     string `xml:"magnetURI"`
+}
+
+type atomEntry struct {
+	Title     string `xml:"title"`
+	ID        string `xml:"id"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Links     []struct {
+		Href   string `xml:"href,attr"`
+		Rel    string `xml:"rel,attr"`
+		Type   string `xml:"type,attr"`
+		Length string `xml:"length,attr"`
+	} `xml:"link"`
+}
+
+// parseFeed parses the items of a RSS 2.0, RSS 1.0 or Atom feed in the document order.
+func parseFeed(data []byte) ([]*feedEntry, error) {
+	var doc feedDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+	entries := make([]*feedEntry, 0)
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		entry := &feedEntry{
+			title:     strings.TrimSpace(item.Title),
+			url:       strings.TrimSpace(item.Link),
+			size:      parseFeedSize(item.ContentLength),
+			published: parseFeedTime(item.PubDate, item.Date),
+		}
+		if item.Enclosure != nil && item.Enclosure.URL != "" {
+			entry.url = strings.TrimSpace(item.Enclosure.URL)
+			entry.mimeType = item.Enclosure.Type
+			if entry.size == 0 {
+				entry.size = parseFeedSize(item.Enclosure.Length)
+			}
+		}
+		if item.MagnetURI != "" && (entry.url == "" || !entry.isDownload()) {
+			entry.url = strings.TrimSpace(item.MagnetURI)
+		}
+		entry.key = firstNonEmpty(strings.TrimSpace(item.GUID), strings.TrimSpace(item.Link), entry.url, entry.title)
+		entries = append(entries, entry)
+	}
+	for _, item := range doc.Entries {
+		entry := &feedEntry{
+			title:     strings.TrimSpace(item.Title),
+			published: parseFeedTime(item.Published, item.Updated),
+		}
+		for _, link := range item.Links {
+			switch {
+			case link.Rel == "enclosure":
+				entry.url = link.Href
+				entry.mimeType = link.Type
+				entry.size = parseFeedSize(link.Length)
+			case entry.url == "" && (link.Rel == "" || link.Rel == "alternate"):
+				entry.url = link.Href
+			}
+		}
+		entry.url = strings.TrimSpace(entry.url)
+		entry.key = firstNonEmpty(strings.TrimSpace(item.ID), entry.url, entry.title)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// isDownload reports whether the url is a torrent or a magnet, rather than a web page of the item.
+func (e *feedEntry) isDownload() bool {
+	return strings.HasPrefix(e.url, "magnet:") || e.isTorrent()
+}
+
+func parseFeedSize(s string) int64 {
+	size, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+var feedTimeLayouts = []string{time.RFC1123Z, time.RFC1123, time.RFC3339, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST"}
+
+func parseFeedTime(values ...string) time.Time {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		for _, layout := range feedTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,375 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+const testAtomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>test</title>
+  <entry>
+    <title>Show S02E10</title>
+    <id>urn:uuid:2</id>
+    <updated>2024-01-02T00:00:00Z</updated>
+    <link rel="alternate" href="https://example.com/2"/>
+    <link rel="enclosure" type="application/x-bittorrent" length="2048" href="https://example.com/2.torrent"/>
+  </entry>
+  <entry>
+    <title>Show 2x09</title>
+    <id>urn:uuid:1</id>
+    <link href="magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"/>
+  </entry>
+</feed>`
+
+func TestParseFeed(t *testing.T) {
+	entries, err := parseFeed([]byte(testAtomFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("parseFeed() atom got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.key != "urn:uuid:2" || e.url != "https://example.com/2.torrent" || e.size != 2048 || !e.isTorrent() || e.published.Year() != 2024 {
+		t.Errorf("parseFeed() atom enclosure got = %+v", e)
+	}
+	if e := entries[1]; !strings.HasPrefix(e.url, "magnet:") || e.isTorrent() || parseEpisode(e.title) != "S02E09" {
+		t.Errorf("parseFeed() atom magnet got = %+v", e)
+	}
+
+	// the magnet of the torrent namespace is preferred to a web page link
+	entries, err = parseFeed([]byte(`<rss version="2.0" xmlns:torrent="http://xmlns.ezrss.it/0.1/"><channel>
+<item><title>A</title><link>https://example.com/a</link><torrent:contentLength>100</torrent:contentLength>
+<torrent:magnetURI>magnet:?xt=urn:btih:a</torrent:magnetURI></item>
+</channel></rss>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].url != "magnet:?xt=urn:btih:a" || entries[0].size != 100 || entries[0].key != "https://example.com/a" {
+		t.Errorf("parseFeed() rss got = %+v", entries[0])
+	}
+
+	if _, err := parseFeed([]byte("not a feed")); err == nil {
+		t.Error("parseFeed() should fail by the invalid document")
+	}
+}
+
+func TestDownloader_FeedCrud(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		if _, err := downloader.CreateFeed(&base.Feed{URL: "ftp://example.com/rss"}); err != ErrFeedInvalid {
+			t.Errorf("CreateFeed() invalid url got = %v, want %v", err, ErrFeedInvalid)
+		}
+		if _, err := downloader.CreateFeed(&base.Feed{URL: "https://example.com/rss", Rules: []*base.FeedRule{{Include: "("}}}); !errors.Is(err, ErrFeedRuleInvalid) {
+			t.Errorf("CreateFeed() invalid rule got = %v, want %v", err, ErrFeedRuleInvalid)
+		}
+
+		feed, err := downloader.CreateFeed(&base.Feed{Name: "test", URL: "https://example.com/rss", Interval: 1, Disabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if feed.ID == "" || feed.Interval != minFeedInterval || len(feed.Rules) != 0 {
+			t.Errorf("CreateFeed() got = %+v", feed)
+		}
+
+		rule, err := downloader.AddFeedRule(feed.ID, &base.FeedRule{Name: "a", Include: "show"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.AddFeedRule(feed.ID, &base.FeedRule{Name: "b", MinSize: 10, MaxSize: 1}); !errors.Is(err, ErrFeedRuleInvalid) {
+			t.Errorf("AddFeedRule() invalid size got = %v, want %v", err, ErrFeedRuleInvalid)
+		}
+		if _, err := downloader.AddFeedRule("not-exist", &base.FeedRule{}); err != ErrFeedNotFound {
+			t.Errorf("AddFeedRule() not found got = %v, want %v", err, ErrFeedNotFound)
+		}
+		if _, err := downloader.UpdateFeedRule(feed.ID, rule.ID, &base.FeedRule{Name: "a2", Exclude: "sample"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.UpdateFeedRule(feed.ID, "not-exist", &base.FeedRule{}); err != ErrFeedRuleNotFound {
+			t.Errorf("UpdateFeedRule() not found got = %v, want %v", err, ErrFeedRuleNotFound)
+		}
+		got, err := downloader.GetFeed(feed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Rules) != 1 || got.Rules[0].ID != rule.ID || got.Rules[0].Name != "a2" || got.Rules[0].Include != "" {
+			t.Errorf("UpdateFeedRule() got = %+v", got.Rules)
+		}
+
+		updated, err := downloader.UpdateFeed(feed.ID, &base.Feed{Name: "renamed", URL: "https://example.com/rss2", Disabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Name != "renamed" || updated.Interval != defaultFeedInterval || len(updated.Rules) != 0 || !updated.CreatedAt.Equal(feed.CreatedAt) {
+			t.Errorf("UpdateFeed() got = %+v", updated)
+		}
+		if err := downloader.DeleteFeedRule(feed.ID, rule.ID); err != ErrFeedRuleNotFound {
+			t.Errorf("DeleteFeedRule() removed got = %v, want %v", err, ErrFeedRuleNotFound)
+		}
+
+		// feeds are persisted
+		if err := downloader.loadFeeds(); err != nil {
+			t.Fatal(err)
+		}
+		if feeds := downloader.GetFeeds(); len(feeds) != 1 || feeds[0].Name != "renamed" {
+			t.Errorf("loadFeeds() got = %+v", feeds)
+		}
+
+		if err := downloader.DeleteFeed(feed.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := downloader.GetFeed(feed.ID); err != ErrFeedNotFound {
+			t.Errorf("DeleteFeed() got = %v, want %v", err, ErrFeedNotFound)
+		}
+		if _, err := downloader.GetFeedItems(feed.ID); err != ErrFeedNotFound {
+			t.Errorf("GetFeedItems() got = %v, want %v", err, ErrFeedNotFound)
+		}
+	})
+}
+
+func TestDownloader_RefreshFeed(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		file := filepath.Join(t.TempDir(), "show.bin")
+		if err := os.WriteFile(file, []byte("feed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lock sync.Mutex
+		items := []string{
+			feedTestItem("Show S01E01 720p", "/files/ep1-720.bin", 100, ""),
+			feedTestItem("Show S01E01 1080p", "/files/ep1-1080.bin", 200, ""),
+			feedTestItem("Show S01E02 SAMPLE", "/files/ep2-sample.bin", 100, ""),
+			feedTestItem("Other Show S01E01", "/files/other.bin", 100, ""),
+			feedTestItem("Show S01E03", "/files/ep3.bin", 1<<40, ""),
+			feedTestItem("Show 1x04", "/files/ep4", 0, "application/x-bittorrent"),
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/rss":
+				lock.Lock()
+				defer lock.Unlock()
+				w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>test</title>`))
+				// the newest item is listed first
+				for i := len(items) - 1; i >= 0; i-- {
+					w.Write([]byte(strings.ReplaceAll(items[i], "{server}", "http://"+r.Host)))
+				}
+				w.Write([]byte(`</channel></rss>`))
+			case r.URL.Path == "/files/ep4":
+				w.Write(created.Torrent)
+			default:
+				w.Write([]byte("content"))
+			}
+		}))
+		defer server.Close()
+
+		dir := t.TempDir()
+		feed, err := downloader.CreateFeed(&base.Feed{
+			URL:      server.URL + "/rss",
+			Disabled: true,
+			Rules: []*base.FeedRule{{
+				Include:       "^show",
+				Exclude:       "sample",
+				MaxSize:       1 << 30,
+				DedupEpisodes: true,
+				Path:          dir,
+				Labels:        map[string]string{"feed": "show"},
+				Paused:        true,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := downloader.RefreshFeed(feed.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		seen, err := downloader.GetFeedItems(feed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != len(items) {
+			t.Fatalf("GetFeedItems() got %d items, want %d", len(seen), len(items))
+		}
+		downloaded := make(map[string]*base.FeedItem)
+		for _, item := range seen {
+			if item.TaskID != "" {
+				downloaded[item.Title] = item
+			}
+			if item.Error != "" {
+				t.Errorf("RefreshFeed() item %s error: %s", item.Title, item.Error)
+			}
+		}
+		if len(downloaded) != 2 || downloaded["Show S01E01 720p"] == nil || downloaded["Show 1x04"] == nil {
+			t.Fatalf("RefreshFeed() downloaded got = %v", downloaded)
+		}
+		if downloaded["Show 1x04"].Episode != "S01E04" || downloaded["Show 1x04"].RuleID != feed.Rules[0].ID {
+			t.Errorf("RefreshFeed() item got = %+v", downloaded["Show 1x04"])
+		}
+		httpTask := downloader.GetTask(downloaded["Show S01E01 720p"].TaskID)
+		btTask := downloader.GetTask(downloaded["Show 1x04"].TaskID)
+		if httpTask == nil || btTask == nil {
+			t.Fatal("RefreshFeed() tasks not found")
+		}
+		if httpTask.Meta.Opts.Path != dir || httpTask.Meta.Req.Labels["feed"] != "show" {
+			t.Errorf("RefreshFeed() http task got = %+v, %+v", httpTask.Meta.Opts, httpTask.Meta.Req)
+		}
+		if btTask.Protocol != "bt" || !strings.HasPrefix(btTask.Meta.Req.URL, "data:application/x-bittorrent;base64,") {
+			t.Errorf("RefreshFeed() bt task got = %s, %s", btTask.Protocol, btTask.Meta.Req.URL)
+		}
+		if btTask.Status != base.DownloadStatusReady {
+			t.Errorf("RefreshFeed() task status got = %s, want %s", btTask.Status, base.DownloadStatusReady)
+		}
+
+		// seen items are not processed again, new items are matched against the downloaded episodes
+		lock.Lock()
+		items = append(items, feedTestItem("Show S01E01 REPACK", "/files/ep1-repack.bin", 100, ""), feedTestItem("Show S01E02", "/files/ep2.bin", 100, ""))
+		lock.Unlock()
+		taskCount := len(downloader.GetTasks())
+		if err := downloader.RefreshFeed(feed.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(downloader.GetTasks()); got != taskCount+1 {
+			t.Errorf("RefreshFeed() again got %d tasks, want %d", got, taskCount+1)
+		}
+		if got, _ := downloader.GetFeed(feed.ID); got.LastPoll.IsZero() || got.LastError != "" {
+			t.Errorf("RefreshFeed() state got = %+v", got)
+		}
+
+		// seen items are persisted
+		if err := downloader.loadFeeds(); err != nil {
+			t.Fatal(err)
+		}
+		if seen, _ := downloader.GetFeedItems(feed.ID); len(seen) != len(items) {
+			t.Errorf("loadFeeds() got %d items, want %d", len(seen), len(items))
+		}
+
+		server.Close()
+		if err := downloader.RefreshFeed(feed.ID); err == nil {
+			t.Error("RefreshFeed() should fail by the closed server")
+		}
+		if got, _ := downloader.GetFeed(feed.ID); got.LastError == "" {
+			t.Error("RefreshFeed() should record the error")
+		}
+	})
+}
+
+func TestDownloader_RefreshFeedRetry(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		file := filepath.Join(t.TempDir(), "retry.bin")
+		if err := os.WriteFile(file, []byte("feed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lock sync.Mutex
+		requests := make(map[string]int)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			requests[r.URL.Path]++
+			count := requests[r.URL.Path]
+			lock.Unlock()
+			switch {
+			case r.URL.Path == "/rss":
+				w.Write([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>test</title>`))
+				for _, item := range []string{
+					feedTestItem("Show S01E01", "/files/ep1", 0, "application/x-bittorrent"),
+					feedTestItem("Show S01E02", "/files/ep2", 0, "application/x-bittorrent"),
+				} {
+					w.Write([]byte(strings.ReplaceAll(item, "{server}", "http://"+r.Host)))
+				}
+				w.Write([]byte(`</channel></rss>`))
+			case r.URL.Path == "/files/ep1" && count > 1:
+				w.Write(created.Torrent)
+			default:
+				// the first enclosure fetch of ep1 and all of ep2 fail
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer server.Close()
+
+		feed, err := downloader.CreateFeed(&base.Feed{
+			URL:      server.URL + "/rss",
+			Disabled: true,
+			Rules:    []*base.FeedRule{{Path: t.TempDir(), Paused: true}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemsByTitle := func() map[string]*base.FeedItem {
+			seen, err := downloader.GetFeedItems(feed.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := make(map[string]*base.FeedItem)
+			for _, item := range seen {
+				result[item.Title] = item
+			}
+			return result
+		}
+
+		if err := downloader.RefreshFeed(feed.ID); err != nil {
+			t.Fatal(err)
+		}
+		items := itemsByTitle()
+		if item := items["Show S01E01"]; item == nil || item.Error == "" || item.TaskID != "" || item.Attempts != 1 {
+			t.Fatalf("RefreshFeed() failed item got = %+v", item)
+		}
+
+		// the failed item is retried on the next poll
+		if err := downloader.RefreshFeed(feed.ID); err != nil {
+			t.Fatal(err)
+		}
+		items = itemsByTitle()
+		if item := items["Show S01E01"]; item == nil || item.Error != "" || item.TaskID == "" || item.Attempts != 2 {
+			t.Errorf("RefreshFeed() retried item got = %+v", item)
+		}
+		if len(items) != 2 {
+			t.Errorf("RefreshFeed() retry got %d items, want 2", len(items))
+		}
+
+		// an item failing every time is given up after the max attempts
+		for i := 0; i < maxFeedItemAttempts; i++ {
+			if err := downloader.RefreshFeed(feed.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if item := itemsByTitle()["Show S01E02"]; item == nil || item.Error == "" || item.Attempts != maxFeedItemAttempts {
+			t.Errorf("RefreshFeed() given up item got = %+v", item)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if requests["/files/ep1"] != 2 || requests["/files/ep2"] != maxFeedItemAttempts {
+			t.Errorf("RefreshFeed() enclosure requests got = %v", requests)
+		}
+
+		// the attempts are persisted
+		if err := downloader.loadFeeds(); err != nil {
+			t.Fatal(err)
+		}
+		if item := itemsByTitle()["Show S01E02"]; item == nil || item.Attempts != maxFeedItemAttempts {
+			t.Errorf("loadFeeds() item got = %+v", item)
+		}
+	})
+}
+
+func feedTestItem(title string, path string, size int64, mimeType string) string {
+	return fmt.Sprintf(`<item><title>%s</title><guid>%s</guid><enclosure url="{server}%s" length="%d" type="%s"/></item>`,
+		title, path, path, size, mimeType)
+}
@@ -41,6 +41,8 @@ type Task struct {
 	runGeneration  uint64
 	speedArr       []int64
 	uploadSpeedArr []int64
+	// progressHookAt is the unix nano time of the last onProgress hook
+	progressHookAt int64
 }
 
 func NewTask() *Task {
@@ -141,10 +143,12 @@ type TaskFilter struct {
 	IDs         []string
 	Statuses    []base.Status
 	NotStatuses []base.Status
+	// DownloadDirs only matches the tasks downloaded into the directories, like Principal.DownloadDirs
+	DownloadDirs []string
 }
 
 func (f *TaskFilter) IsEmpty() bool {
-	return len(f.IDs) == 0 && len(f.Statuses) == 0 && len(f.NotStatuses) == 0
+	return len(f.IDs) == 0 && len(f.Statuses) == 0 && len(f.NotStatuses) == 0 && len(f.DownloadDirs) == 0
 }
 
 type DownloaderConfig struct {
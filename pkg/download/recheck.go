@@ -0,0 +1,51 @@
+package download
+
+import (
+	"errors"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+var ErrTaskRunning = errors.New("task is running")
+
+// RecheckTask verifies the downloaded data of a task that is not running by the hashes of the protocol,
+// the pieces of bt, the part hashes of ed2k, or the checksum of http. The damaged data is downloaded again
+// when the task is continued instead of the whole task, a done task with damaged data is paused for that.
+func (d *Downloader) RecheckTask(id string) (*base.Recheck, error) {
+	task := d.GetTask(id)
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+
+	var result *base.Recheck
+	// the status is locked until the recheck is done, so the task can't be started meanwhile
+	_, err := d.statusMut(task, func() (bool, error) {
+		if task.Status == base.DownloadStatusRunning {
+			return false, ErrTaskRunning
+		}
+		if err := d.restoreTask(task); err != nil {
+			return false, err
+		}
+		rechecker, ok := task.fetcher.(fetcher.Rechecker)
+		if !ok {
+			return false, fetcher.ErrRecheckNotSupported
+		}
+		var err error
+		result, err = rechecker.Recheck()
+		if err != nil {
+			return false, err
+		}
+		if !result.Intact {
+			if task.Status == base.DownloadStatusDone {
+				task.updateStatus(base.DownloadStatusPause)
+			}
+			task.Progress.Downloaded = result.VerifiedBytes
+		}
+		return false, d.saveTask(task)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+func TestDownloader_RecheckTask(t *testing.T) {
+	content := []byte("recheck content")
+	sum := sha256.Sum256(content)
+	digest := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if digest {
+			w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		http.ServeContent(w, r, "recheck.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	setupAccessTest(t, func(downloader *Downloader) {
+		doneCh := make(chan string, 1)
+		downloader.Listener(func(event *Event) {
+			if event.Key == EventKeyDone {
+				doneCh <- event.Task.ID
+			}
+		})
+		waitDone := func(id string) {
+			select {
+			case done := <-doneCh:
+				if done != id {
+					t.Fatalf("done task got = %s, want %s", done, id)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("wait task done timeout")
+			}
+		}
+
+		if _, err := downloader.RecheckTask("not-exist"); err != ErrTaskNotFound {
+			t.Errorf("RecheckTask() not found got = %v, want %v", err, ErrTaskNotFound)
+		}
+
+		dir := t.TempDir()
+		id, err := downloader.CreateDirect(&base.Request{URL: server.URL + "/recheck.txt"}, &base.Options{Path: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitDone(id)
+		result, err := downloader.RecheckTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Intact || result.VerifiedBytes != int64(len(content)) {
+			t.Errorf("RecheckTask() intact got = %+v", result)
+		}
+
+		// the damaged file is downloaded again when the task is continued
+		if err := os.WriteFile(filepath.Join(dir, "recheck.txt"), []byte("damaged content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, err = downloader.RecheckTask(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Intact || len(result.CorruptedPieces) != 1 {
+			t.Errorf("RecheckTask() damaged got = %+v", result)
+		}
+		task := downloader.GetTask(id)
+		if task.Status != base.DownloadStatusPause || task.Progress.Downloaded != 0 {
+			t.Errorf("RecheckTask() task got status %s, downloaded %d", task.Status, task.Progress.Downloaded)
+		}
+		if err := downloader.Continue(&TaskFilter{IDs: []string{id}}); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(id)
+		if got, _ := os.ReadFile(filepath.Join(dir, "recheck.txt")); !bytes.Equal(got, content) {
+			t.Errorf("downloaded again got = %s, want %s", got, content)
+		}
+		if result, err = downloader.RecheckTask(id); err != nil || !result.Intact {
+			t.Errorf("RecheckTask() downloaded again got = %+v, %v", result, err)
+		}
+
+		// no checksum in the options or the response headers
+		digest = false
+		id, err = downloader.CreateDirect(&base.Request{URL: server.URL + "/recheck.txt"}, &base.Options{Path: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitDone(id)
+		if _, err := downloader.RecheckTask(id); !errors.Is(err, fetcher.ErrRecheckNotSupported) {
+			t.Errorf("RecheckTask() no checksum error = %v", err)
+		}
+
+		file := filepath.Join(t.TempDir(), "seed.txt")
+		if err := os.WriteFile(file, []byte("seed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file, Seed: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err = downloader.RecheckTask(created.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Intact || result.Pieces != 1 || result.VerifiedBytes != int64(len("seed content")) {
+			t.Errorf("RecheckTask() bt task got = %+v", result)
+		}
+	})
+}
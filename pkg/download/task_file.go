@@ -0,0 +1,179 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+var (
+	ErrTaskNotDone        = errors.New("task is not done")
+	ErrTaskFileNotFound   = errors.New("task file not found")
+	ErrStreamNotSupported = fetcher.ErrStreamNotSupported
+)
+
+// TaskFile is a selected file of a task resolved to the local file system.
+type TaskFile struct {
+	// Index is the index of the file in the task resource
+	Index int `json:"index"`
+	// Name is the name of the file in the task, e.g. a renamed single file uses the new name
+	Name string `json:"name"`
+	// RelPath is the slash separated path relative to the task root directory
+	RelPath string `json:"relPath"`
+	// LocalPath is the absolute path of the file on the local file system
+	LocalPath string `json:"localPath"`
+	Size      int64  `json:"size"`
+}
+
+// GetTaskFiles returns the selected files of a done task, the task download directory must be in the
+// WhiteDownloadDirs and match one of the dirs patterns, empty dirs means no extra restriction.
+func (d *Downloader) GetTaskFiles(id string, dirs []string) ([]*TaskFile, error) {
+	task, err := d.checkTaskFiles(id, dirs, false)
+	if err != nil {
+		return nil, err
+	}
+	selected := append([]int(nil), task.Meta.Opts.SelectFiles...)
+	if len(selected) == 0 {
+		for i := range task.Meta.Res.Files {
+			selected = append(selected, i)
+		}
+	}
+	sort.Ints(selected)
+
+	files := make([]*TaskFile, 0, len(selected))
+	for _, index := range selected {
+		file, err := resolveTaskFile(task, index)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// GetTaskFile returns a selected file of a done task by index, see GetTaskFiles for the dirs restriction.
+func (d *Downloader) GetTaskFile(id string, index int, dirs []string) (*TaskFile, error) {
+	task, err := d.checkTaskFiles(id, dirs, false)
+	if err != nil {
+		return nil, err
+	}
+	return selectedTaskFile(task, index)
+}
+
+// OpenTaskFile opens a selected file of a done or running task, see GetTaskFiles for the dirs restriction.
+// A running task is streamed by the fetcher, reads block until the data is downloaded or the ctx is done,
+// and the data at the read position is downloaded first.
+func (d *Downloader) OpenTaskFile(ctx context.Context, id string, index int, dirs []string) (*TaskFile, io.ReadSeekCloser, error) {
+	task, err := d.checkTaskFiles(id, dirs, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := selectedTaskFile(task, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task.Status == base.DownloadStatusDone {
+		f, err := os.Open(file.LocalPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return file, f, nil
+	}
+
+	streamer, ok := task.fetcher.(fetcher.Streamer)
+	if !ok {
+		return nil, nil, ErrStreamNotSupported
+	}
+	reader, err := streamer.Stream(ctx, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, reader, nil
+}
+
+func selectedTaskFile(task *Task, index int) (*TaskFile, error) {
+	selected := task.Meta.Opts.SelectFiles
+	if len(selected) > 0 {
+		found := false
+		for _, i := range selected {
+			if i == index {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrTaskFileNotFound
+		}
+	}
+	return resolveTaskFile(task, index)
+}
+
+func (d *Downloader) checkTaskFiles(id string, dirs []string, stream bool) (*Task, error) {
+	task := d.GetTask(id)
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	if task.Status != base.DownloadStatusDone && !(stream && task.Status == base.DownloadStatusRunning) {
+		return nil, ErrTaskNotDone
+	}
+	if task.Meta == nil || task.Meta.Opts == nil || task.Meta.Res == nil {
+		return nil, ErrTaskFileNotFound
+	}
+	if len(d.cfg.WhiteDownloadDirs) > 0 && !matchDownloadDir(d.cfg.WhiteDownloadDirs, task.Meta.Opts.Path) {
+		return nil, ErrDownloadDirForbidden
+	}
+	if err := d.CheckDownloadDir(task.Meta.Opts, dirs); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func resolveTaskFile(task *Task, index int) (*TaskFile, error) {
+	meta := task.Meta
+	if index < 0 || index >= len(meta.Res.Files) {
+		return nil, ErrTaskFileNotFound
+	}
+	file := meta.Res.Files[index]
+
+	var name, localPath string
+	if meta.Res.Name == "" {
+		name = file.Name
+		if meta.Opts.Name != "" {
+			name = meta.Opts.Name
+		}
+		localPath = meta.SingleFilepath()
+	} else {
+		name = file.Name
+		localPath = path.Join(meta.FolderPath(), file.Path, file.Name)
+	}
+
+	// file paths come from the resource, e.g. a torrent, so make sure they don't escape the root directory
+	root, err := filepath.Abs(meta.RootDirPath())
+	if err != nil {
+		return nil, err
+	}
+	localPath, err = filepath.Abs(localPath)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, localPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, ErrTaskFileNotFound
+	}
+
+	return &TaskFile{
+		Index:     index,
+		Name:      name,
+		RelPath:   filepath.ToSlash(rel),
+		LocalPath: localPath,
+		Size:      file.Size,
+	}, nil
+}
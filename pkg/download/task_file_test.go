@@ -0,0 +1,110 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+func addTaskFileTestTask(downloader *Downloader, meta *fetcher.FetcherMeta) *Task {
+	task := NewTask()
+	task.Status = base.DownloadStatusDone
+	task.Meta = meta
+	task.Progress = &Progress{}
+	initTask(task)
+	downloader.lock.Lock()
+	downloader.tasks = append(downloader.tasks, task)
+	downloader.lock.Unlock()
+	return task
+}
+
+func TestDownloader_GetTaskFiles(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		dir := t.TempDir()
+		task := addTaskFileTestTask(downloader, &fetcher.FetcherMeta{
+			Opts: &base.Options{Path: dir, SelectFiles: []int{2, 0}},
+			Res: &base.Resource{
+				Name: "folder",
+				Files: []*base.FileInfo{
+					{Name: "a.txt", Size: 1},
+					{Name: "b.txt", Path: "sub", Size: 2},
+					{Name: "c.txt", Path: "sub/deep", Size: 3},
+					{Name: "escape.txt", Path: "../..", Size: 4},
+				},
+			},
+		})
+
+		files, err := downloader.GetTaskFiles(task.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 || files[0].Index != 0 || files[1].Index != 2 {
+			t.Fatalf("GetTaskFiles() should return the selected files ordered by index, got %v", files)
+		}
+		if files[1].RelPath != "sub/deep/c.txt" || files[1].LocalPath != filepath.Join(dir, "folder", "sub", "deep", "c.txt") {
+			t.Errorf("GetTaskFiles() got = %s, %s", files[1].RelPath, files[1].LocalPath)
+		}
+
+		file, err := downloader.GetTaskFile(task.ID, 2, nil)
+		if err != nil || file.Name != "c.txt" || file.Size != 3 {
+			t.Errorf("GetTaskFile() got = %v, %v", file, err)
+		}
+		if _, err := downloader.GetTaskFile(task.ID, 1, nil); !errors.Is(err, ErrTaskFileNotFound) {
+			t.Errorf("GetTaskFile() unselected file got = %v, want %v", err, ErrTaskFileNotFound)
+		}
+		if _, err := downloader.GetTaskFile(task.ID, 9, nil); !errors.Is(err, ErrTaskFileNotFound) {
+			t.Errorf("GetTaskFile() out of range got = %v, want %v", err, ErrTaskFileNotFound)
+		}
+		task.Meta.Opts.SelectFiles = nil
+		if _, err := downloader.GetTaskFile(task.ID, 3, nil); !errors.Is(err, ErrTaskFileNotFound) {
+			t.Errorf("GetTaskFile() escaping file got = %v, want %v", err, ErrTaskFileNotFound)
+		}
+		if _, err := downloader.GetTaskFile("not-exist", 0, nil); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("GetTaskFile() not exist task got = %v, want %v", err, ErrTaskNotFound)
+		}
+
+		if _, err := downloader.GetTaskFile(task.ID, 0, []string{filepath.Join(dir, "other")}); !errors.Is(err, ErrDownloadDirForbidden) {
+			t.Errorf("GetTaskFile() forbidden dir got = %v, want %v", err, ErrDownloadDirForbidden)
+		}
+		downloader.cfg.WhiteDownloadDirs = []string{filepath.Join(dir, "other")}
+		if _, err := downloader.GetTaskFile(task.ID, 0, nil); !errors.Is(err, ErrDownloadDirForbidden) {
+			t.Errorf("GetTaskFile() not in white list got = %v, want %v", err, ErrDownloadDirForbidden)
+		}
+		downloader.cfg.WhiteDownloadDirs = nil
+
+		task.Status = base.DownloadStatusPause
+		if _, err := downloader.GetTaskFiles(task.ID, nil); !errors.Is(err, ErrTaskNotDone) {
+			t.Errorf("GetTaskFiles() paused task got = %v, want %v", err, ErrTaskNotDone)
+		}
+		if _, _, err := downloader.OpenTaskFile(context.Background(), task.ID, 0, nil); !errors.Is(err, ErrTaskNotDone) {
+			t.Errorf("OpenTaskFile() paused task got = %v, want %v", err, ErrTaskNotDone)
+		}
+		task.Status = base.DownloadStatusRunning
+		if _, _, err := downloader.OpenTaskFile(context.Background(), task.ID, 0, nil); !errors.Is(err, ErrStreamNotSupported) {
+			t.Errorf("OpenTaskFile() running task without a streamer got = %v, want %v", err, ErrStreamNotSupported)
+		}
+		task.Status = base.DownloadStatusPause
+	})
+}
+
+func TestDownloader_GetTaskFileSingle(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		dir := t.TempDir()
+		task := addTaskFileTestTask(downloader, &fetcher.FetcherMeta{
+			Opts: &base.Options{Path: dir, Name: "renamed.bin"},
+			Res:  &base.Resource{Files: []*base.FileInfo{{Name: "origin.bin", Size: 10}}},
+		})
+
+		file, err := downloader.GetTaskFile(task.ID, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Name != "renamed.bin" || file.RelPath != "renamed.bin" || file.LocalPath != filepath.Join(dir, "renamed.bin") {
+			t.Errorf("GetTaskFile() got = %v", file)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+gopeed.events.onResolve(async function (ctx) {
+    ctx.res = {
+        name: "permissions",
+        files: [{name: "a.txt", req: {url: "https://example.com/a.txt"}}]
+    }
+});
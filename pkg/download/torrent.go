@@ -0,0 +1,232 @@
+package download
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+
+	"github.com/GopeedLab/gopeed/internal/fetcher"
+	"github.com/GopeedLab/gopeed/internal/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/base"
+	pbt "github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+var ErrTorrentPathRequired = errors.New("torrent path is required")
+
+// CreateTorrent creates a torrent from a local file or folder, and seeds it by a done task if opts.Seed is set.
+// The parent directory of the content must be in the WhiteDownloadDirs and match one of the dirs patterns,
+// empty dirs means no extra restriction. The hashing stops once ctx is done.
+func (d *Downloader) CreateTorrent(ctx context.Context, opts *pbt.CreateTorrentOpts, dirs []string) (*pbt.CreateTorrentResult, error) {
+	if opts == nil || opts.Path == "" {
+		return nil, ErrTorrentPathRequired
+	}
+	path, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	// the content is seeded from its parent directory, like a task downloaded to it
+	seedOpts := &base.Options{Path: filepath.Dir(path)}
+	if len(d.cfg.WhiteDownloadDirs) > 0 && !matchDownloadDir(d.cfg.WhiteDownloadDirs, seedOpts.Path) {
+		return nil, ErrDownloadDirForbidden
+	}
+	if err := d.CheckDownloadDir(seedOpts, dirs); err != nil {
+		return nil, err
+	}
+
+	createOpts := *opts
+	createOpts.Path = path
+	result, err := bt.CreateTorrent(ctx, &createOpts)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Seed {
+		return result, nil
+	}
+
+	req := &base.Request{
+		URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(result.Torrent),
+	}
+	result.TaskID, err = d.createSeedTask(req, seedOpts)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// createSeedTask creates a done task of the local content, the pieces are verified and uploaded by the fetcher,
+// and the seeding stops by the protocol seed config like a downloaded task.
+func (d *Downloader) createSeedTask(req *base.Request, opts *base.Options) (taskId string, err error) {
+	fm, err := d.parseFm(req.URL)
+	if err != nil {
+		return
+	}
+	f := fm.Build()
+	d.setupFetcher(fm, f)
+	if _, ok := f.(fetcher.Uploader); !ok {
+		return "", ErrUnSupportedProtocol
+	}
+	if err = f.Resolve(req, opts); err != nil {
+		return
+	}
+
+	task := NewTask()
+	task.fetcherManager = fm
+	task.fetcher = f
+	task.Protocol = fm.Name()
+	task.Meta = f.Meta()
+	task.Status = base.DownloadStatusDone
+	task.Uploading = true
+	task.Progress = &Progress{Downloaded: task.Meta.Res.Size}
+	initTask(task)
+	if err = d.storage.Put(bucketTask, task.ID, task.clone()); err != nil {
+		f.Close()
+		return
+	}
+
+	d.lock.Lock()
+	d.tasks = append(d.tasks, task)
+	d.lock.Unlock()
+
+	go d.watch(task)
+	return task.ID, nil
+}
+
+var ErrTaskNotTorrent = errors.New("task is not a bt task")
+
+// GetTaskPeers returns the connected peers of a running bt task.
+func (d *Downloader) GetTaskPeers(id string) ([]*pbt.Peer, error) {
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.Peers()
+}
+
+// AddTaskPeer connects a running bt task to a peer by ip:port.
+func (d *Downloader) AddTaskPeer(id string, addr string) error {
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return err
+	}
+	return f.AddPeer(addr)
+}
+
+// BanTaskPeer bans a peer by ip or ip:port for all bt tasks until the process exits.
+func (d *Downloader) BanTaskPeer(id string, addr string) error {
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return err
+	}
+	return f.BanPeer(addr)
+}
+
+// GetTaskTrackers returns the trackers of a running bt task with the result of the last announce.
+func (d *Downloader) GetTaskTrackers(id string) ([]*pbt.Tracker, error) {
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.Trackers()
+}
+
+// AddTaskTrackers adds trackers to a running bt task and announces to them, the trackers are saved in the task request.
+func (d *Downloader) AddTaskTrackers(id string, urls []string) error {
+	return d.modifyTaskTrackers(id, func(f *bt.Fetcher) error {
+		return f.AddTrackers(urls)
+	})
+}
+
+// RemoveTaskTrackers removes trackers from a running bt task and from the task request.
+func (d *Downloader) RemoveTaskTrackers(id string, urls []string) error {
+	return d.modifyTaskTrackers(id, func(f *bt.Fetcher) error {
+		return f.RemoveTrackers(urls)
+	})
+}
+
+// ReannounceTask announces a running bt task to all its trackers in the background.
+func (d *Downloader) ReannounceTask(id string) error {
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return err
+	}
+	return f.Reannounce()
+}
+
+func (d *Downloader) modifyTaskTrackers(id string, fn func(f *bt.Fetcher) error) error {
+	f, task, err := d.torrentFetcher(id)
+	if err != nil {
+		return err
+	}
+	task.lock.Lock()
+	defer task.lock.Unlock()
+
+	if err := fn(f); err != nil {
+		return err
+	}
+	task.Meta = f.Meta()
+	return d.saveTask(task)
+}
+
+// torrentFetcher returns the fetcher of a bt task, the torrent is only available while the task is running or seeding.
+func (d *Downloader) torrentFetcher(id string) (*bt.Fetcher, *Task, error) {
+	task := d.GetTask(id)
+	if task == nil {
+		return nil, nil, ErrTaskNotFound
+	}
+	if task.Protocol != "bt" {
+		return nil, nil, ErrTaskNotTorrent
+	}
+	f, ok := task.fetcher.(*bt.Fetcher)
+	if !ok {
+		return nil, nil, bt.ErrTorrentNotReady
+	}
+	return f, task, nil
+}
+
+var ErrTaskNotSeeding = errors.New("task is not seeding")
+
+// StopSeeding stops uploading a done bt task before the seed limits are reached, the task and its files are kept.
+func (d *Downloader) StopSeeding(id string) error {
+	task := d.GetTask(id)
+	if task == nil {
+		return ErrTaskNotFound
+	}
+	if task.Protocol != "bt" {
+		return ErrTaskNotTorrent
+	}
+	task.lock.Lock()
+	defer task.lock.Unlock()
+	if task.Status != base.DownloadStatusDone || !task.Uploading {
+		return ErrTaskNotSeeding
+	}
+	if task.fetcher != nil {
+		if err := task.fetcher.Close(); err != nil {
+			return err
+		}
+	}
+	task.Uploading = false
+	task.Progress.UploadSpeed = 0
+	return d.storage.Put(bucketTask, task.ID, task.clone())
+}
+
+// ExportTorrent exports the metadata of a bt task or a resolved bt request as a .torrent file,
+// id is a task id or the id of a resolve result, the torrent of a task is only available while it's running or seeding.
+func (d *Downloader) ExportTorrent(id string) (*pbt.TorrentMetadata, error) {
+	d.fetcherMapLock.RLock()
+	resolved, ok := d.fetcherCache[id]
+	d.fetcherMapLock.RUnlock()
+	if ok {
+		f, ok := resolved.(*bt.Fetcher)
+		if !ok {
+			return nil, ErrTaskNotTorrent
+		}
+		return f.ExportTorrent()
+	}
+
+	f, _, err := d.torrentFetcher(id)
+	if err != nil {
+		return nil, err
+	}
+	return f.ExportTorrent()
+}
@@ -0,0 +1,192 @@
+package download
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+)
+
+func TestDownloader_CreateTorrent(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		dir := t.TempDir()
+		root := filepath.Join(dir, "dataset")
+		if err := os.MkdirAll(filepath.Join(root, "sub"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "sub", "b.txt"), []byte("world!"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{}, nil); err != ErrTorrentPathRequired {
+			t.Errorf("CreateTorrent() empty path got = %v, want %v", err, ErrTorrentPathRequired)
+		}
+		if _, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: root}, []string{filepath.Join(dir, "other")}); err != ErrDownloadDirForbidden {
+			t.Errorf("CreateTorrent() forbidden dir got = %v, want %v", err, ErrDownloadDirForbidden)
+		}
+
+		result, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: root}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.TaskID != "" || len(result.Torrent) == 0 || result.Magnet == "" {
+			t.Errorf("CreateTorrent() without seed got = %+v", result)
+		}
+		if len(downloader.GetTasks()) != 0 {
+			t.Errorf("CreateTorrent() without seed should not create a task, got %d", len(downloader.GetTasks()))
+		}
+
+		result, err = downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: root, Seed: true}, []string{dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := downloader.GetTask(result.TaskID)
+		if task == nil {
+			t.Fatal("CreateTorrent() seed task not found")
+		}
+		if task.Status != base.DownloadStatusDone || !task.Uploading || task.Protocol != "bt" {
+			t.Errorf("CreateTorrent() seed task got = %s, uploading %v, protocol %s", task.Status, task.Uploading, task.Protocol)
+		}
+		if task.Meta.Opts.Path != dir || task.Meta.Res.Hash != result.InfoHash || task.Progress.Downloaded != 11 {
+			t.Errorf("CreateTorrent() seed task meta got = %s, %s, %d", task.Meta.Opts.Path, task.Meta.Res.Hash, task.Progress.Downloaded)
+		}
+		files, err := downloader.GetTaskFiles(task.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 2 || files[0].LocalPath != filepath.Join(root, "a.txt") {
+			t.Errorf("CreateTorrent() seed task files got = %+v", files)
+		}
+	})
+}
+
+func TestDownloader_TaskSwarm(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "swarm.txt")
+		if err := os.WriteFile(file, []byte("swarm content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := downloader.GetTaskPeers("not-exist"); err != ErrTaskNotFound {
+			t.Errorf("GetTaskPeers() not found got = %v, want %v", err, ErrTaskNotFound)
+		}
+
+		result, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file, Seed: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers, err := downloader.GetTaskPeers(result.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(peers) != 0 {
+			t.Errorf("GetTaskPeers() got = %+v, want empty", peers)
+		}
+
+		tracker := "udp://127.0.0.1:1/announce"
+		if err := downloader.AddTaskTrackers(result.TaskID, []string{tracker}); err != nil {
+			t.Fatal(err)
+		}
+		trackers, err := downloader.GetTaskTrackers(result.TaskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trackers) != 1 || trackers[0].URL != tracker {
+			t.Errorf("GetTaskTrackers() got = %+v", trackers)
+		}
+		if extra := downloader.GetTask(result.TaskID).Meta.Req.Extra.(*bt.ReqExtra); len(extra.Trackers) != 1 {
+			t.Errorf("AddTaskTrackers() should save the tracker in the task, got = %v", extra.Trackers)
+		}
+		if err := downloader.ReannounceTask(result.TaskID); err != nil {
+			t.Fatal(err)
+		}
+		if err := downloader.RemoveTaskTrackers(result.TaskID, []string{tracker}); err != nil {
+			t.Fatal(err)
+		}
+		if trackers, _ = downloader.GetTaskTrackers(result.TaskID); len(trackers) != 0 {
+			t.Errorf("RemoveTaskTrackers() got = %+v", trackers)
+		}
+	})
+}
+
+func TestDownloader_ExportTorrent(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		file := filepath.Join(t.TempDir(), "export.txt")
+		if err := os.WriteFile(file, []byte("export content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := downloader.ExportTorrent("not-exist"); err != ErrTaskNotFound {
+			t.Errorf("ExportTorrent() not found got = %v, want %v", err, ErrTaskNotFound)
+		}
+		rr, err := downloader.Resolve(&base.Request{
+			URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(created.Torrent),
+		}, &base.Options{Path: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata, err := downloader.ExportTorrent(rr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.InfoHash != created.InfoHash || metadata.Name != "export.txt" || len(metadata.Torrent) == 0 {
+			t.Errorf("ExportTorrent() resolved got = %+v", metadata)
+		}
+
+		taskId, err := downloader.Create(rr.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata, err = downloader.ExportTorrent(taskId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.InfoHash != created.InfoHash {
+			t.Errorf("ExportTorrent() task got = %+v", metadata)
+		}
+	})
+}
+
+func TestDownloader_StopSeeding(t *testing.T) {
+	setupAccessTest(t, func(downloader *Downloader) {
+		file := filepath.Join(t.TempDir(), "seed.txt")
+		if err := os.WriteFile(file, []byte("seed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created, err := downloader.CreateTorrent(context.Background(), &bt.CreateTorrentOpts{Path: file, Seed: true}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := downloader.StopSeeding("not-exist"); err != ErrTaskNotFound {
+			t.Errorf("StopSeeding() not found got = %v, want %v", err, ErrTaskNotFound)
+		}
+		if err := downloader.StopSeeding(created.TaskID); err != nil {
+			t.Fatal(err)
+		}
+		task := downloader.GetTask(created.TaskID)
+		if task.Uploading || task.Status != base.DownloadStatusDone {
+			t.Errorf("StopSeeding() task got uploading %v, status %s", task.Uploading, task.Status)
+		}
+		if err := downloader.StopSeeding(created.TaskID); err != ErrTaskNotSeeding {
+			t.Errorf("StopSeeding() again got = %v, want %v", err, ErrTaskNotSeeding)
+		}
+
+		// the task is kept and can still be deleted
+		if err := downloader.Delete(&TaskFilter{IDs: []string{created.TaskID}}, false); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -1,7 +1,33 @@
 package bt
 
+import "time"
+
 type ReqExtra struct {
 	Trackers []string `json:"trackers"`
+	// WebSeeds are the HTTP mirrors of the content (BEP 19), added to the web seeds embedded in the torrent or magnet
+	WebSeeds []string `json:"webSeeds"`
+}
+
+// FilePriority is the download priority of a file in the torrent.
+type FilePriority string
+
+const (
+	// FilePrioritySkip excludes the file from the download like deselecting it
+	FilePrioritySkip   FilePriority = "skip"
+	FilePriorityLow    FilePriority = "low"
+	FilePriorityNormal FilePriority = "normal"
+	FilePriorityHigh   FilePriority = "high"
+)
+
+type OptsExtra struct {
+	// Sequential downloads the pieces of the selected files in order, so they can be previewed before the download is complete
+	Sequential bool `json:"sequential"`
+	// FilePriorities is the priority of the files by index, files not in the map have the normal priority
+	FilePriorities map[int]FilePriority `json:"filePriorities"`
+	// SeedKeep, SeedRatio and SeedTime override the seed config of the protocol for the task, nil uses the protocol config
+	SeedKeep  *bool    `json:"seedKeep"`
+	SeedRatio *float64 `json:"seedRatio"`
+	SeedTime  *int64   `json:"seedTime"`
 }
 
 // Stats for torrent
@@ -10,10 +36,95 @@ type Stats struct {
 	TotalPeers       int `json:"totalPeers"`
 	ActivePeers      int `json:"activePeers"`
 	ConnectedSeeders int `json:"connectedSeeders"`
+	// WebSeeds is the number of the HTTP mirrors of the torrent
+	WebSeeds int `json:"webSeeds"`
 	// Total seed bytes
 	SeedBytes int64 `json:"seedBytes"`
 	// Seed ratio
 	SeedRatio float64 `json:"seedRatio"`
 	// Total seed time
 	SeedTime int64 `json:"seedTime"`
+	// SeedQueued is true if the done torrent is waiting in the seeding queue for a slot
+	SeedQueued bool `json:"seedQueued"`
+	// Files is the completion of each file in the torrent
+	Files []*StatsFile `json:"files"`
+	// PieceCount is the number of pieces in the torrent
+	PieceCount int `json:"pieceCount"`
+	// Pieces is a bitfield of the completed pieces, the high bit of the first byte is the first piece
+	Pieces []byte `json:"pieces"`
+}
+
+type StatsFile struct {
+	Completed int64        `json:"completed"`
+	Size      int64        `json:"size"`
+	Priority  FilePriority `json:"priority"`
+}
+
+// CreateTorrentOpts are the options to create a torrent from a local file or folder.
+type CreateTorrentOpts struct {
+	// Path is the local file or folder to create the torrent from
+	Path string `json:"path"`
+	// PieceLength is the piece size in bytes, a power of 2 of at least 16KiB, zero chooses it by the content size
+	PieceLength int64    `json:"pieceLength"`
+	Trackers    []string `json:"trackers"`
+	// Private disables DHT and PEX for the torrent, peers are only from the trackers (BEP 27)
+	Private bool `json:"private"`
+	// WebSeeds are the urls serving the same content over HTTP (BEP 19)
+	WebSeeds []string `json:"webSeeds"`
+	Comment  string   `json:"comment"`
+	// Seed creates a task seeding the content after the torrent is created
+	Seed bool `json:"seed"`
+}
+
+type CreateTorrentResult struct {
+	// TaskID is the id of the seeding task, empty if not seeding
+	TaskID   string `json:"taskId"`
+	Name     string `json:"name"`
+	InfoHash string `json:"infoHash"`
+	Magnet   string `json:"magnet"`
+	// Torrent is the content of the .torrent file, base64 encoded in JSON
+	Torrent []byte `json:"torrent"`
+}
+
+// TorrentMetadata is the metadata of a resolved torrent or magnet exported as a .torrent file.
+type TorrentMetadata struct {
+	Name     string `json:"name"`
+	InfoHash string `json:"infoHash"`
+	Magnet   string `json:"magnet"`
+	// Torrent is the content of the .torrent file, base64 encoded in JSON
+	Torrent []byte `json:"torrent"`
+}
+
+// Peer is a connected peer of a torrent.
+type Peer struct {
+	Addr   string `json:"addr"`
+	Client string `json:"client"`
+	// Network is the transport of the connection, e.g. tcp, utp or webrtc
+	Network string `json:"network"`
+	// Source is how the peer was found, Tr (tracker), I (incoming), Hg/Ha (DHT), X (PEX) or M (added manually)
+	Source            string `json:"source"`
+	Seeder            bool   `json:"seeder"`
+	PrefersEncryption bool   `json:"prefersEncryption"`
+	// DownloadSpeed and UploadSpeed are in bytes/s
+	DownloadSpeed int64 `json:"downloadSpeed"`
+	UploadSpeed   int64 `json:"uploadSpeed"`
+	Downloaded    int64 `json:"downloaded"`
+	Uploaded      int64 `json:"uploaded"`
+	// Progress is the ratio of the pieces the peer has, from 0 to 1
+	Progress float64 `json:"progress"`
+}
+
+// Tracker is a tracker of a torrent with the result of the last announce,
+// Peers and Error come from the torrent client, the other result fields are empty until the tracker is added or reannounced.
+type Tracker struct {
+	URL          string    `json:"url"`
+	Tier         int       `json:"tier"`
+	LastAnnounce time.Time `json:"lastAnnounce"`
+	Error        string    `json:"error"`
+	Seeders      int       `json:"seeders"`
+	Leechers     int       `json:"leechers"`
+	// Peers is the number of peers returned by the last announce
+	Peers int `json:"peers"`
+	// Interval is the announce interval in seconds returned by the tracker
+	Interval int `json:"interval"`
 }
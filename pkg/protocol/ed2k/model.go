@@ -1,5 +1,11 @@
 package ed2k
 
+import (
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
 type Stats struct {
 	State         string `json:"state"`
 	Paused        bool   `json:"paused"`
@@ -11,4 +17,116 @@ type Stats struct {
 	TotalDone     int64  `json:"totalDone"`
 	TotalReceived int64  `json:"totalReceived"`
 	TotalWanted   int64  `json:"totalWanted"`
+	// CorruptedParts is the number of parts failed the part hash verification while downloading, they are downloaded again
+	CorruptedParts int `json:"corruptedParts"`
+	// RedownloadedBytes is the bytes of the corrupted parts downloaded again
+	RedownloadedBytes int64 `json:"redownloadedBytes"`
+	// Recheck is the result of the last recheck, nil if the file is never rechecked
+	Recheck *base.Recheck `json:"recheck"`
+}
+
+// ServerList is the servers known by the client.
+type ServerList struct {
+	Servers []*Server `json:"servers"`
+	// ServerMetError is the error of the last server.met load
+	ServerMetError string `json:"serverMetError"`
+}
+
+// Server is an ed2k server known by the client with its connection status.
+type Server struct {
+	Addr string `json:"addr"`
+	// Configured is true if the server is in the connect list, and it's reconnected after a failure
+	Configured bool `json:"configured"`
+	Connected  bool `json:"connected"`
+	// LoggedIn is true after the server assigned an id to the client
+	LoggedIn bool `json:"loggedIn"`
+	// Primary is the server used for the source requests and the searches
+	Primary bool `json:"primary"`
+	// IDClass is HIGH or LOW by the id assigned by the server, a LOW id means the client is firewalled, UNKNOWN before login
+	IDClass string `json:"idClass"`
+	// LastReceive is the time in milliseconds since the last packet from the server
+	LastReceive  int64 `json:"lastReceive"`
+	DownloadRate int   `json:"downloadRate"`
+	UploadRate   int   `json:"uploadRate"`
+	// Error is the last connect error of the server
+	Error string `json:"error"`
+}
+
+// Kad is the status of the KAD network.
+type Kad struct {
+	Enabled      bool `json:"enabled"`
+	Bootstrapped bool `json:"bootstrapped"`
+	Firewalled   bool `json:"firewalled"`
+	LiveNodes    int  `json:"liveNodes"`
+	KnownNodes   int  `json:"knownNodes"`
+	ListenPort   int  `json:"listenPort"`
+	// NodesDatError is the error of the last nodes.dat load
+	NodesDatError string `json:"nodesDatError"`
+}
+
+// Source is a peer of an ed2k transfer.
+type Source struct {
+	Addr   string `json:"addr"`
+	Client string `json:"client"`
+	// From is how the source was found, any of server, kad, resume and incoming
+	From []string `json:"from"`
+	// DownloadSpeed and UploadSpeed are in bytes/s
+	DownloadSpeed int `json:"downloadSpeed"`
+	UploadSpeed   int `json:"uploadSpeed"`
+	// Pieces is the number of pieces the source has
+	Pieces    int `json:"pieces"`
+	FailCount int `json:"failCount"`
+}
+
+const (
+	SearchScopeServer = "server"
+	SearchScopeKad    = "kad"
+)
+
+const (
+	SearchStateRunning  = "running"
+	SearchStateFinished = "finished"
+	SearchStateStopped  = "stopped"
+	SearchStateFailed   = "failed"
+)
+
+// SearchOpts are the keyword search options, the filters are sent to the servers and also applied to the KAD results.
+type SearchOpts struct {
+	Query string `json:"query"`
+	// Scope is server or kad, empty searches both
+	Scope   string `json:"scope"`
+	MinSize int64  `json:"minSize"`
+	// MaxSize is the max file size in bytes, 0 means no limit
+	MaxSize int64 `json:"maxSize"`
+	// MinSources and MinCompleteSources filter the results by availability
+	MinSources         int `json:"minSources"`
+	MinCompleteSources int `json:"minCompleteSources"`
+	// FileType is the ed2k file type, one of Audio, Video, Image, Pro, Doc, Arc and Iso
+	FileType string `json:"fileType"`
+	// Extension is the file extension without the dot
+	Extension string `json:"extension"`
+}
+
+// Search is a keyword search, the results are updated until the state is not running.
+type Search struct {
+	ID        string          `json:"id"`
+	Opts      *SearchOpts     `json:"opts"`
+	State     string          `json:"state"`
+	Error     string          `json:"error"`
+	StartedAt time.Time       `json:"startedAt"`
+	Results   []*SearchResult `json:"results"`
+}
+
+type SearchResult struct {
+	// Link is the ed2k://|file| link to create a task
+	Link            string `json:"link"`
+	Name            string `json:"name"`
+	Size            int64  `json:"size"`
+	Hash            string `json:"hash"`
+	Sources         int    `json:"sources"`
+	CompleteSources int    `json:"completeSources"`
+	FileType        string `json:"fileType"`
+	Extension       string `json:"extension"`
+	// From is where the result was found, server and/or kad
+	From []string `json:"from"`
 }
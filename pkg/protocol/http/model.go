@@ -8,6 +8,8 @@ type ReqExtra struct {
 
 type OptsExtra struct {
 	Connections int `json:"connections"`
+	// Sequential downloads the file from the head, so it can be previewed before the download is complete
+	Sequential bool `json:"sequential"`
 	// AutoTorrent when task download complete, and it is a .torrent file, it will be auto create a new task for the torrent file
 	// nil means use global config, true/false means explicit setting
 	AutoTorrent *bool `json:"autoTorrent"`
@@ -21,11 +23,16 @@ type OptsExtra struct {
 	ArchivePassword string `json:"archivePassword"`
 	// DeleteAfterExtract when true, deletes the archive file after successful extraction
 	DeleteAfterExtract bool `json:"deleteAfterExtract"`
+	// Checksum is the expected checksum of the file to recheck it, in the format of algorithm:hex,
+	// the algorithm is md5, sha1, sha256 or sha512. The checksum in the response headers is used if it's empty.
+	Checksum string `json:"checksum"`
 }
 
 // Stats for download
 type Stats struct {
 	Connections []*StatsConnection `json:"connections"`
+	// Checksum is the checksum to recheck the file, from the options or the response headers
+	Checksum string `json:"checksum"`
 }
 
 type StatsConnection struct {
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+type principalKey struct{}
+
+func withPrincipal(r *http.Request, principal *base.Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+}
+
+// principalOf returns the authenticated principal of the request, requests without authentication enabled are admin.
+func principalOf(r *http.Request) *base.Principal {
+	if p, ok := r.Context().Value(principalKey{}).(*base.Principal); ok && p != nil {
+		return p
+	}
+	return base.AdminPrincipal("")
+}
+
+// authorize checks the scope of the matched route, routes without a description require admin scope.
+func authorize(r *http.Request, principal *base.Principal) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return principal.HasScope(base.AccessScopeAdmin)
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return principal.HasScope(base.AccessScopeAdmin)
+	}
+	ar := apiRoutes[r.Method+" "+path]
+	if ar == nil {
+		ar = apiRoutes["ANY "+path]
+	}
+	if ar == nil {
+		return principal.HasScope(base.AccessScopeAdmin)
+	}
+	return ar.Scope == "" || principal.HasScope(ar.Scope)
+}
+
+// authorizeTask checks the download directory of the task of a task route against the principal restriction,
+// including the task files served to the web ui.
+func authorizeTask(r *http.Request, principal *base.Principal) bool {
+	if len(principal.DownloadDirs) == 0 {
+		return true
+	}
+	var id string
+	if strings.HasPrefix(r.URL.Path, "/fs/tasks/") {
+		id, _, _ = strings.Cut(strings.TrimPrefix(r.URL.Path, "/fs/tasks/"), "/")
+	} else if route := mux.CurrentRoute(r); route != nil {
+		path, err := route.GetPathTemplate()
+		// the task file routes check the principal directories themselves and answer with forbidden
+		isFileRoute := strings.HasPrefix(path, "/api/v1/tasks/{id}/files/") || path == "/api/v1/tasks/{id}/zip"
+		if err == nil && !isFileRoute && (strings.HasPrefix(path, "/api/v1/tasks/{id}") || strings.HasPrefix(path, "/api/v1/torrents/{id}")) {
+			id = mux.Vars(r)["id"]
+		}
+	}
+	if id == "" {
+		return true
+	}
+	// an unknown id is left to the route, e.g. a resolved request is exported by its id
+	task := Downloader.GetTask(id)
+	return task == nil || Downloader.CheckDownloadDir(task.Meta.Opts, principal.DownloadDirs) == nil
+}
+
+// checkDownloadDir checks the download directory against the principal restriction.
+func checkDownloadDir(w http.ResponseWriter, r *http.Request, opts ...*base.Options) bool {
+	principal := principalOf(r)
+	for _, o := range opts {
+		if err := Downloader.CheckDownloadDir(o, principal.DownloadDirs); err != nil {
+			WriteJson(w, model.NewErrorResult(err.Error(), model.CodeForbidden))
+			return false
+		}
+	}
+	return true
+}
+
+func GetAccessPrincipal(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, model.NewOkResult(principalOf(r)))
+}
+
+func CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateAccessToken
+	if ReadJson(r, w, &req) {
+		if req.Name == "" {
+			WriteJson(w, model.NewErrorResult("param invalid: name", model.CodeInvalidParam))
+			return
+		}
+		token, info, err := Downloader.CreateAccessToken(req.Name, req.Scopes, req.DownloadDirs)
+		if err != nil {
+			WriteJson(w, model.NewErrorResult(err.Error(), model.CodeInvalidParam))
+			return
+		}
+		WriteJson(w, model.NewOkResult(&model.CreateAccessTokenResp{
+			Token: token,
+			Info:  info,
+		}))
+	}
+}
+
+func GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, model.NewOkResult(Downloader.GetAccessTokens()))
+}
+
+func RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := Downloader.RevokeAccessToken(vars["id"]); err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func PutAccessUser(w http.ResponseWriter, r *http.Request) {
+	var req base.AccessUser
+	if ReadJson(r, w, &req) {
+		if err := Downloader.PutAccessUser(&req); err != nil {
+			WriteJson(w, model.NewErrorResult(err.Error(), model.CodeInvalidParam))
+			return
+		}
+		// the password or scopes may be changed, the user has to login again
+		sessions.revokePrincipal(base.PrincipalKindUser, req.Username)
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
+func GetAccessUsers(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, model.NewOkResult(Downloader.GetAccessUsers()))
+}
+
+func DeleteAccessUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := Downloader.DeleteAccessUser(vars["username"]); err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	sessions.revokePrincipal(base.PrincipalKindUser, vars["username"])
+	WriteJson(w, model.NewNilResult())
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -26,8 +27,16 @@ func Info(w http.ResponseWriter, r *http.Request) {
 func Resolve(w http.ResponseWriter, r *http.Request) {
 	var req model.ResolveTask
 	if ReadJson(r, w, &req) {
+		if !checkDownloadDir(w, r, req.Opts) {
+			return
+		}
 		rr, err := Downloader.Resolve(req.Req, req.Opts)
 		if err != nil {
+			var extErr *download.ExtensionError
+			if errors.As(err, &extErr) {
+				WriteJson(w, model.NewErrorResult(err.Error(), model.CodeExtensionError))
+				return
+			}
 			WriteJson(w, model.NewErrorResult(err.Error()))
 			return
 		}
@@ -43,9 +52,12 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 			err    error
 		)
 		if req.Rid != "" {
-			taskId, err = Downloader.Create(req.Rid)
+			taskId, err = Downloader.Create(req.Rid, principalOf(r).DownloadDirs...)
 		} else if req.Req != nil {
-			taskId, err = Downloader.CreateDirect(req.Req, req.Opts)
+			if !checkDownloadDir(w, r, req.Opts) {
+				return
+			}
+			taskId, err = Downloader.CreateDirect(req.Req, req.Opts, principalOf(r).DownloadDirs...)
 		} else {
 			WriteJson(w, model.NewErrorResult("param invalid: rid or req", model.CodeInvalidParam))
 			return
@@ -65,7 +77,16 @@ func CreateTaskBatch(w http.ResponseWriter, r *http.Request) {
 			WriteJson(w, model.NewErrorResult("param invalid: reqs", model.CodeInvalidParam))
 			return
 		}
-		taskIds, err := Downloader.CreateDirectBatch(&req)
+		for _, ir := range req.Reqs {
+			opts := ir.Opts
+			if opts == nil {
+				opts = req.Opts
+			}
+			if !checkDownloadDir(w, r, opts) {
+				return
+			}
+		}
+		taskIds, err := Downloader.CreateDirectBatch(&req, principalOf(r).DownloadDirs...)
 		if err != nil {
 			WriteJson(w, model.NewErrorResult(err.Error()))
 			return
@@ -84,6 +105,9 @@ func PatchTask(w http.ResponseWriter, r *http.Request) {
 
 	var req model.ResolveTask
 	if ReadJson(r, w, &req) {
+		if req.Opts != nil && req.Opts.Path != "" && !checkDownloadDir(w, r, req.Opts) {
+			return
+		}
 		if err := Downloader.Patch(taskId, req.Req, req.Opts); err != nil {
 			if err == download.ErrTaskNotFound {
 				WriteJson(w, model.NewErrorResult("task not found", model.CodeTaskNotFound))
@@ -230,9 +254,14 @@ func InstallExtension(w http.ResponseWriter, r *http.Request) {
 			installedExt *download.Extension
 			err          error
 		)
-		if req.DevMode {
+		switch {
+		case req.DevMode:
 			installedExt, err = Downloader.InstallExtensionByFolder(req.URL, true)
-		} else {
+		case req.Identity != "":
+			installedExt, err = Downloader.InstallExtensionByIndex(req.Identity)
+		case download.IsExtensionArchive(req.URL):
+			installedExt, err = Downloader.InstallExtensionByURL(req.URL, req.Checksum)
+		default:
 			installedExt, err = Downloader.InstallExtensionByGit(req.URL)
 		}
 		if err != nil {
@@ -243,6 +272,62 @@ func InstallExtension(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// InstallExtensionArchive installs an extension from the uploaded .zip or .tgz archive in the request body.
+func InstallExtensionArchive(w http.ResponseWriter, r *http.Request) {
+	installedExt, err := Downloader.InstallExtensionByArchive(r.Body)
+	if err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	WriteJson(w, model.NewOkResult(installedExt.Identity))
+}
+
+// PreviewExtension fetches the extension of an install request without installing it, the client can show the
+// permissions it requests and install it after they are approved.
+func PreviewExtension(w http.ResponseWriter, r *http.Request) {
+	var req model.InstallExtension
+	if ReadJson(r, w, &req) {
+		var (
+			ext *download.Extension
+			err error
+		)
+		switch {
+		case req.DevMode:
+			ext, err = Downloader.PreviewExtensionByFolder(req.URL, true)
+		case req.Identity != "":
+			ext, err = Downloader.PreviewExtensionByIndex(req.Identity)
+		case download.IsExtensionArchive(req.URL):
+			ext, err = Downloader.PreviewExtensionByURL(req.URL, req.Checksum)
+		default:
+			ext, err = Downloader.PreviewExtensionByGit(req.URL)
+		}
+		if err != nil {
+			WriteJson(w, model.NewErrorResult(err.Error()))
+			return
+		}
+		WriteJson(w, model.NewOkResult(ext))
+	}
+}
+
+// PreviewExtensionArchive reads the extension in the uploaded .zip or .tgz archive without installing it.
+func PreviewExtensionArchive(w http.ResponseWriter, r *http.Request) {
+	ext, err := Downloader.PreviewExtensionByArchive(r.Body)
+	if err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	WriteJson(w, model.NewOkResult(ext))
+}
+
+func GetExtensionIndex(w http.ResponseWriter, r *http.Request) {
+	index, err := Downloader.GetExtensionIndex()
+	if err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	WriteJson(w, model.NewOkResult(index))
+}
+
 func GetExtensions(w http.ResponseWriter, r *http.Request) {
 	list := Downloader.GetExtensions()
 	WriteJson(w, model.NewOkResult(list))
@@ -285,6 +370,19 @@ func SwitchExtension(w http.ResponseWriter, r *http.Request) {
 	WriteJson(w, model.NewNilResult())
 }
 
+func UpdateExtensionLimits(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	identity := vars["identity"]
+	var limits base.ExtensionLimits
+	if ReadJson(r, w, &limits) {
+		if err := Downloader.UpdateExtensionLimits(identity, &limits); err != nil {
+			WriteJson(w, model.NewErrorResult(err.Error()))
+			return
+		}
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
 func DeleteExtension(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	identity := vars["identity"]
@@ -369,6 +467,20 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 	WriteJson(w, model.NewOkResult(statsResult))
 }
 
+// RecheckTask verifies the downloaded data of a task that is not running, the damaged data is downloaded again.
+func RecheckTask(w http.ResponseWriter, r *http.Request) {
+	result, err := Downloader.RecheckTask(mux.Vars(r)["id"])
+	if err != nil {
+		if err == download.ErrTaskNotFound {
+			WriteJson(w, model.NewErrorResult(err.Error(), model.CodeTaskNotFound))
+			return
+		}
+		writeError(w, err.Error())
+		return
+	}
+	WriteJson(w, model.NewOkResult(result))
+}
+
 func parseIdFilter(r *http.Request) (*download.TaskFilter, any) {
 	vars := mux.Vars(r)
 	taskId := vars["id"]
@@ -377,7 +489,8 @@ func parseIdFilter(r *http.Request) (*download.TaskFilter, any) {
 	}
 
 	filter := &download.TaskFilter{
-		IDs: []string{taskId},
+		IDs:          []string{taskId},
+		DownloadDirs: principalOf(r).DownloadDirs,
 	}
 	return filter, nil
 }
@@ -388,9 +501,10 @@ func parseFilter(r *http.Request) (*download.TaskFilter, any) {
 	}
 
 	filter := &download.TaskFilter{
-		IDs:         r.Form["id"],
-		Statuses:    convertStatues(r.Form["status"]),
-		NotStatuses: convertStatues(r.Form["notStatus"]),
+		IDs:          r.Form["id"],
+		Statuses:     convertStatues(r.Form["status"]),
+		NotStatuses:  convertStatues(r.Form["notStatus"]),
+		DownloadDirs: principalOf(r).DownloadDirs,
 	}
 	return filter, nil
 }
@@ -0,0 +1,218 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// auditMaxBody is the max request body size that is summarized, larger bodies only record their size
+	auditMaxBody = 64 * 1024
+	// auditMaxString truncates long strings like base64 torrent data in the summary
+	auditMaxString = 256
+)
+
+var (
+	auditSensitiveKey = regexp.MustCompile(`(?i)pass|pwd|token|secret|authorization|cookie`)
+	auditResultCode   = regexp.MustCompile(`^\s*\{\s*"code"\s*:\s*(-?\d+)`)
+)
+
+// auditMiddleware records the state-changing API calls described in apiRoutes.
+func auditMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := auditRoute(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		params := auditParams(r)
+		aw := &auditResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(aw, r)
+
+		principal := principalOf(r)
+		entry := &base.AuditEntry{
+			Principal:     principal.Name,
+			PrincipalKind: principal.Kind,
+			IP:            remoteIP(r),
+			Method:        r.Method,
+			Route:         route,
+			Path:          r.URL.Path,
+			Params:        params,
+			Status:        aw.statusCode(),
+		}
+		if matched := auditResultCode.FindSubmatch(aw.prefix); matched != nil {
+			entry.Code, _ = strconv.Atoi(string(matched[1]))
+		}
+		if err := Downloader.RecordAudit(entry); err != nil {
+			Downloader.Logger.Warn().Err(err).Msgf("record audit failed: %s %s", r.Method, r.URL.Path)
+		}
+	})
+}
+
+// auditRoute returns the route template if the request should be audited.
+func auditRoute(r *http.Request) (string, bool) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "", false
+	}
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil || !strings.HasPrefix(path, "/api/") {
+		return "", false
+	}
+	ar := apiRoutes[r.Method+" "+path]
+	if ar == nil {
+		ar = apiRoutes["ANY "+path]
+	}
+	if ar != nil && ar.NoAudit {
+		return "", false
+	}
+	return path, true
+}
+
+// auditParams summarizes the path variables, query and json body of the request, the body is restored for the handler.
+func auditParams(r *http.Request) map[string]any {
+	params := make(map[string]any)
+	if vars := mux.Vars(r); len(vars) > 0 {
+		params["vars"] = vars
+	}
+	if query := r.URL.Query(); len(query) > 0 {
+		q := make(map[string]any, len(query))
+		for k, v := range query {
+			if len(v) == 1 {
+				q[k] = v[0]
+			} else {
+				q[k] = v
+			}
+		}
+		params["query"] = redactAudit(q)
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") && r.Header.Get("Content-Type") != "" {
+			if r.ContentLength > 0 {
+				params["bodySize"] = r.ContentLength
+			}
+		} else {
+			buf, err := io.ReadAll(io.LimitReader(r.Body, auditMaxBody+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+			if err == nil && len(buf) > 0 {
+				var body any
+				if len(buf) <= auditMaxBody && json.Unmarshal(buf, &body) == nil {
+					params["body"] = redactAudit(body)
+				} else {
+					params["bodySize"] = max(r.ContentLength, int64(len(buf)))
+				}
+			}
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	return params
+}
+
+func redactAudit(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(val))
+		for k, item := range val {
+			if auditSensitiveKey.MatchString(k) {
+				m[k] = "******"
+			} else {
+				m[k] = redactAudit(item)
+			}
+		}
+		return m
+	case []any:
+		arr := make([]any, len(val))
+		for i, item := range val {
+			arr[i] = redactAudit(item)
+		}
+		return arr
+	case string:
+		if len(val) > auditMaxString {
+			return fmt.Sprintf("%s...(%d bytes)", val[:auditMaxString], len(val))
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditResponseWriter captures the status code and the beginning of the body to get the result code.
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	prefix []byte
+}
+
+func (w *auditResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if remain := 64 - len(w.prefix); remain > 0 {
+		w.prefix = append(w.prefix, b[:min(remain, len(b))]...)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *auditResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var page, pageSize int
+	var err error
+	if s := query.Get("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil {
+			WriteJson(w, model.NewErrorResult("param invalid: page", model.CodeInvalidParam))
+			return
+		}
+	}
+	if s := query.Get("pageSize"); s != "" {
+		if pageSize, err = strconv.Atoi(s); err != nil {
+			WriteJson(w, model.NewErrorResult("param invalid: pageSize", model.CodeInvalidParam))
+			return
+		}
+	}
+	WriteJson(w, model.NewOkResult(Downloader.GetAuditLog(page, pageSize)))
+}
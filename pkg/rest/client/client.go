@@ -0,0 +1,660 @@
+// Package client is a typed Go client for the Gopeed REST API.
+//
+// The routes and payloads are the same as the OpenAPI document served at /api/v1/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+)
+
+type Config struct {
+	// Network is the network of the server, tcp or unix, default is tcp
+	Network string
+	// Address is the server address, e.g. 127.0.0.1:9999, or the socket path for unix network
+	Address string
+	// ApiToken is sent as X-Api-Token header when it's not empty
+	ApiToken string
+	// SessionToken is a web login session token returned by Login, it's sent as a bearer token when it's not empty
+	SessionToken string
+	// TLSConfig enables https when it's not nil, e.g. set RootCAs to trust a self-signed server certificate
+	TLSConfig *tls.Config
+	// HTTPClient is used to send requests, if nil a client for Network and Address is created
+	HTTPClient *http.Client
+}
+
+type Client struct {
+	baseURL      string
+	apiToken     string
+	sessionToken string
+	httpClient   *http.Client
+}
+
+// Error is returned when the server responds with a non-zero model.Result code.
+type Error struct {
+	Code model.RespCode
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gopeed api error: code=%d, msg=%s", e.Code, e.Msg)
+}
+
+func New(cfg *Config) *Client {
+	network := cfg.Network
+	if network == "" {
+		network = "tcp"
+	}
+	c := &Client{
+		baseURL:      "http://" + cfg.Address,
+		apiToken:     cfg.ApiToken,
+		sessionToken: cfg.SessionToken,
+		httpClient:   cfg.HTTPClient,
+	}
+	if network == "unix" {
+		// host is ignored when dialing a unix socket
+		c.baseURL = "http://unix"
+	}
+	if cfg.TLSConfig != nil {
+		c.baseURL = "https" + strings.TrimPrefix(c.baseURL, "http")
+	}
+	if c.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg.TLSConfig
+		if network == "unix" {
+			transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", cfg.Address)
+			}
+		}
+		c.httpClient = &http.Client{Transport: transport}
+	}
+	return c
+}
+
+// TaskFilter is the query filter of batch task operations, an empty filter matches all tasks.
+type TaskFilter struct {
+	IDs         []string
+	Statuses    []base.Status
+	NotStatuses []base.Status
+}
+
+func (f *TaskFilter) query() url.Values {
+	q := url.Values{}
+	if f == nil {
+		return q
+	}
+	for _, id := range f.IDs {
+		q.Add("id", id)
+	}
+	for _, status := range f.Statuses {
+		q.Add("status", string(status))
+	}
+	for _, status := range f.NotStatuses {
+		q.Add("notStatus", string(status))
+	}
+	return q
+}
+
+func (c *Client) Info(ctx context.Context) (map[string]any, error) {
+	return do[map[string]any](ctx, c, http.MethodGet, "/api/v1/info", nil, nil)
+}
+
+// OpenAPI returns the raw OpenAPI document of the server.
+func (c *Client) OpenAPI(ctx context.Context) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/openapi.json", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) Resolve(ctx context.Context, req *base.Request, opts *base.Options) (*model.ResolveResult, error) {
+	return do[*model.ResolveResult](ctx, c, http.MethodPost, "/api/v1/resolve", nil, &model.ResolveTask{
+		Req:  req,
+		Opts: opts,
+	})
+}
+
+// CreateTask creates a task from the resolved id returned by Resolve.
+func (c *Client) CreateTask(ctx context.Context, rid string) (string, error) {
+	return do[string](ctx, c, http.MethodPost, "/api/v1/tasks", nil, &model.CreateTask{
+		Rid: rid,
+	})
+}
+
+// CreateDirectTask creates a task without resolving it first.
+func (c *Client) CreateDirectTask(ctx context.Context, req *base.Request, opts *base.Options) (string, error) {
+	return do[string](ctx, c, http.MethodPost, "/api/v1/tasks", nil, &model.CreateTask{
+		Req:  req,
+		Opts: opts,
+	})
+}
+
+func (c *Client) CreateTaskBatch(ctx context.Context, batch *base.CreateTaskBatch) ([]string, error) {
+	return do[[]string](ctx, c, http.MethodPost, "/api/v1/tasks/batch", nil, batch)
+}
+
+func (c *Client) PatchTask(ctx context.Context, id string, req *base.Request, opts *base.Options) error {
+	_, err := do[any](ctx, c, http.MethodPatch, "/api/v1/tasks/"+url.PathEscape(id), nil, &model.ResolveTask{
+		Req:  req,
+		Opts: opts,
+	})
+	return err
+}
+
+func (c *Client) PauseTask(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/"+url.PathEscape(id)+"/pause", nil, nil)
+	return err
+}
+
+func (c *Client) PauseTasks(ctx context.Context, filter *TaskFilter) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/pause", filter.query(), nil)
+	return err
+}
+
+func (c *Client) ContinueTask(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/"+url.PathEscape(id)+"/continue", nil, nil)
+	return err
+}
+
+func (c *Client) ContinueTasks(ctx context.Context, filter *TaskFilter) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/continue", filter.query(), nil)
+	return err
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id string, force bool) error {
+	q := url.Values{}
+	q.Set("force", fmt.Sprint(force))
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/tasks/"+url.PathEscape(id), q, nil)
+	return err
+}
+
+func (c *Client) DeleteTasks(ctx context.Context, filter *TaskFilter, force bool) error {
+	q := filter.query()
+	q.Set("force", fmt.Sprint(force))
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/tasks", q, nil)
+	return err
+}
+
+func (c *Client) GetTask(ctx context.Context, id string) (*model.Task, error) {
+	return do[*model.Task](ctx, c, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) GetTasks(ctx context.Context, filter *TaskFilter) ([]*model.Task, error) {
+	return do[[]*model.Task](ctx, c, http.MethodGet, "/api/v1/tasks", filter.query(), nil)
+}
+
+// GetTaskStats returns the protocol specific statistics of a task, decoded into a generic JSON value.
+func (c *Client) GetTaskStats(ctx context.Context, id string) (any, error) {
+	return do[any](ctx, c, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/stats", nil, nil)
+}
+
+func (c *Client) GetConfig(ctx context.Context) (*base.DownloaderStoreConfig, error) {
+	return do[*base.DownloaderStoreConfig](ctx, c, http.MethodGet, "/api/v1/config", nil, nil)
+}
+
+func (c *Client) PutConfig(ctx context.Context, cfg *base.DownloaderStoreConfig) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/config", nil, cfg)
+	return err
+}
+
+// InstallExtension installs an extension and returns its identity.
+func (c *Client) InstallExtension(ctx context.Context, req *model.InstallExtension) (string, error) {
+	return do[string](ctx, c, http.MethodPost, "/api/v1/extensions", nil, req)
+}
+
+// InstallExtensionArchive uploads a .zip or .tgz archive to install the extension in it and returns its identity.
+func (c *Client) InstallExtensionArchive(ctx context.Context, archive io.Reader) (string, error) {
+	return do[string](ctx, c, http.MethodPost, "/api/v1/extensions/archive", nil, archive)
+}
+
+// PreviewExtension returns the extension of an install request with its requested permissions without installing it.
+func (c *Client) PreviewExtension(ctx context.Context, req *model.InstallExtension) (*model.Extension, error) {
+	return do[*model.Extension](ctx, c, http.MethodPost, "/api/v1/extensions/preview", nil, req)
+}
+
+// PreviewExtensionArchive uploads a .zip or .tgz archive and returns the extension in it with its requested
+// permissions without installing it.
+func (c *Client) PreviewExtensionArchive(ctx context.Context, archive io.Reader) (*model.Extension, error) {
+	return do[*model.Extension](ctx, c, http.MethodPost, "/api/v1/extensions/archive/preview", nil, archive)
+}
+
+// GetExtensionIndex returns the extension index configured on the server.
+func (c *Client) GetExtensionIndex(ctx context.Context) (*base.ExtensionIndex, error) {
+	return do[*base.ExtensionIndex](ctx, c, http.MethodGet, "/api/v1/extensions/index", nil, nil)
+}
+
+func (c *Client) GetExtensions(ctx context.Context) ([]*model.Extension, error) {
+	return do[[]*model.Extension](ctx, c, http.MethodGet, "/api/v1/extensions", nil, nil)
+}
+
+func (c *Client) GetExtension(ctx context.Context, identity string) (*model.Extension, error) {
+	return do[*model.Extension](ctx, c, http.MethodGet, "/api/v1/extensions/"+url.PathEscape(identity), nil, nil)
+}
+
+func (c *Client) UpdateExtensionSettings(ctx context.Context, identity string, settings map[string]any) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/extensions/"+url.PathEscape(identity)+"/settings", nil, &model.UpdateExtensionSettings{
+		Settings: settings,
+	})
+	return err
+}
+
+func (c *Client) SwitchExtension(ctx context.Context, identity string, status bool) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/extensions/"+url.PathEscape(identity)+"/switch", nil, &model.SwitchExtension{
+		Status: status,
+	})
+	return err
+}
+
+// UpdateExtensionLimits overrides the execution limits of an extension, zero limits mean using the global limits.
+func (c *Client) UpdateExtensionLimits(ctx context.Context, identity string, limits *base.ExtensionLimits) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/extensions/"+url.PathEscape(identity)+"/limits", nil, limits)
+	return err
+}
+
+func (c *Client) DeleteExtension(ctx context.Context, identity string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/extensions/"+url.PathEscape(identity), nil, nil)
+	return err
+}
+
+// UpdateCheckExtension returns the new version of the extension, empty means it's up to date.
+func (c *Client) UpdateCheckExtension(ctx context.Context, identity string) (string, error) {
+	resp, err := do[*model.UpdateCheckExtensionResp](ctx, c, http.MethodGet, "/api/v1/extensions/"+url.PathEscape(identity)+"/update", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return resp.NewVersion, nil
+}
+
+func (c *Client) UpdateExtension(ctx context.Context, identity string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/extensions/"+url.PathEscape(identity)+"/update", nil, nil)
+	return err
+}
+
+func (c *Client) TestWebhook(ctx context.Context, webhookURL string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/webhook/test", nil, &model.TestWebhookReq{
+		URL: webhookURL,
+	})
+	return err
+}
+
+// Principal returns the principal authenticated by the client credentials.
+func (c *Client) Principal(ctx context.Context) (*base.Principal, error) {
+	return do[*base.Principal](ctx, c, http.MethodGet, "/api/v1/access/me", nil, nil)
+}
+
+// Login logs in as a web user and returns the session token, use it as Config.SessionToken of a new client.
+func (c *Client) Login(ctx context.Context, username string, password string) (string, error) {
+	return do[string](ctx, c, http.MethodPost, "/api/web/login", nil, &model.Login{
+		Username: username,
+		Password: password,
+	})
+}
+
+// Logout revokes the session of Config.SessionToken.
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/web/logout", nil, nil)
+	return err
+}
+
+// Proxy sends a request to the target url through the server, the response of the target is returned as it is.
+// The caller must close the response body.
+func (c *Client) Proxy(ctx context.Context, method string, targetURL string, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, "/api/v1/proxy", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Target-Uri", targetURL)
+	return c.httpClient.Do(req)
+}
+
+// CreateAccessToken creates a scoped token, the plain token is only returned once.
+func (c *Client) CreateAccessToken(ctx context.Context, req *model.CreateAccessToken) (*model.CreateAccessTokenResp, error) {
+	return do[*model.CreateAccessTokenResp](ctx, c, http.MethodPost, "/api/v1/access/tokens", nil, req)
+}
+
+func (c *Client) GetAccessTokens(ctx context.Context) ([]*base.AccessToken, error) {
+	return do[[]*base.AccessToken](ctx, c, http.MethodGet, "/api/v1/access/tokens", nil, nil)
+}
+
+func (c *Client) RevokeAccessToken(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/access/tokens/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+func (c *Client) PutAccessUser(ctx context.Context, user *base.AccessUser) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/access/users", nil, user)
+	return err
+}
+
+func (c *Client) GetAccessUsers(ctx context.Context) ([]*base.AccessUser, error) {
+	return do[[]*base.AccessUser](ctx, c, http.MethodGet, "/api/v1/access/users", nil, nil)
+}
+
+func (c *Client) DeleteAccessUser(ctx context.Context, username string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/access/users/"+url.PathEscape(username), nil, nil)
+	return err
+}
+
+// GetSessions returns the web login sessions.
+func (c *Client) GetSessions(ctx context.Context) ([]*model.Session, error) {
+	return do[[]*model.Session](ctx, c, http.MethodGet, "/api/v1/access/sessions", nil, nil)
+}
+
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/access/sessions/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// GetAuditLog returns a page of the audit log from newest to oldest, page starts from 1, zero values use the server defaults.
+func (c *Client) GetAuditLog(ctx context.Context, page int, pageSize int) (*base.AuditPage, error) {
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+	return do[*base.AuditPage](ctx, c, http.MethodGet, "/api/v1/audit", query, nil)
+}
+
+// OpenTaskFile opens a file of a done task from the offset, the caller must close the returned reader.
+func (c *Client) OpenTaskFile(ctx context.Context, id string, index int, offset int64) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/files/"+strconv.Itoa(index), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return c.stream(req, http.StatusOK, http.StatusPartialContent)
+}
+
+// StreamTaskFile opens a file of a running task from the offset while it's downloading, reads block until
+// the data arrives. The caller must close the returned reader.
+func (c *Client) StreamTaskFile(ctx context.Context, id string, index int, offset int64) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/files/"+strconv.Itoa(index)+"/stream", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	return c.stream(req, http.StatusOK, http.StatusPartialContent)
+}
+
+// OpenTaskZip opens the selected files of a done task as a zip archive, the caller must close the returned reader.
+func (c *Client) OpenTaskZip(ctx context.Context, id string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/zip", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.stream(req, http.StatusOK)
+}
+
+// CreateTorrent creates a torrent from a local file or folder on the server, and optionally seeds it.
+func (c *Client) CreateTorrent(ctx context.Context, opts *bt.CreateTorrentOpts) (*bt.CreateTorrentResult, error) {
+	return do[*bt.CreateTorrentResult](ctx, c, http.MethodPost, "/api/v1/torrents", nil, opts)
+}
+
+// ExportTorrent exports the metadata of a bt task or a resolved bt request by the task id or the resolve id.
+func (c *Client) ExportTorrent(ctx context.Context, id string) (*bt.TorrentMetadata, error) {
+	return do[*bt.TorrentMetadata](ctx, c, http.MethodGet, "/api/v1/torrents/"+url.PathEscape(id), nil, nil)
+}
+
+// GetEd2kServers returns the known ed2k servers with the connection status and the last connect error.
+func (c *Client) GetEd2kServers(ctx context.Context) (*ed2k.ServerList, error) {
+	return do[*ed2k.ServerList](ctx, c, http.MethodGet, "/api/v1/ed2k/servers", nil, nil)
+}
+
+func (c *Client) ConnectEd2kServer(ctx context.Context, addr string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/ed2k/servers", nil, &model.Ed2kServer{Addr: addr})
+	return err
+}
+
+func (c *Client) DisconnectEd2kServer(ctx context.Context, addr string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/ed2k/servers", url.Values{"addr": {addr}}, nil)
+	return err
+}
+
+// RefreshEd2kServerMet reloads the server.met of the ed2k config and connects to the servers in it.
+func (c *Client) RefreshEd2kServerMet(ctx context.Context) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/ed2k/servers/refresh", nil, nil)
+	return err
+}
+
+func (c *Client) GetEd2kKad(ctx context.Context) (*ed2k.Kad, error) {
+	return do[*ed2k.Kad](ctx, c, http.MethodGet, "/api/v1/ed2k/kad", nil, nil)
+}
+
+// RefreshEd2kNodesDat reloads the nodes.dat of the ed2k config to bootstrap the KAD network.
+func (c *Client) RefreshEd2kNodesDat(ctx context.Context) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/ed2k/kad/refresh", nil, nil)
+	return err
+}
+
+// SearchEd2k starts an ed2k keyword search, the results are polled by GetEd2kSearch.
+func (c *Client) SearchEd2k(ctx context.Context, opts *ed2k.SearchOpts) (*ed2k.Search, error) {
+	return do[*ed2k.Search](ctx, c, http.MethodPost, "/api/v1/ed2k/searches", nil, opts)
+}
+
+func (c *Client) GetEd2kSearches(ctx context.Context) ([]*ed2k.Search, error) {
+	return do[[]*ed2k.Search](ctx, c, http.MethodGet, "/api/v1/ed2k/searches", nil, nil)
+}
+
+// GetEd2kSearch returns an ed2k search with the results found so far.
+func (c *Client) GetEd2kSearch(ctx context.Context, id string) (*ed2k.Search, error) {
+	return do[*ed2k.Search](ctx, c, http.MethodGet, "/api/v1/ed2k/searches/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) DeleteEd2kSearch(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/ed2k/searches/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// GetTaskSources returns the sources of a running ed2k task.
+func (c *Client) GetTaskSources(ctx context.Context, id string) ([]*ed2k.Source, error) {
+	return do[[]*ed2k.Source](ctx, c, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/sources", nil, nil)
+}
+
+// RecheckTask verifies the downloaded data of a task that is not running, the damaged data is downloaded again.
+func (c *Client) RecheckTask(ctx context.Context, id string) (*base.Recheck, error) {
+	return do[*base.Recheck](ctx, c, http.MethodPut, "/api/v1/tasks/"+url.PathEscape(id)+"/recheck", nil, nil)
+}
+
+// GetTaskPeers returns the connected peers of a running bt task.
+func (c *Client) GetTaskPeers(ctx context.Context, id string) ([]*bt.Peer, error) {
+	return do[[]*bt.Peer](ctx, c, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/peers", nil, nil)
+}
+
+// AddTaskPeer connects a running bt task to a peer by ip:port.
+func (c *Client) AddTaskPeer(ctx context.Context, id string, addr string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/tasks/"+url.PathEscape(id)+"/peers", nil, &model.TaskPeer{Addr: addr})
+	return err
+}
+
+// BanTaskPeer bans a peer by ip or ip:port for all bt tasks.
+func (c *Client) BanTaskPeer(ctx context.Context, id string, addr string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/tasks/"+url.PathEscape(id)+"/peers/ban", nil, &model.TaskPeer{Addr: addr})
+	return err
+}
+
+// GetTaskTrackers returns the trackers of a running bt task with the result of the last announce.
+func (c *Client) GetTaskTrackers(ctx context.Context, id string) ([]*bt.Tracker, error) {
+	return do[[]*bt.Tracker](ctx, c, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id)+"/trackers", nil, nil)
+}
+
+func (c *Client) AddTaskTrackers(ctx context.Context, id string, urls []string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/tasks/"+url.PathEscape(id)+"/trackers", nil, &model.TaskTrackers{URLs: urls})
+	return err
+}
+
+func (c *Client) RemoveTaskTrackers(ctx context.Context, id string, urls []string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/tasks/"+url.PathEscape(id)+"/trackers", url.Values{"url": urls}, nil)
+	return err
+}
+
+func (c *Client) ReannounceTask(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/"+url.PathEscape(id)+"/trackers/reannounce", nil, nil)
+	return err
+}
+
+// StopSeeding stops seeding a done bt task without deleting it.
+func (c *Client) StopSeeding(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodPut, "/api/v1/tasks/"+url.PathEscape(id)+"/seed/stop", nil, nil)
+	return err
+}
+
+// stream returns the response body when the status is expected, otherwise the error result is decoded.
+func (c *Client) stream(req *http.Request, statuses ...int) (io.ReadCloser, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return resp.Body, nil
+		}
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result model.Result[any]
+	if err := json.Unmarshal(buf, &result); err != nil || result.Code == model.CodeOk {
+		return nil, &Error{Code: model.CodeError, Msg: strings.TrimSpace(resp.Status + " " + string(buf))}
+	}
+	return nil, &Error{Code: result.Code, Msg: result.Msg}
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, body any) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, body any) (*http.Request, error) {
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		// an uploaded file is sent as it is
+		reader = b
+		contentType = "application/octet-stream"
+	default:
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.apiToken != "" {
+		req.Header.Set("X-Api-Token", c.apiToken)
+	}
+	if c.sessionToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.sessionToken)
+	}
+	return req, nil
+}
+
+// do sends the request and unwraps the model.Result envelope.
+func do[T any](ctx context.Context, c *Client, method string, path string, query url.Values, body any) (T, error) {
+	var zero T
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return zero, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return zero, err
+	}
+	var result model.Result[T]
+	if err := json.Unmarshal(buf, &result); err != nil {
+		// the server only writes plain text for internal errors, e.g. stats of a broken task
+		return zero, &Error{Code: model.CodeError, Msg: strings.TrimSpace(string(buf))}
+	}
+	if result.Code != model.CodeOk {
+		return zero, &Error{Code: result.Code, Msg: result.Msg}
+	}
+	return result.Data, nil
+}
+
+// CreateFeed subscribes a rss or atom feed, matched items are downloaded by the rules of the feed.
+func (c *Client) CreateFeed(ctx context.Context, feed *base.Feed) (*base.Feed, error) {
+	return do[*base.Feed](ctx, c, http.MethodPost, "/api/v1/feeds", nil, feed)
+}
+
+func (c *Client) GetFeeds(ctx context.Context) ([]*base.Feed, error) {
+	return do[[]*base.Feed](ctx, c, http.MethodGet, "/api/v1/feeds", nil, nil)
+}
+
+func (c *Client) GetFeed(ctx context.Context, id string) (*base.Feed, error) {
+	return do[*base.Feed](ctx, c, http.MethodGet, "/api/v1/feeds/"+url.PathEscape(id), nil, nil)
+}
+
+// UpdateFeed replaces the settings and the rules of a feed, the seen items are kept.
+func (c *Client) UpdateFeed(ctx context.Context, id string, feed *base.Feed) (*base.Feed, error) {
+	return do[*base.Feed](ctx, c, http.MethodPut, "/api/v1/feeds/"+url.PathEscape(id), nil, feed)
+}
+
+func (c *Client) DeleteFeed(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/feeds/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+func (c *Client) AddFeedRule(ctx context.Context, feedId string, rule *base.FeedRule) (*base.FeedRule, error) {
+	return do[*base.FeedRule](ctx, c, http.MethodPost, "/api/v1/feeds/"+url.PathEscape(feedId)+"/rules", nil, rule)
+}
+
+func (c *Client) UpdateFeedRule(ctx context.Context, feedId string, ruleId string, rule *base.FeedRule) (*base.FeedRule, error) {
+	return do[*base.FeedRule](ctx, c, http.MethodPut, "/api/v1/feeds/"+url.PathEscape(feedId)+"/rules/"+url.PathEscape(ruleId), nil, rule)
+}
+
+func (c *Client) DeleteFeedRule(ctx context.Context, feedId string, ruleId string) error {
+	_, err := do[any](ctx, c, http.MethodDelete, "/api/v1/feeds/"+url.PathEscape(feedId)+"/rules/"+url.PathEscape(ruleId), nil, nil)
+	return err
+}
+
+// RefreshFeed polls a feed now and downloads the new matched items.
+func (c *Client) RefreshFeed(ctx context.Context, id string) error {
+	_, err := do[any](ctx, c, http.MethodPost, "/api/v1/feeds/"+url.PathEscape(id)+"/refresh", nil, nil)
+	return err
+}
+
+// GetFeedItems returns the seen items of a feed from newest to oldest.
+func (c *Client) GetFeedItems(ctx context.Context, id string) ([]*base.FeedItem, error) {
+	return do[[]*base.FeedItem](ctx, c, http.MethodGet, "/api/v1/feeds/"+url.PathEscape(id)+"/items", nil, nil)
+}
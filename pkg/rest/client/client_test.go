@@ -0,0 +1,230 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/rest"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+)
+
+func TestClient(t *testing.T) {
+	doTest(t, "", func(c *Client) {
+		ctx := context.Background()
+
+		info, err := c.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info["version"] != base.Version {
+			t.Errorf("Info() got = %v, want %v", info["version"], base.Version)
+		}
+
+		cfg, err := c.GetConfig(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.MaxRunning = 3
+		if err := c.PutConfig(ctx, cfg); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err = c.GetConfig(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.MaxRunning != 3 {
+			t.Errorf("GetConfig() got = %v, want %v", cfg.MaxRunning, 3)
+		}
+
+		tasks, err := c.GetTasks(ctx, &TaskFilter{Statuses: []base.Status{base.DownloadStatusRunning}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != 0 {
+			t.Errorf("GetTasks() got = %v, want %v", len(tasks), 0)
+		}
+
+		_, err = c.GetTask(ctx, "not-exist")
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.Code != model.CodeTaskNotFound {
+			t.Errorf("GetTask() got = %v, want code %v", err, model.CodeTaskNotFound)
+		}
+
+		if _, err := c.OpenTaskFile(ctx, "not-exist", 0, 10); !errors.As(err, &apiErr) || apiErr.Code != model.CodeTaskNotFound {
+			t.Errorf("OpenTaskFile() got = %v, want code %v", err, model.CodeTaskNotFound)
+		}
+		if _, err := c.OpenTaskZip(ctx, "not-exist"); !errors.As(err, &apiErr) || apiErr.Code != model.CodeTaskNotFound {
+			t.Errorf("OpenTaskZip() got = %v, want code %v", err, model.CodeTaskNotFound)
+		}
+
+		buf, err := c.OpenAPI(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(buf, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["openapi"] == nil {
+			t.Errorf("OpenAPI() got = %s, want openapi document", buf)
+		}
+
+		sessions, err := c.GetSessions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("GetSessions() got = %v, want empty", sessions)
+		}
+		if err := c.RevokeSession(ctx, "not-exist"); err == nil {
+			t.Errorf("RevokeSession() unknown session should fail")
+		}
+
+		audit, err := c.GetAuditLog(ctx, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if audit.Total != 2 || audit.Entries[0].Route != "/api/v1/access/sessions/{id}" || audit.Entries[1].Route != "/api/v1/config" {
+			t.Errorf("GetAuditLog() got = %+v, want the revoke session and put config entries", audit)
+		}
+	})
+}
+
+func TestClient_ExtensionArchive(t *testing.T) {
+	doTest(t, "", func(c *Client) {
+		ctx := context.Background()
+
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		files := map[string]string{
+			"manifest.json": `{"name": "archive", "author": "gopeed", "title": "Archive", "version": "1.0.0"}`,
+			"index.js":      ``,
+		}
+		for name, content := range files {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		identity, err := c.InstallExtensionArchive(ctx, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ext, err := c.GetExtension(ctx, identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ext.Identity != "gopeed@archive" || ext.Version != "1.0.0" {
+			t.Errorf("InstallExtensionArchive() got = %s %s", ext.Identity, ext.Version)
+		}
+
+		if _, err := c.GetExtensionIndex(ctx); err == nil {
+			t.Errorf("GetExtensionIndex() not configured should fail")
+		}
+	})
+}
+
+func TestClient_ApiToken(t *testing.T) {
+	doTest(t, "123456", func(c *Client) {
+		ctx := context.Background()
+		if _, err := c.Info(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := c.CreateAccessToken(ctx, &model.CreateAccessToken{
+			Name:   "reader",
+			Scopes: []base.AccessScope{base.AccessScopeRead},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := New(&Config{Address: c.baseURL[len("http://"):], ApiToken: resp.Token})
+		principal, err := reader.Principal(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if principal.Name != "reader" {
+			t.Errorf("Principal() got = %v, want %v", principal.Name, "reader")
+		}
+		_, err = reader.GetConfig(ctx)
+		var forbiddenErr *Error
+		if !errors.As(err, &forbiddenErr) || forbiddenErr.Code != model.CodeForbidden {
+			t.Errorf("GetConfig() got = %v, want code %v", err, model.CodeForbidden)
+		}
+
+		c.apiToken = "wrong"
+		_, err = c.Info(ctx)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.Code != model.CodeUnauthorized {
+			t.Errorf("Info() got = %v, want code %v", err, model.CodeUnauthorized)
+		}
+	})
+}
+
+func TestClient_TLS(t *testing.T) {
+	storageDir := t.TempDir()
+	port, err := rest.Start(&model.StartConfig{
+		Storage:    model.StorageMem,
+		StorageDir: storageDir,
+		TLS:        &model.TLSConfig{AutoCert: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Stop()
+
+	certPem, err := os.ReadFile(filepath.Join(storageDir, "tls", "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPem)
+	c := New(&Config{
+		Address:   fmt.Sprintf("127.0.0.1:%d", port),
+		TLSConfig: &tls.Config{RootCAs: pool},
+	})
+	if _, err := c.Info(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func doTest(t *testing.T, apiToken string, handler func(c *Client)) {
+	storageDir, err := os.MkdirTemp("", "gopeed-client-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storageDir)
+
+	cfg := &model.StartConfig{
+		Storage:    model.StorageMem,
+		StorageDir: storageDir,
+		ApiToken:   apiToken,
+	}
+	port, err := rest.Start(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Stop()
+
+	handler(New(&Config{
+		Address:  fmt.Sprintf("127.0.0.1:%d", port),
+		ApiToken: apiToken,
+	}))
+}
@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	ed2kf "github.com/GopeedLab/gopeed/internal/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/download"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+// GetEd2kServers returns the known ed2k servers with the connection status and the last connect error.
+func GetEd2kServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := Downloader.GetEd2kServers()
+	if err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(servers))
+}
+
+func ConnectEd2kServer(w http.ResponseWriter, r *http.Request) {
+	var req model.Ed2kServer
+	if ReadJson(r, w, &req) {
+		if err := Downloader.ConnectEd2kServer(req.Addr); err != nil {
+			writeEd2kError(w, err)
+			return
+		}
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
+func DisconnectEd2kServer(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.DisconnectEd2kServer(r.URL.Query().Get("addr")); err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// RefreshEd2kServerMet reloads the server.met of the ed2k config and connects to the servers in it.
+func RefreshEd2kServerMet(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.RefreshEd2kServerMet(); err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func GetEd2kKad(w http.ResponseWriter, r *http.Request) {
+	kad, err := Downloader.GetEd2kKad()
+	if err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(kad))
+}
+
+// RefreshEd2kNodesDat reloads the nodes.dat of the ed2k config to bootstrap the KAD network.
+func RefreshEd2kNodesDat(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.RefreshEd2kNodesDat(); err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// SearchEd2k starts an ed2k keyword search, the results are polled by the returned search id.
+func SearchEd2k(w http.ResponseWriter, r *http.Request) {
+	var req ed2k.SearchOpts
+	if ReadJson(r, w, &req) {
+		search, err := Downloader.SearchEd2k(&req)
+		if err != nil {
+			writeEd2kError(w, err)
+			return
+		}
+		WriteJson(w, model.NewOkResult(search))
+	}
+}
+
+func GetEd2kSearches(w http.ResponseWriter, r *http.Request) {
+	searches, err := Downloader.GetEd2kSearches()
+	if err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(searches))
+}
+
+func GetEd2kSearch(w http.ResponseWriter, r *http.Request) {
+	search, err := Downloader.GetEd2kSearch(mux.Vars(r)["id"])
+	if err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(search))
+}
+
+func DeleteEd2kSearch(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.DeleteEd2kSearch(mux.Vars(r)["id"]); err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// GetTaskSources returns the sources of a running ed2k task.
+func GetTaskSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := Downloader.GetTaskSources(mux.Vars(r)["id"])
+	if err != nil {
+		writeEd2kError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(sources))
+}
+
+func writeEd2kError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, download.ErrTaskNotFound):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeTaskNotFound))
+	case errors.Is(err, ed2kf.ErrInvalidServerAddr), errors.Is(err, ed2kf.ErrServerNotFound),
+		errors.Is(err, ed2kf.ErrSearchQueryRequired), errors.Is(err, ed2kf.ErrInvalidSearchScope):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeInvalidParam))
+	case errors.Is(err, ed2kf.ErrSearchNotFound):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeEd2kSearchNotFound))
+	default:
+		WriteJson(w, model.NewErrorResult(err.Error()))
+	}
+}
@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/download"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+func CreateFeed(w http.ResponseWriter, r *http.Request) {
+	var req base.Feed
+	if ReadJson(r, w, &req) {
+		if !checkFeedRuleDirs(w, r, req.Rules...) {
+			return
+		}
+		feed, err := Downloader.CreateFeed(&req)
+		if err != nil {
+			writeFeedError(w, err)
+			return
+		}
+		WriteJson(w, model.NewOkResult(feed))
+	}
+}
+
+func GetFeeds(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, model.NewOkResult(Downloader.GetFeeds()))
+}
+
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := Downloader.GetFeed(mux.Vars(r)["id"])
+	if err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(feed))
+}
+
+// UpdateFeed replaces the settings and the rules of a feed.
+func UpdateFeed(w http.ResponseWriter, r *http.Request) {
+	var req base.Feed
+	if ReadJson(r, w, &req) {
+		if !checkFeedRuleDirs(w, r, req.Rules...) {
+			return
+		}
+		feed, err := Downloader.UpdateFeed(mux.Vars(r)["id"], &req)
+		if err != nil {
+			writeFeedError(w, err)
+			return
+		}
+		WriteJson(w, model.NewOkResult(feed))
+	}
+}
+
+func DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.DeleteFeed(mux.Vars(r)["id"]); err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func AddFeedRule(w http.ResponseWriter, r *http.Request) {
+	var req base.FeedRule
+	if ReadJson(r, w, &req) {
+		if !checkFeedRuleDirs(w, r, &req) {
+			return
+		}
+		rule, err := Downloader.AddFeedRule(mux.Vars(r)["id"], &req)
+		if err != nil {
+			writeFeedError(w, err)
+			return
+		}
+		WriteJson(w, model.NewOkResult(rule))
+	}
+}
+
+func UpdateFeedRule(w http.ResponseWriter, r *http.Request) {
+	var req base.FeedRule
+	if ReadJson(r, w, &req) {
+		if !checkFeedRuleDirs(w, r, &req) {
+			return
+		}
+		vars := mux.Vars(r)
+		rule, err := Downloader.UpdateFeedRule(vars["id"], vars["ruleId"], &req)
+		if err != nil {
+			writeFeedError(w, err)
+			return
+		}
+		WriteJson(w, model.NewOkResult(rule))
+	}
+}
+
+func DeleteFeedRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := Downloader.DeleteFeedRule(vars["id"], vars["ruleId"]); err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// RefreshFeed polls a feed now and downloads the new matched items.
+func RefreshFeed(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.RefreshFeed(mux.Vars(r)["id"]); err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func GetFeedItems(w http.ResponseWriter, r *http.Request) {
+	items, err := Downloader.GetFeedItems(mux.Vars(r)["id"])
+	if err != nil {
+		writeFeedError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(items))
+}
+
+// checkFeedRuleDirs checks the download directories of the rules, since the tasks are created on behalf of the principal.
+func checkFeedRuleDirs(w http.ResponseWriter, r *http.Request, rules ...*base.FeedRule) bool {
+	opts := make([]*base.Options, 0, len(rules))
+	for _, rule := range rules {
+		if rule != nil {
+			opts = append(opts, &base.Options{Path: rule.Path})
+		}
+	}
+	return checkDownloadDir(w, r, opts...)
+}
+
+func writeFeedError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, download.ErrFeedNotFound), errors.Is(err, download.ErrFeedRuleNotFound):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeFeedNotFound))
+	case errors.Is(err, download.ErrFeedInvalid), errors.Is(err, download.ErrFeedRuleInvalid):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeInvalidParam))
+	default:
+		WriteJson(w, model.NewErrorResult(err.Error()))
+	}
+}
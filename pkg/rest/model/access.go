@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
+type CreateAccessToken struct {
+	Name         string             `json:"name"`
+	Scopes       []base.AccessScope `json:"scopes"`
+	DownloadDirs []string           `json:"downloadDirs"`
+}
+
+type CreateAccessTokenResp struct {
+	// Token is the plain token, it's only returned once when the token is created
+	Token string            `json:"token"`
+	Info  *base.AccessToken `json:"info"`
+}
+
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Session is a web login session, the session token is never returned after login.
+type Session struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Kind       base.PrincipalKind `json:"kind"`
+	IP         string             `json:"ip"`
+	UserAgent  string             `json:"userAgent"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	LastSeenAt time.Time          `json:"lastSeenAt"`
+	ExpiresAt  time.Time          `json:"expiresAt"`
+	// Current is true for the session of the request
+	Current bool `json:"current"`
+}
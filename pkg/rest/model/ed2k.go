@@ -0,0 +1,6 @@
+package model
+
+type Ed2kServer struct {
+	// Addr is host:port of the server
+	Addr string `json:"addr"`
+}
@@ -1,8 +1,19 @@
 package model
 
+import (
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
+
 type InstallExtension struct {
-	DevMode bool   `json:"devMode"`
-	URL     string `json:"url"`
+	DevMode bool `json:"devMode"`
+	// URL is the git repository, or the url of a .zip or .tgz archive, or the local folder in dev mode
+	URL string `json:"url"`
+	// Checksum is the checksum of the archive url in the format of algorithm:hex, the algorithm is sha256 or sha512
+	Checksum string `json:"checksum"`
+	// Identity installs the latest version of the extension in the extension index instead of the url
+	Identity string `json:"identity"`
 }
 
 type UpdateExtensionSettings struct {
@@ -16,3 +27,77 @@ type SwitchExtension struct {
 type UpdateCheckExtensionResp struct {
 	NewVersion string `json:"newVersion"`
 }
+
+// Extension is an installed extension in the api responses.
+type Extension struct {
+	Identity    string                `json:"identity"`
+	Name        string                `json:"name"`
+	Author      string                `json:"author"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Icon        string                `json:"icon"`
+	Version     string                `json:"version"`
+	Homepage    string                `json:"homepage"`
+	Repository  *ExtensionRepository  `json:"repository"`
+	Scripts     []*ExtensionScript    `json:"scripts"`
+	Protocols   []*ExtensionProtocol  `json:"protocols"`
+	Settings    []*ExtensionSetting   `json:"settings"`
+	Permissions *ExtensionPermissions `json:"permissions"`
+	Disabled    bool                  `json:"disabled"`
+	Limits      *base.ExtensionLimits `json:"limits"`
+	// Signer is the trusted publisher key that signed the package, empty means it's not verified
+	Signer string            `json:"signer"`
+	Files  map[string]string `json:"files"`
+	// IntegrityError is why the extension is blocked at load time, e.g. its files are tampered
+	IntegrityError string `json:"integrityError"`
+
+	DevMode bool   `json:"devMode"`
+	DevPath string `json:"devPath"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ExtensionRepository struct {
+	Url       string `json:"url"`
+	Directory string `json:"directory"`
+}
+
+type ExtensionScript struct {
+	Event string          `json:"event"`
+	Match *ExtensionMatch `json:"match"`
+	Entry string          `json:"entry"`
+}
+
+type ExtensionMatch struct {
+	Urls   []string `json:"urls"`
+	Labels []string `json:"labels"`
+}
+
+type ExtensionProtocol struct {
+	Scheme string `json:"scheme"`
+	Entry  string `json:"entry"`
+}
+
+type ExtensionSetting struct {
+	Name        string                    `json:"name"`
+	Title       string                    `json:"title"`
+	Description string                    `json:"description"`
+	Required    bool                      `json:"required"`
+	Type        string                    `json:"type"`
+	Value       any                       `json:"value"`
+	Options     []*ExtensionSettingOption `json:"options"`
+}
+
+type ExtensionSettingOption struct {
+	Label string `json:"label"`
+	Value any    `json:"value"`
+}
+
+type ExtensionPermissions struct {
+	Hosts   []string `json:"hosts"`
+	WebView bool     `json:"webview"`
+	Blob    bool     `json:"blob"`
+	Storage bool     `json:"storage"`
+	Task    bool     `json:"task"`
+}
@@ -10,8 +10,22 @@ const (
 	CodeUnauthorized RespCode = 1001
 	// CodeInvalidParam is the error code for invalid parameter
 	CodeInvalidParam RespCode = 1002
+	// CodeForbidden is the error code for an authenticated request without the required scope
+	CodeForbidden RespCode = 1003
+	// CodeTooManyRequests is the error code for a login locked by too many failed attempts
+	CodeTooManyRequests RespCode = 1004
 	// CodeTaskNotFound is the error code for task not found
 	CodeTaskNotFound RespCode = 2001
+	// CodeTaskNotDone is the error code for reading the files of a task that is not done
+	CodeTaskNotDone RespCode = 2002
+	// CodeTaskFileNotFound is the error code for a task file that is not selected or doesn't exist
+	CodeTaskFileNotFound RespCode = 2003
+	// CodeFeedNotFound is the error code for a feed or a feed rule not found
+	CodeFeedNotFound RespCode = 3001
+	// CodeEd2kSearchNotFound is the error code for an ed2k search not found or expired
+	CodeEd2kSearchNotFound RespCode = 3002
+	// CodeExtensionError is the error code for an extension hook that failed to finish, e.g. it timed out
+	CodeExtensionError RespCode = 4001
 )
 
 type Result[T any] struct {
@@ -4,6 +4,7 @@ import (
 	"github.com/GopeedLab/gopeed/pkg/base"
 	enginewebview "github.com/GopeedLab/gopeed/pkg/download/engine/webview"
 	"io/fs"
+	"time"
 )
 
 type Storage string
@@ -23,6 +24,7 @@ type StartConfig struct {
 	ApiToken          string                      `json:"apiToken"`
 	DownloadConfig    *base.DownloaderStoreConfig `json:"downloadConfig"`
 	WebViewRPCConfig  *enginewebview.RPCConfig    `json:"webViewRpcConfig,omitempty"`
+	TLS               *TLSConfig                  `json:"tls,omitempty"`
 
 	ProductionMode  bool
 	WebViewProvider enginewebview.Provider `json:"-"`
@@ -48,10 +50,48 @@ func (cfg *StartConfig) Init() *StartConfig {
 	if cfg.StorageDir == "" {
 		cfg.StorageDir = "./"
 	}
+	if cfg.WebAuth != nil {
+		cfg.WebAuth.Init()
+	}
 	return cfg
 }
 
 type WebAuth struct {
 	Username string
+	// Password is the plain text password or a bcrypt hash
 	Password string
+	// SessionLifetime is how long a login session is valid, default is 7 days
+	SessionLifetime time.Duration
+	// MaxLoginAttempts is the max failed logins per IP and per username within LoginLockout, default is 5, negative disables the limit
+	MaxLoginAttempts int
+	// LoginLockout is how long the failed logins are counted and the login is locked after too many failures, default is 15 minutes
+	LoginLockout time.Duration
+}
+
+func (a *WebAuth) Init() *WebAuth {
+	if a.SessionLifetime <= 0 {
+		a.SessionLifetime = 7 * 24 * time.Hour
+	}
+	if a.MaxLoginAttempts == 0 {
+		a.MaxLoginAttempts = 5
+	}
+	if a.LoginLockout <= 0 {
+		a.LoginLockout = 15 * time.Minute
+	}
+	return a
+}
+
+type TLSConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// AutoCert generates a self-signed certificate in the storage directory on first boot when CertFile and KeyFile are empty
+	AutoCert bool `json:"autoCert"`
+	// ClientCAFile enables mTLS, clients must present a certificate signed by one of the CAs in this PEM file
+	ClientCAFile string `json:"clientCaFile"`
+}
+
+// Enabled returns true if any tls option is set, a cert file without a key file or vice versa, and a client ca
+// without a certificate are rejected at startup.
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && (c.CertFile != "" || c.KeyFile != "" || c.AutoCert || c.ClientCAFile != "")
 }
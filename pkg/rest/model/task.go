@@ -1,6 +1,10 @@
 package model
 
-import "github.com/GopeedLab/gopeed/pkg/base"
+import (
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+)
 
 type ResolveTask struct {
 	Req  *base.Request `json:"req"`
@@ -13,3 +17,53 @@ type CreateTask struct {
 	Req  *base.Request `json:"req"`
 	Opts *base.Options `json:"opts"`
 }
+
+type TaskPeer struct {
+	// Addr is ip:port of the peer, or only the ip when banning
+	Addr string `json:"addr"`
+}
+
+type TaskTrackers struct {
+	URLs []string `json:"urls"`
+}
+
+// ResolveResult is the resolved resource of a download request, the id is used to create the task.
+type ResolveResult struct {
+	ID  string         `json:"id"`
+	Res *base.Resource `json:"res"`
+}
+
+// Task is a download task in the api responses.
+type Task struct {
+	ID        string        `json:"id"`
+	Protocol  string        `json:"protocol"`
+	Meta      *TaskMeta     `json:"meta"`
+	Status    base.Status   `json:"status"`
+	Uploading bool          `json:"uploading"`
+	Progress  *TaskProgress `json:"progress"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+type TaskMeta struct {
+	Req  *base.Request  `json:"req"`
+	Res  *base.Resource `json:"res"`
+	Opts *base.Options  `json:"opts"`
+}
+
+type TaskProgress struct {
+	// Used is the total download time(ns)
+	Used int64 `json:"used"`
+	// Speed is the download speed(bytes/s)
+	Speed      int64 `json:"speed"`
+	Downloaded int64 `json:"downloaded"`
+	// UploadSpeed is the upload speed(bytes/s)
+	UploadSpeed int64 `json:"uploadSpeed"`
+	Uploaded    int64 `json:"uploaded"`
+	// ExtractStatus is the archive extraction status, empty means not started
+	ExtractStatus     string `json:"extractStatus"`
+	ExtractProgress   int    `json:"extractProgress"`
+	MultiPartBaseName string `json:"multiPartBaseName,omitempty"`
+	MultiPartNumber   int    `json:"multiPartNumber,omitempty"`
+	MultiPartIsFirst  bool   `json:"multiPartIsFirst,omitempty"`
+}
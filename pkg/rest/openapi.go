@@ -0,0 +1,488 @@
+package rest
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/download"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+const openAPIVersion = "3.0.3"
+
+// apiRoute describes a single REST route for the OpenAPI document and access control.
+// Routes are still registered on the mux router in BuildServer, the document is built by walking the
+// router and looking up the description by "METHOD path", so a route can never silently disappear from the spec.
+type apiRoute struct {
+	Summary string
+	Tag     string
+	// Scope is the access scope required by the route, empty means any authenticated principal
+	Scope base.AccessScope
+	Query []*apiParam
+	// Body is a zero value of the request body type, nil means no request body
+	Body any
+	// Upload means the request body is the raw content of an uploaded file
+	Upload bool
+	// Data is a zero value of the model.Result data type, nil means the data is always null
+	Data any
+	// Raw means the response is not wrapped by model.Result
+	Raw bool
+	// NoAudit excludes a non-GET route that doesn't change any state from the audit log
+	NoAudit bool
+}
+
+type apiParam struct {
+	Name        string
+	Description string
+	Array       bool
+	Type        string
+}
+
+var taskFilterParams = []*apiParam{
+	{Name: "id", Description: "Filter by task id", Array: true, Type: "string"},
+	{Name: "status", Description: "Filter by task status", Array: true, Type: "string"},
+	{Name: "notStatus", Description: "Exclude tasks by status", Array: true, Type: "string"},
+}
+
+var auditQueryParams = []*apiParam{
+	{Name: "page", Description: "Page number, starts from 1", Type: "integer"},
+	{Name: "pageSize", Description: "Page size, default 20", Type: "integer"},
+}
+
+var inlineParam = &apiParam{Name: "inline", Description: "Serve the file inline instead of as an attachment", Type: "boolean"}
+
+var trackerUrlParam = &apiParam{Name: "url", Description: "Tracker url to remove", Array: true, Type: "string"}
+
+var ed2kServerParam = &apiParam{Name: "addr", Description: "Server address to disconnect, host:port", Type: "string"}
+
+var forceParam = &apiParam{Name: "force", Description: "Also delete the downloaded files", Type: "boolean"}
+
+var apiRoutes = map[string]*apiRoute{
+	"GET /api/v1/openapi.json":                    {Summary: "Get the OpenAPI document of this server", Tag: "system", Raw: true},
+	"GET /api/v1/info":                            {Summary: "Get server info", Scope: base.AccessScopeRead, Tag: "system", Data: map[string]any{}},
+	"POST /api/v1/resolve":                        {Summary: "Resolve a download request", Scope: base.AccessScopeCreate, Tag: "task", Body: model.ResolveTask{}, Data: download.ResolveResult{}, NoAudit: true},
+	"POST /api/v1/tasks":                          {Summary: "Create a task from a resolved id or a request", Scope: base.AccessScopeCreate, Tag: "task", Body: model.CreateTask{}, Data: ""},
+	"POST /api/v1/tasks/batch":                    {Summary: "Create tasks in batch", Scope: base.AccessScopeCreate, Tag: "task", Body: base.CreateTaskBatch{}, Data: []string{}},
+	"PATCH /api/v1/tasks/{id}":                    {Summary: "Patch a task", Scope: base.AccessScopeManage, Tag: "task", Body: model.ResolveTask{}},
+	"PUT /api/v1/tasks/{id}/pause":                {Summary: "Pause a task", Scope: base.AccessScopeManage, Tag: "task"},
+	"PUT /api/v1/tasks/pause":                     {Summary: "Pause tasks by filter", Scope: base.AccessScopeManage, Tag: "task", Query: taskFilterParams},
+	"PUT /api/v1/tasks/{id}/continue":             {Summary: "Continue a task", Scope: base.AccessScopeManage, Tag: "task"},
+	"PUT /api/v1/tasks/continue":                  {Summary: "Continue tasks by filter", Scope: base.AccessScopeManage, Tag: "task", Query: taskFilterParams},
+	"DELETE /api/v1/tasks/{id}":                   {Summary: "Delete a task", Scope: base.AccessScopeManage, Tag: "task", Query: []*apiParam{forceParam}},
+	"DELETE /api/v1/tasks":                        {Summary: "Delete tasks by filter", Scope: base.AccessScopeManage, Tag: "task", Query: append(append([]*apiParam{}, taskFilterParams...), forceParam)},
+	"GET /api/v1/tasks/{id}":                      {Summary: "Get a task", Scope: base.AccessScopeRead, Tag: "task", Data: download.Task{}},
+	"GET /api/v1/tasks":                           {Summary: "Get tasks by filter", Scope: base.AccessScopeRead, Tag: "task", Query: taskFilterParams, Data: []*download.Task{}},
+	"GET /api/v1/tasks/{id}/stats":                {Summary: "Get protocol specific statistics of a task", Scope: base.AccessScopeRead, Tag: "task", Data: new(any)},
+	"GET /api/v1/tasks/{id}/files/{index}":        {Summary: "Download a file of a done task, range requests are supported", Scope: base.AccessScopeRead, Tag: "task", Query: []*apiParam{inlineParam}, Raw: true},
+	"GET /api/v1/tasks/{id}/files/{index}/stream": {Summary: "Stream a file of a running task, reads block until the requested range is downloaded", Scope: base.AccessScopeRead, Tag: "task", Query: []*apiParam{inlineParam}, Raw: true},
+	"GET /api/v1/tasks/{id}/zip":                  {Summary: "Download the selected files of a done task as a zip archive", Scope: base.AccessScopeRead, Tag: "task", Raw: true},
+	"GET /api/v1/tasks/{id}/peers":                {Summary: "Get the connected peers of a running bt task", Scope: base.AccessScopeRead, Tag: "task", Data: []*bt.Peer{}},
+	"POST /api/v1/tasks/{id}/peers":               {Summary: "Connect a running bt task to a peer", Scope: base.AccessScopeManage, Tag: "task", Body: model.TaskPeer{}},
+	"POST /api/v1/tasks/{id}/peers/ban":           {Summary: "Ban a peer ip for all bt tasks until the server exits", Scope: base.AccessScopeManage, Tag: "task", Body: model.TaskPeer{}},
+	"GET /api/v1/tasks/{id}/trackers":             {Summary: "Get the trackers of a running bt task with the last announce result", Scope: base.AccessScopeRead, Tag: "task", Data: []*bt.Tracker{}},
+	"POST /api/v1/tasks/{id}/trackers":            {Summary: "Add trackers to a running bt task and announce to them", Scope: base.AccessScopeManage, Tag: "task", Body: model.TaskTrackers{}},
+	"DELETE /api/v1/tasks/{id}/trackers":          {Summary: "Remove trackers from a running bt task", Scope: base.AccessScopeManage, Tag: "task", Query: []*apiParam{trackerUrlParam}},
+	"PUT /api/v1/tasks/{id}/trackers/reannounce":  {Summary: "Announce a running bt task to all its trackers", Scope: base.AccessScopeManage, Tag: "task"},
+	"PUT /api/v1/tasks/{id}/seed/stop":            {Summary: "Stop seeding a done bt task without deleting it", Scope: base.AccessScopeManage, Tag: "task"},
+	"GET /api/v1/tasks/{id}/sources":              {Summary: "Get the sources of a running ed2k task", Scope: base.AccessScopeRead, Tag: "task", Data: []*ed2k.Source{}},
+	"PUT /api/v1/tasks/{id}/recheck":              {Summary: "Verify the downloaded data of a task that is not running, the damaged data is downloaded again", Scope: base.AccessScopeManage, Tag: "task", Data: &base.Recheck{}},
+	"POST /api/v1/torrents":                       {Summary: "Create a torrent and magnet link from a local file or folder, and optionally seed it", Scope: base.AccessScopeCreate, Tag: "task", Body: bt.CreateTorrentOpts{}, Data: bt.CreateTorrentResult{}},
+	"GET /api/v1/torrents/{id}":                   {Summary: "Export the metadata of a bt task or a resolved bt request as a .torrent file", Scope: base.AccessScopeRead, Tag: "task", Data: bt.TorrentMetadata{}},
+	"GET /api/v1/ed2k/servers":                    {Summary: "Get the known ed2k servers with the connection status and the last connect error", Scope: base.AccessScopeRead, Tag: "ed2k", Data: ed2k.ServerList{}},
+	"POST /api/v1/ed2k/servers":                   {Summary: "Connect to an ed2k server", Scope: base.AccessScopeConfig, Tag: "ed2k", Body: model.Ed2kServer{}},
+	"DELETE /api/v1/ed2k/servers":                 {Summary: "Disconnect from an ed2k server until it's connected again", Scope: base.AccessScopeConfig, Tag: "ed2k", Query: []*apiParam{ed2kServerParam}},
+	"PUT /api/v1/ed2k/servers/refresh":            {Summary: "Reload the server.met of the ed2k config and connect to the servers in it", Scope: base.AccessScopeConfig, Tag: "ed2k"},
+	"GET /api/v1/ed2k/kad":                        {Summary: "Get the status of the KAD network", Scope: base.AccessScopeRead, Tag: "ed2k", Data: ed2k.Kad{}},
+	"PUT /api/v1/ed2k/kad/refresh":                {Summary: "Reload the nodes.dat of the ed2k config to bootstrap the KAD network", Scope: base.AccessScopeConfig, Tag: "ed2k"},
+	"POST /api/v1/ed2k/searches":                  {Summary: "Start an ed2k keyword search on the servers and the KAD network, poll the results by the search id", Scope: base.AccessScopeCreate, Tag: "ed2k", Body: ed2k.SearchOpts{}, Data: ed2k.Search{}},
+	"GET /api/v1/ed2k/searches":                   {Summary: "Get the recent ed2k searches without the results, newest first", Scope: base.AccessScopeRead, Tag: "ed2k", Data: []*ed2k.Search{}},
+	"GET /api/v1/ed2k/searches/{id}":              {Summary: "Get an ed2k search with the results found so far as ed2k links", Scope: base.AccessScopeRead, Tag: "ed2k", Data: ed2k.Search{}},
+	"DELETE /api/v1/ed2k/searches/{id}":           {Summary: "Stop an ed2k search and remove it", Scope: base.AccessScopeCreate, Tag: "ed2k"},
+	"POST /api/v1/feeds":                          {Summary: "Subscribe a rss or atom feed with auto download rules", Scope: base.AccessScopeCreate, Tag: "feed", Body: base.Feed{}, Data: base.Feed{}},
+	"GET /api/v1/feeds":                           {Summary: "Get feed subscriptions", Scope: base.AccessScopeRead, Tag: "feed", Data: []*base.Feed{}},
+	"GET /api/v1/feeds/{id}":                      {Summary: "Get a feed subscription", Scope: base.AccessScopeRead, Tag: "feed", Data: base.Feed{}},
+	"PUT /api/v1/feeds/{id}":                      {Summary: "Replace the settings and rules of a feed, seen items are kept", Scope: base.AccessScopeCreate, Tag: "feed", Body: base.Feed{}, Data: base.Feed{}},
+	"DELETE /api/v1/feeds/{id}":                   {Summary: "Unsubscribe a feed, created tasks are kept", Scope: base.AccessScopeCreate, Tag: "feed"},
+	"POST /api/v1/feeds/{id}/rules":               {Summary: "Add an auto download rule to a feed", Scope: base.AccessScopeCreate, Tag: "feed", Body: base.FeedRule{}, Data: base.FeedRule{}},
+	"PUT /api/v1/feeds/{id}/rules/{ruleId}":       {Summary: "Replace an auto download rule of a feed", Scope: base.AccessScopeCreate, Tag: "feed", Body: base.FeedRule{}, Data: base.FeedRule{}},
+	"DELETE /api/v1/feeds/{id}/rules/{ruleId}":    {Summary: "Delete an auto download rule of a feed", Scope: base.AccessScopeCreate, Tag: "feed"},
+	"POST /api/v1/feeds/{id}/refresh":             {Summary: "Poll a feed now and download the new matched items", Scope: base.AccessScopeCreate, Tag: "feed"},
+	"GET /api/v1/feeds/{id}/items":                {Summary: "Get the seen items of a feed, newest first", Scope: base.AccessScopeRead, Tag: "feed", Data: []*base.FeedItem{}},
+	"GET /api/v1/config":                          {Summary: "Get downloader config", Scope: base.AccessScopeConfig, Tag: "config", Data: base.DownloaderStoreConfig{}},
+	"PUT /api/v1/config":                          {Summary: "Update downloader config", Scope: base.AccessScopeConfig, Tag: "config", Body: base.DownloaderStoreConfig{}},
+	"POST /api/v1/extensions":                     {Summary: "Install an extension", Scope: base.AccessScopeExtension, Tag: "extension", Body: model.InstallExtension{}, Data: ""},
+	"POST /api/v1/extensions/archive":             {Summary: "Install an extension from an uploaded .zip or .tgz archive", Scope: base.AccessScopeExtension, Tag: "extension", Upload: true, Data: ""},
+	"POST /api/v1/extensions/preview":             {Summary: "Get an extension with its requested permissions without installing it", Scope: base.AccessScopeExtension, Tag: "extension", Body: model.InstallExtension{}, Data: download.Extension{}, NoAudit: true},
+	"POST /api/v1/extensions/archive/preview":     {Summary: "Get the extension in an uploaded .zip or .tgz archive with its requested permissions without installing it", Scope: base.AccessScopeExtension, Tag: "extension", Upload: true, Data: download.Extension{}, NoAudit: true},
+	"GET /api/v1/extensions":                      {Summary: "Get installed extensions", Scope: base.AccessScopeRead, Tag: "extension", Data: []*download.Extension{}},
+	"GET /api/v1/extensions/index":                {Summary: "Get the configured extension index", Scope: base.AccessScopeRead, Tag: "extension", Data: base.ExtensionIndex{}},
+	"GET /api/v1/extensions/{identity}":           {Summary: "Get an extension", Scope: base.AccessScopeRead, Tag: "extension", Data: download.Extension{}},
+	"DELETE /api/v1/extensions/{identity}":        {Summary: "Delete an extension", Scope: base.AccessScopeExtension, Tag: "extension"},
+	"PUT /api/v1/extensions/{identity}/settings":  {Summary: "Update extension settings", Scope: base.AccessScopeExtension, Tag: "extension", Body: model.UpdateExtensionSettings{}},
+	"PUT /api/v1/extensions/{identity}/switch":    {Summary: "Enable or disable an extension", Scope: base.AccessScopeExtension, Tag: "extension", Body: model.SwitchExtension{}},
+	"PUT /api/v1/extensions/{identity}/limits":    {Summary: "Override the execution limits of an extension", Scope: base.AccessScopeExtension, Tag: "extension", Body: base.ExtensionLimits{}},
+	"GET /api/v1/extensions/{identity}/update":    {Summary: "Check extension update", Scope: base.AccessScopeExtension, Tag: "extension", Data: model.UpdateCheckExtensionResp{}},
+	"POST /api/v1/extensions/{identity}/update":   {Summary: "Update an extension", Scope: base.AccessScopeExtension, Tag: "extension"},
+	"POST /api/v1/webhook/test":                   {Summary: "Send a test event to a webhook url", Scope: base.AccessScopeConfig, Tag: "config", Body: model.TestWebhookReq{}, NoAudit: true},
+	"ANY /api/v1/proxy":                           {Summary: "Forward the request to the X-Target-Uri header", Scope: base.AccessScopeConfig, Tag: "system", Raw: true, NoAudit: true},
+	"GET /api/v1/access/me":                       {Summary: "Get the authenticated principal", Tag: "access", Data: base.Principal{}},
+	"POST /api/v1/access/tokens":                  {Summary: "Create an access token", Scope: base.AccessScopeAdmin, Tag: "access", Body: model.CreateAccessToken{}, Data: model.CreateAccessTokenResp{}},
+	"GET /api/v1/access/tokens":                   {Summary: "Get access tokens", Scope: base.AccessScopeAdmin, Tag: "access", Data: []*base.AccessToken{}},
+	"DELETE /api/v1/access/tokens/{id}":           {Summary: "Revoke an access token", Scope: base.AccessScopeAdmin, Tag: "access"},
+	"PUT /api/v1/access/users":                    {Summary: "Create or replace an access user", Scope: base.AccessScopeAdmin, Tag: "access", Body: base.AccessUser{}},
+	"GET /api/v1/access/users":                    {Summary: "Get access users", Scope: base.AccessScopeAdmin, Tag: "access", Data: []*base.AccessUser{}},
+	"DELETE /api/v1/access/users/{username}":      {Summary: "Delete an access user", Scope: base.AccessScopeAdmin, Tag: "access"},
+	"GET /api/v1/access/sessions":                 {Summary: "Get the web login sessions", Scope: base.AccessScopeAdmin, Tag: "access", Data: []*model.Session{}},
+	"DELETE /api/v1/access/sessions/{id}":         {Summary: "Revoke a web login session", Scope: base.AccessScopeAdmin, Tag: "access"},
+	"GET /api/v1/audit":                           {Summary: "Get the audit log of state-changing calls, newest first", Scope: base.AccessScopeAdmin, Tag: "audit", Query: auditQueryParams, Data: base.AuditPage{}},
+	"POST /api/web/login":                         {Summary: "Login to the web ui and get a session token", Tag: "system", Body: model.Login{}, Data: "", NoAudit: true},
+	"POST /api/web/logout":                        {Summary: "Revoke the session of the request", Tag: "system"},
+}
+
+// proxyMethods are the methods documented for routes that match any method.
+var proxyMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       *openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components *openAPIComponents                      `json:"components"`
+	Security   []map[string][]string                   `json:"security,omitempty"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Scope       base.AccessScope            `json:"x-gopeed-scope,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `json:"type"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Enum                 []any                     `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+}
+
+var pathVarRegex = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?}`)
+
+// buildOpenAPI builds the OpenAPI document of all /api/ routes registered on the router.
+func buildOpenAPI(r *mux.Router) *openAPIDocument {
+	sg := newSchemaGenerator()
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: &openAPIInfo{
+			Title:   "Gopeed REST API",
+			Version: base.Version,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: &openAPIComponents{
+			Schemas: sg.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				"apiToken": {Type: "apiKey", In: "header", Name: "X-Api-Token"},
+				"bearer":   {Type: "http", Scheme: "bearer"},
+			},
+		},
+		Security: []map[string][]string{{"apiToken": {}}, {"bearer": {}}},
+	}
+	sg.schemas["Result"] = &openAPISchema{
+		Type:        "object",
+		Description: "Common response envelope, code 0 means success",
+		Properties: map[string]*openAPISchema{
+			"code": {Type: "integer", Enum: []any{model.CodeOk, model.CodeError, model.CodeUnauthorized, model.CodeInvalidParam, model.CodeForbidden, model.CodeTaskNotFound}},
+			"msg":  {Type: "string"},
+			"data": {Nullable: true},
+		},
+	}
+
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		docKey := func(method string) string { return method + " " + path }
+		if err != nil {
+			methods = proxyMethods
+			docKey = func(string) string { return "ANY " + path }
+		}
+		for _, method := range methods {
+			ad := apiRoutes[docKey(method)]
+			if ad == nil {
+				ad = &apiRoute{}
+			}
+			specPath := pathVarRegex.ReplaceAllString(path, "{$1}")
+			if doc.Paths[specPath] == nil {
+				doc.Paths[specPath] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[specPath][strings.ToLower(method)] = sg.operation(method, path, ad)
+		}
+		return nil
+	})
+	return doc
+}
+
+func (sg *schemaGenerator) operation(method string, path string, ad *apiRoute) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: operationID(method, path),
+		Scope:       ad.Scope,
+		Summary:     ad.Summary,
+		Responses:   make(map[string]*openAPIResponse),
+	}
+	if ad.Tag != "" {
+		op.Tags = []string{ad.Tag}
+	}
+	for _, m := range pathVarRegex.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openAPISchema{Type: "string"},
+		})
+	}
+	for _, p := range ad.Query {
+		schema := &openAPISchema{Type: p.Type}
+		if p.Array {
+			schema = &openAPISchema{Type: "array", Items: schema}
+		}
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name:        p.Name,
+			In:          "query",
+			Description: p.Description,
+			Schema:      schema,
+		})
+	}
+	if ad.Body != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content: map[string]*openAPIMediaType{
+				"application/json": {Schema: sg.schema(reflect.TypeOf(ad.Body))},
+			},
+		}
+	}
+	if ad.Upload {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content: map[string]*openAPIMediaType{
+				"application/octet-stream": {Schema: &openAPISchema{Type: "string", Format: "binary"}},
+			},
+		}
+	}
+	if ad.Raw {
+		op.Responses["200"] = &openAPIResponse{Description: "Raw response"}
+		return op
+	}
+	data := &openAPISchema{Nullable: true}
+	if ad.Data != nil {
+		t := reflect.TypeOf(ad.Data)
+		// new(any) is used to document an arbitrary data value
+		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+			data = &openAPISchema{}
+		} else {
+			data = sg.schema(t)
+		}
+	}
+	op.Responses["200"] = &openAPIResponse{
+		Description: "Result envelope",
+		Content: map[string]*openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{
+				AllOf: []*openAPISchema{
+					{Ref: "#/components/schemas/Result"},
+					{Type: "object", Properties: map[string]*openAPISchema{"data": data}},
+				},
+			}},
+		},
+	}
+	return op
+}
+
+// operationID converts a route to a camel case id, e.g. PUT /api/v1/tasks/{id}/pause -> putTasksByIdPause
+func operationID(method string, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/api/v1"), "/api")
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if m := pathVarRegex.FindStringSubmatch(seg); m != nil {
+			sb.WriteString("By")
+			seg = m[1]
+		}
+		seg = strings.TrimSuffix(seg, ".json")
+		sb.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return sb.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// extraProperties are json properties added by custom MarshalJSON implementations.
+var extraProperties = map[reflect.Type]map[string]*openAPISchema{
+	reflect.TypeOf(download.Task{}): {
+		"name": {Type: "string"},
+	},
+}
+
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (sg *schemaGenerator) schema(t reflect.Type) *openAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: sg.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: sg.schema(t.Elem())}
+	case reflect.Struct:
+		return &openAPISchema{Ref: "#/components/schemas/" + sg.structSchema(t)}
+	}
+	// interface and other dynamic values
+	return &openAPISchema{}
+}
+
+func (sg *schemaGenerator) structSchema(t reflect.Type) string {
+	if name, ok := sg.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, exists := sg.schemas[name]; exists || name == "" {
+		name = pkgName(t) + name
+	}
+	sg.names[t] = name
+	// register before resolving fields, so that recursive types can refer to themselves
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	sg.schemas[name] = schema
+	sg.fields(t, schema)
+	for k, v := range extraProperties[t] {
+		schema.Properties[k] = v
+	}
+	return name
+}
+
+func (sg *schemaGenerator) fields(t reflect.Type, schema *openAPISchema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		jsonName, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && jsonName == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				sg.fields(ft, schema)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+		schema.Properties[jsonName] = sg.schema(field.Type)
+	}
+}
+
+func pkgName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" {
+		return "Anonymous"
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:]
+}
+
+func openAPIHandler(r *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		WriteJson(w, buildOpenAPI(r))
+	}
+}
@@ -2,23 +2,19 @@ package rest
 
 import (
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/fs"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/GopeedLab/gopeed/pkg/base"
 	"github.com/GopeedLab/gopeed/pkg/download"
 	"github.com/GopeedLab/gopeed/pkg/rest/model"
 	"github.com/GopeedLab/gopeed/pkg/util"
@@ -30,7 +26,8 @@ import (
 var (
 	srv         *http.Server
 	runningPort int
-	aesKey      []byte
+	sessions    *sessionManager
+	loginLimit  *loginLimiter
 
 	Downloader *download.Downloader
 )
@@ -107,19 +104,28 @@ func BuildServer(startCfg *model.StartConfig) (*http.Server, net.Listener, error
 		util.SafeRemove(startCfg.Address)
 	}
 
-	if startCfg.WebEnable {
-		aesKey = make([]byte, 32)
-		if _, err := rand.Read(aesKey); err != nil {
-			return nil, nil, errors.Wrap(err, "generate aes key failed")
-		}
+	webAuthCfg := startCfg.WebAuth
+	if webAuthCfg == nil {
+		webAuthCfg = (&model.WebAuth{}).Init()
 	}
+	sessions = newSessionManager(webAuthCfg.SessionLifetime)
+	loginLimit = newLoginLimiter(webAuthCfg.MaxLoginAttempts, webAuthCfg.LoginLockout)
 
 	listener, err := net.Listen(startCfg.Network, startCfg.Address)
 	if err != nil {
 		return nil, nil, err
 	}
+	if startCfg.TLS.Enabled() {
+		tlsCfg, err := buildTLSConfig(startCfg)
+		if err != nil {
+			listener.Close()
+			return nil, nil, err
+		}
+		listener = tls.NewListener(listener, tlsCfg)
+	}
 
 	var r = mux.NewRouter()
+	r.Methods(http.MethodGet).Path("/api/v1/openapi.json").HandlerFunc(openAPIHandler(r))
 	r.Methods(http.MethodGet).Path("/api/v1/info").HandlerFunc(Info)
 	r.Methods(http.MethodPost).Path("/api/v1/resolve").HandlerFunc(Resolve)
 	r.Methods(http.MethodPost).Path("/api/v1/tasks").HandlerFunc(CreateTask)
@@ -134,109 +140,159 @@ func BuildServer(startCfg *model.StartConfig) (*http.Server, net.Listener, error
 	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}").HandlerFunc(GetTask)
 	r.Methods(http.MethodGet).Path("/api/v1/tasks").HandlerFunc(GetTasks)
 	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/stats").HandlerFunc(GetStats)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/files/{index}").HandlerFunc(GetTaskFile)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/files/{index}/stream").HandlerFunc(StreamTaskFile)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/zip").HandlerFunc(GetTaskZip)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/peers").HandlerFunc(GetTaskPeers)
+	r.Methods(http.MethodPost).Path("/api/v1/tasks/{id}/peers").HandlerFunc(AddTaskPeer)
+	r.Methods(http.MethodPost).Path("/api/v1/tasks/{id}/peers/ban").HandlerFunc(BanTaskPeer)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/trackers").HandlerFunc(GetTaskTrackers)
+	r.Methods(http.MethodPost).Path("/api/v1/tasks/{id}/trackers").HandlerFunc(AddTaskTrackers)
+	r.Methods(http.MethodDelete).Path("/api/v1/tasks/{id}/trackers").HandlerFunc(RemoveTaskTrackers)
+	r.Methods(http.MethodPut).Path("/api/v1/tasks/{id}/trackers/reannounce").HandlerFunc(ReannounceTask)
+	r.Methods(http.MethodPut).Path("/api/v1/tasks/{id}/seed/stop").HandlerFunc(StopSeeding)
+	r.Methods(http.MethodGet).Path("/api/v1/tasks/{id}/sources").HandlerFunc(GetTaskSources)
+	r.Methods(http.MethodPut).Path("/api/v1/tasks/{id}/recheck").HandlerFunc(RecheckTask)
+	r.Methods(http.MethodPost).Path("/api/v1/torrents").HandlerFunc(CreateTorrent)
+	r.Methods(http.MethodGet).Path("/api/v1/torrents/{id}").HandlerFunc(ExportTorrent)
+	r.Methods(http.MethodGet).Path("/api/v1/ed2k/servers").HandlerFunc(GetEd2kServers)
+	r.Methods(http.MethodPost).Path("/api/v1/ed2k/servers").HandlerFunc(ConnectEd2kServer)
+	r.Methods(http.MethodDelete).Path("/api/v1/ed2k/servers").HandlerFunc(DisconnectEd2kServer)
+	r.Methods(http.MethodPut).Path("/api/v1/ed2k/servers/refresh").HandlerFunc(RefreshEd2kServerMet)
+	r.Methods(http.MethodGet).Path("/api/v1/ed2k/kad").HandlerFunc(GetEd2kKad)
+	r.Methods(http.MethodPut).Path("/api/v1/ed2k/kad/refresh").HandlerFunc(RefreshEd2kNodesDat)
+	r.Methods(http.MethodPost).Path("/api/v1/ed2k/searches").HandlerFunc(SearchEd2k)
+	r.Methods(http.MethodGet).Path("/api/v1/ed2k/searches").HandlerFunc(GetEd2kSearches)
+	r.Methods(http.MethodGet).Path("/api/v1/ed2k/searches/{id}").HandlerFunc(GetEd2kSearch)
+	r.Methods(http.MethodDelete).Path("/api/v1/ed2k/searches/{id}").HandlerFunc(DeleteEd2kSearch)
+	r.Methods(http.MethodPost).Path("/api/v1/feeds").HandlerFunc(CreateFeed)
+	r.Methods(http.MethodGet).Path("/api/v1/feeds").HandlerFunc(GetFeeds)
+	r.Methods(http.MethodGet).Path("/api/v1/feeds/{id}").HandlerFunc(GetFeed)
+	r.Methods(http.MethodPut).Path("/api/v1/feeds/{id}").HandlerFunc(UpdateFeed)
+	r.Methods(http.MethodDelete).Path("/api/v1/feeds/{id}").HandlerFunc(DeleteFeed)
+	r.Methods(http.MethodPost).Path("/api/v1/feeds/{id}/rules").HandlerFunc(AddFeedRule)
+	r.Methods(http.MethodPut).Path("/api/v1/feeds/{id}/rules/{ruleId}").HandlerFunc(UpdateFeedRule)
+	r.Methods(http.MethodDelete).Path("/api/v1/feeds/{id}/rules/{ruleId}").HandlerFunc(DeleteFeedRule)
+	r.Methods(http.MethodPost).Path("/api/v1/feeds/{id}/refresh").HandlerFunc(RefreshFeed)
+	r.Methods(http.MethodGet).Path("/api/v1/feeds/{id}/items").HandlerFunc(GetFeedItems)
 	r.Methods(http.MethodGet).Path("/api/v1/config").HandlerFunc(GetConfig)
 	r.Methods(http.MethodPut).Path("/api/v1/config").HandlerFunc(PutConfig)
 	r.Methods(http.MethodPost).Path("/api/v1/extensions").HandlerFunc(InstallExtension)
+	r.Methods(http.MethodPost).Path("/api/v1/extensions/archive").HandlerFunc(InstallExtensionArchive)
+	r.Methods(http.MethodPost).Path("/api/v1/extensions/preview").HandlerFunc(PreviewExtension)
+	r.Methods(http.MethodPost).Path("/api/v1/extensions/archive/preview").HandlerFunc(PreviewExtensionArchive)
 	r.Methods(http.MethodGet).Path("/api/v1/extensions").HandlerFunc(GetExtensions)
+	r.Methods(http.MethodGet).Path("/api/v1/extensions/index").HandlerFunc(GetExtensionIndex)
 	r.Methods(http.MethodGet).Path("/api/v1/extensions/{identity}").HandlerFunc(GetExtension)
 	r.Methods(http.MethodPut).Path("/api/v1/extensions/{identity}/settings").HandlerFunc(UpdateExtensionSettings)
 	r.Methods(http.MethodPut).Path("/api/v1/extensions/{identity}/switch").HandlerFunc(SwitchExtension)
+	r.Methods(http.MethodPut).Path("/api/v1/extensions/{identity}/limits").HandlerFunc(UpdateExtensionLimits)
 	r.Methods(http.MethodDelete).Path("/api/v1/extensions/{identity}").HandlerFunc(DeleteExtension)
 	r.Methods(http.MethodGet).Path("/api/v1/extensions/{identity}/update").HandlerFunc(UpdateCheckExtension)
 	r.Methods(http.MethodPost).Path("/api/v1/extensions/{identity}/update").HandlerFunc(UpdateExtension)
 	r.Methods(http.MethodPost).Path("/api/v1/webhook/test").HandlerFunc(TestWebhook)
 	r.Path("/api/v1/proxy").HandlerFunc(DoProxy)
 
+	r.Methods(http.MethodGet).Path("/api/v1/access/me").HandlerFunc(GetAccessPrincipal)
+	r.Methods(http.MethodPost).Path("/api/v1/access/tokens").HandlerFunc(CreateAccessToken)
+	r.Methods(http.MethodGet).Path("/api/v1/access/tokens").HandlerFunc(GetAccessTokens)
+	r.Methods(http.MethodDelete).Path("/api/v1/access/tokens/{id}").HandlerFunc(RevokeAccessToken)
+	r.Methods(http.MethodPut).Path("/api/v1/access/users").HandlerFunc(PutAccessUser)
+	r.Methods(http.MethodGet).Path("/api/v1/access/users").HandlerFunc(GetAccessUsers)
+	r.Methods(http.MethodDelete).Path("/api/v1/access/users/{username}").HandlerFunc(DeleteAccessUser)
+	r.Methods(http.MethodGet).Path("/api/v1/access/sessions").HandlerFunc(GetSessions)
+	r.Methods(http.MethodDelete).Path("/api/v1/access/sessions/{id}").HandlerFunc(RevokeSession)
+	r.Methods(http.MethodGet).Path("/api/v1/audit").HandlerFunc(GetAuditLog)
+
 	enableApiToken := startCfg.ApiToken != ""
 	enableWebAuth := startCfg.WebEnable && startCfg.WebAuth != nil
-	if startCfg.WebEnable {
-		if enableWebAuth {
-			r.Methods(http.MethodPost).Path("/api/web/login").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				var loginReq model.WebAuth
-				if ReadJson(r, w, &loginReq) {
-					if loginReq.Username == startCfg.WebAuth.Username && loginReq.Password == startCfg.WebAuth.Password {
-						// Generate a login token, Username:Password:Timestamp
-						timestamp := time.Now().Unix()
-						tokenData := fmt.Sprintf("%s:%s:%d", loginReq.Username, loginReq.Password, timestamp)
-						token, err := aesEncrypt(aesKey, []byte(tokenData))
-						if err != nil {
-							WriteJson(w, model.NewErrorResult(err.Error()))
-							return
-						}
-
-						WriteJson(w, model.NewOkResult(token))
-						return
-					}
-				}
-				WriteStatusJson(w, http.StatusUnauthorized, model.NewErrorResult("unauthorized", model.CodeUnauthorized))
-			})
+	// authenticateUser checks the legacy web auth user first, then the stored access users
+	authenticateUser := func(username string, password string) *base.Principal {
+		if enableWebAuth && username == startCfg.WebAuth.Username && util.CheckPassword(startCfg.WebAuth.Password, password) {
+			return base.AdminPrincipal(username)
 		}
+		return Downloader.AuthenticateAccessUser(username, password)
+	}
+	// webLogin returns true if the web ui can be logged in by the legacy web auth user or a stored access user
+	webLogin := func() bool {
+		return enableWebAuth || (startCfg.WebEnable && Downloader.HasAccessUsers())
+	}
+	if startCfg.WebEnable {
+		// the stored access users can login even if the legacy web auth is not configured
+		r.Methods(http.MethodPost).Path("/api/web/login").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			login(w, r, authenticateUser)
+		})
+		r.Methods(http.MethodPost).Path("/api/web/logout").HandlerFunc(Logout)
 		r.PathPrefix("/fs/tasks").Handler(http.FileServer(new(taskFileSystem)))
 		r.PathPrefix("/fs/extensions").Handler(http.FileServer(new(extensionFileSystem)))
 		r.PathPrefix("/").Handler(gzipMiddleware(http.FileServer(newEmbedCacheFileSystem(http.FS(startCfg.WebFS)))))
 	}
-	if enableApiToken || enableWebAuth {
-		writeUnauthorized := func(w http.ResponseWriter, r *http.Request) {
-			WriteStatusJson(w, http.StatusUnauthorized, model.NewErrorResult("unauthorized", model.CodeUnauthorized))
-		}
 
-		r.Use(func(h http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	writeUnauthorized := func(w http.ResponseWriter, r *http.Request) {
+		WriteStatusJson(w, http.StatusUnauthorized, model.NewErrorResult("unauthorized", model.CodeUnauthorized))
+	}
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// authentication is required once any access token or user is stored, even if the legacy api token
+			// and web auth are not configured, otherwise the requests without credentials would be admin
+			if !enableApiToken && !enableWebAuth && !Downloader.HasAccessTokens() && !Downloader.HasAccessUsers() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			apiTokenHeader := r.Header["X-Api-Token"]
+			// If api token header is set, only check api token ignore basic auth
+			if len(apiTokenHeader) > 0 {
+				if enableApiToken && apiTokenHeader[0] == startCfg.ApiToken {
+					h.ServeHTTP(w, withPrincipal(r, base.AdminPrincipal("")))
+					return
+				}
+				if principal := Downloader.AuthenticateAccessToken(apiTokenHeader[0]); principal != nil {
+					h.ServeHTTP(w, withPrincipal(r, principal))
+					return
+				}
 				if enableApiToken {
-					apiTokenHeader := r.Header["X-Api-Token"]
-					// If api token header is set, only check api token ignore basic auth
-					if len(apiTokenHeader) > 0 {
-						if apiTokenHeader[0] == startCfg.ApiToken {
-							h.ServeHTTP(w, r)
-							return
-						}
-
-						writeUnauthorized(w, r)
-						return
-					}
+					writeUnauthorized(w, r)
+					return
 				}
+			}
 
-				if enableWebAuth {
-					if !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api/web/login" {
-						h.ServeHTTP(w, r)
-						return
-					}
-
-					token := r.Header.Get("Authorization")
-					if token == "" {
-						writeUnauthorized(w, r)
-						return
-					}
-
-					token = strings.TrimPrefix(token, "Bearer ")
-					tokenData, err := aesDecrypt(aesKey, token)
-					if err != nil {
-						writeUnauthorized(w, r)
-						return
-					}
-					parts := strings.SplitN(string(tokenData), ":", 3)
-					username := parts[0]
-					password := parts[1]
-					timestamp, _ := strconv.Atoi(parts[2])
-
-					if username != startCfg.WebAuth.Username || password != startCfg.WebAuth.Password {
-						writeUnauthorized(w, r)
-						return
-					}
-
-					// Check if the token is expired (7 days)
-					if time.Now().Unix()-int64(timestamp) > 7*24*3600 {
-						writeUnauthorized(w, r)
-						return
-					}
-
+			if webLogin() {
+				if !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api/web/login" {
 					h.ServeHTTP(w, r)
 					return
 				}
-				writeUnauthorized(w, r)
-			})
+
+				s := sessions.get(bearerToken(r))
+				if s == nil {
+					writeUnauthorized(w, r)
+					return
+				}
+				h.ServeHTTP(w, withSession(r, s))
+				return
+			}
+			writeUnauthorized(w, r)
 		})
-	}
+	})
+
+	// record state-changing calls, including the ones rejected by the scope check
+	r.Use(auditMiddleware)
+
+	// check the scope of the authenticated principal
+	r.Use(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/api/") && r.URL.Path != "/api/web/login" && !authorize(r, principalOf(r)) {
+				WriteStatusJson(w, http.StatusForbidden, model.NewErrorResult("forbidden", model.CodeForbidden))
+				return
+			}
+			// the tasks out of the download directories of the principal are hidden
+			if !authorizeTask(r, principalOf(r)) {
+				WriteJson(w, model.NewErrorResult("task not found", model.CodeTaskNotFound))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	})
 
 	// recover panic
 	r.Use(func(h http.Handler) http.Handler {
@@ -392,47 +448,3 @@ func WriteStatusJson(w http.ResponseWriter, statusCode int, v any) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(v)
 }
-
-func aesEncrypt(key, data []byte) (string, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	cipherText := gcm.Seal(nonce, nonce, data, nil)
-	return base64.StdEncoding.EncodeToString(cipherText), nil
-}
-
-func aesDecrypt(key []byte, encryptedData string) ([]byte, error) {
-	cipherText, err := base64.StdEncoding.DecodeString(encryptedData)
-	if err != nil {
-		return nil, err
-	}
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(cipherText) < gcm.NonceSize() {
-		return nil, errors.New("ciphertext too short")
-	}
-
-	nonce, cipherText := cipherText[:gcm.NonceSize()], cipherText[gcm.NonceSize():]
-	return gcm.Open(nil, nonce, cipherText, nil)
-}
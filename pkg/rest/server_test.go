@@ -1,17 +1,30 @@
 package rest
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -21,7 +34,11 @@ import (
 	"github.com/GopeedLab/gopeed/pkg/base"
 	"github.com/GopeedLab/gopeed/pkg/download"
 	enginewebview "github.com/GopeedLab/gopeed/pkg/download/engine/webview"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/protocol/ed2k"
+	"github.com/GopeedLab/gopeed/pkg/rest/client"
 	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/GopeedLab/gopeed/pkg/util"
 )
 
 var (
@@ -434,6 +451,383 @@ func TestGetTasks(t *testing.T) {
 	})
 }
 
+func TestGetTaskFile(t *testing.T) {
+	doTest0(func(cfg *model.StartConfig) {
+		cfg.ApiToken = "123456"
+	}, func() {
+		adminHeaders := map[string]string{"X-Api-Token": "123456"}
+		var wg sync.WaitGroup
+		wg.Add(1)
+		Downloader.Listener(func(event *download.Event) {
+			if event.Key == download.EventKeyFinally {
+				wg.Done()
+			}
+		})
+		_, result := doHttpRequest[string](http.MethodPost, "/api/v1/tasks", adminHeaders, createReq)
+		checkOk(int(result.Code))
+		taskId := result.Data
+		wg.Wait()
+
+		want := make([]byte, 100)
+		f, err := os.Open(test.BuildFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.ReadAt(want, 100); err != nil {
+			t.Fatal(err)
+		}
+		rangeHeaders := map[string]string{"X-Api-Token": "123456", "Range": "bytes=100-199"}
+		status, headers, body := doHttpRequest1(http.MethodGet, "/api/v1/tasks/"+taskId+"/files/0", rangeHeaders, nil)
+		if status != http.StatusPartialContent || !bytes.Equal(body, want) {
+			t.Errorf("GetTaskFile() range got = %v, %d bytes", status, len(body))
+		}
+		if headers["Content-Disposition"] != `attachment; filename=`+createOpts.Name {
+			t.Errorf("GetTaskFile() Content-Disposition got = %v", headers["Content-Disposition"])
+		}
+
+		errorTests := []struct {
+			path   string
+			status int
+			code   model.RespCode
+		}{
+			{"/api/v1/tasks/" + taskId + "/files/1", http.StatusNotFound, model.CodeTaskFileNotFound},
+			{"/api/v1/tasks/not-exist/files/0", http.StatusNotFound, model.CodeTaskNotFound},
+			{"/api/v1/tasks/" + taskId + "/files/x", http.StatusOK, model.CodeInvalidParam},
+		}
+		for _, tt := range errorTests {
+			status, body := doHttpRequest0(http.MethodGet, tt.path, adminHeaders, nil)
+			var r model.Result[any]
+			if err := json.Unmarshal(body, &r); err != nil || status != tt.status || r.Code != tt.code {
+				t.Errorf("GetTaskFile() %s got = %v, %s", tt.path, status, body)
+			}
+		}
+
+		_, tokenResult := doHttpRequest[*model.CreateAccessTokenResp](http.MethodPost, "/api/v1/access/tokens", adminHeaders, &model.CreateAccessToken{
+			Name:         "other-dir",
+			Scopes:       []base.AccessScope{base.AccessScopeRead},
+			DownloadDirs: []string{"/other/*"},
+		})
+		checkOk(int(tokenResult.Code))
+		status, _ = doHttpRequest0(http.MethodGet, "/api/v1/tasks/"+taskId+"/zip", map[string]string{"X-Api-Token": tokenResult.Data.Token}, nil)
+		if status != http.StatusForbidden {
+			t.Errorf("GetTaskZip() other dir got = %v, want %v", status, http.StatusForbidden)
+		}
+
+		// stream the archive to disk, the test file is too large to keep in memory twice
+		request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/api/v1/tasks/%s/zip", restPort, taskId), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("X-Api-Token", "123456")
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		zipFile := filepath.Join(t.TempDir(), "task.zip")
+		out, err := os.Create(zipFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(out, response.Body)
+		out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.OpenReader(zipFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		if len(zr.File) != 1 || zr.File[0].Name != createOpts.Name || zr.File[0].UncompressedSize64 != test.BuildSize {
+			t.Errorf("GetTaskZip() got = %v", zr.File)
+		}
+	})
+}
+
+func TestStreamTaskFile(t *testing.T) {
+	doTest(func() {
+		taskId := httpRequestCheckOk[string](http.MethodPost, "/api/v1/tasks", createReq)
+
+		want := make([]byte, 1000)
+		f, err := os.Open(test.BuildFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		offset := int64(test.BuildSize - 2000)
+		if _, err := f.ReadAt(want, offset); err != nil {
+			t.Fatal(err)
+		}
+		// wait until the task is resolved, the stream blocks until the range is downloaded
+		for i := 0; i < 100; i++ {
+			if task := Downloader.GetTask(taskId); task.Meta.Res != nil && (task.Status == base.DownloadStatusRunning || task.Status == base.DownloadStatusDone) {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		headers := map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(want))-1)}
+		status, body := doHttpRequest0(http.MethodGet, "/api/v1/tasks/"+taskId+"/files/0/stream", headers, nil)
+		if status != http.StatusPartialContent || !bytes.Equal(body, want) {
+			t.Errorf("StreamTaskFile() got = %v, %d bytes", status, len(body))
+		}
+
+		status, _ = doHttpRequest0(http.MethodGet, "/api/v1/tasks/not-exist/files/0/stream", nil, nil)
+		if status != http.StatusNotFound {
+			t.Errorf("StreamTaskFile() not exist task got = %v, want %v", status, http.StatusNotFound)
+		}
+	})
+}
+
+func TestCreateTorrent(t *testing.T) {
+	doTest(func() {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "data.bin")
+		if err := os.WriteFile(file, []byte("some dataset content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _ := httpRequest[any](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{})
+		checkCode(code, model.CodeInvalidParam)
+
+		result := httpRequestCheckOk[*bt.CreateTorrentResult](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{
+			Path:     file,
+			Trackers: []string{"udp://tracker.example.com:80/announce"},
+			Seed:     true,
+		})
+		if result.Name != "data.bin" || len(result.Torrent) == 0 || !strings.HasPrefix(result.Magnet, "magnet:?") {
+			t.Errorf("CreateTorrent() got = %+v", result)
+		}
+		task := httpRequestCheckOk[*download.Task](http.MethodGet, "/api/v1/tasks/"+result.TaskID, nil)
+		if task.Status != base.DownloadStatusDone || !task.Uploading {
+			t.Errorf("CreateTorrent() seed task got = %s, uploading %v", task.Status, task.Uploading)
+		}
+	})
+}
+
+func TestExportTorrent(t *testing.T) {
+	doTest(func() {
+		file := filepath.Join(t.TempDir(), "export.bin")
+		if err := os.WriteFile(file, []byte("export content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		created := httpRequestCheckOk[*bt.CreateTorrentResult](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{Path: file})
+
+		code, _ := httpRequest[any](http.MethodGet, "/api/v1/torrents/not-exist", nil)
+		checkCode(code, model.CodeTaskNotFound)
+		rr := httpRequestCheckOk[*download.ResolveResult](http.MethodPost, "/api/v1/resolve", &model.ResolveTask{
+			Req: &base.Request{
+				URL: "data:application/x-bittorrent;base64," + base64.StdEncoding.EncodeToString(created.Torrent),
+			},
+		})
+		metadata := httpRequestCheckOk[*bt.TorrentMetadata](http.MethodGet, "/api/v1/torrents/"+rr.ID, nil)
+		if metadata.InfoHash != created.InfoHash || len(metadata.Torrent) == 0 {
+			t.Errorf("ExportTorrent() got = %+v", metadata)
+		}
+	})
+}
+
+func TestTaskSwarm(t *testing.T) {
+	doTest(func() {
+		file := filepath.Join(t.TempDir(), "swarm.bin")
+		if err := os.WriteFile(file, []byte("swarm content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result := httpRequestCheckOk[*bt.CreateTorrentResult](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{
+			Path: file,
+			Seed: true,
+		})
+		path := "/api/v1/tasks/" + result.TaskID
+
+		code, _ := httpRequest[any](http.MethodGet, "/api/v1/tasks/not-exist/peers", nil)
+		checkCode(code, model.CodeTaskNotFound)
+		peers := httpRequestCheckOk[[]*bt.Peer](http.MethodGet, path+"/peers", nil)
+		if len(peers) != 0 {
+			t.Errorf("GetTaskPeers() got = %+v, want empty", peers)
+		}
+		code, _ = httpRequest[any](http.MethodPost, path+"/peers", &model.TaskPeer{Addr: "invalid"})
+		checkCode(code, model.CodeInvalidParam)
+
+		code, _ = httpRequest[any](http.MethodPost, path+"/trackers", &model.TaskTrackers{})
+		checkCode(code, model.CodeInvalidParam)
+		tracker := "udp://127.0.0.1:1/announce"
+		httpRequestCheckOk[any](http.MethodPost, path+"/trackers", &model.TaskTrackers{URLs: []string{tracker}})
+		trackers := httpRequestCheckOk[[]*bt.Tracker](http.MethodGet, path+"/trackers", nil)
+		if len(trackers) != 1 || trackers[0].URL != tracker {
+			t.Errorf("GetTaskTrackers() got = %+v", trackers)
+		}
+		httpRequestCheckOk[any](http.MethodPut, path+"/trackers/reannounce", nil)
+		httpRequestCheckOk[any](http.MethodDelete, path+"/trackers?url="+url.QueryEscape(tracker), nil)
+		if trackers = httpRequestCheckOk[[]*bt.Tracker](http.MethodGet, path+"/trackers", nil); len(trackers) != 0 {
+			t.Errorf("RemoveTaskTrackers() got = %+v", trackers)
+		}
+	})
+}
+
+func TestStopSeeding(t *testing.T) {
+	doTest(func() {
+		file := filepath.Join(t.TempDir(), "seed.bin")
+		if err := os.WriteFile(file, []byte("seed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result := httpRequestCheckOk[*bt.CreateTorrentResult](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{
+			Path: file,
+			Seed: true,
+		})
+
+		code, _ := httpRequest[any](http.MethodPut, "/api/v1/tasks/not-exist/seed/stop", nil)
+		checkCode(code, model.CodeTaskNotFound)
+		httpRequestCheckOk[any](http.MethodPut, "/api/v1/tasks/"+result.TaskID+"/seed/stop", nil)
+		task := httpRequestCheckOk[*download.Task](http.MethodGet, "/api/v1/tasks/"+result.TaskID, nil)
+		if task.Uploading || task.Status != base.DownloadStatusDone {
+			t.Errorf("StopSeeding() task got uploading %v, status %s", task.Uploading, task.Status)
+		}
+		code, _ = httpRequest[any](http.MethodPut, "/api/v1/tasks/"+result.TaskID+"/seed/stop", nil)
+		checkCode(code, model.CodeError)
+	})
+}
+
+func TestRecheckTask(t *testing.T) {
+	doTest(func() {
+		file := filepath.Join(t.TempDir(), "seed.bin")
+		if err := os.WriteFile(file, []byte("seed content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result := httpRequestCheckOk[*bt.CreateTorrentResult](http.MethodPost, "/api/v1/torrents", &bt.CreateTorrentOpts{
+			Path: file,
+			Seed: true,
+		})
+
+		code, _ := httpRequest[any](http.MethodPut, "/api/v1/tasks/not-exist/recheck", nil)
+		checkCode(code, model.CodeTaskNotFound)
+		recheck := httpRequestCheckOk[*base.Recheck](http.MethodPut, "/api/v1/tasks/"+result.TaskID+"/recheck", nil)
+		if !recheck.Intact || recheck.VerifiedBytes != int64(len("seed content")) {
+			t.Errorf("RecheckTask() got = %v", test.ToJson(recheck))
+		}
+	})
+}
+
+func TestEd2k(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			if _, err := listener.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+
+	doTest(func() {
+		// no bootstrap from the internet
+		cfg := httpRequestCheckOk[*base.DownloaderStoreConfig](http.MethodGet, "/api/v1/config", nil)
+		cfg.ProtocolConfig["ed2k"] = map[string]any{
+			"disableDHT":  true,
+			"disableUPnP": true,
+		}
+		httpRequestCheckOk[any](http.MethodPut, "/api/v1/config", cfg)
+
+		addr := listener.Addr().String()
+		code, _ := httpRequest[any](http.MethodPost, "/api/v1/ed2k/servers", &model.Ed2kServer{Addr: "bad"})
+		checkCode(code, model.CodeInvalidParam)
+		httpRequestCheckOk[any](http.MethodPost, "/api/v1/ed2k/servers", &model.Ed2kServer{Addr: addr})
+		list := httpRequestCheckOk[*ed2k.ServerList](http.MethodGet, "/api/v1/ed2k/servers", nil)
+		if len(list.Servers) != 1 || list.Servers[0].Addr != addr || !list.Servers[0].Connected {
+			t.Errorf("GetEd2kServers() got = %v", test.ToJson(list))
+		}
+		httpRequestCheckOk[any](http.MethodDelete, "/api/v1/ed2k/servers?addr="+url.QueryEscape(addr), nil)
+		code, _ = httpRequest[any](http.MethodDelete, "/api/v1/ed2k/servers?addr="+url.QueryEscape(addr), nil)
+		checkCode(code, model.CodeInvalidParam)
+
+		kad := httpRequestCheckOk[*ed2k.Kad](http.MethodGet, "/api/v1/ed2k/kad", nil)
+		if kad.Enabled {
+			t.Errorf("GetEd2kKad() got = %v, want disabled", test.ToJson(kad))
+		}
+		code, _ = httpRequest[any](http.MethodPut, "/api/v1/ed2k/kad/refresh", nil)
+		checkCode(code, model.CodeError)
+
+		code, _ = httpRequest[any](http.MethodGet, "/api/v1/tasks/not-exist/sources", nil)
+		checkCode(code, model.CodeTaskNotFound)
+
+		code, _ = httpRequest[any](http.MethodPost, "/api/v1/ed2k/searches", &ed2k.SearchOpts{})
+		checkCode(code, model.CodeInvalidParam)
+		// no server is connected and kad is disabled
+		search := httpRequestCheckOk[*ed2k.Search](http.MethodPost, "/api/v1/ed2k/searches", &ed2k.SearchOpts{Query: "test"})
+		if search.ID == "" || search.State != ed2k.SearchStateFailed {
+			t.Errorf("SearchEd2k() got = %v", test.ToJson(search))
+		}
+		searches := httpRequestCheckOk[[]*ed2k.Search](http.MethodGet, "/api/v1/ed2k/searches", nil)
+		if len(searches) != 1 || searches[0].ID != search.ID {
+			t.Errorf("GetEd2kSearches() got = %v", test.ToJson(searches))
+		}
+		got := httpRequestCheckOk[*ed2k.Search](http.MethodGet, "/api/v1/ed2k/searches/"+search.ID, nil)
+		if got.Error == "" {
+			t.Errorf("GetEd2kSearch() got = %v", test.ToJson(got))
+		}
+		httpRequestCheckOk[any](http.MethodDelete, "/api/v1/ed2k/searches/"+search.ID, nil)
+		code, _ = httpRequest[any](http.MethodGet, "/api/v1/ed2k/searches/"+search.ID, nil)
+		checkCode(code, model.CodeEd2kSearchNotFound)
+	})
+}
+
+func TestFeeds(t *testing.T) {
+	doTest(func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/rss" {
+				fmt.Fprintf(w, `<rss version="2.0"><channel><title>test</title>
+<item><title>Show S01E02</title><guid>2</guid><enclosure url="http://%s/files/2.bin" length="10"/></item>
+<item><title>Show S01E01</title><guid>1</guid><enclosure url="http://%s/files/1.bin" length="10"/></item>
+</channel></rss>`, r.Host, r.Host)
+				return
+			}
+			w.Write([]byte("content"))
+		}))
+		defer server.Close()
+
+		code, _ := httpRequest[any](http.MethodPost, "/api/v1/feeds", &base.Feed{URL: "not a url"})
+		checkCode(code, model.CodeInvalidParam)
+		feed := httpRequestCheckOk[*base.Feed](http.MethodPost, "/api/v1/feeds", &base.Feed{
+			Name:     "test",
+			URL:      server.URL + "/rss",
+			Disabled: true,
+		})
+		path := "/api/v1/feeds/" + feed.ID
+
+		rule := httpRequestCheckOk[*base.FeedRule](http.MethodPost, path+"/rules", &base.FeedRule{Include: "S01E01", Paused: true})
+		code, _ = httpRequest[any](http.MethodPut, path+"/rules/not-exist", &base.FeedRule{})
+		checkCode(code, model.CodeFeedNotFound)
+		httpRequestCheckOk[*base.FeedRule](http.MethodPut, path+"/rules/"+rule.ID, &base.FeedRule{Include: "S01E0[12]", Paused: true})
+		got := httpRequestCheckOk[*base.Feed](http.MethodGet, path, nil)
+		if len(got.Rules) != 1 || got.Rules[0].Include != "S01E0[12]" {
+			t.Errorf("UpdateFeedRule() got = %+v", got.Rules)
+		}
+
+		httpRequestCheckOk[any](http.MethodPost, path+"/refresh", nil)
+		items := httpRequestCheckOk[[]*base.FeedItem](http.MethodGet, path+"/items", nil)
+		if len(items) != 2 || items[0].TaskID == "" || items[1].TaskID == "" || items[0].Title != "Show S01E02" {
+			t.Errorf("GetFeedItems() got = %+v", items)
+		}
+		task := httpRequestCheckOk[*download.Task](http.MethodGet, "/api/v1/tasks/"+items[0].TaskID, nil)
+		if task.Status != base.DownloadStatusReady {
+			t.Errorf("RefreshFeed() task status got = %s", task.Status)
+		}
+
+		httpRequestCheckOk[any](http.MethodDelete, path+"/rules/"+rule.ID, nil)
+		httpRequestCheckOk[*base.Feed](http.MethodPut, path, &base.Feed{Name: "renamed", URL: server.URL + "/rss", Disabled: true})
+		feeds := httpRequestCheckOk[[]*base.Feed](http.MethodGet, "/api/v1/feeds", nil)
+		if len(feeds) != 1 || feeds[0].Name != "renamed" || len(feeds[0].Rules) != 0 || feeds[0].LastPoll.IsZero() {
+			t.Errorf("GetFeeds() got = %+v", feeds)
+		}
+		httpRequestCheckOk[any](http.MethodDelete, path, nil)
+		code, _ = httpRequest[any](http.MethodGet, path+"/items", nil)
+		checkCode(code, model.CodeFeedNotFound)
+	})
+}
+
 func TestGetAndPutConfig(t *testing.T) {
 	doTest(func() {
 		cfg := httpRequestCheckOk[*base.DownloaderStoreConfig](http.MethodGet, "/api/v1/config", nil)
@@ -485,6 +879,29 @@ func TestGetExtensions(t *testing.T) {
 	})
 }
 
+func TestPreviewExtension(t *testing.T) {
+	doTest(func() {
+		ext := httpRequestCheckOk[*download.Extension](http.MethodPost, "/api/v1/extensions/preview", &model.InstallExtension{
+			DevMode: true,
+			URL:     "../download/testdata/extensions/permissions",
+		})
+		if ext.Identity != "permissions" || ext.Permissions == nil || !ext.Permissions.Storage ||
+			!slices.Equal(ext.Permissions.Hosts, []string{"*://example.com/*"}) {
+			t.Errorf("PreviewExtension() got = %+v, permissions %+v", ext, ext.Permissions)
+		}
+		extensions := httpRequestCheckOk[[]*download.Extension](http.MethodGet, "/api/v1/extensions", nil)
+		if len(extensions) != 0 {
+			t.Errorf("PreviewExtension() should not install the extension, got %d extensions", len(extensions))
+		}
+
+		code, _ := httpRequest[any](http.MethodPost, "/api/v1/extensions/preview", &model.InstallExtension{
+			DevMode: true,
+			URL:     "../download/testdata/extensions/not-exist",
+		})
+		checkCode(code, model.CodeError)
+	})
+}
+
 func TestUpdateExtensionSettings(t *testing.T) {
 	doTest(func() {
 		identity := httpRequestCheckOk[string](http.MethodPost, "/api/v1/extensions", installExtensionReq)
@@ -520,6 +937,37 @@ func TestSwitchExtension(t *testing.T) {
 	})
 }
 
+func TestUpdateExtensionLimits(t *testing.T) {
+	doTest(func() {
+		extDir := filepath.Join(os.TempDir(), "gopeed-rest-test-extension-limits")
+		defer os.RemoveAll(extDir)
+		if err := os.MkdirAll(extDir, 0755); err != nil {
+			panic(err)
+		}
+		manifest := `{"name":"limits","title":"Limits","version":"0.0.1","scripts":[{"event":"onResolve","match":{"urls":["*://example.com/*"]},"entry":"index.js"}]}`
+		if err := os.WriteFile(filepath.Join(extDir, "manifest.json"), []byte(manifest), 0644); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(filepath.Join(extDir, "index.js"), []byte(`gopeed.events.onResolve((ctx) => { while (true) {} });`), 0644); err != nil {
+			panic(err)
+		}
+		identity := httpRequestCheckOk[string](http.MethodPost, "/api/v1/extensions", &model.InstallExtension{DevMode: true, URL: extDir})
+		httpRequestCheckOk[any](http.MethodPut, "/api/v1/extensions/"+identity+"/limits", &base.ExtensionLimits{HookTimeout: 1})
+		extension := httpRequestCheckOk[*download.Extension](http.MethodGet, "/api/v1/extensions/"+identity, nil)
+		if extension.Limits == nil || extension.Limits.HookTimeout != 1 {
+			t.Errorf("UpdateExtensionLimits() got = %v, want hook timeout %d", extension.Limits, 1)
+		}
+
+		code, result := httpRequest[any](http.MethodPost, "/api/v1/resolve", &model.ResolveTask{
+			Req: &base.Request{URL: "https://example.com/test"},
+		})
+		checkCode(code, model.CodeExtensionError)
+		if !strings.Contains(result.Msg, identity) {
+			t.Errorf("Resolve() error got = %s, want the extension %s", result.Msg, identity)
+		}
+	})
+}
+
 func TestDeleteExtension(t *testing.T) {
 	doTest(func() {
 		identity := httpRequestCheckOk[string](http.MethodPost, "/api/v1/extensions", installExtensionReq)
@@ -758,8 +1206,197 @@ func TestTestWebhook(t *testing.T) {
 	})
 }
 
+func TestOpenAPI(t *testing.T) {
+	doTest(func() {
+		status, body := doHttpRequest0(http.MethodGet, "/api/v1/openapi.json", nil, nil)
+		if status != http.StatusOK {
+			t.Fatalf("OpenAPI() got = %v, want %v", status, http.StatusOK)
+		}
+		var doc struct {
+			OpenAPI    string                               `json:"openapi"`
+			Paths      map[string]map[string]map[string]any `json:"paths"`
+			Components struct {
+				Schemas map[string]any `json:"schemas"`
+			} `json:"components"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.OpenAPI != openAPIVersion {
+			t.Errorf("OpenAPI() version got = %v, want %v", doc.OpenAPI, openAPIVersion)
+		}
+		// every registered route must be described in apiDocs
+		for path, ops := range doc.Paths {
+			for method, op := range ops {
+				if op["summary"] == nil {
+					t.Errorf("OpenAPI() route %s %s is not documented", strings.ToUpper(method), path)
+				}
+			}
+		}
+		if _, ok := doc.Paths["/api/v1/tasks/{id}"]["patch"]; !ok {
+			t.Errorf("OpenAPI() missing route %s", "PATCH /api/v1/tasks/{id}")
+		}
+		for _, name := range []string{"Result", "Task", "Request", "DownloaderStoreConfig", "Extension"} {
+			if _, ok := doc.Components.Schemas[name]; !ok {
+				t.Errorf("OpenAPI() missing schema %s", name)
+			}
+		}
+	})
+}
+
+// clientMethods maps every route to the method of the Go client, a new route must be added to the client.
+var clientMethods = map[string]string{
+	"GET /api/v1/openapi.json":                    "OpenAPI",
+	"GET /api/v1/info":                            "Info",
+	"POST /api/v1/resolve":                        "Resolve",
+	"POST /api/v1/tasks":                          "CreateTask",
+	"POST /api/v1/tasks/batch":                    "CreateTaskBatch",
+	"PATCH /api/v1/tasks/{id}":                    "PatchTask",
+	"PUT /api/v1/tasks/{id}/pause":                "PauseTask",
+	"PUT /api/v1/tasks/pause":                     "PauseTasks",
+	"PUT /api/v1/tasks/{id}/continue":             "ContinueTask",
+	"PUT /api/v1/tasks/continue":                  "ContinueTasks",
+	"DELETE /api/v1/tasks/{id}":                   "DeleteTask",
+	"DELETE /api/v1/tasks":                        "DeleteTasks",
+	"GET /api/v1/tasks/{id}":                      "GetTask",
+	"GET /api/v1/tasks":                           "GetTasks",
+	"GET /api/v1/tasks/{id}/stats":                "GetTaskStats",
+	"GET /api/v1/tasks/{id}/files/{index}":        "OpenTaskFile",
+	"GET /api/v1/tasks/{id}/files/{index}/stream": "StreamTaskFile",
+	"GET /api/v1/tasks/{id}/zip":                  "OpenTaskZip",
+	"GET /api/v1/tasks/{id}/peers":                "GetTaskPeers",
+	"POST /api/v1/tasks/{id}/peers":               "AddTaskPeer",
+	"POST /api/v1/tasks/{id}/peers/ban":           "BanTaskPeer",
+	"GET /api/v1/tasks/{id}/trackers":             "GetTaskTrackers",
+	"POST /api/v1/tasks/{id}/trackers":            "AddTaskTrackers",
+	"DELETE /api/v1/tasks/{id}/trackers":          "RemoveTaskTrackers",
+	"PUT /api/v1/tasks/{id}/trackers/reannounce":  "ReannounceTask",
+	"PUT /api/v1/tasks/{id}/seed/stop":            "StopSeeding",
+	"GET /api/v1/tasks/{id}/sources":              "GetTaskSources",
+	"PUT /api/v1/tasks/{id}/recheck":              "RecheckTask",
+	"POST /api/v1/torrents":                       "CreateTorrent",
+	"GET /api/v1/torrents/{id}":                   "ExportTorrent",
+	"GET /api/v1/ed2k/servers":                    "GetEd2kServers",
+	"POST /api/v1/ed2k/servers":                   "ConnectEd2kServer",
+	"DELETE /api/v1/ed2k/servers":                 "DisconnectEd2kServer",
+	"PUT /api/v1/ed2k/servers/refresh":            "RefreshEd2kServerMet",
+	"GET /api/v1/ed2k/kad":                        "GetEd2kKad",
+	"PUT /api/v1/ed2k/kad/refresh":                "RefreshEd2kNodesDat",
+	"POST /api/v1/ed2k/searches":                  "SearchEd2k",
+	"GET /api/v1/ed2k/searches":                   "GetEd2kSearches",
+	"GET /api/v1/ed2k/searches/{id}":              "GetEd2kSearch",
+	"DELETE /api/v1/ed2k/searches/{id}":           "DeleteEd2kSearch",
+	"POST /api/v1/feeds":                          "CreateFeed",
+	"GET /api/v1/feeds":                           "GetFeeds",
+	"GET /api/v1/feeds/{id}":                      "GetFeed",
+	"PUT /api/v1/feeds/{id}":                      "UpdateFeed",
+	"DELETE /api/v1/feeds/{id}":                   "DeleteFeed",
+	"POST /api/v1/feeds/{id}/rules":               "AddFeedRule",
+	"PUT /api/v1/feeds/{id}/rules/{ruleId}":       "UpdateFeedRule",
+	"DELETE /api/v1/feeds/{id}/rules/{ruleId}":    "DeleteFeedRule",
+	"POST /api/v1/feeds/{id}/refresh":             "RefreshFeed",
+	"GET /api/v1/feeds/{id}/items":                "GetFeedItems",
+	"GET /api/v1/config":                          "GetConfig",
+	"PUT /api/v1/config":                          "PutConfig",
+	"POST /api/v1/extensions":                     "InstallExtension",
+	"POST /api/v1/extensions/archive":             "InstallExtensionArchive",
+	"POST /api/v1/extensions/preview":             "PreviewExtension",
+	"POST /api/v1/extensions/archive/preview":     "PreviewExtensionArchive",
+	"GET /api/v1/extensions":                      "GetExtensions",
+	"GET /api/v1/extensions/index":                "GetExtensionIndex",
+	"GET /api/v1/extensions/{identity}":           "GetExtension",
+	"DELETE /api/v1/extensions/{identity}":        "DeleteExtension",
+	"PUT /api/v1/extensions/{identity}/settings":  "UpdateExtensionSettings",
+	"PUT /api/v1/extensions/{identity}/switch":    "SwitchExtension",
+	"PUT /api/v1/extensions/{identity}/limits":    "UpdateExtensionLimits",
+	"GET /api/v1/extensions/{identity}/update":    "UpdateCheckExtension",
+	"POST /api/v1/extensions/{identity}/update":   "UpdateExtension",
+	"POST /api/v1/webhook/test":                   "TestWebhook",
+	"ANY /api/v1/proxy":                           "Proxy",
+	"GET /api/v1/access/me":                       "Principal",
+	"POST /api/v1/access/tokens":                  "CreateAccessToken",
+	"GET /api/v1/access/tokens":                   "GetAccessTokens",
+	"DELETE /api/v1/access/tokens/{id}":           "RevokeAccessToken",
+	"PUT /api/v1/access/users":                    "PutAccessUser",
+	"GET /api/v1/access/users":                    "GetAccessUsers",
+	"DELETE /api/v1/access/users/{username}":      "DeleteAccessUser",
+	"GET /api/v1/access/sessions":                 "GetSessions",
+	"DELETE /api/v1/access/sessions/{id}":         "RevokeSession",
+	"GET /api/v1/audit":                           "GetAuditLog",
+	"POST /api/web/login":                         "Login",
+	"POST /api/web/logout":                        "Logout",
+}
+
+// clientUnwrappedResults are the client methods that return a field of the route data instead of the data.
+var clientUnwrappedResults = map[string]bool{
+	"UpdateCheckExtension": true,
+}
+
+func TestClientRoutes(t *testing.T) {
+	clientType := reflect.TypeFor[*client.Client]()
+	for route, ad := range apiRoutes {
+		name, ok := clientMethods[route]
+		if !ok {
+			t.Errorf("route %s has no client method", route)
+			continue
+		}
+		method, ok := clientType.MethodByName(name)
+		if !ok {
+			t.Errorf("route %s client method %s not found", route, name)
+			continue
+		}
+		// the client result must decode the same json as the route data, the client has its own dto types
+		// so it doesn't depend on the downloader
+		if ad.Data == nil || ad.Raw || method.Type.NumOut() != 2 || clientUnwrappedResults[name] {
+			continue
+		}
+		if got, want := jsonShape(method.Type.Out(0)), jsonShape(reflect.TypeOf(ad.Data)); !reflect.DeepEqual(got, want) {
+			t.Errorf("route %s client method %s result got = %v, want %v", route, name, got, want)
+		}
+	}
+	for route := range clientMethods {
+		if _, ok := apiRoutes[route]; !ok {
+			t.Errorf("client method %s is mapped to an unknown route %s", clientMethods[route], route)
+		}
+	}
+}
+
+// jsonShape describes the json encoding of a type, the types with the same shape decode the same json.
+func jsonShape(t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return "time"
+	case t.Kind() == reflect.Struct:
+		fields := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" && opts == "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			fields[name] = jsonShape(field.Type)
+		}
+		return fields
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8, t.Kind() == reflect.Array:
+		return []any{jsonShape(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"{key}": jsonShape(t.Elem())}
+	default:
+		return t.Kind().String()
+	}
+}
+
 func TestApiToken(t *testing.T) {
-	var cfg = &model.StartConfig{}
+	var cfg = &model.StartConfig{StorageDir: t.TempDir()}
 	cfg.Init()
 	cfg.ApiToken = "123456"
 	fileListener := doStart(cfg)
@@ -785,7 +1422,7 @@ func TestApiToken(t *testing.T) {
 }
 
 func TestAuthorization(t *testing.T) {
-	var cfg = &model.StartConfig{}
+	var cfg = &model.StartConfig{StorageDir: t.TempDir()}
 	cfg.Init()
 	cfg.ApiToken = "123456"
 	cfg.WebEnable = true
@@ -834,22 +1471,8 @@ func TestAuthorization(t *testing.T) {
 		t.Errorf("TestAuthorization() got = %v, want %v", status, http.StatusUnauthorized)
 	}
 
-	buildToken := func(username, password string, ts int64) string {
-		token, _ := aesEncrypt(aesKey, []byte(fmt.Sprintf("%s:%s:%d", username, password, ts)))
-		return token
-	}
-
-	fakeToken := buildToken("fake", "fake", time.Now().Unix())
-	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/config", map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", fakeToken),
-	}, nil)
-	if status != http.StatusUnauthorized {
-		t.Errorf("TestAuthorization() got = %v, want %v", status, http.StatusUnauthorized)
-	}
-
-	expireToken := buildToken(cfg.WebAuth.Username, cfg.WebAuth.Password, time.Now().Add(-time.Hour*8*24).Unix())
 	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/config", map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", expireToken),
+		"Authorization": "Bearer " + strings.Repeat("0", len(token)),
 	}, nil)
 	if status != http.StatusUnauthorized {
 		t.Errorf("TestAuthorization() got = %v, want %v", status, http.StatusUnauthorized)
@@ -884,6 +1507,599 @@ func TestAuthorization(t *testing.T) {
 	}
 }
 
+func TestAccessControl(t *testing.T) {
+	var cfg = &model.StartConfig{}
+	cfg.Init()
+	cfg.Storage = model.StorageMem
+	cfg.ApiToken = "123456"
+	cfg.WebEnable = true
+	cfg.WebAuth = &model.WebAuth{
+		Username: "admin",
+		Password: "123456",
+	}
+	fileListener := doStart(cfg)
+	defer func() {
+		if err := fileListener.Close(); err != nil {
+			panic(err)
+		}
+		Stop()
+	}()
+	adminHeaders := map[string]string{"X-Api-Token": cfg.ApiToken}
+
+	_, result := doHttpRequest[*model.CreateAccessTokenResp](http.MethodPost, "/api/v1/access/tokens", adminHeaders, &model.CreateAccessToken{
+		Name:         "reader",
+		Scopes:       []base.AccessScope{base.AccessScopeRead, base.AccessScopeCreate},
+		DownloadDirs: []string{"/allowed/*"},
+	})
+	checkOk(int(result.Code))
+	tokenHeaders := map[string]string{"X-Api-Token": result.Data.Token}
+
+	status, _ := doHttpRequest0(http.MethodGet, "/api/v1/tasks", tokenHeaders, nil)
+	if status != http.StatusOK {
+		t.Errorf("TestAccessControl() read got = %v, want %v", status, http.StatusOK)
+	}
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/config", tokenHeaders, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("TestAccessControl() config got = %v, want %v", status, http.StatusForbidden)
+	}
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/access/tokens", tokenHeaders, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("TestAccessControl() token list got = %v, want %v", status, http.StatusForbidden)
+	}
+	code, _ := doHttpRequest[string](http.MethodPost, "/api/v1/tasks", tokenHeaders, &model.CreateTask{
+		Req:  &base.Request{URL: "http://127.0.0.1/file"},
+		Opts: &base.Options{Path: "/forbidden/dir"},
+	})
+	checkCode(code, model.CodeForbidden)
+
+	_, me := doHttpRequest[*base.Principal](http.MethodGet, "/api/v1/access/me", tokenHeaders, nil)
+	if me.Data.Name != "reader" || me.Data.Kind != base.PrincipalKindToken {
+		t.Errorf("TestAccessControl() me got = %v, want reader token", me.Data)
+	}
+
+	// stored users can login from web
+	code, _ = doHttpRequest[any](http.MethodPut, "/api/v1/access/users", adminHeaders, &base.AccessUser{
+		Username: "alice",
+		Password: "secret",
+		Scopes:   []base.AccessScope{base.AccessScopeRead},
+	})
+	checkOk(code)
+	loginToken := httpRequestCheckOk[string](http.MethodPost, "/api/web/login", &model.WebAuth{
+		Username: "alice",
+		Password: "secret",
+	})
+	userHeaders := map[string]string{"Authorization": "Bearer " + loginToken}
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/tasks", userHeaders, nil)
+	if status != http.StatusOK {
+		t.Errorf("TestAccessControl() user read got = %v, want %v", status, http.StatusOK)
+	}
+	status, _ = doHttpRequest0(http.MethodPut, "/api/v1/tasks/pause", userHeaders, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("TestAccessControl() user manage got = %v, want %v", status, http.StatusForbidden)
+	}
+
+	_, tokens := doHttpRequest[[]*base.AccessToken](http.MethodGet, "/api/v1/access/tokens", adminHeaders, nil)
+	if len(tokens.Data) != 1 || tokens.Data[0].Hash != "" {
+		t.Fatalf("TestAccessControl() token list got = %v", tokens.Data)
+	}
+	code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/access/tokens/"+tokens.Data[0].ID, adminHeaders, nil)
+	checkOk(code)
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/tasks", tokenHeaders, nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("TestAccessControl() revoked got = %v, want %v", status, http.StatusUnauthorized)
+	}
+}
+
+func TestAccessWithoutLegacyAuth(t *testing.T) {
+	var cfg = &model.StartConfig{}
+	cfg.Init()
+	cfg.Storage = model.StorageMem
+	cfg.WebEnable = true
+	fileListener := doStart(cfg)
+	defer func() {
+		if err := fileListener.Close(); err != nil {
+			panic(err)
+		}
+		Stop()
+	}()
+	checkStatus := func(name string, headers map[string]string, want int) {
+		status, _ := doHttpRequest0(http.MethodGet, "/api/v1/tasks", headers, nil)
+		if status != want {
+			t.Errorf("TestAccessWithoutLegacyAuth() %s got = %v, want %v", name, status, want)
+		}
+	}
+
+	// the server is open until an access token or user is stored
+	checkStatus("open", nil, http.StatusOK)
+	_, result := doHttpRequest[*model.CreateAccessTokenResp](http.MethodPost, "/api/v1/access/tokens", nil, &model.CreateAccessToken{
+		Name:   "admin",
+		Scopes: []base.AccessScope{base.AccessScopeAdmin},
+	})
+	checkOk(int(result.Code))
+	tokenHeaders := map[string]string{"X-Api-Token": result.Data.Token}
+	checkStatus("no credentials", nil, http.StatusUnauthorized)
+	checkStatus("wrong token", map[string]string{"X-Api-Token": "wrong"}, http.StatusUnauthorized)
+	checkStatus("token", tokenHeaders, http.StatusOK)
+
+	// the stored users can login without the legacy web auth
+	code, _ := doHttpRequest[any](http.MethodPut, "/api/v1/access/users", tokenHeaders, &base.AccessUser{
+		Username: "alice",
+		Password: "secret",
+		Scopes:   []base.AccessScope{base.AccessScopeRead},
+	})
+	checkOk(code)
+	loginToken := httpRequestCheckOk[string](http.MethodPost, "/api/web/login", &model.Login{Username: "alice", Password: "secret"})
+	checkStatus("session", map[string]string{"Authorization": "Bearer " + loginToken}, http.StatusOK)
+	checkStatus("no session", nil, http.StatusUnauthorized)
+}
+
+func TestTaskDownloadDirs(t *testing.T) {
+	doTest0(func(cfg *model.StartConfig) {
+		cfg.ApiToken = "123456"
+	}, func() {
+		adminHeaders := map[string]string{"X-Api-Token": "123456"}
+		allowedDir := filepath.Join(createOpts.Path, "allowed")
+		_, result := doHttpRequest[*model.CreateAccessTokenResp](http.MethodPost, "/api/v1/access/tokens", adminHeaders, &model.CreateAccessToken{
+			Name:         "restricted",
+			Scopes:       []base.AccessScope{base.AccessScopeRead, base.AccessScopeManage},
+			DownloadDirs: []string{allowedDir},
+		})
+		checkOk(int(result.Code))
+		tokenHeaders := map[string]string{"X-Api-Token": result.Data.Token}
+
+		createTask := func(path string) string {
+			opts := *createOpts
+			opts.Path = path
+			_, result := doHttpRequest[string](http.MethodPost, "/api/v1/tasks", adminHeaders, &model.CreateTask{Req: createReq.Req, Opts: &opts})
+			checkOk(int(result.Code))
+			return result.Data
+		}
+		allowedId := createTask(allowedDir)
+		otherId := createTask(createOpts.Path)
+		taskExists := func(id string) bool {
+			code, _ := doHttpRequest[*download.Task](http.MethodGet, "/api/v1/tasks/"+id, adminHeaders, nil)
+			return code == int(model.CodeOk)
+		}
+
+		_, tasks := doHttpRequest[[]*download.Task](http.MethodGet, "/api/v1/tasks", tokenHeaders, nil)
+		if len(tasks.Data) != 1 || tasks.Data[0].ID != allowedId {
+			t.Errorf("TestTaskDownloadDirs() tasks got = %v, want only %s", tasks.Data, allowedId)
+		}
+		code, _ := doHttpRequest[*download.Task](http.MethodGet, "/api/v1/tasks/"+allowedId, tokenHeaders, nil)
+		checkOk(code)
+		code, _ = doHttpRequest[*download.Task](http.MethodGet, "/api/v1/tasks/"+otherId, tokenHeaders, nil)
+		checkCode(code, model.CodeTaskNotFound)
+		code, _ = doHttpRequest[any](http.MethodPatch, "/api/v1/tasks/"+otherId, tokenHeaders, &model.ResolveTask{Opts: &base.Options{Name: "renamed"}})
+		checkCode(code, model.CodeTaskNotFound)
+		code, _ = doHttpRequest[any](http.MethodPut, "/api/v1/tasks/"+otherId+"/pause", tokenHeaders, nil)
+		checkCode(code, model.CodeTaskNotFound)
+		code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/tasks/"+otherId+"?force=true", tokenHeaders, nil)
+		checkCode(code, model.CodeTaskNotFound)
+
+		// batch operations only affect the tasks in the allowed directories
+		code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/tasks?force=true", tokenHeaders, nil)
+		checkOk(code)
+		if taskExists(allowedId) || !taskExists(otherId) {
+			t.Errorf("TestTaskDownloadDirs() delete tasks got allowed = %v, other = %v", taskExists(allowedId), taskExists(otherId))
+		}
+	})
+}
+
+func TestWebSession(t *testing.T) {
+	hash, err := util.HashPassword("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg = &model.StartConfig{}
+	cfg.Storage = model.StorageMem
+	cfg.ApiToken = "123456"
+	cfg.WebEnable = true
+	cfg.WebAuth = &model.WebAuth{
+		Username:         "admin",
+		Password:         hash,
+		MaxLoginAttempts: 3,
+	}
+	cfg.Init()
+	fileListener := doStart(cfg)
+	defer func() {
+		if err := fileListener.Close(); err != nil {
+			panic(err)
+		}
+		Stop()
+	}()
+	adminHeaders := map[string]string{"X-Api-Token": cfg.ApiToken}
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+	checkStatus := func(name string, headers map[string]string, want int) {
+		status, _ := doHttpRequest0(http.MethodGet, "/api/v1/config", headers, nil)
+		if status != want {
+			t.Errorf("TestWebSession() %s got = %v, want %v", name, status, want)
+		}
+	}
+
+	// the hashed password is checked against the plain password
+	token1 := httpRequestCheckOk[string](http.MethodPost, "/api/web/login", &model.Login{Username: "admin", Password: "123456"})
+	token2 := httpRequestCheckOk[string](http.MethodPost, "/api/web/login", &model.Login{Username: "admin", Password: "123456"})
+	checkStatus("session 1", bearer(token1), http.StatusOK)
+
+	_, result := doHttpRequest[[]*model.Session](http.MethodGet, "/api/v1/access/sessions", bearer(token1), nil)
+	checkOk(int(result.Code))
+	if len(result.Data) != 2 || !result.Data[0].Current || result.Data[1].Current || result.Data[0].Name != "admin" {
+		t.Fatalf("TestWebSession() sessions got = %+v", result.Data)
+	}
+	session2 := result.Data[1]
+	if session2.IP != "127.0.0.1" || !session2.ExpiresAt.After(time.Now().Add(6*24*time.Hour)) {
+		t.Errorf("TestWebSession() session got = %+v", session2)
+	}
+
+	// logout revokes the current session only
+	code, _ := doHttpRequest[any](http.MethodPost, "/api/web/logout", bearer(token1), nil)
+	checkOk(code)
+	checkStatus("logout", bearer(token1), http.StatusUnauthorized)
+	checkStatus("other session", bearer(token2), http.StatusOK)
+
+	code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/access/sessions/"+session2.ID, adminHeaders, nil)
+	checkOk(code)
+	checkStatus("revoked", bearer(token2), http.StatusUnauthorized)
+	code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/access/sessions/"+session2.ID, adminHeaders, nil)
+	checkCode(code, model.CodeError)
+
+	// sessions of a user are revoked when the user is deleted
+	code, _ = doHttpRequest[any](http.MethodPut, "/api/v1/access/users", adminHeaders, &base.AccessUser{
+		Username: "alice",
+		Password: "secret",
+		Scopes:   []base.AccessScope{base.AccessScopeRead},
+	})
+	checkOk(code)
+	aliceToken := httpRequestCheckOk[string](http.MethodPost, "/api/web/login", &model.Login{Username: "alice", Password: "secret"})
+	status, _ := doHttpRequest0(http.MethodGet, "/api/v1/tasks", bearer(aliceToken), nil)
+	if status != http.StatusOK {
+		t.Errorf("TestWebSession() alice got = %v, want %v", status, http.StatusOK)
+	}
+	code, _ = doHttpRequest[any](http.MethodDelete, "/api/v1/access/users/alice", adminHeaders, nil)
+	checkOk(code)
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/tasks", bearer(aliceToken), nil)
+	if status != http.StatusUnauthorized {
+		t.Errorf("TestWebSession() deleted user got = %v, want %v", status, http.StatusUnauthorized)
+	}
+
+	// the login is locked after too many failures, even with the right password
+	for i := 0; i < cfg.WebAuth.MaxLoginAttempts; i++ {
+		status, _ = doHttpRequest0(http.MethodPost, "/api/web/login", nil, &model.Login{Username: "admin", Password: "wrong"})
+		if status != http.StatusUnauthorized {
+			t.Errorf("TestWebSession() failed login got = %v, want %v", status, http.StatusUnauthorized)
+		}
+	}
+	status, headers, _ := doHttpRequest1(http.MethodPost, "/api/web/login", nil, &model.Login{Username: "admin", Password: "123456"})
+	if status != http.StatusTooManyRequests || headers["Retry-After"] == "" {
+		t.Errorf("TestWebSession() locked login got = %v, retry after = %s", status, headers["Retry-After"])
+	}
+}
+
+func TestSessionManager(t *testing.T) {
+	m := newSessionManager(50 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, "/api/web/login", nil)
+	token, err := m.create(base.AdminPrincipal("admin"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.get(token); s == nil || s.principal.Name != "admin" {
+		t.Fatalf("get() got = %v, want admin session", s)
+	}
+	if m.get("wrong") != nil || m.get("") != nil {
+		t.Errorf("get() unknown token should not return a session")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if m.get(token) != nil {
+		t.Errorf("get() expired session should not be returned")
+	}
+	if len(m.list("")) != 0 {
+		t.Errorf("list() expired session should be removed")
+	}
+}
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter(2, 50*time.Millisecond)
+	l.fail("ip:1", "user:a")
+	if l.retryAfter("ip:1") != 0 {
+		t.Errorf("retryAfter() should not be locked before max failures")
+	}
+	l.fail("ip:2", "user:a")
+	if l.retryAfter("ip:1") != 0 || l.retryAfter("ip:3", "user:a") <= 0 {
+		t.Errorf("retryAfter() user should be locked by failures from different addresses")
+	}
+	l.reset("user:a")
+	if l.retryAfter("user:a") != 0 {
+		t.Errorf("retryAfter() should not be locked after reset")
+	}
+	l.fail("ip:1")
+	if l.retryAfter("ip:1") <= 0 {
+		t.Errorf("retryAfter() ip should be locked")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if l.retryAfter("ip:1") != 0 {
+		t.Errorf("retryAfter() should not be locked after the window")
+	}
+
+	unlimited := newLoginLimiter(-1, time.Minute)
+	for i := 0; i < 10; i++ {
+		unlimited.fail("ip:1")
+	}
+	if unlimited.retryAfter("ip:1") != 0 {
+		t.Errorf("retryAfter() negative max should disable the limit")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	var cfg = &model.StartConfig{}
+	cfg.Init()
+	cfg.Storage = model.StorageMem
+	cfg.ApiToken = "123456"
+	fileListener := doStart(cfg)
+	defer func() {
+		if err := fileListener.Close(); err != nil {
+			panic(err)
+		}
+		Stop()
+	}()
+	adminHeaders := map[string]string{"X-Api-Token": cfg.ApiToken}
+
+	_, token := doHttpRequest[*model.CreateAccessTokenResp](http.MethodPost, "/api/v1/access/tokens", adminHeaders, &model.CreateAccessToken{
+		Name:   "reader",
+		Scopes: []base.AccessScope{base.AccessScopeRead},
+	})
+	checkOk(int(token.Code))
+	readerHeaders := map[string]string{"X-Api-Token": token.Data.Token}
+
+	code, _ := doHttpRequest[any](http.MethodPut, "/api/v1/access/users", adminHeaders, &base.AccessUser{
+		Username: "alice",
+		Password: "secret",
+		Scopes:   []base.AccessScope{base.AccessScopeRead},
+	})
+	checkOk(code)
+	// forbidden calls are recorded too
+	status, _ := doHttpRequest0(http.MethodDelete, "/api/v1/tasks/not-exist", readerHeaders, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("TestAuditLog() delete got = %v, want %v", status, http.StatusForbidden)
+	}
+	// read-only calls are not recorded
+	doHttpRequest0(http.MethodGet, "/api/v1/tasks", readerHeaders, nil)
+	doHttpRequest0(http.MethodPost, "/api/v1/resolve", readerHeaders, &model.ResolveTask{Req: &base.Request{URL: "http://127.0.0.1/file"}})
+
+	status, _ = doHttpRequest0(http.MethodGet, "/api/v1/audit", readerHeaders, nil)
+	if status != http.StatusForbidden {
+		t.Errorf("TestAuditLog() reader get audit got = %v, want %v", status, http.StatusForbidden)
+	}
+	_, result := doHttpRequest[*base.AuditPage](http.MethodGet, "/api/v1/audit?page=1&pageSize=10", adminHeaders, nil)
+	checkOk(int(result.Code))
+	page := result.Data
+	if page.Total != 3 {
+		t.Fatalf("TestAuditLog() got total = %d, want 3, entries = %v", page.Total, page.Entries)
+	}
+
+	deleteEntry := page.Entries[0]
+	if deleteEntry.Route != "/api/v1/tasks/{id}" || deleteEntry.Method != http.MethodDelete || deleteEntry.Principal != "reader" ||
+		deleteEntry.PrincipalKind != base.PrincipalKindToken || deleteEntry.Status != http.StatusForbidden ||
+		deleteEntry.Code != int(model.CodeForbidden) || deleteEntry.IP != "127.0.0.1" {
+		t.Errorf("TestAuditLog() delete entry got = %+v", deleteEntry)
+	}
+	if vars, _ := deleteEntry.Params["vars"].(map[string]any); vars["id"] != "not-exist" {
+		t.Errorf("TestAuditLog() delete entry params got = %v", deleteEntry.Params)
+	}
+
+	userEntry := page.Entries[1]
+	body, _ := userEntry.Params["body"].(map[string]any)
+	if userEntry.Route != "/api/v1/access/users" || userEntry.PrincipalKind != base.PrincipalKindAdmin || body["username"] != "alice" {
+		t.Errorf("TestAuditLog() user entry got = %+v", userEntry)
+	}
+	if body["password"] == "secret" {
+		t.Errorf("TestAuditLog() password should be redacted")
+	}
+
+	code, _ = doHttpRequest[any](http.MethodGet, "/api/v1/audit?page=x", adminHeaders, nil)
+	checkCode(code, model.CodeInvalidParam)
+}
+
+func TestTLS(t *testing.T) {
+	storageDir := t.TempDir()
+	cfg := &model.StartConfig{
+		Storage:    model.StorageMem,
+		StorageDir: storageDir,
+		TLS:        &model.TLSConfig{AutoCert: true},
+	}
+	addr := startTLSServer(t, cfg)
+	certFile := filepath.Join(storageDir, autoCertDir, "cert.pem")
+	keyFile := filepath.Join(storageDir, autoCertDir, "key.pem")
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	serial := func() string {
+		resp, err := client.Get("https://" + addr + "/api/v1/info")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("TestTLS() got = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		client.CloseIdleConnections()
+		return resp.TLS.PeerCertificates[0].SerialNumber.String()
+	}
+	first := serial()
+
+	if resp, err := http.Get("http://" + addr + "/api/v1/info"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("TestTLS() plain http got = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	// replace the cert files, the server should pick up the new certificate without restart
+	newDir := t.TempDir()
+	newCertFile, newKeyFile := filepath.Join(newDir, "cert.pem"), filepath.Join(newDir, "key.pem")
+	if err := ensureSelfSignedCert(newCertFile, newKeyFile, "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	for src, dst := range map[string]string{newCertFile: certFile, newKeyFile: keyFile} {
+		buf, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dst, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(certCheckInterval)
+	if second := serial(); second == first {
+		t.Errorf("TestTLS() certificate was not reloaded")
+	}
+}
+
+func TestSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ensureSelfSignedCert(certFile, keyFile, "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	certPem, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPem)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.IsCA || !cert.BasicConstraintsValid {
+		t.Errorf("TestSelfSignedCert() got a ca certificate")
+	}
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment {
+		t.Errorf("TestSelfSignedCert() key usage got = %v", cert.KeyUsage)
+	}
+	if !slices.Equal(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+		t.Errorf("TestSelfSignedCert() ext key usage got = %v", cert.ExtKeyUsage)
+	}
+
+	// a cert file without a key file or a client ca without a certificate must not silently fall back to plain http
+	for _, tlsCfg := range []*model.TLSConfig{{CertFile: certFile}, {KeyFile: keyFile}, {ClientCAFile: certFile}} {
+		if server, _, err := BuildServer(&model.StartConfig{Storage: model.StorageMem, StorageDir: dir, TLS: tlsCfg}); err == nil {
+			server.Close()
+			t.Errorf("TestSelfSignedCert() start with %+v expect error", tlsCfg)
+		}
+		if Downloader != nil {
+			Downloader.Clear()
+			Downloader = nil
+		}
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &model.StartConfig{
+		Storage:    model.StorageMem,
+		StorageDir: dir,
+		TLS:        &model.TLSConfig{AutoCert: true, ClientCAFile: caFile},
+	}
+	addr := startTLSServer(t, cfg)
+
+	get := func(certs []tls.Certificate) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       certs,
+		}}}
+		resp, err := client.Get("https://" + addr + "/api/v1/info")
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	if err := get(nil); err == nil {
+		t.Errorf("TestTLSClientCA() request without client certificate should fail")
+	}
+	if err := get([]tls.Certificate{newTestClientCert(t, caCert, caKey)}); err != nil {
+		t.Errorf("TestTLSClientCA() request with client certificate failed: %v", err)
+	}
+	otherCACert, otherCAKey := newTestCA(t)
+	if err := get([]tls.Certificate{newTestClientCert(t, otherCACert, otherCAKey)}); err == nil {
+		t.Errorf("TestTLSClientCA() request with untrusted client certificate should fail")
+	}
+}
+
+func startTLSServer(t *testing.T, cfg *model.StartConfig) string {
+	server, listener, err := BuildServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+		if Downloader != nil {
+			Downloader.Clear()
+			Downloader = nil
+		}
+	})
+	return listener.Addr().String()
+}
+
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func newTestClientCert(t *testing.T, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
 func TestBuildServerPropagatesWebViewProvider(t *testing.T) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
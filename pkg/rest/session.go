@@ -0,0 +1,295 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/base"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// maxLoginFailures bounds the memory used by the login limiter when it's hammered from many addresses
+const maxLoginFailures = 100000
+
+type sessionKey struct{}
+
+type session struct {
+	info      *model.Session
+	principal *base.Principal
+}
+
+// sessionManager keeps the web login sessions in memory, so all sessions are invalidated when the server restarts.
+type sessionManager struct {
+	lock     *sync.Mutex
+	lifetime time.Duration
+	// sessions is keyed by the sha256 hash of the session token
+	sessions map[string]*session
+}
+
+func newSessionManager(lifetime time.Duration) *sessionManager {
+	return &sessionManager{
+		lock:     &sync.Mutex{},
+		lifetime: lifetime,
+		sessions: make(map[string]*session),
+	}
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// create creates a session for the principal and returns the session token.
+func (m *sessionManager) create(principal *base.Principal, r *http.Request) (string, error) {
+	id, err := gonanoid.New()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	now := time.Now()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.removeExpired(now)
+	m.sessions[hashSessionToken(token)] = &session{
+		info: &model.Session{
+			ID:         id,
+			Name:       principal.Name,
+			Kind:       principal.Kind,
+			IP:         remoteIP(r),
+			UserAgent:  r.UserAgent(),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(m.lifetime),
+		},
+		principal: principal,
+	}
+	return token, nil
+}
+
+// get returns the session of the token, or nil if the session doesn't exist or is expired.
+func (m *sessionManager) get(token string) *session {
+	if token == "" {
+		return nil
+	}
+	hash := hashSessionToken(token)
+	now := time.Now()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	s, ok := m.sessions[hash]
+	if !ok {
+		return nil
+	}
+	if now.After(s.info.ExpiresAt) {
+		delete(m.sessions, hash)
+		return nil
+	}
+	s.info.LastSeenAt = now
+	return s
+}
+
+func (m *sessionManager) list(currentID string) []*model.Session {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.removeExpired(time.Now())
+
+	list := make([]*model.Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		info := *s.info
+		info.Current = info.ID == currentID
+		list = append(list, &info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+func (m *sessionManager) revoke(id string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for hash, s := range m.sessions {
+		if s.info.ID == id {
+			delete(m.sessions, hash)
+			return true
+		}
+	}
+	return false
+}
+
+// revokePrincipal revokes all sessions of a principal, e.g. when a user is deleted or its password is changed.
+func (m *sessionManager) revokePrincipal(kind base.PrincipalKind, name string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for hash, s := range m.sessions {
+		if s.info.Kind == kind && s.info.Name == name {
+			delete(m.sessions, hash)
+		}
+	}
+}
+
+func (m *sessionManager) removeExpired(now time.Time) {
+	for hash, s := range m.sessions {
+		if now.After(s.info.ExpiresAt) {
+			delete(m.sessions, hash)
+		}
+	}
+}
+
+func withSession(r *http.Request, s *session) *http.Request {
+	r = withPrincipal(r, s.principal)
+	return r.WithContext(context.WithValue(r.Context(), sessionKey{}, s.info.ID))
+}
+
+// sessionIDOf returns the session id of the request, empty if the request is not authenticated by a session.
+func sessionIDOf(r *http.Request) string {
+	id, _ := r.Context().Value(sessionKey{}).(string)
+	return id
+}
+
+// loginLimiter counts failed logins per key, a key is locked when the failures reach max within the window.
+type loginLimiter struct {
+	lock     *sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[string]*loginFailure
+}
+
+type loginFailure struct {
+	count int
+	since time.Time
+}
+
+func newLoginLimiter(max int, window time.Duration) *loginLimiter {
+	return &loginLimiter{
+		lock:     &sync.Mutex{},
+		max:      max,
+		window:   window,
+		failures: make(map[string]*loginFailure),
+	}
+}
+
+// retryAfter returns how long the login is locked for any of the keys, zero means not locked.
+func (l *loginLimiter) retryAfter(keys ...string) time.Duration {
+	if l.max < 0 {
+		return 0
+	}
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || f.count < l.max {
+			continue
+		}
+		if d := f.since.Add(l.window).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+func (l *loginLimiter) fail(keys ...string) {
+	if l.max < 0 {
+		return
+	}
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.failures) >= maxLoginFailures {
+		for key, f := range l.failures {
+			if now.Sub(f.since) > l.window {
+				delete(l.failures, key)
+			}
+		}
+	}
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || now.Sub(f.since) > l.window {
+			f = &loginFailure{since: now}
+			l.failures[key] = f
+		}
+		f.count++
+	}
+}
+
+func (l *loginLimiter) reset(keys ...string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// login authenticates the credentials with the login limiter and creates a session.
+func login(w http.ResponseWriter, r *http.Request, authenticate func(username string, password string) *base.Principal) {
+	var req model.Login
+	if !ReadJson(r, w, &req) {
+		return
+	}
+	keys := []string{"ip:" + remoteIP(r), "user:" + req.Username}
+	if wait := loginLimit.retryAfter(keys...); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		WriteStatusJson(w, http.StatusTooManyRequests, model.NewErrorResult("too many failed login attempts", model.CodeTooManyRequests))
+		return
+	}
+
+	principal := authenticate(req.Username, req.Password)
+	if principal == nil {
+		loginLimit.fail(keys...)
+		Downloader.Logger.Warn().Msgf("web login failed: user %s from %s", req.Username, remoteIP(r))
+		WriteStatusJson(w, http.StatusUnauthorized, model.NewErrorResult("unauthorized", model.CodeUnauthorized))
+		return
+	}
+	loginLimit.reset(keys...)
+
+	token, err := sessions.create(principal, r)
+	if err != nil {
+		WriteJson(w, model.NewErrorResult(err.Error()))
+		return
+	}
+	WriteJson(w, model.NewOkResult(token))
+}
+
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if id := sessionIDOf(r); id != "" {
+		sessions.revoke(id)
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	WriteJson(w, model.NewOkResult(sessions.list(sessionIDOf(r))))
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !sessions.revoke(vars["id"]) {
+		WriteJson(w, model.NewErrorResult("session not found"))
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
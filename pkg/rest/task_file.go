@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/download"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+// GetTaskFile serves a file of a done task, range requests are supported so media can be played directly.
+func GetTaskFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		WriteJson(w, model.NewErrorResult("param invalid: index", model.CodeInvalidParam))
+		return
+	}
+	file, err := Downloader.GetTaskFile(vars["id"], index, principalOf(r).DownloadDirs)
+	if err != nil {
+		writeTaskFileError(w, err)
+		return
+	}
+
+	f, err := os.Open(file.LocalPath)
+	if err != nil {
+		writeTaskFileError(w, err)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		writeTaskFileError(w, download.ErrTaskFileNotFound)
+		return
+	}
+
+	serveTaskFile(w, r, file, stat.ModTime(), f)
+}
+
+// StreamTaskFile serves a file of a running task while it's downloading, reads block until the requested
+// range arrives and the range is downloaded first. Done tasks are served like GetTaskFile.
+func StreamTaskFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil {
+		WriteJson(w, model.NewErrorResult("param invalid: index", model.CodeInvalidParam))
+		return
+	}
+	file, reader, err := Downloader.OpenTaskFile(r.Context(), vars["id"], index, principalOf(r).DownloadDirs)
+	if err != nil {
+		writeTaskFileError(w, err)
+		return
+	}
+	defer reader.Close()
+	serveTaskFile(w, r, file, time.Time{}, reader)
+}
+
+func serveTaskFile(w http.ResponseWriter, r *http.Request, file *download.TaskFile, modTime time.Time, content io.ReadSeeker) {
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") == "true" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": file.Name}))
+	http.ServeContent(w, r, file.Name, modTime, content)
+}
+
+// GetTaskZip streams the selected files of a done task as a zip archive, the files are stored without
+// compression since most downloads are already compressed.
+func GetTaskZip(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	files, err := Downloader.GetTaskFiles(vars["id"], principalOf(r).DownloadDirs)
+	if err == nil && len(files) == 0 {
+		err = download.ErrTaskFileNotFound
+	}
+	if err != nil {
+		writeTaskFileError(w, err)
+		return
+	}
+	task := Downloader.GetTask(vars["id"])
+	if task == nil {
+		writeTaskFileError(w, download.ErrTaskNotFound)
+		return
+	}
+	// stat all files first, so a missing file is reported as an error instead of a broken archive,
+	// the files are opened one at a time while writing to keep the number of open descriptors bounded
+	stats := make([]os.FileInfo, 0, len(files))
+	for _, file := range files {
+		stat, err := os.Stat(file.LocalPath)
+		if err != nil {
+			writeTaskFileError(w, err)
+			return
+		}
+		stats = append(stats, stat)
+	}
+
+	name := task.Meta.Res.Name
+	if task.Meta.Opts.Name != "" {
+		name = task.Meta.Opts.Name
+	}
+	if name == "" {
+		name = files[0].Name
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".zip"}))
+
+	zw := zip.NewWriter(w)
+	for i, file := range files {
+		header := &zip.FileHeader{
+			Name:     path.Clean(file.RelPath),
+			Method:   zip.Store,
+			Modified: stats[i].ModTime(),
+		}
+		if err := writeZipEntry(zw, header, file.LocalPath); err != nil {
+			// the response has been started, the client sees a truncated archive
+			Downloader.Logger.Warn().Err(err).Msgf("zip task files failed: %s", file.LocalPath)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		Downloader.Logger.Warn().Err(err).Msgf("zip task files failed: %s", vars["id"])
+	}
+}
+
+func writeZipEntry(zw *zip.Writer, header *zip.FileHeader, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, f)
+	return err
+}
+
+func writeTaskFileError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, download.ErrTaskNotFound):
+		WriteStatusJson(w, http.StatusNotFound, model.NewErrorResult(err.Error(), model.CodeTaskNotFound))
+	case errors.Is(err, download.ErrTaskNotDone):
+		WriteStatusJson(w, http.StatusConflict, model.NewErrorResult(err.Error(), model.CodeTaskNotDone))
+	case errors.Is(err, download.ErrDownloadDirForbidden):
+		WriteStatusJson(w, http.StatusForbidden, model.NewErrorResult(err.Error(), model.CodeForbidden))
+	case errors.Is(err, download.ErrStreamNotSupported):
+		WriteStatusJson(w, http.StatusNotImplemented, model.NewErrorResult(err.Error()))
+	case errors.Is(err, download.ErrTaskFileNotFound), errors.Is(err, os.ErrNotExist):
+		WriteStatusJson(w, http.StatusNotFound, model.NewErrorResult(download.ErrTaskFileNotFound.Error(), model.CodeTaskFileNotFound))
+	default:
+		WriteStatusJson(w, http.StatusInternalServerError, model.NewErrorResult(err.Error()))
+	}
+}
@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// autoCertDir is the directory under the storage directory where the self-signed certificate is kept
+	autoCertDir = "tls"
+	// autoCertValidity is long because the certificate is self-signed and trusted manually
+	autoCertValidity = 10 * 365 * 24 * time.Hour
+	// certCheckInterval throttles how often the cert files are checked for changes
+	certCheckInterval = 2 * time.Second
+)
+
+func buildTLSConfig(startCfg *model.StartConfig) (*tls.Config, error) {
+	certFile, keyFile := startCfg.TLS.CertFile, startCfg.TLS.KeyFile
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("tls cert file and key file must be provided together")
+	}
+	if certFile == "" && !startCfg.TLS.AutoCert {
+		return nil, errors.New("tls client ca requires a cert file and key file or auto cert")
+	}
+	if certFile == "" {
+		dir := filepath.Join(startCfg.StorageDir, autoCertDir)
+		certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		if err := ensureSelfSignedCert(certFile, keyFile, startCfg.Address); err != nil {
+			return nil, errors.Wrap(err, "generate self-signed certificate failed")
+		}
+	}
+
+	reloader := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		lock:     &sync.Mutex{},
+	}
+	if err := reloader.load(); err != nil {
+		return nil, errors.Wrap(err, "load tls certificate failed")
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if startCfg.TLS.ClientCAFile != "" {
+		caPem, err := os.ReadFile(startCfg.TLS.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read tls client ca failed")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, errors.New("no certificate found in tls client ca file")
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// certReloader serves the certificate from the cert files and reloads it when they change,
+// so that renewed certificates are picked up without restarting the server.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	lock      *sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if time.Since(c.checkedAt) >= certCheckInterval {
+		c.checkedAt = time.Now()
+		if modTime, err := c.latestModTime(); err == nil && !modTime.Equal(c.modTime) {
+			if err := c.loadLocked(); err != nil && Downloader != nil {
+				// keep serving the previous certificate, the files may be in the middle of being replaced
+				Downloader.Logger.Warn().Err(err).Msg("reload tls certificate failed")
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+func (c *certReloader) load() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checkedAt = time.Now()
+	return c.loadLocked()
+}
+
+func (c *certReloader) loadLocked() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// ensureSelfSignedCert generates a self-signed certificate if the cert files don't exist yet,
+// the certificate covers localhost, the listening host and the local interface addresses.
+func ensureSelfSignedCert(certFile string, keyFile string, address string) error {
+	if _, err := os.Stat(certFile); err == nil {
+		if _, err := os.Stat(keyFile); err == nil {
+			return nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Gopeed"}, CommonName: "Gopeed self-signed"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(autoCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		// a leaf certificate, it's trusted directly and must not be able to sign other certificates
+		IsCA: false,
+	}
+	tpl.DNSNames, tpl.IPAddresses = selfSignedHosts(address)
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func selfSignedHosts(address string) (dnsNames []string, ips []net.IP) {
+	dnsNames = []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		dnsNames = append(dnsNames, hostname)
+	}
+	ips = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+
+	if host, _, err := net.SplitHostPort(address); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil {
+			dnsNames = append(dnsNames, host)
+		} else if !ip.IsUnspecified() && !ip.IsLoopback() {
+			ips = append(ips, ip)
+		}
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+	}
+	return
+}
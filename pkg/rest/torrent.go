@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	btf "github.com/GopeedLab/gopeed/internal/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/download"
+	"github.com/GopeedLab/gopeed/pkg/protocol/bt"
+	"github.com/GopeedLab/gopeed/pkg/rest/model"
+	"github.com/gorilla/mux"
+)
+
+// CreateTorrent creates a torrent from a local file or folder on the server, and optionally seeds it.
+func CreateTorrent(w http.ResponseWriter, r *http.Request) {
+	var req bt.CreateTorrentOpts
+	if ReadJson(r, w, &req) {
+		if req.Path == "" {
+			WriteJson(w, model.NewErrorResult("param invalid: path", model.CodeInvalidParam))
+			return
+		}
+		result, err := Downloader.CreateTorrent(r.Context(), &req, principalOf(r).DownloadDirs)
+		if err != nil {
+			if errors.Is(err, download.ErrDownloadDirForbidden) {
+				WriteJson(w, model.NewErrorResult(err.Error(), model.CodeForbidden))
+				return
+			}
+			WriteJson(w, model.NewErrorResult(err.Error()))
+			return
+		}
+		WriteJson(w, model.NewOkResult(result))
+	}
+}
+
+// ExportTorrent exports the metadata of a bt task or a resolved bt request as a .torrent file.
+func ExportTorrent(w http.ResponseWriter, r *http.Request) {
+	metadata, err := Downloader.ExportTorrent(mux.Vars(r)["id"])
+	if err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(metadata))
+}
+
+// GetTaskPeers returns the connected peers of a running bt task.
+func GetTaskPeers(w http.ResponseWriter, r *http.Request) {
+	peers, err := Downloader.GetTaskPeers(mux.Vars(r)["id"])
+	if err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(peers))
+}
+
+// AddTaskPeer connects a running bt task to a peer manually.
+func AddTaskPeer(w http.ResponseWriter, r *http.Request) {
+	var req model.TaskPeer
+	if ReadJson(r, w, &req) {
+		if err := Downloader.AddTaskPeer(mux.Vars(r)["id"], req.Addr); err != nil {
+			writeSwarmError(w, err)
+			return
+		}
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
+// BanTaskPeer bans a peer for all bt tasks and closes its connections.
+func BanTaskPeer(w http.ResponseWriter, r *http.Request) {
+	var req model.TaskPeer
+	if ReadJson(r, w, &req) {
+		if err := Downloader.BanTaskPeer(mux.Vars(r)["id"], req.Addr); err != nil {
+			writeSwarmError(w, err)
+			return
+		}
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
+// GetTaskTrackers returns the trackers of a running bt task with the result of the last announce.
+func GetTaskTrackers(w http.ResponseWriter, r *http.Request) {
+	trackers, err := Downloader.GetTaskTrackers(mux.Vars(r)["id"])
+	if err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewOkResult(trackers))
+}
+
+// AddTaskTrackers adds trackers to a running bt task and announces to them.
+func AddTaskTrackers(w http.ResponseWriter, r *http.Request) {
+	var req model.TaskTrackers
+	if ReadJson(r, w, &req) {
+		if len(req.URLs) == 0 {
+			WriteJson(w, model.NewErrorResult("param invalid: urls", model.CodeInvalidParam))
+			return
+		}
+		if err := Downloader.AddTaskTrackers(mux.Vars(r)["id"], req.URLs); err != nil {
+			writeSwarmError(w, err)
+			return
+		}
+		WriteJson(w, model.NewNilResult())
+	}
+}
+
+// RemoveTaskTrackers removes the trackers in the url query from a running bt task.
+func RemoveTaskTrackers(w http.ResponseWriter, r *http.Request) {
+	urls := r.URL.Query()["url"]
+	if len(urls) == 0 {
+		WriteJson(w, model.NewErrorResult("param invalid: url", model.CodeInvalidParam))
+		return
+	}
+	if err := Downloader.RemoveTaskTrackers(mux.Vars(r)["id"], urls); err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// ReannounceTask forces a running bt task to announce to all its trackers.
+func ReannounceTask(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.ReannounceTask(mux.Vars(r)["id"]); err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+// StopSeeding stops uploading a done bt task without deleting it.
+func StopSeeding(w http.ResponseWriter, r *http.Request) {
+	if err := Downloader.StopSeeding(mux.Vars(r)["id"]); err != nil {
+		writeSwarmError(w, err)
+		return
+	}
+	WriteJson(w, model.NewNilResult())
+}
+
+func writeSwarmError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, download.ErrTaskNotFound):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeTaskNotFound))
+	case errors.Is(err, btf.ErrInvalidPeerAddr), errors.Is(err, btf.ErrInvalidTrackerUrl):
+		WriteJson(w, model.NewErrorResult(err.Error(), model.CodeInvalidParam))
+	default:
+		WriteJson(w, model.NewErrorResult(err.Error()))
+	}
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword returns the bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsPasswordHash checks if the string is a bcrypt hash, e.g. generated by `htpasswd -bnBC 10 "" password`.
+func IsPasswordHash(s string) bool {
+	if !strings.HasPrefix(s, "$2a$") && !strings.HasPrefix(s, "$2b$") && !strings.HasPrefix(s, "$2y$") {
+		return false
+	}
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
+
+// CheckPassword compares the password with a bcrypt hash, or with a plain text password in constant time.
+func CheckPassword(hashOrPlain string, password string) bool {
+	if IsPasswordHash(hashOrPlain) {
+		return bcrypt.CompareHashAndPassword([]byte(hashOrPlain), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(hashOrPlain), []byte(password)) == 1
+}
@@ -0,0 +1,43 @@
+package util
+
+import "testing"
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsPasswordHash(hash) {
+		t.Errorf("IsPasswordHash() got = false, want true for %s", hash)
+	}
+	if !CheckPassword(hash, "secret") {
+		t.Errorf("CheckPassword() got = false, want true")
+	}
+	if CheckPassword(hash, "wrong") {
+		t.Errorf("CheckPassword() got = true, want false")
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		hashOrPlain string
+		password    string
+		want        bool
+	}{
+		{"plain", "secret", "secret", true},
+		{"plain wrong", "secret", "secret2", false},
+		{"empty", "", "", true},
+		// a $2y$ hash like htpasswd -bnBC 10 "" secret generates
+		{"htpasswd", "$2y$10$HgpQ8Ab0pTkbDR2uiUJIQeW3smKJT.qkksTPePcLThDm/PK38aa9O", "secret", true},
+		{"htpasswd wrong", "$2y$10$HgpQ8Ab0pTkbDR2uiUJIQeW3smKJT.qkksTPePcLThDm/PK38aa9O", "secret2", false},
+		{"not a hash", "$2y$invalid", "$2y$invalid", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.hashOrPlain, tt.password); got != tt.want {
+				t.Errorf("CheckPassword() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}